@@ -0,0 +1,8 @@
+//go:build !lru_nopad && (amd64 || arm64 || ppc64 || ppc64le)
+
+package lru
+
+// shardAlign is two 64-byte cache lines on amd64, where the adjacent-line
+// prefetcher pulls lines in pairs, and one 128-byte line on arm64 (Apple
+// M-series) and ppc64.
+const shardAlign = 128
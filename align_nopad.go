@@ -0,0 +1,6 @@
+//go:build lru_nopad
+
+package lru
+
+// shardAlign of 1 leaves shards unpadded.
+const shardAlign = 1
@@ -0,0 +1,6 @@
+//go:build !lru_nopad && !amd64 && !arm64 && !ppc64 && !ppc64le && !s390x
+
+package lru
+
+// shardAlign is a 64-byte cache line, the common size elsewhere.
+const shardAlign = 64
@@ -0,0 +1,6 @@
+//go:build !lru_nopad
+
+package lru
+
+// shardAlign is s390x's 256-byte cache line.
+const shardAlign = 256
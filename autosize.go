@@ -0,0 +1,158 @@
+package lru
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+// AutoSizeConfig configures an AutoSizer.
+type AutoSizeConfig struct {
+	// Target is the hit ratio to hold.
+	Target float64
+	// Slack is how far above Target the hit ratio must be for the cache
+	// to count as over-provisioned and shrink.  The default is 0.02.
+	Slack float64
+	// Min and Max bound the cache's size.
+	Min, Max int
+	// Step is the fraction of its size the cache grows or shrinks by at
+	// a time.  The default is 0.1.
+	Step float64
+	// Window is the number of lookups the hit ratio is measured over,
+	// and the number that must happen after a resize before the next.
+	// The default is 10000.
+	Window int
+	// Interval is how often Start checks the hit ratio.  The default is
+	// a minute.
+	Interval time.Duration
+	// CanGrow, if set, is asked before growing the cache, so that it
+	// only grows while memory allows.
+	CanGrow func() bool
+}
+
+// AutoSizable is the subset of a cache's methods that an AutoSizer uses,
+// which Cache and ShardedCache implement.
+type AutoSizable interface {
+	Cap() int
+	Resize(size int) (evicted int)
+	Stats() simplelru.Stats
+	HitRatio(window int) float64
+}
+
+var (
+	_ AutoSizable = (*Cache[int, int])(nil)
+	_ AutoSizable = (*ShardedCache[int])(nil)
+)
+
+// AutoSizer adjusts the size of a cache, within bounds, to hold a target
+// hit ratio as measured over a sliding window of lookups: it grows the
+// cache while the hit ratio is below the target, and shrinks it while the
+// hit ratio is comfortably above, giving back memory the cache doesn't
+// need.
+type AutoSizer struct {
+	cache  AutoSizable
+	config AutoSizeConfig
+
+	mu sync.Mutex
+	// lookups is the cache's lookup count at the last resize.
+	lookups uint64
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewAutoSizer creates an AutoSizer for cache.  It doesn't resize anything until Adjust or Start is
+// called.
+func NewAutoSizer(cache AutoSizable, config AutoSizeConfig) (*AutoSizer, error) {
+	if config.Target <= 0 || config.Target >= 1 {
+		return nil, errors.New("must provide a target hit ratio between 0 and 1")
+	}
+	if config.Min <= 0 || config.Max < config.Min {
+		return nil, errors.New("must provide positive bounds with Min <= Max")
+	}
+	if config.Slack <= 0 {
+		config.Slack = 0.02
+	}
+	if config.Step <= 0 {
+		config.Step = 0.1
+	}
+	if config.Window <= 0 {
+		config.Window = 10000
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Minute
+	}
+	return &AutoSizer{cache: cache, config: config}, nil
+}
+
+// Adjust resizes the cache once, if its hit ratio calls for it and enough
+// lookups have happened since the last resize to measure it afresh, and
+// returns the cache's size.
+func (a *AutoSizer) Adjust() (size int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	size = a.cache.Cap()
+	stats := a.cache.Stats()
+	lookups := stats.Hits + stats.Misses
+	if lookups-a.lookups < uint64(a.config.Window) {
+		return size
+	}
+	step := int(float64(size) * a.config.Step)
+	if step < 1 {
+		step = 1
+	}
+	target := size
+	ratio := a.cache.HitRatio(a.config.Window)
+	switch {
+	case ratio < a.config.Target && (a.config.CanGrow == nil || a.config.CanGrow()):
+		target = min(size+step, a.config.Max)
+	case ratio > a.config.Target+a.config.Slack:
+		target = max(size-step, a.config.Min)
+	}
+	if target == size {
+		return size
+	}
+	a.cache.Resize(target)
+	a.lookups = lookups
+	return target
+}
+
+// Start calls Adjust every Interval in a new goroutine, until Stop is
+// called.
+func (a *AutoSizer) Start() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.stop != nil {
+		return
+	}
+	a.stop = make(chan struct{})
+	a.done = make(chan struct{})
+	go a.run(a.stop, a.done)
+}
+
+func (a *AutoSizer) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(a.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.Adjust()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Stop stops the goroutine started by Start, and waits for it to exit.
+func (a *AutoSizer) Stop() {
+	a.mu.Lock()
+	stop, done := a.stop, a.done
+	a.stop, a.done = nil, nil
+	a.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
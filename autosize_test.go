@@ -0,0 +1,75 @@
+package lru
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestAutoSizer(t *testing.T) {
+	l, err := New[int, int](100)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	a, err := NewAutoSizer(l, AutoSizeConfig{Target: 0.9, Min: 50, Max: 1000, Window: 2000})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	lookup := func(workingSet int) {
+		for i := 0; i < 2000; i++ {
+			key := rng.Intn(workingSet)
+			if _, ok := l.Get(key); !ok {
+				l.Add(key, key)
+			}
+		}
+	}
+
+	// a working set of 400 needs a bigger cache
+	for i := 0; i < 50; i++ {
+		lookup(400)
+		a.Adjust()
+	}
+	// it settles around the size that holds the target
+	if size := l.Cap(); size < 300 || size > 450 {
+		t.Fatalf("expected the cache to grow, got %d", size)
+	}
+	// adjusting without fresh lookups does nothing
+	size := l.Cap()
+	if a.Adjust() != size {
+		t.Fatalf("expected no resize without new lookups")
+	}
+
+	// a working set of 20 needs much less
+	for i := 0; i < 100; i++ {
+		lookup(20)
+		a.Adjust()
+	}
+	if size := l.Cap(); size != 50 {
+		t.Fatalf("expected the cache to shrink to Min, got %d", size)
+	}
+
+	grow := false
+	a, _ = NewAutoSizer(l, AutoSizeConfig{Target: 0.99, Min: 50, Max: 1000, Window: 2000, CanGrow: func() bool { return grow }})
+	lookup(400)
+	if a.Adjust() != 50 {
+		t.Fatalf("expected CanGrow to prevent growth")
+	}
+
+	a, _ = NewAutoSizer(l, AutoSizeConfig{Target: 0.99, Min: 50, Max: 1000, Window: 1, Interval: time.Millisecond})
+	a.Start()
+	lookup(400)
+	deadline := time.Now().Add(time.Second)
+	for l.Cap() == 50 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	a.Stop()
+	a.Stop()
+	if l.Cap() == 50 {
+		t.Fatalf("expected Start to adjust the cache")
+	}
+
+	if _, err := NewAutoSizer(l, AutoSizeConfig{Target: 0.9, Min: 10, Max: 5}); err == nil {
+		t.Fatalf("expected bad bounds to fail")
+	}
+}
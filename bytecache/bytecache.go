@@ -0,0 +1,309 @@
+// Package bytecache is a cache of []byte values that keeps them out of
+// the garbage collector's way, in the style of bigcache: values are
+// copied into large pre-allocated buffers, and indexed by maps from key
+// hashes to offsets, which hold no pointers for the collector to scan.
+// Millions of cached blobs cost the collector a handful of buffers rather
+// than millions of pointers.
+//
+// Each shard's buffer is a ring of segments.  Entries are appended to the
+// newest segment, and when the ring is full the oldest segment is
+// reclaimed whole, evicting every entry in it.  Like the approximate LRU
+// in the rest of this module, that only approximates recency: an entry
+// hit while it's in the segment due to be reclaimed next is copied
+// forward into the newest, so entries in use survive reclamation.
+package bytecache
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/maphash"
+	"sync"
+)
+
+const (
+	defaultShards   = 64
+	defaultSegments = 8
+
+	// headerSize is the size of an entry's header: its key hash, then
+	// the lengths of its key and value.
+	headerSize = 8 + 2 + 4
+
+	maxKeyLen = 1<<16 - 1
+)
+
+// ErrEntryTooLarge is returned by Set for an entry that doesn't fit in a
+// segment.
+var ErrEntryTooLarge = errors.New("bytecache: entry too large")
+
+// Stats is a point-in-time snapshot of a cache's counters.
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// Collisions counts lookups whose key hash matched a different key.
+	Collisions uint64
+}
+
+// Option configures optional behavior of a Cache at construction time.
+type Option func(o *options)
+
+type options struct {
+	shards   int
+	segments int
+}
+
+// WithShards sets the number of shards, each with its own lock and
+// buffer.  The default is 64.
+func WithShards(n int) Option {
+	return func(o *options) {
+		o.shards = n
+	}
+}
+
+// WithSegments sets the number of segments each shard's buffer is
+// divided into.  More segments evict less at a time, but limit the size
+// of entries more.  The default is 8.
+func WithSegments(n int) Option {
+	return func(o *options) {
+		o.segments = n
+	}
+}
+
+// Cache is a thread-safe cache of []byte values, bounded by the total
+// size of its entries rather than their number.
+type Cache struct {
+	seed   maphash.Seed
+	shards []shard
+}
+
+type shard struct {
+	mu sync.Mutex
+	// index maps key hashes to the logical position of their entries.
+	// Positions only grow; an entry's offset in buf is its position
+	// modulo len(buf).
+	index   map[uint64]uint64
+	buf     []byte
+	segSize uint64
+	// used holds how many bytes of each segment hold entries.
+	used []uint64
+	// head is the position of the oldest segment still holding
+	// entries, and tail the position the next entry is written at.
+	head, tail uint64
+	stats      Stats
+}
+
+// New creates a Cache that holds up to capacity bytes of entries,
+// including their keys and a small header each.  The buffers are
+// allocated up front.
+func New(capacity int, opts ...Option) (*Cache, error) {
+	o := options{shards: defaultShards, segments: defaultSegments}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.shards <= 0 || o.segments < 2 {
+		return nil, errors.New("must provide a positive number of shards and at least 2 segments")
+	}
+	segSize := capacity / o.shards / o.segments
+	if segSize < headerSize+1 {
+		return nil, errors.New("capacity is too small for the number of shards and segments")
+	}
+	c := &Cache{
+		seed:   maphash.MakeSeed(),
+		shards: make([]shard, o.shards),
+	}
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.index = make(map[uint64]uint64)
+		s.buf = make([]byte, segSize*o.segments)
+		s.segSize = uint64(segSize)
+		s.used = make([]uint64, o.segments)
+	}
+	return c, nil
+}
+
+func (c *Cache) shard(key string) (*shard, uint64) {
+	h := maphash.String(c.seed, key)
+	return &c.shards[h%uint64(len(c.shards))], h
+}
+
+// Set stores a copy of value under key, replacing any value already
+// there.  It returns ErrEntryTooLarge if the entry doesn't fit in a
+// segment.
+func (c *Cache) Set(key string, value []byte) error {
+	if len(key) > maxKeyLen {
+		return ErrEntryTooLarge
+	}
+	s, h := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(h, key, value)
+}
+
+// Get returns a copy of key's value.
+func (c *Cache) Get(key string) (value []byte, ok bool) {
+	s, h := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pos, ok := s.lookup(h, key)
+	if !ok {
+		s.stats.Misses++
+		return nil, false
+	}
+	s.stats.Hits++
+	value = append([]byte(nil), s.value(pos)...)
+	// copy entries about to be reclaimed forward, so that entries in
+	// use survive
+	if pos < s.head+s.segSize {
+		delete(s.index, h)
+		s.write(h, key, value)
+	}
+	return value, true
+}
+
+// Contains checks if key is in the cache, without updating its
+// recent-ness or the cache's stats.
+func (c *Cache) Contains(key string) bool {
+	s, h := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.lookup(h, key)
+	return ok
+}
+
+// Delete removes key from the cache, returning if it was there.  The
+// space its entry took is reclaimed with the rest of its segment.
+func (c *Cache) Delete(key string) bool {
+	s, h := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.lookup(h, key); !ok {
+		return false
+	}
+	delete(s.index, h)
+	return true
+}
+
+// Len returns the number of entries in the cache.
+func (c *Cache) Len() int {
+	n := 0
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		n += len(s.index)
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Reset removes every entry from the cache, keeping its buffers.
+func (c *Cache) Reset() {
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		s.index = make(map[uint64]uint64)
+		for j := range s.used {
+			s.used[j] = 0
+		}
+		s.head, s.tail = 0, 0
+		s.mu.Unlock()
+	}
+}
+
+// Stats returns a snapshot of the cache's counters.
+func (c *Cache) Stats() (stats Stats) {
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		stats.Hits += s.stats.Hits
+		stats.Misses += s.stats.Misses
+		stats.Evictions += s.stats.Evictions
+		stats.Collisions += s.stats.Collisions
+		s.mu.Unlock()
+	}
+	return stats
+}
+
+// lookup returns the position of key's entry, which has hash h.
+func (s *shard) lookup(h uint64, key string) (pos uint64, ok bool) {
+	pos, ok = s.index[h]
+	if !ok {
+		return 0, false
+	}
+	off := s.offset(pos)
+	keyLen := uint64(binary.LittleEndian.Uint16(s.buf[off+8:]))
+	if string(s.buf[off+headerSize:off+headerSize+keyLen]) != key {
+		s.stats.Collisions++
+		return 0, false
+	}
+	return pos, true
+}
+
+func (s *shard) offset(pos uint64) uint64 {
+	return pos % uint64(len(s.buf))
+}
+
+func (s *shard) segment(pos uint64) uint64 {
+	return pos / s.segSize % uint64(len(s.used))
+}
+
+// value returns the value of the entry at pos, in place.
+func (s *shard) value(pos uint64) []byte {
+	off := s.offset(pos)
+	keyLen := uint64(binary.LittleEndian.Uint16(s.buf[off+8:]))
+	valueLen := uint64(binary.LittleEndian.Uint32(s.buf[off+10:]))
+	start := off + headerSize + keyLen
+	return s.buf[start : start+valueLen]
+}
+
+// write appends an entry, reclaiming the oldest segment if it needs the
+// room.
+func (s *shard) write(h uint64, key string, value []byte) error {
+	size := uint64(headerSize + len(key) + len(value))
+	if size > s.segSize {
+		return ErrEntryTooLarge
+	}
+	if s.tail%s.segSize+size > s.segSize {
+		// entries don't straddle segments; skip to the next
+		s.tail += s.segSize - s.tail%s.segSize
+	}
+	if s.tail%s.segSize == 0 {
+		if s.tail-s.head >= uint64(len(s.buf)) {
+			s.reclaim()
+		}
+		s.used[s.segment(s.tail)] = 0
+	}
+
+	pos := s.tail
+	off := s.offset(pos)
+	binary.LittleEndian.PutUint64(s.buf[off:], h)
+	binary.LittleEndian.PutUint16(s.buf[off+8:], uint16(len(key)))
+	binary.LittleEndian.PutUint32(s.buf[off+10:], uint32(len(value)))
+	copy(s.buf[off+headerSize:], key)
+	copy(s.buf[off+headerSize+uint64(len(key)):], value)
+
+	s.index[h] = pos
+	s.used[s.segment(pos)] = pos%s.segSize + size
+	s.tail = pos + size
+	return nil
+}
+
+// reclaim evicts every entry in the oldest segment, making room for a new
+// one.
+func (s *shard) reclaim() {
+	seg := s.segment(s.head)
+	for rel := uint64(0); rel < s.used[seg]; {
+		pos := s.head + rel
+		off := s.offset(pos)
+		h := binary.LittleEndian.Uint64(s.buf[off:])
+		keyLen := uint64(binary.LittleEndian.Uint16(s.buf[off+8:]))
+		valueLen := uint64(binary.LittleEndian.Uint32(s.buf[off+10:]))
+		// the entry may have been overwritten or deleted since
+		if cur, ok := s.index[h]; ok && cur == pos {
+			delete(s.index, h)
+			s.stats.Evictions++
+		}
+		rel += headerSize + keyLen + valueLen
+	}
+	s.used[seg] = 0
+	s.head += s.segSize
+}
@@ -0,0 +1,130 @@
+package bytecache
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	c, err := New(1<<16, WithShards(4))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.Set("a", []byte("hello")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	v, ok := c.Get("a")
+	if !ok || string(v) != "hello" {
+		t.Fatalf("bad value: %q, %v", v, ok)
+	}
+	// the returned value is a copy
+	v[0] = 'j'
+	if v, _ := c.Get("a"); string(v) != "hello" {
+		t.Fatalf("bad value: %q", v)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Fatalf("expected a miss")
+	}
+
+	c.Set("a", []byte("hi"))
+	if v, _ := c.Get("a"); string(v) != "hi" {
+		t.Fatalf("bad value: %q", v)
+	}
+	if c.Len() != 1 {
+		t.Fatalf("bad len: %v", c.Len())
+	}
+	if !c.Delete("a") || c.Delete("a") || c.Contains("a") {
+		t.Fatalf("bad delete")
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 3 || stats.Misses != 1 {
+		t.Fatalf("bad stats: %+v", stats)
+	}
+
+	c.Set("c", nil)
+	c.Reset()
+	if c.Len() != 0 || c.Contains("c") {
+		t.Fatalf("expected Reset to remove everything")
+	}
+}
+
+func TestCacheEvict(t *testing.T) {
+	// one shard of 4 segments of 256 bytes
+	c, err := New(1024, WithShards(1), WithSegments(4))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	value := make([]byte, 50)
+	for i := 0; i < 1000; i++ {
+		value[0] = byte(i)
+		if err := c.Set(strconv.Itoa(i), value); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	// 3 entries of 14+50+3 bytes fit a segment, and the newest segment
+	// may be partly full
+	if n := c.Len(); n < 9 || n > 12 {
+		t.Fatalf("bad len: %v", n)
+	}
+	if n := c.Stats().Evictions; n != uint64(1000-c.Len()) {
+		t.Fatalf("bad evictions: %v", n)
+	}
+	for i := 1000 - 9; i < 1000; i++ {
+		v, ok := c.Get(strconv.Itoa(i))
+		if !ok || v[0] != byte(i) || !bytes.Equal(v[1:], value[1:]) {
+			t.Fatalf("%d: bad value: %v, %v", i, v, ok)
+		}
+	}
+
+	if err := c.Set("big", make([]byte, 256)); err != ErrEntryTooLarge {
+		t.Fatalf("expected ErrEntryTooLarge, got %v", err)
+	}
+	if _, err := New(1024, WithShards(8), WithSegments(16)); err == nil {
+		t.Fatalf("expected segments that can't hold an entry to fail")
+	}
+}
+
+func TestCacheHotKeySurvives(t *testing.T) {
+	c, err := New(1024, WithShards(1), WithSegments(4))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c.Set("hot", []byte("value"))
+	value := make([]byte, 50)
+	for i := 0; i < 1000; i++ {
+		c.Set(strconv.Itoa(i), value)
+		// the hot key is hit at least once a segment
+		if i%2 == 0 {
+			if _, ok := c.Get("hot"); !ok {
+				t.Fatalf("%d: expected hot key to survive", i)
+			}
+		}
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	c, err := New(1<<16, WithShards(4))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 5000; i++ {
+				key := strconv.Itoa((i * 7) % 500)
+				if i%3 == 0 {
+					c.Set(key, []byte(key))
+				} else if v, ok := c.Get(key); ok && string(v) != key {
+					t.Errorf("%s: bad value %q", key, v)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,47 @@
+package lru
+
+// Cacher is the set of methods common to Cache, ShardedCache and
+// NopCache, so that application code and tests can swap one for another
+// behind a single type.  A ShardedCache is a Cacher[string, V].
+type Cacher[K comparable, V any] interface {
+	Add(key K, value V) (evicted bool)
+	Get(key K) (value V, ok bool)
+	Peek(key K) (value V, ok bool)
+	Contains(key K) bool
+	Remove(key K) (present bool)
+	Len() int
+	Purge()
+}
+
+var (
+	_ Cacher[int, int]    = (*Cache[int, int])(nil)
+	_ Cacher[string, int] = (*ShardedCache[int])(nil)
+	_ Cacher[int, int]    = NopCache[int, int]{}
+)
+
+// NopCache implements Cacher by caching nothing: Add drops the value, and
+// every lookup misses.  It's for turning caching off, or ruling it out
+// while debugging, without changing the code that uses a cache.  The zero
+// value is ready to use.
+type NopCache[K comparable, V any] struct{}
+
+// Add drops key and value, reporting no eviction.
+func (NopCache[K, V]) Add(key K, value V) (evicted bool) { return false }
+
+// Get always misses.
+func (NopCache[K, V]) Get(key K) (value V, ok bool) { return value, false }
+
+// Peek always misses.
+func (NopCache[K, V]) Peek(key K) (value V, ok bool) { return value, false }
+
+// Contains always returns false.
+func (NopCache[K, V]) Contains(key K) bool { return false }
+
+// Remove always returns false.
+func (NopCache[K, V]) Remove(key K) (present bool) { return false }
+
+// Len always returns 0.
+func (NopCache[K, V]) Len() int { return 0 }
+
+// Purge does nothing.
+func (NopCache[K, V]) Purge() {}
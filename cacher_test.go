@@ -0,0 +1,52 @@
+package lru
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestCacher(t *testing.T) {
+	l, err := New[string, int](8)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sharded, err := NewSharded[int](8, 2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for name, c := range map[string]Cacher[string, int]{"cache": l, "sharded": sharded} {
+		for i := 0; i < 4; i++ {
+			c.Add(strconv.Itoa(i), i)
+		}
+		if v, ok := c.Get("1"); !ok || v != 1 {
+			t.Fatalf("%s: bad get %v %v", name, v, ok)
+		}
+		if v, ok := c.Peek("2"); !ok || v != 2 {
+			t.Fatalf("%s: bad peek %v %v", name, v, ok)
+		}
+		if !c.Contains("3") || !c.Remove("3") || c.Contains("3") {
+			t.Fatalf("%s: bad remove", name)
+		}
+		if n := c.Len(); n != 3 {
+			t.Fatalf("%s: expected 3 entries, got %d", name, n)
+		}
+		c.Purge()
+		if n := c.Len(); n != 0 {
+			t.Fatalf("%s: expected no entries after purge, got %d", name, n)
+		}
+	}
+}
+
+func TestNopCache(t *testing.T) {
+	var c Cacher[string, int] = NopCache[string, int]{}
+	if c.Add("a", 1) {
+		t.Fatalf("expected no eviction")
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("expected a miss")
+	}
+	if _, ok := c.Peek("a"); ok || c.Contains("a") || c.Remove("a") || c.Len() != 0 {
+		t.Fatalf("expected nothing to be cached")
+	}
+	c.Purge()
+}
@@ -0,0 +1,438 @@
+package lru
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+)
+
+// CBOR major types, from RFC 8949.
+const (
+	cborUint  = 0
+	cborNeg   = 1
+	cborBytes = 2
+	cborText  = 3
+	cborArray = 4
+	cborMap   = 5
+	cborTag   = 6
+	cborOther = 7
+
+	// simple values of major type 7
+	cborFalse = 20
+	cborTrue  = 21
+)
+
+// maxCBORLen bounds the lengths we'll allocate for while decoding, so a
+// corrupt snapshot can't make us allocate unbounded memory up front.
+const maxCBORLen = 1 << 30
+
+// SaveCBOR writes the cache's keys and values to w as CBOR (RFC 8949), a
+// compact schema-less format with decoders for most languages, so that
+// snapshots can be analyzed or generated by tools not written in Go.  The
+// snapshot is a map:
+//
+//	{"version": 3, "size": 128, "policy": "approximate-lru",
+//	 "entries": [[key, value], ...]}
+//
+// where size and policy describe the cache that wrote the snapshot, and
+// entries are least recently used first.  Keys and values that are
+// strings, integers, floats, booleans or byte slices are written as the
+// equivalent CBOR items.  Others are written as byte strings encoded by
+// the codecs given WithKeyCodec and WithValueCodec, which must be set,
+// as the default GobCodec's output can't be read outside of Go.
+func (c *Cache[K, V]) SaveCBOR(w io.Writer) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+	if err := checkCBORCodec(c.keyCodec); err != nil {
+		return err
+	}
+	if err := checkCBORCodec(c.valueCodec); err != nil {
+		return err
+	}
+	snap := c.snapshot()
+	entries := snap.Entries()
+
+	bw := bufio.NewWriter(w)
+	e := cborEncoder{w: bw}
+	e.head(cborMap, 4)
+	e.text("version")
+	e.head(cborUint, snapshotVersion)
+	e.text("size")
+	e.head(cborUint, uint64(snap.size))
+	e.text("policy")
+	e.text(snapshotPolicy)
+	e.text("entries")
+	e.head(cborArray, uint64(len(entries)))
+	for _, entry := range entries {
+		e.head(cborArray, 2)
+		if err := encodeCBORItem(&e, entry.Key, c.keyCodec); err != nil {
+			return err
+		}
+		if err := encodeCBORItem(&e, entry.Value, c.valueCodec); err != nil {
+			return err
+		}
+	}
+	if e.err != nil {
+		return e.err
+	}
+	return bw.Flush()
+}
+
+// LoadCBOR adds the entries from a snapshot written by SaveCBOR to the
+// cache, as Load does.  Map keys other than "version" and "entries" are
+// ignored, so snapshots from before version 3, which lacked "size" and
+// "policy", can still be read.
+func (c *Cache[K, V]) LoadCBOR(r io.Reader) error {
+	d := cborDecoder{r: bufio.NewReader(r)}
+	n, err := d.expect(cborMap)
+	if err != nil {
+		return err
+	}
+	var entries []snapshotEntry[K, V]
+	for i := uint64(0); i < n; i++ {
+		name, err := d.text()
+		if err != nil {
+			return err
+		}
+		switch name {
+		case "version":
+			version, err := d.expect(cborUint)
+			if err != nil {
+				return err
+			}
+			if version < 1 || version > snapshotVersion {
+				return fmt.Errorf("unsupported snapshot version %d", version)
+			}
+		case "entries":
+			if entries, err = decodeCBOREntries(&d, c.keyCodec, c.valueCodec); err != nil {
+				return err
+			}
+		default:
+			if err := d.skip(); err != nil {
+				return err
+			}
+		}
+	}
+	return c.restore(entries)
+}
+
+func decodeCBOREntries[K comparable, V any](d *cborDecoder, keyCodec Codec[K], valueCodec Codec[V]) ([]snapshotEntry[K, V], error) {
+	n, err := d.expect(cborArray)
+	if err != nil {
+		return nil, err
+	}
+	var entries []snapshotEntry[K, V]
+	for i := uint64(0); i < n; i++ {
+		if m, err := d.expect(cborArray); err != nil {
+			return nil, err
+		} else if m != 2 {
+			return nil, fmt.Errorf("cbor: expected a [key, value] pair, got %d items", m)
+		}
+		var e snapshotEntry[K, V]
+		if e.Key, err = decodeCBORItem(d, keyCodec); err != nil {
+			return nil, err
+		}
+		if e.Value, err = decodeCBORItem(d, valueCodec); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// cborNative reports whether values of type t are written as native CBOR
+// items rather than with a codec.
+func cborNative(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
+
+// checkCBORCodec returns an error if values of type T would be written
+// with GobCodec.
+func checkCBORCodec[T any](codec Codec[T]) error {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if _, ok := codec.(GobCodec[T]); ok && !cborNative(t) {
+		return fmt.Errorf("cbor: %v needs a codec other than GobCodec", t)
+	}
+	return nil
+}
+
+func encodeCBORItem[T any](e *cborEncoder, v T, codec Codec[T]) error {
+	rv := reflect.ValueOf(&v).Elem()
+	if !cborNative(rv.Type()) {
+		data, err := codec.Encode(v)
+		if err != nil {
+			return err
+		}
+		e.bytes(data)
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.String:
+		e.text(rv.String())
+	case reflect.Bool:
+		if rv.Bool() {
+			e.raw(cborOther<<5 | cborTrue)
+		} else {
+			e.raw(cborOther<<5 | cborFalse)
+		}
+	case reflect.Float32:
+		e.raw(binary.BigEndian.AppendUint32([]byte{cborOther<<5 | 26}, math.Float32bits(float32(rv.Float())))...)
+	case reflect.Float64:
+		e.raw(binary.BigEndian.AppendUint64([]byte{cborOther<<5 | 27}, math.Float64bits(rv.Float()))...)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := rv.Int(); n >= 0 {
+			e.head(cborUint, uint64(n))
+		} else {
+			e.head(cborNeg, uint64(-1-n))
+		}
+	case reflect.Slice:
+		e.bytes(rv.Bytes())
+	default:
+		e.head(cborUint, rv.Uint())
+	}
+	return nil
+}
+
+// decodeCBORItem reads a value written by encodeCBORItem.  A byte string
+// where a native item is expected is decoded with codec, as written by
+// versions of SaveCBOR that always used the codecs.
+func decodeCBORItem[T any](d *cborDecoder, codec Codec[T]) (T, error) {
+	var v T
+	rv := reflect.ValueOf(&v).Elem()
+	major, info, n, err := d.headInfo()
+	if err != nil {
+		return v, err
+	}
+	if major == cborBytes && (!cborNative(rv.Type()) || rv.Kind() != reflect.Slice) {
+		data, err := d.payload(n)
+		if err != nil {
+			return v, err
+		}
+		return codec.Decode(data)
+	}
+	mismatch := fmt.Errorf("cbor: can't decode major type %d into %v", major, rv.Type())
+	switch rv.Kind() {
+	case reflect.String:
+		if major != cborText {
+			return v, mismatch
+		}
+		data, err := d.payload(n)
+		if err != nil {
+			return v, err
+		}
+		rv.SetString(string(data))
+	case reflect.Bool:
+		if major != cborOther || (n != cborTrue && n != cborFalse) {
+			return v, mismatch
+		}
+		rv.SetBool(n == cborTrue)
+	case reflect.Float32, reflect.Float64:
+		switch {
+		case major == cborOther && info == 26:
+			rv.SetFloat(float64(math.Float32frombits(uint32(n))))
+		case major == cborOther && info == 27:
+			rv.SetFloat(math.Float64frombits(n))
+		default:
+			return v, mismatch
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if (major != cborUint && major != cborNeg) || n > math.MaxInt64 {
+			return v, mismatch
+		}
+		i := int64(n)
+		if major == cborNeg {
+			i = -1 - i
+		}
+		if rv.OverflowInt(i) {
+			return v, fmt.Errorf("cbor: %d overflows %v", i, rv.Type())
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if major != cborUint {
+			return v, mismatch
+		}
+		if rv.OverflowUint(n) {
+			return v, fmt.Errorf("cbor: %d overflows %v", n, rv.Type())
+		}
+		rv.SetUint(n)
+	case reflect.Slice:
+		if major != cborBytes {
+			return v, mismatch
+		}
+		data, err := d.payload(n)
+		if err != nil {
+			return v, err
+		}
+		rv.SetBytes(data)
+	default:
+		return v, mismatch
+	}
+	return v, nil
+}
+
+// cborEncoder writes the subset of CBOR used by snapshots.  Errors are
+// sticky, and reported by err.
+type cborEncoder struct {
+	w   *bufio.Writer
+	buf [9]byte
+	err error
+}
+
+func (e *cborEncoder) head(major byte, n uint64) {
+	if e.err != nil {
+		return
+	}
+	b := e.buf[:0]
+	switch {
+	case n < 24:
+		b = append(b, major<<5|byte(n))
+	case n <= 0xff:
+		b = append(b, major<<5|24, byte(n))
+	case n <= 0xffff:
+		b = binary.BigEndian.AppendUint16(append(b, major<<5|25), uint16(n))
+	case n <= 0xffffffff:
+		b = binary.BigEndian.AppendUint32(append(b, major<<5|26), uint32(n))
+	default:
+		b = binary.BigEndian.AppendUint64(append(b, major<<5|27), n)
+	}
+	_, e.err = e.w.Write(b)
+}
+
+func (e *cborEncoder) raw(p ...byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(p)
+	}
+}
+
+func (e *cborEncoder) bytes(p []byte) {
+	e.head(cborBytes, uint64(len(p)))
+	if e.err == nil {
+		_, e.err = e.w.Write(p)
+	}
+}
+
+func (e *cborEncoder) text(s string) {
+	e.head(cborText, uint64(len(s)))
+	if e.err == nil {
+		_, e.err = e.w.WriteString(s)
+	}
+}
+
+// cborDecoder reads the subset of CBOR used by snapshots.  It doesn't
+// support indefinite-length items.
+type cborDecoder struct {
+	r *bufio.Reader
+}
+
+func (d *cborDecoder) head() (major byte, n uint64, err error) {
+	major, _, n, err = d.headInfo()
+	return major, n, err
+}
+
+// headInfo reads an item's head, returning its major type, its
+// additional information (which distinguishes floats of different sizes)
+// and the argument that follows.
+func (d *cborDecoder) headInfo() (major, info byte, n uint64, err error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = b>>5, b&0x1f
+	var size int
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, 0, 0, errors.New("cbor: indefinite-length and reserved items are not supported")
+	}
+	var buf [8]byte
+	if _, err := io.ReadFull(d.r, buf[8-size:]); err != nil {
+		return 0, 0, 0, err
+	}
+	return major, info, binary.BigEndian.Uint64(buf[:]), nil
+}
+
+func (d *cborDecoder) expect(major byte) (uint64, error) {
+	m, n, err := d.head()
+	if err != nil {
+		return 0, err
+	}
+	if m != major {
+		return 0, fmt.Errorf("cbor: expected major type %d, got %d", major, m)
+	}
+	return n, nil
+}
+
+func (d *cborDecoder) payload(n uint64) ([]byte, error) {
+	if n > maxCBORLen {
+		return nil, fmt.Errorf("cbor: item of %d bytes is too large", n)
+	}
+	p := make([]byte, n)
+	_, err := io.ReadFull(d.r, p)
+	return p, err
+}
+
+func (d *cborDecoder) bytes() ([]byte, error) {
+	n, err := d.expect(cborBytes)
+	if err != nil {
+		return nil, err
+	}
+	return d.payload(n)
+}
+
+func (d *cborDecoder) text() (string, error) {
+	n, err := d.expect(cborText)
+	if err != nil {
+		return "", err
+	}
+	p, err := d.payload(n)
+	return string(p), err
+}
+
+// skip reads and discards a single item, including any items nested in
+// it.
+func (d *cborDecoder) skip() error {
+	major, n, err := d.head()
+	if err != nil {
+		return err
+	}
+	switch major {
+	case cborBytes, cborText:
+		_, err = d.payload(n)
+		return err
+	case cborArray, cborMap:
+		if major == cborMap {
+			n *= 2
+		}
+		for i := uint64(0); i < n; i++ {
+			if err := d.skip(); err != nil {
+				return err
+			}
+		}
+	case cborTag:
+		return d.skip()
+	}
+	// integers and simple values are entirely in the head
+	return nil
+}
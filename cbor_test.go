@@ -0,0 +1,132 @@
+package lru
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+func TestSaveLoadCBOR(t *testing.T) {
+	l, err := New[string, string](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add("a", "1")
+
+	var buf bytes.Buffer
+	if err := l.SaveCBOR(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// {"version": 3, "size": 128, "policy": "approximate-lru", "entries": [["a", "1"]]}
+	const expected = "a4" + "6776657273696f6e" + "03" + "6473697a65" + "1880" +
+		"66706f6c696379" + "6f617070726f78696d6174652d6c7275" +
+		"67656e7472696573" + "81" + "82" + "6161" + "6131"
+	if got := hex.EncodeToString(buf.Bytes()); got != expected {
+		t.Fatalf("bad encoding:\n got %s\nwant %s", got, expected)
+	}
+
+	restored, err := New[string, string](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := restored.LoadCBOR(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if v, ok := restored.Get("a"); !ok || v != "1" {
+		t.Errorf("bad value for a: %v, %v", v, ok)
+	}
+}
+
+func TestLoadCBORUnknownKeys(t *testing.T) {
+	// a version 1 snapshot, with keys and values as codec-encoded byte
+	// strings, and an unknown key:
+	// {"comment": ["x", {1: 2}], "version": 1, "entries": [[h'01', h'02']]}
+	data, _ := hex.DecodeString("a3" + "67636f6d6d656e74" + "82" + "6178" + "a10102" +
+		"6776657273696f6e" + "01" + "67656e7472696573" + "81" + "82" + "4102" + "4104")
+	l, err := New[int, int](128, WithKeyCodec[int, int](IntCodec[int]{}), WithValueCodec[int, int](IntCodec[int]{}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := l.LoadCBOR(bytes.NewReader(data)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// varints are zig-zag encoded, so 0x02 is 1 and 0x04 is 2
+	if v, ok := l.Get(1); !ok || v != 2 {
+		t.Errorf("bad value for 1: %v, %v", v, ok)
+	}
+}
+
+func TestLoadCBORBadVersion(t *testing.T) {
+	data, _ := hex.DecodeString("a1" + "6776657273696f6e" + "18" + "63")
+	l, err := New[int, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := l.LoadCBOR(bytes.NewReader(data)); err == nil {
+		t.Fatalf("expected an error for version 99")
+	}
+}
+
+func TestSaveLoadCBORNative(t *testing.T) {
+	type pair struct {
+		i int
+		f float64
+		b bool
+	}
+	l, err := New[int, pair](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := l.SaveCBOR(&buf); err == nil {
+		t.Fatalf("expected an error saving struct values with GobCodec")
+	}
+
+	ints, err := New[int64, float64](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ints.Add(-500, 1.5)
+	ints.Add(math.MaxInt64, -2)
+	if err := ints.SaveCBOR(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// -500 is 0x39 0x01f3, and 1.5 a float64
+	if !bytes.Contains(buf.Bytes(), []byte{0x82, 0x39, 0x01, 0xf3, 0xfb, 0x3f, 0xf8}) {
+		t.Fatalf("expected native integers and floats: %x", buf.Bytes())
+	}
+	restored, err := New[int64, float64](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := restored.LoadCBOR(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if v, ok := restored.Get(-500); !ok || v != 1.5 {
+		t.Errorf("bad value for -500: %v, %v", v, ok)
+	}
+	if v, ok := restored.Get(math.MaxInt64); !ok || v != -2 {
+		t.Errorf("bad value for MaxInt64: %v, %v", v, ok)
+	}
+
+	bools, err := New[uint8, bool](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	bools.Add(1, true)
+	buf.Reset()
+	if err := bools.SaveCBOR(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	small, err := New[int8, bool](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := small.LoadCBOR(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if v, ok := small.Get(1); !ok || !v {
+		t.Errorf("bad value for 1: %v, %v", v, ok)
+	}
+}
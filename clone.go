@@ -0,0 +1,32 @@
+package lru
+
+// WithClone makes lookups return clone(value) rather than the cached
+// value itself, so that callers mutating what they get back, a common
+// bug when caching slices, maps or pointers, can't corrupt the cached
+// copy that other callers share.  It applies to Get, Peek, GetMany,
+// PeekOrAdd, the loading methods, Do and references from GetRef and
+// PeekRef; Range, the export methods and eviction callbacks see the
+// cached values themselves.  clone may be called with the cache locked,
+// so it must not call back into the cache.  Without it, lookups cost
+// nothing extra.
+func WithClone[K comparable, V any](clone func(value V) V) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.clone = clone
+	}
+}
+
+// cloned returns value, cloned if the cache was created WithClone.
+func (c *Cache[K, V]) cloned(value V) V {
+	if c.clone != nil {
+		return c.clone(value)
+	}
+	return value
+}
+
+// cloned returns value, cloned if the cache was created WithClone.
+func (c *ShardedCache[V]) cloned(value V) V {
+	if c.clone != nil {
+		return c.clone(value)
+	}
+	return value
+}
@@ -0,0 +1,63 @@
+package lru
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestWithClone(t *testing.T) {
+	clone := WithClone[int, []int](slices.Clone[[]int])
+	load := WithLoader[int, []int](func(ctx context.Context, key int) ([]int, error) {
+		return []int{key}, nil
+	})
+	l, err := New[int, []int](16, clone, load)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, []int{1})
+	lookups := map[string]func() []int{
+		"Get":  func() []int { v, _ := l.Get(1); return v },
+		"Peek": func() []int { v, _ := l.Peek(1); return v },
+		"PeekOrAdd": func() []int {
+			v, _, _ := l.PeekOrAdd(1, nil)
+			return v
+		},
+		"GetRef": func() []int {
+			r, _ := l.GetRef(1)
+			defer r.Release()
+			return *r.Value()
+		},
+		"GetOrLoad": func() []int { v, _ := l.GetOrLoad(context.Background(), 2); return v },
+		"loaded":    func() []int { v, _ := l.Get(3); return v },
+	}
+	for name, lookup := range lookups {
+		v := lookup()
+		if len(v) != 1 {
+			t.Fatalf("%s: unexpected value %v", name, v)
+		}
+		want := v[0]
+		v[0] = -1
+		if v := lookup(); v[0] != want {
+			t.Fatalf("%s: a caller's change leaked into the cache: %v", name, v)
+		}
+	}
+}
+
+func TestShardedWithClone(t *testing.T) {
+	l, err := NewSharded[[]int](64, 4, WithClone[string, []int](slices.Clone[[]int]))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add("a", []int{1})
+	v, _ := l.Get("a")
+	v[0] = -1
+	found, _ := l.GetMany([]string{"a"})
+	if found["a"][0] != 1 {
+		t.Fatalf("a caller's change leaked into the cache: %v", found["a"])
+	}
+	found["a"][0] = -1
+	if v, _ := l.Peek("a"); v[0] != 1 {
+		t.Fatalf("a caller's change leaked into the cache: %v", v)
+	}
+}
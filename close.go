@@ -0,0 +1,95 @@
+package lru
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrClosed is returned by methods that report errors, like Save, Load
+// and GetOrLoad, when called on a cache that has been closed.
+var ErrClosed = errors.New("lru: cache closed")
+
+var (
+	_ io.Closer = (*Cache[int, int])(nil)
+	_ io.Closer = (*ShardedCache[int])(nil)
+)
+
+// Close tears the cache down: it cancels any loads in flight, so that
+// their results aren't stored, and drops every entry without calling the
+// eviction callback, releasing the memory held for them.  Afterwards,
+// methods that report errors return ErrClosed, and the rest behave as if
+// the cache were empty and ignore additions.  Close returns ErrClosed if
+// the cache was already closed.  A cache created WithWriteBehind writes
+// everything queued before Close returns.
+func (c *Cache[K, V]) Close() error {
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return ErrClosed
+	}
+	c.closed = true
+	c.lru.Release()
+	c.unlock()
+	if c.sizing != nil {
+		c.sizing.close()
+	}
+	if c.evictor != nil {
+		c.evictor.close()
+	}
+	if c.quarantine != nil {
+		c.quarantine.drain()
+	}
+	if c.loads != nil {
+		c.loads.cancelAll()
+	}
+	if c.behind != nil {
+		c.behind.close()
+	}
+	if c.logger != nil {
+		c.logger.Info("lru: closed cache")
+	}
+	return nil
+}
+
+func (c *Cache[K, V]) isClosed() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.closed
+}
+
+// Close tears the cache down as Cache.Close does, one shard at a time.
+func (c *ShardedCache[V]) Close() error {
+	if c.closed.Swap(true) {
+		return ErrClosed
+	}
+	// anything that locked a shard before we did is released with the
+	// rest of its entries, and anything after sees closed
+	for i := 0; i < len(c.shards); i++ {
+		shard := &c.shards[i]
+		shard.lock()
+		shard.lru.Release()
+		c.unlock(shard)
+	}
+	if c.sizing != nil {
+		c.sizing.close()
+	}
+	if c.evictor != nil {
+		c.evictor.close()
+	}
+	if c.flusher != nil {
+		c.flusher.close()
+	}
+	if c.quarantine != nil {
+		c.quarantine.drain()
+	}
+	if c.loads != nil {
+		c.loads.cancelAll()
+	}
+	if c.behind != nil {
+		c.behind.close()
+	}
+	if c.logger != nil {
+		c.logger.Info("lru: closed sharded cache")
+	}
+	return nil
+}
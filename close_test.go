@@ -0,0 +1,86 @@
+package lru
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"testing"
+)
+
+func TestClose(t *testing.T) {
+	evicted := 0
+	l, err := NewWithEvict[int, int](128, func(k, v int) { evicted++ })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 64; i++ {
+		l.Add(i, i)
+	}
+	var buf bytes.Buffer
+	if err := l.Save(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if evicted != 0 {
+		t.Fatalf("expected no eviction callbacks, got %d", evicted)
+	}
+	if l.Len() != 0 || l.Contains(1) {
+		t.Fatalf("expected a closed cache to be empty")
+	}
+	l.Add(1, 1)
+	if _, ok := l.Get(1); ok {
+		t.Fatalf("expected a closed cache to ignore additions")
+	}
+	if err := l.Load(bytes.NewReader(buf.Bytes())); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	if err := l.Save(&buf); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	if err := <-l.SnapshotAsync(&buf); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	if err := l.Close(); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestCloseCancelsLoads(t *testing.T) {
+	started := make(chan struct{})
+	load := func(ctx context.Context, key string) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	l, err := NewSharded[int](1024, 16, WithLoader[string, int](load))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 64; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+	loaded := make(chan error)
+	go func() {
+		_, err := l.GetOrLoad(context.Background(), "a")
+		loaded <- err
+	}()
+	<-started
+	if err := l.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := <-loaded; err != context.Canceled {
+		t.Fatalf("expected the load to be canceled, got %v", err)
+	}
+	if l.Len() != 0 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if _, err := l.GetOrLoad(context.Background(), "a"); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	if l.Add("b", 1); l.Contains("b") {
+		t.Fatalf("expected a closed cache to ignore additions")
+	}
+}
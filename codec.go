@@ -0,0 +1,77 @@
+package lru
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+
+	"golang.org/x/exp/constraints"
+)
+
+// Codec converts keys or values to and from bytes for snapshots.
+type Codec[T any] interface {
+	Encode(v T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// StringCodec encodes strings as their bytes.
+type StringCodec struct{}
+
+func (StringCodec) Encode(v string) ([]byte, error) {
+	return []byte(v), nil
+}
+
+func (StringCodec) Decode(data []byte) (string, error) {
+	return string(data), nil
+}
+
+// IntCodec encodes integers as varints.
+type IntCodec[T constraints.Integer] struct{}
+
+func (IntCodec[T]) Encode(v T) ([]byte, error) {
+	return binary.AppendVarint(nil, int64(v)), nil
+}
+
+func (IntCodec[T]) Decode(data []byte) (T, error) {
+	v, n := binary.Varint(data)
+	if n <= 0 || n != len(data) {
+		return 0, errors.New("invalid varint")
+	}
+	return T(v), nil
+}
+
+// GobCodec encodes each key or value with encoding/gob.  It handles any
+// type gob does, but repeats gob's type information for every key or
+// value, so a specific codec is more compact where one exists.
+type GobCodec[T any] struct{}
+
+func (GobCodec[T]) Encode(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec[T]) Decode(data []byte) (T, error) {
+	var v T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return v, err
+}
+
+// WithKeyCodec sets how keys are encoded in snapshots written by Save and
+// read by Load.  The default is GobCodec.
+func WithKeyCodec[K comparable, V any](codec Codec[K]) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.keyCodec = codec
+	}
+}
+
+// WithValueCodec sets how values are encoded in snapshots written by Save
+// and read by Load.  The default is GobCodec.
+func WithValueCodec[K comparable, V any](codec Codec[V]) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.valueCodec = codec
+	}
+}
@@ -0,0 +1,75 @@
+package lru
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestIntCodec(t *testing.T) {
+	for _, v := range []int64{0, 1, -1, math.MaxInt64, math.MinInt64} {
+		data, err := IntCodec[int64]{}.Encode(v)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		got, err := IntCodec[int64]{}.Decode(data)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if got != v {
+			t.Errorf("round trip of %d gave %d", v, got)
+		}
+	}
+	got, err := IntCodec[uint64]{}.Decode(mustEncode(t, IntCodec[uint64]{}, math.MaxUint64))
+	if err != nil || got != math.MaxUint64 {
+		t.Errorf("bad uint64 round trip: %v, %v", got, err)
+	}
+	if _, err := (IntCodec[int]{}).Decode(nil); err == nil {
+		t.Errorf("expected an error decoding an empty varint")
+	}
+}
+
+func TestGobCodec(t *testing.T) {
+	type point struct{ X, Y int }
+	data := mustEncode(t, GobCodec[point]{}, point{1, 2})
+	got, err := GobCodec[point]{}.Decode(data)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if got != (point{1, 2}) {
+		t.Errorf("bad round trip: %v", got)
+	}
+}
+
+func TestSaveLoadCodecs(t *testing.T) {
+	l, err := New[string, int](128, WithKeyCodec[string, int](StringCodec{}), WithValueCodec[string, int](IntCodec[int]{}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add("a", 1)
+	l.Add("b", -2)
+
+	var buf bytes.Buffer
+	if err := l.Save(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	restored, err := New[string, int](128, WithKeyCodec[string, int](StringCodec{}), WithValueCodec[string, int](IntCodec[int]{}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := restored.Load(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if v, ok := restored.Get("b"); !ok || v != -2 {
+		t.Errorf("bad value for b: %v, %v", v, ok)
+	}
+}
+
+func mustEncode[T any](t *testing.T, codec Codec[T], v T) []byte {
+	t.Helper()
+	data, err := codec.Encode(v)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return data
+}
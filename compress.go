@@ -0,0 +1,209 @@
+package lru
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// Compressor compresses values for a CompressedCache.
+type Compressor interface {
+	// Compress appends the compressed form of src to dst.
+	Compress(dst, src []byte) ([]byte, error)
+	// Decompress appends the decompressed form of src to dst.
+	Decompress(dst, src []byte) ([]byte, error)
+}
+
+// FlateCompressor is a Compressor using compress/flate at the given
+// level, or flate.DefaultCompression if it's zero.  Use flate.BestSpeed
+// to spend the least CPU.
+type FlateCompressor struct {
+	Level int
+
+	writers sync.Pool
+}
+
+func (f *FlateCompressor) Compress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	w, _ := f.writers.Get().(*flate.Writer)
+	if w == nil {
+		level := f.Level
+		if level == 0 {
+			level = flate.DefaultCompression
+		}
+		var err error
+		if w, err = flate.NewWriter(buf, level); err != nil {
+			return nil, err
+		}
+	} else {
+		w.Reset(buf)
+	}
+	defer f.writers.Put(w)
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (f *FlateCompressor) Decompress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	r := flate.NewReader(bytes.NewReader(src))
+	defer r.Close()
+	if _, err := io.Copy(buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Compressed values are stored with a one-byte header saying whether the
+// rest is compressed, since values that don't compress are stored as they
+// are.
+const (
+	storedRaw byte = iota
+	storedCompressed
+)
+
+// CompressionStats is a point-in-time snapshot of a CompressedCache's
+// counters.
+type CompressionStats struct {
+	// Values is the number of values added.
+	Values uint64
+	// Compressed is the number of those that were stored compressed;
+	// the rest didn't shrink.
+	Compressed uint64
+	// RawBytes and StoredBytes are the total sizes of the values added,
+	// and of what was stored for them.
+	RawBytes    uint64
+	StoredBytes uint64
+	// Errors counts values that failed to compress or decompress.
+	// Values that fail to decompress are dropped, and Get misses.
+	Errors uint64
+}
+
+// Ratio returns RawBytes over StoredBytes: how many times more values
+// fit in the same memory, or 0 before any values are added.
+func (s CompressionStats) Ratio() float64 {
+	if s.StoredBytes == 0 {
+		return 0
+	}
+	return float64(s.RawBytes) / float64(s.StoredBytes)
+}
+
+// CompressedCache is a thread-safe cache of []byte values that stores
+// them compressed, trading CPU on Add and Get for more values in the same
+// memory.  Values that don't shrink are stored as they are.
+type CompressedCache[K comparable] struct {
+	cache *Cache[K, []byte]
+	comp  Compressor
+
+	values      atomic.Uint64
+	compressed  atomic.Uint64
+	rawBytes    atomic.Uint64
+	storedBytes atomic.Uint64
+	errors      atomic.Uint64
+}
+
+// NewCompressed creates a CompressedCache of the given size, compressing
+// values with comp.  opts configure the underlying Cache, which sees the
+// compressed values.
+func NewCompressed[K comparable](size int, comp Compressor, opts ...Option[K, []byte]) (*CompressedCache[K], error) {
+	cache, err := New[K, []byte](size, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &CompressedCache[K]{cache: cache, comp: comp}, nil
+}
+
+// Cache returns the underlying cache, which holds compressed values, for
+// its stats, persistence and so on.
+func (c *CompressedCache[K]) Cache() *Cache[K, []byte] {
+	return c.cache
+}
+
+// Add compresses value and adds it to the cache.  Returns true if an
+// eviction occurred.  A value that fails to compress is stored as it is.
+func (c *CompressedCache[K]) Add(key K, value []byte) (evicted bool) {
+	stored, err := c.comp.Compress([]byte{storedCompressed}, value)
+	if err != nil {
+		c.errors.Add(1)
+	}
+	if err != nil || len(stored) >= len(value)+1 {
+		stored = append([]byte{storedRaw}, value...)
+	} else {
+		c.compressed.Add(1)
+	}
+	c.values.Add(1)
+	c.rawBytes.Add(uint64(len(value)))
+	c.storedBytes.Add(uint64(len(stored)))
+	return c.cache.Add(key, stored)
+}
+
+// Get looks up and decompresses a key's value.
+func (c *CompressedCache[K]) Get(key K) (value []byte, ok bool) {
+	stored, ok := c.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	return c.decompress(key, stored)
+}
+
+// Peek returns a key's value without updating its "recently used"-ness.
+func (c *CompressedCache[K]) Peek(key K) (value []byte, ok bool) {
+	stored, ok := c.cache.Peek(key)
+	if !ok {
+		return nil, false
+	}
+	return c.decompress(key, stored)
+}
+
+func (c *CompressedCache[K]) decompress(key K, stored []byte) ([]byte, bool) {
+	if len(stored) > 0 && stored[0] == storedRaw {
+		return stored[1:len(stored):len(stored)], true
+	}
+	if len(stored) > 0 && stored[0] == storedCompressed {
+		if value, err := c.comp.Decompress(nil, stored[1:]); err == nil {
+			return value, true
+		}
+	}
+	c.errors.Add(1)
+	c.cache.Remove(key)
+	return nil, false
+}
+
+// Contains checks if a key is in the cache, without updating its
+// "recently used"-ness or decompressing it.
+func (c *CompressedCache[K]) Contains(key K) bool {
+	return c.cache.Contains(key)
+}
+
+// Remove removes a key from the cache.
+func (c *CompressedCache[K]) Remove(key K) (present bool) {
+	return c.cache.Remove(key)
+}
+
+// Purge removes every entry from the cache.
+func (c *CompressedCache[K]) Purge() {
+	c.cache.Purge()
+}
+
+// Len returns the number of entries in the cache.
+func (c *CompressedCache[K]) Len() int {
+	return c.cache.Len()
+}
+
+// CompressionStats returns a snapshot of the cache's compression
+// counters.
+func (c *CompressedCache[K]) CompressionStats() CompressionStats {
+	return CompressionStats{
+		Values:      c.values.Load(),
+		Compressed:  c.compressed.Load(),
+		RawBytes:    c.rawBytes.Load(),
+		StoredBytes: c.storedBytes.Load(),
+		Errors:      c.errors.Load(),
+	}
+}
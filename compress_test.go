@@ -0,0 +1,53 @@
+package lru
+
+import (
+	"bytes"
+	"compress/flate"
+	"testing"
+)
+
+func TestCompressedCache(t *testing.T) {
+	l, err := NewCompressed[string](128, &FlateCompressor{Level: flate.BestSpeed})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	text := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog. "), 100)
+	l.Add("text", text)
+	// too short to shrink
+	l.Add("short", []byte("hi"))
+
+	if v, ok := l.Get("text"); !ok || !bytes.Equal(v, text) {
+		t.Fatalf("bad value: %q", v)
+	}
+	if v, ok := l.Peek("short"); !ok || string(v) != "hi" {
+		t.Fatalf("bad value: %q", v)
+	}
+	if stored, _ := l.Cache().Peek("text"); len(stored) >= len(text)/10 {
+		t.Fatalf("expected the value to be stored compressed, got %d bytes", len(stored))
+	}
+
+	stats := l.CompressionStats()
+	if stats.Values != 2 || stats.Compressed != 1 || stats.RawBytes != uint64(len(text)+2) {
+		t.Fatalf("bad stats: %+v", stats)
+	}
+	if stats.Ratio() < 10 {
+		t.Fatalf("bad ratio: %v", stats.Ratio())
+	}
+
+	// a corrupt value is dropped
+	l.Cache().Add("corrupt", []byte{storedCompressed, 0xff, 0xff})
+	if _, ok := l.Get("corrupt"); ok || l.Contains("corrupt") {
+		t.Fatalf("expected a corrupt value to be dropped")
+	}
+	if l.CompressionStats().Errors != 1 {
+		t.Fatalf("expected an error to be counted")
+	}
+
+	if !l.Remove("text") || l.Len() != 1 {
+		t.Fatalf("bad remove")
+	}
+	l.Purge()
+	if l.Len() != 0 {
+		t.Fatalf("bad purge")
+	}
+}
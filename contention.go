@@ -0,0 +1,87 @@
+package lru
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ContentionStats describes how often acquiring a shard's lock had to wait
+// for another goroutine to release it.
+type ContentionStats struct {
+	Acquisitions uint64
+	Contended    uint64
+	Wait         time.Duration
+}
+
+// contentionRecorder is updated atomically, since readers holding the
+// shard's read lock record their acquisitions concurrently.
+type contentionRecorder struct {
+	acquisitions uint64
+	contended    uint64
+	wait         int64
+}
+
+func (r *contentionRecorder) record(start time.Time, contended bool) {
+	if contended {
+		atomic.AddUint64(&r.contended, 1)
+		atomic.AddInt64(&r.wait, int64(time.Since(start)))
+	}
+	atomic.AddUint64(&r.acquisitions, 1)
+}
+
+func (r *contentionRecorder) stats() ContentionStats {
+	return ContentionStats{
+		Acquisitions: atomic.LoadUint64(&r.acquisitions),
+		Contended:    atomic.LoadUint64(&r.contended),
+		Wait:         time.Duration(atomic.LoadInt64(&r.wait)),
+	}
+}
+
+// lock acquires the shard's mutex, recording whether it had to wait if
+// contention tracking is enabled.  It is for cache operations only:
+// diagnostic readers like Stats and Len take s.mu directly, so that
+// polling them doesn't show up in the contention they'd report.
+func (s *shard[V]) lock() {
+	s.acquire()
+	// Gets made under the read lock are applied before anything can
+	// move the entries they hit
+	s.lru.ApplyReads()
+	// writes buffered WithWriteBuffer come before whatever the lock is
+	// for
+	if s.buffered() {
+		s.applyWrites()
+	}
+}
+
+func (s *shard[V]) acquire() {
+	if s.instr == nil || s.instr.contention == nil {
+		s.mu.Lock()
+		return
+	}
+	if s.mu.TryLock() {
+		s.instr.contention.record(time.Time{}, false)
+		return
+	}
+	start := time.Now()
+	s.mu.Lock()
+	s.instr.contention.record(start, true)
+}
+
+// rlock is lock for operations that don't modify the shard, like Peek,
+// Contains and most Gets, which can run alongside each other.
+func (s *shard[V]) rlock() {
+	if s.buffered() {
+		s.flush()
+	}
+	if s.instr == nil || s.instr.contention == nil {
+		s.mu.RLock()
+		return
+	}
+	if s.mu.TryRLock() {
+		s.instr.contention.record(time.Time{}, false)
+		return
+	}
+	start := time.Now()
+	s.mu.RLock()
+	s.instr.contention.record(start, true)
+}
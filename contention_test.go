@@ -0,0 +1,61 @@
+package lru
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestContentionTracking(t *testing.T) {
+	l, err := NewSharded[int](1024, 2, WithContentionTracking[string, int]())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				l.Add(strconv.Itoa(i), i)
+				l.Peek(strconv.Itoa(i))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// reading diagnostics doesn't count as contention
+	l.Stats()
+	l.Len()
+	l.HitRatio(1000)
+
+	var acquisitions, contended uint64
+	for _, s := range l.ShardStats() {
+		acquisitions += s.Contention.Acquisitions
+		contended += s.Contention.Contended
+		if s.Contention.Contended > 0 && s.Contention.Wait <= 0 {
+			t.Errorf("expected a positive wait with contention: %+v", s.Contention)
+		}
+	}
+	// each Add and Peek takes a lock, and nothing else is counted
+	if acquisitions != 16000 {
+		t.Errorf("expected 16000 acquisitions, got %d", acquisitions)
+	}
+	if contended > acquisitions {
+		t.Errorf("more contended acquisitions (%d) than acquisitions (%d)", contended, acquisitions)
+	}
+}
+
+func TestContentionTrackingDisabled(t *testing.T) {
+	l, err := NewSharded[int](1024, 2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add("a", 1)
+	for _, s := range l.ShardStats() {
+		if s.Contention.Acquisitions != 0 {
+			t.Errorf("expected no contention stats, got %+v", s.Contention)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package lru
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+// maxDebugEntries bounds the number of entries a single debug request can
+// list, so that a careless query can't serialize an entire large cache.
+const maxDebugEntries = 1000
+
+type debugSource[K comparable, V any] interface {
+	Len() int
+	Cap() int
+	Stats() simplelru.Stats
+	HotKeys(k int) []simplelru.HotKey[K]
+	Range(fn func(key K, value V) bool)
+}
+
+type debugHandler[K comparable, V any] struct {
+	cache  debugSource[K, V]
+	shards func() []ShardStats
+}
+
+type debugStats struct {
+	Hits           uint64  `json:"hits"`
+	Misses         uint64  `json:"misses"`
+	Evictions      uint64  `json:"evictions"`
+	HitRatio       float64 `json:"hitRatio"`
+	EvictionAgeP50 uint64  `json:"evictionAgeP50"`
+	EvictionAgeP99 uint64  `json:"evictionAgeP99"`
+	ValueSizeP50   uint64  `json:"valueSizeP50,omitempty"`
+	ValueSizeP99   uint64  `json:"valueSizeP99,omitempty"`
+}
+
+type debugShard struct {
+	Len   int        `json:"len"`
+	Cap   int        `json:"cap"`
+	Stats debugStats `json:"stats"`
+}
+
+type debugHotKey struct {
+	Key   string `json:"key"`
+	Count uint64 `json:"count"`
+	Error uint64 `json:"error"`
+}
+
+type debugEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+type debugResponse struct {
+	Len     int           `json:"len"`
+	Cap     int           `json:"cap"`
+	Stats   debugStats    `json:"stats"`
+	Shards  []debugShard  `json:"shards,omitempty"`
+	HotKeys []debugHotKey `json:"hotKeys,omitempty"`
+	Entries []debugEntry  `json:"entries,omitempty"`
+}
+
+func newDebugStats(s simplelru.Stats) debugStats {
+	return debugStats{
+		Hits:           s.Hits,
+		Misses:         s.Misses,
+		Evictions:      s.Evictions,
+		HitRatio:       s.HitRatio(),
+		EvictionAgeP50: s.EvictionAge.Quantile(0.5),
+		EvictionAgeP99: s.EvictionAge.Quantile(0.99),
+		ValueSizeP50:   s.ValueSize.Quantile(0.5),
+		ValueSizeP99:   s.ValueSize.Quantile(0.99),
+	}
+}
+
+// ServeHTTP responds with a JSON description of the cache.  The following
+// query parameters add optional sections:
+//
+//	shards=1      per-shard occupancy and counters (sharded caches only)
+//	hot=N         the N hottest keys (requires WithHotKeys)
+//	entries=N     up to N keys, starting at offset=M
+//	values=1      include values in the entry listing
+func (h *debugHandler[K, V]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	resp := debugResponse{
+		Len:   h.cache.Len(),
+		Cap:   h.cache.Cap(),
+		Stats: newDebugStats(h.cache.Stats()),
+	}
+
+	if q.Get("shards") != "" && h.shards != nil {
+		for _, s := range h.shards() {
+			resp.Shards = append(resp.Shards, debugShard{
+				Len:   s.Len,
+				Cap:   s.Cap,
+				Stats: newDebugStats(s.Stats),
+			})
+		}
+	}
+
+	if n, err := strconv.Atoi(q.Get("hot")); err == nil && n > 0 {
+		for _, hk := range h.cache.HotKeys(n) {
+			resp.HotKeys = append(resp.HotKeys, debugHotKey{
+				Key:   fmt.Sprint(hk.Key),
+				Count: hk.Count,
+				Error: hk.Error,
+			})
+		}
+	}
+
+	if limit, err := strconv.Atoi(q.Get("entries")); err == nil && limit > 0 {
+		if limit > maxDebugEntries {
+			limit = maxDebugEntries
+		}
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		withValues := q.Get("values") != ""
+		i := 0
+		h.cache.Range(func(key K, value V) bool {
+			if i >= offset {
+				e := debugEntry{Key: fmt.Sprint(key)}
+				if withValues {
+					e.Value = fmt.Sprint(value)
+				}
+				resp.Entries = append(resp.Entries, e)
+			}
+			i++
+			return len(resp.Entries) < limit
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(resp)
+}
+
+// DebugHandler returns an http.Handler that serves the cache's stats,
+// hottest keys, and optionally a paginated listing of its entries as
+// JSON, for mounting under an internal admin mux in the style of expvar
+// and pprof.  It must not be exposed publicly: it can reveal keys and
+// values.
+func (c *Cache[K, V]) DebugHandler() http.Handler {
+	return &debugHandler[K, V]{cache: c}
+}
+
+// DebugHandler returns an http.Handler that serves the cache's stats,
+// per-shard occupancy, hottest keys, and optionally a paginated listing
+// of its entries as JSON, for mounting under an internal admin mux in the
+// style of expvar and pprof.  It must not be exposed publicly: it can
+// reveal keys and values.
+func (c *ShardedCache[V]) DebugHandler() http.Handler {
+	return &debugHandler[string, V]{cache: c, shards: c.ShardStats}
+}
@@ -0,0 +1,74 @@
+package lru
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestShardedDebugHandler(t *testing.T) {
+	l, err := NewSharded[int](64, 4, WithHotKeys[string, int](4))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+	for i := 0; i < 5; i++ {
+		l.Get("7")
+	}
+
+	rec := httptest.NewRecorder()
+	l.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/cache?shards=1&hot=1&entries=3&offset=2&values=1", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("bad content type %q", ct)
+	}
+
+	var resp debugResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Len != 10 || resp.Cap != 64 {
+		t.Errorf("bad len/cap: %d/%d", resp.Len, resp.Cap)
+	}
+	if resp.Stats.Hits != 5 {
+		t.Errorf("bad hits: %d", resp.Stats.Hits)
+	}
+	if len(resp.Shards) != 4 {
+		t.Errorf("expected 4 shards, got %d", len(resp.Shards))
+	}
+	if len(resp.HotKeys) != 1 || resp.HotKeys[0].Key != "7" {
+		t.Errorf("bad hot keys: %+v", resp.HotKeys)
+	}
+	if len(resp.Entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(resp.Entries))
+	}
+	for _, e := range resp.Entries {
+		if e.Key != e.Value {
+			t.Errorf("bad entry %+v", e)
+		}
+	}
+}
+
+func TestDebugHandler(t *testing.T) {
+	l, err := New[int, string](8)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, "secret")
+
+	rec := httptest.NewRecorder()
+	l.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/?shards=1&entries=10", nil))
+
+	var resp debugResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Len != 1 || resp.Shards != nil {
+		t.Errorf("bad response: %+v", resp)
+	}
+	if len(resp.Entries) != 1 || resp.Entries[0].Key != "1" || resp.Entries[0].Value != "" {
+		t.Errorf("values should only be listed on request: %+v", resp.Entries)
+	}
+}
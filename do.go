@@ -0,0 +1,59 @@
+package lru
+
+import (
+	"sync"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+// lockStripes is the number of mutexes that keyLocks hashes keys onto.
+const lockStripes = 64
+
+// keyLocks serializes work on individual keys without a mutex per key,
+// by hashing keys onto a fixed set of mutexes.  Different keys may share
+// a mutex, so work done holding one mustn't wait on another key's.
+type keyLocks[K comparable] [lockStripes]sync.Mutex
+
+// lock locks key's mutex, and returns it for the caller to unlock.
+func (l *keyLocks[K]) lock(key K) *sync.Mutex {
+	mu := &l[simplelru.HashKey(key)%lockStripes]
+	mu.Lock()
+	return mu
+}
+
+// Do calls fn with key's value, and if fn returns store, adds the value
+// it returns to the cache, for read-modify-write updates such as
+// aggregating into a cached bucket.  Calls to Do for the same key are
+// serialized, while those for other keys proceed concurrently; other
+// methods don't wait for Do, so an Add or Remove of key can still slip in
+// while fn runs.  fn is called without the cache locked, so it may call
+// back into the cache, but not Do for a key, as keys share locks.  Do
+// returns the value key is left holding, if any, which isn't fn's if the
+// cache didn't store it.
+func (c *Cache[K, V]) Do(key K, fn func(value V, ok bool) (V, bool)) (value V, ok bool) {
+	mu := c.keyLocks.lock(key)
+	defer mu.Unlock()
+	value, ok = c.get(key)
+	if value, store := fn(value, ok); store {
+		c.Add(key, value)
+		// Add doesn't store value if the cache is closed or frozen, say,
+		// or rejects it
+		return c.Peek(key)
+	}
+	return value, ok
+}
+
+// Do calls fn with key's value, and if fn returns store, adds the value
+// it returns to the cache.  See Cache.Do.
+func (c *ShardedCache[V]) Do(key string, fn func(value V, ok bool) (V, bool)) (value V, ok bool) {
+	mu := c.keyLocks.lock(key)
+	defer mu.Unlock()
+	value, ok = c.get(key)
+	if value, store := fn(value, ok); store {
+		c.Add(key, value)
+		// Add doesn't store value if the cache is closed or frozen, say,
+		// or rejects it
+		return c.Peek(key)
+	}
+	return value, ok
+}
@@ -0,0 +1,104 @@
+package lru
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestDo(t *testing.T) {
+	l, err := New[string, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	incr := func(v int, ok bool) (int, bool) {
+		return v + 1, true
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				l.Do("bucket"+strconv.Itoa(i%4), incr)
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 4; i++ {
+		if v, _ := l.Peek("bucket" + strconv.Itoa(i)); v != 2000 {
+			t.Fatalf("expected no lost updates, got %d", v)
+		}
+	}
+
+	v, ok := l.Do("missing", func(v int, ok bool) (int, bool) {
+		if ok {
+			t.Fatalf("expected a miss")
+		}
+		return 0, false
+	})
+	if ok || v != 0 || l.Contains("missing") {
+		t.Fatalf("expected Do not to store")
+	}
+	v, ok = l.Do("bucket0", func(v int, ok bool) (int, bool) {
+		// fn may call back into the cache
+		l.Add("other", 1)
+		return -1, false
+	})
+	if !ok || v != 2000 {
+		t.Fatalf("expected the existing value, got %v, %v", v, ok)
+	}
+
+	// values the cache doesn't store aren't reported as stored
+	store := func(v int, ok bool) (int, bool) {
+		return 1, true
+	}
+	l.Freeze()
+	if v, ok := l.Do("frozen", store); ok {
+		t.Fatalf("expected a frozen cache not to store, got %v", v)
+	}
+	if v, ok := l.Do("bucket1", store); !ok || v != 2000 {
+		t.Fatalf("expected the frozen value, got %v, %v", v, ok)
+	}
+	l.Thaw()
+	if err := l.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if v, ok := l.Do("closed", store); ok {
+		t.Fatalf("expected a closed cache not to store, got %v", v)
+	}
+}
+
+func TestShardedDo(t *testing.T) {
+	l, err := NewSharded[[]string](128, 4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				l.Do("log", func(v []string, ok bool) ([]string, bool) {
+					return append(v, strconv.Itoa(g)), true
+				})
+			}
+		}(g)
+	}
+	wg.Wait()
+	if v, _ := l.Peek("log"); len(v) != 800 {
+		t.Fatalf("expected no lost appends, got %d", len(v))
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	v, ok := l.Do("closed", func(v []string, ok bool) ([]string, bool) {
+		return []string{"x"}, true
+	})
+	if ok {
+		t.Fatalf("expected a closed cache not to store, got %v", v)
+	}
+}
@@ -0,0 +1,111 @@
+package lru
+
+import (
+	"time"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+// SizingStats describes a cache to a DynamicSizer.
+type SizingStats struct {
+	// Size is the cache's current size, and Len the number of entries
+	// in it.
+	Size int
+	Len  int
+	// Stats are the cache's counters over its lifetime, and Interval
+	// the hits, misses, evictions and rejections since the sizer was
+	// last called.
+	Stats    simplelru.Stats
+	Interval simplelru.Stats
+	// Elapsed is the time since the sizer was last called.
+	Elapsed time.Duration
+}
+
+// DynamicSizer chooses a cache's size, for custom sizing policies such as
+// ones that weigh the cost of a miss against the cost of memory.
+type DynamicSizer interface {
+	// TargetSize returns the size the cache should have, or a value less
+	// than 1 to leave it as it is.
+	TargetSize(stats SizingStats) int
+}
+
+// DynamicSizerFunc adapts a function to a DynamicSizer.
+type DynamicSizerFunc func(stats SizingStats) int
+
+// TargetSize implements DynamicSizer.
+func (f DynamicSizerFunc) TargetSize(stats SizingStats) int {
+	return f(stats)
+}
+
+// WithDynamicSizer calls sizer every interval, in a goroutine the cache
+// starts and Close stops, and resizes the cache to the size it returns.
+func WithDynamicSizer[K comparable, V any](sizer DynamicSizer, interval time.Duration) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.sizer = sizer
+		o.sizingInterval = interval
+	}
+}
+
+// dynamicSizing runs a DynamicSizer for a cache.
+type dynamicSizing struct {
+	cache    Managed
+	sizer    DynamicSizer
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// startDynamicSizing starts calling sizer for cache, returning nil if
+// sizer is.
+func startDynamicSizing(cache Managed, sizer DynamicSizer, interval time.Duration) *dynamicSizing {
+	if sizer == nil {
+		return nil
+	}
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	d := &dynamicSizing{
+		cache:    cache,
+		sizer:    sizer,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go d.run(simplelru.StatsSnapshot{Stats: cache.Stats(), At: time.Now()})
+	return d
+}
+
+// run calls the sizer every interval, starting from the cache's stats in
+// prev.
+func (d *dynamicSizing) run(prev simplelru.StatsSnapshot) {
+	defer close(d.done)
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			cur := simplelru.StatsSnapshot{Stats: d.cache.Stats(), At: now}
+			delta := cur.Delta(prev)
+			size := d.cache.Cap()
+			target := d.sizer.TargetSize(SizingStats{
+				Size:     size,
+				Len:      d.cache.Len(),
+				Stats:    cur.Stats,
+				Interval: delta.Stats,
+				Elapsed:  delta.Elapsed,
+			})
+			if target > 0 && target != size {
+				d.cache.Resize(target)
+			}
+			prev = cur
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// close stops calling the sizer, and waits for a call in progress.
+func (d *dynamicSizing) close() {
+	close(d.stop)
+	<-d.done
+}
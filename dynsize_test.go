@@ -0,0 +1,63 @@
+package lru
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDynamicSizer(t *testing.T) {
+	var calls atomic.Int32
+	var sawHits atomic.Bool
+	sizer := DynamicSizerFunc(func(stats SizingStats) int {
+		calls.Add(1)
+		if stats.Interval.Hits > 0 {
+			sawHits.Store(true)
+		}
+		if stats.Stats.Hits+stats.Stats.Misses == 0 {
+			return 0
+		}
+		// grow by one entry for every miss
+		return stats.Size + int(stats.Interval.Misses)
+	})
+	l, err := New[int, int](10, WithDynamicSizer[int, int](sizer, time.Millisecond))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Get(1)
+	for i := 0; i < 5; i++ {
+		l.Get(100 + i)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for l.Cap() != 15 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if l.Cap() != 15 {
+		t.Fatalf("expected the sizer to grow the cache, got %d", l.Cap())
+	}
+	if !sawHits.Load() {
+		t.Fatalf("expected the sizer to see the interval's hits")
+	}
+
+	l.Close()
+	n := calls.Load()
+	time.Sleep(10 * time.Millisecond)
+	if calls.Load() != n {
+		t.Fatalf("expected Close to stop the sizer")
+	}
+
+	sharded, err := NewSharded[int](64, 4, WithDynamicSizer[string, int](DynamicSizerFunc(func(stats SizingStats) int {
+		return 128
+	}), time.Millisecond))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer sharded.Close()
+	for sharded.Cap() != 128 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if sharded.Cap() != 128 {
+		t.Fatalf("expected the sizer to resize the cache, got %d", sharded.Cap())
+	}
+}
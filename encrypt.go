@@ -0,0 +1,150 @@
+package lru
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+)
+
+// EncryptionCodec is a Codec that encrypts what another codec encodes
+// with AES-GCM, for caching sensitive values such as tokens.  Given to
+// WithValueCodec, it keeps plaintext values out of snapshot files; a
+// cache created by NewEncrypted also keeps them encrypted in memory, out
+// of heap dumps.
+type EncryptionCodec[V any] struct {
+	inner Codec[V]
+	aead  cipher.AEAD
+}
+
+// NewEncryptionCodec returns a codec that encodes values with inner and
+// encrypts the result with key, which must be 16, 24 or 32 bytes long, to
+// select AES-128, AES-192 or AES-256.
+func NewEncryptionCodec[V any](inner Codec[V], key []byte) (*EncryptionCodec[V], error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptionCodec[V]{inner: inner, aead: aead}, nil
+}
+
+// Encode encodes v with the inner codec and encrypts it, with a random
+// nonce prepended.
+func (c *EncryptionCodec[V]) Encode(v V) ([]byte, error) {
+	plain, err := c.inner.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plain)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := c.aead.Seal(nonce, nonce, plain, nil)
+	clear(plain)
+	return sealed, nil
+}
+
+// Decode decrypts data, failing if it was tampered with or encrypted with
+// another key, and decodes it with the inner codec.
+func (c *EncryptionCodec[V]) Decode(data []byte) (V, error) {
+	var zero V
+	if len(data) < c.aead.NonceSize() {
+		return zero, errors.New("lru: encrypted value too short")
+	}
+	nonce, sealed := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return zero, err
+	}
+	defer clear(plain)
+	return c.inner.Decode(plain)
+}
+
+// EncryptedCache is a thread-safe cache that holds its values encrypted,
+// so that neither heap dumps nor its snapshots contain them in plaintext.
+// Values are decrypted on every Get, and the caller's copy is plaintext,
+// so it should be dropped as soon as possible.
+type EncryptedCache[K comparable, V any] struct {
+	cache *Cache[K, []byte]
+	codec *EncryptionCodec[V]
+}
+
+// NewEncrypted creates an EncryptedCache of the given size, encrypting
+// values with codec.  opts configure the underlying Cache, which sees the
+// encrypted values.
+func NewEncrypted[K comparable, V any](size int, codec *EncryptionCodec[V], opts ...Option[K, []byte]) (*EncryptedCache[K, V], error) {
+	cache, err := New[K, []byte](size, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptedCache[K, V]{cache: cache, codec: codec}, nil
+}
+
+// Cache returns the underlying cache, which holds encrypted values, for
+// its stats, persistence and so on.
+func (c *EncryptedCache[K, V]) Cache() *Cache[K, []byte] {
+	return c.cache
+}
+
+// Add encrypts value and adds it to the cache.  Returns true if an
+// eviction occurred, or the codec's error, in which case the cache is
+// left unchanged.
+func (c *EncryptedCache[K, V]) Add(key K, value V) (evicted bool, err error) {
+	sealed, err := c.codec.Encode(value)
+	if err != nil {
+		return false, err
+	}
+	return c.cache.Add(key, sealed), nil
+}
+
+// Get looks up and decrypts a key's value.  A value that fails to decrypt
+// is removed, and Get returns the error.
+func (c *EncryptedCache[K, V]) Get(key K) (value V, ok bool, err error) {
+	sealed, ok := c.cache.Get(key)
+	if !ok {
+		return value, false, nil
+	}
+	return c.decode(key, sealed)
+}
+
+// Peek returns a key's value without updating its "recently used"-ness.
+func (c *EncryptedCache[K, V]) Peek(key K) (value V, ok bool, err error) {
+	sealed, ok := c.cache.Peek(key)
+	if !ok {
+		return value, false, nil
+	}
+	return c.decode(key, sealed)
+}
+
+func (c *EncryptedCache[K, V]) decode(key K, sealed []byte) (V, bool, error) {
+	value, err := c.codec.Decode(sealed)
+	if err != nil {
+		c.cache.Remove(key)
+		return value, false, err
+	}
+	return value, true, nil
+}
+
+// Contains checks if a key is in the cache, without decrypting it.
+func (c *EncryptedCache[K, V]) Contains(key K) bool {
+	return c.cache.Contains(key)
+}
+
+// Remove removes a key from the cache.
+func (c *EncryptedCache[K, V]) Remove(key K) (present bool) {
+	return c.cache.Remove(key)
+}
+
+// Purge removes every entry from the cache.
+func (c *EncryptedCache[K, V]) Purge() {
+	c.cache.Purge()
+}
+
+// Len returns the number of entries in the cache.
+func (c *EncryptedCache[K, V]) Len() int {
+	return c.cache.Len()
+}
@@ -0,0 +1,78 @@
+package lru
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptionCodec(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	codec, err := NewEncryptionCodec[string](StringCodec{}, key)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l, err := New[string, string](128, WithValueCodec[string, string](codec))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add("token", "hunter2-secret")
+
+	var buf bytes.Buffer
+	if err := l.Save(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("hunter2")) {
+		t.Fatalf("expected the snapshot not to contain plaintext")
+	}
+	restored, err := New[string, string](128, WithValueCodec[string, string](codec))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := restored.Load(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if v, _ := restored.Get("token"); v != "hunter2-secret" {
+		t.Fatalf("bad value: %q", v)
+	}
+
+	other, _ := NewEncryptionCodec[string](StringCodec{}, bytes.Repeat([]byte{8}, 32))
+	wrongKey, _ := New[string, string](128, WithValueCodec[string, string](other))
+	if err := wrongKey.Load(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Fatalf("expected loading with the wrong key to fail")
+	}
+	if _, err := NewEncryptionCodec[string](StringCodec{}, []byte("short")); err == nil {
+		t.Fatalf("expected a bad key to fail")
+	}
+}
+
+func TestEncryptedCache(t *testing.T) {
+	codec, err := NewEncryptionCodec[string](StringCodec{}, bytes.Repeat([]byte{7}, 16))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l, err := NewEncrypted[string, string](128, codec)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := l.Add("ssn", "123-45-6789"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if v, ok, err := l.Get("ssn"); err != nil || !ok || v != "123-45-6789" {
+		t.Fatalf("bad value: %q, %v, %v", v, ok, err)
+	}
+	if sealed, _ := l.Cache().Peek("ssn"); bytes.Contains(sealed, []byte("123-45")) {
+		t.Fatalf("expected the value to be held encrypted")
+	}
+
+	// tampering is detected
+	sealed, _ := l.Cache().Peek("ssn")
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+	l.Cache().Add("ssn", tampered)
+	if _, ok, err := l.Peek("ssn"); ok || err == nil {
+		t.Fatalf("expected a tampered value to fail")
+	}
+	if l.Contains("ssn") || l.Len() != 0 {
+		t.Fatalf("expected a tampered value to be removed")
+	}
+}
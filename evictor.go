@@ -0,0 +1,111 @@
+package lru
+
+import (
+	"errors"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+// evictBatch is the most entries the background evictor evicts under one
+// hold of a lock, so that it doesn't hold up requests for long.
+const evictBatch = 64
+
+var errEvictHeadroom = errors.New("must provide a positive headroom for background eviction")
+
+// WithBackgroundEviction takes eviction off the path of Add: rather than
+// evicting to make room for a new key, Add lets the cache grow up to
+// headroom entries past its size, and wakes a goroutine, which the cache
+// starts and Close stops, to evict the excess.  If the goroutine falls
+// so far behind that the headroom is used up, Add evicts as usual.  For
+// a ShardedCache, the headroom is divided between the shards.  See
+// simplelru.WithDeferredEviction.
+func WithBackgroundEviction[K comparable, V any](headroom int) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.background = true
+		o.headroom = headroom
+	}
+}
+
+// evictor runs a cache's background evictions.
+type evictor struct {
+	trim func()
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startEvictor starts a goroutine that calls trim each time it's woken,
+// returning nil if the cache wasn't created WithBackgroundEviction.
+func startEvictor[K comparable, V any](o *options[K, V], trim func()) *evictor {
+	if !o.background {
+		return nil
+	}
+	e := &evictor{
+		trim: trim,
+		wake: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+func (e *evictor) run() {
+	defer close(e.done)
+	for {
+		select {
+		case <-e.wake:
+			e.trim()
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+// signal wakes the goroutine if it isn't already due to run, without
+// blocking.
+func (e *evictor) signal() {
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// close stops the goroutine, and waits for a trim in progress.
+func (e *evictor) close() {
+	close(e.stop)
+	<-e.done
+}
+
+// overflowing reports whether lru holds entries past its size for the
+// evictor to trim.
+func overflowing[K comparable, V any](lru *simplelru.LRU[K, V]) bool {
+	return lru.Len() > lru.Cap()
+}
+
+// trim evicts the cache's excess entries, a batch at a time.
+func (c *Cache[K, V]) trim() {
+	for {
+		c.lock.Lock()
+		n := c.lru.Trim(evictBatch)
+		c.unlock()
+		if n < evictBatch {
+			return
+		}
+	}
+}
+
+// trim evicts each shard's excess entries, a batch at a time.
+func (c *ShardedCache[V]) trim() {
+	for i := 0; i < len(c.shards); i++ {
+		shard := &c.shards[i]
+		for {
+			shard.lock()
+			n := shard.lru.Trim(evictBatch)
+			c.unlock(shard)
+			if n < evictBatch {
+				break
+			}
+		}
+	}
+}
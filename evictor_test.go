@@ -0,0 +1,76 @@
+package lru
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackgroundEviction(t *testing.T) {
+	var evictions atomic.Int32
+	onEvict := func(key, value int) { evictions.Add(1) }
+	l, err := NewWithEvict[int, int](100, onEvict, WithBackgroundEviction[int, int](50))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 140; i++ {
+		if l.Add(i, i) {
+			t.Fatalf("expected Add of %d not to evict", i)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for l.Len() != 100 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if l.Len() != 100 || l.Cap() != 100 {
+		t.Fatalf("expected the evictor to trim to 100, got len %d of %d", l.Len(), l.Cap())
+	}
+	if n := evictions.Load(); n != 40 {
+		t.Fatalf("expected 40 evictions, got %d", n)
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	sharded, err := NewSharded[int](64, 4, WithBackgroundEviction[string, int](32))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer sharded.Close()
+	for i := 0; i < 1000; i++ {
+		sharded.Add(strconv.Itoa(i), i)
+	}
+	for sharded.Len() != 64 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if sharded.Len() != 64 {
+		t.Fatalf("expected the evictor to trim to 64, got %d", sharded.Len())
+	}
+	if err := sharded.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, err := New[int, int](100, WithBackgroundEviction[int, int](0)); err == nil {
+		t.Fatalf("expected an error for no headroom")
+	}
+	if _, err := New[int, int](100, WithBackgroundEviction[int, int](10), WithoutLocking[int, int]()); err == nil {
+		t.Fatalf("expected an error WithoutLocking")
+	}
+}
+
+func BenchmarkLRU_BackgroundEviction(b *testing.B) {
+	l, err := New[int, int](8192, WithBackgroundEviction[int, int](1024))
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Add(i, i)
+	}
+}
@@ -0,0 +1,61 @@
+package lru
+
+import "errors"
+
+// ErrFrozen is returned by TryAdd when called on a frozen cache.
+var ErrFrozen = errors.New("lru: cache frozen")
+
+// Freeze puts the cache in a read-only mode, for taking a consistent
+// snapshot, draining traffic from it, or examining a misbehaving instance
+// as it was: until Thaw is called, additions and removals do nothing,
+// TryAdd returns ErrFrozen, and hits don't update entries' recency.
+// Values loaded on misses are returned but not cached.  Close still
+// works.  See simplelru.LRU.Freeze.
+func (c *Cache[K, V]) Freeze() {
+	c.lock.Lock()
+	c.lru.Freeze()
+	c.unlock()
+}
+
+// Thaw undoes Freeze.
+func (c *Cache[K, V]) Thaw() {
+	c.lock.Lock()
+	c.lru.Thaw()
+	c.unlock()
+}
+
+// Frozen reports whether the cache is frozen.
+func (c *Cache[K, V]) Frozen() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.lru.Frozen()
+}
+
+// Freeze puts every shard in the read-only mode Cache.Freeze describes,
+// one at a time, after applying any writes buffered WithWriteBuffer.
+func (c *ShardedCache[V]) Freeze() {
+	for i := 0; i < len(c.shards); i++ {
+		shard := &c.shards[i]
+		shard.lock()
+		shard.lru.Freeze()
+		c.unlock(shard)
+	}
+}
+
+// Thaw undoes Freeze.
+func (c *ShardedCache[V]) Thaw() {
+	for i := 0; i < len(c.shards); i++ {
+		shard := &c.shards[i]
+		shard.lock()
+		shard.lru.Thaw()
+		c.unlock(shard)
+	}
+}
+
+// Frozen reports whether the cache is frozen.
+func (c *ShardedCache[V]) Frozen() bool {
+	shard := &c.shards[0]
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	return shard.lru.Frozen()
+}
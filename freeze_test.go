@@ -0,0 +1,55 @@
+package lru
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	l, err := New[int, int](4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Freeze()
+	if !l.Frozen() {
+		t.Fatalf("expected the cache to be frozen")
+	}
+	l.Add(2, 2)
+	if _, err := l.TryAdd(3, 3); !errors.Is(err, ErrFrozen) {
+		t.Fatalf("expected ErrFrozen, got %v", err)
+	}
+	if l.Remove(1) || l.Len() != 1 {
+		t.Fatalf("expected the cache to be unchanged")
+	}
+	l.Thaw()
+	if _, err := l.TryAdd(3, 3); err != nil || !l.Contains(3) {
+		t.Fatalf("expected TryAdd to work after Thaw: %v", err)
+	}
+}
+
+func TestShardedFreeze(t *testing.T) {
+	l, err := NewSharded[int](64, 4, WithWriteBuffer[string, int](16, 0))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+	l.Add("a", 1)
+	// Freeze applies buffered writes before freezing
+	l.Freeze()
+	if !l.Frozen() || !l.Contains("a") {
+		t.Fatalf("expected the cache to be frozen with a in it")
+	}
+	l.Add("b", 2)
+	if _, err := l.TryAdd("c", 3); !errors.Is(err, ErrFrozen) {
+		t.Fatalf("expected ErrFrozen, got %v", err)
+	}
+	if l.Remove("a") || l.Contains("b") {
+		t.Fatalf("expected the cache to be unchanged")
+	}
+	l.Thaw()
+	l.Add("b", 2)
+	if l.Frozen() || !l.Contains("b") {
+		t.Fatalf("expected Add to work after Thaw")
+	}
+}
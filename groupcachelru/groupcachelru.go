@@ -0,0 +1,128 @@
+// Package groupcachelru adapts the approximate LRU to the shape of the
+// main and hot caches in groupcache (and its galaxycache fork), so it can
+// stand in for them.  Values are anything with a length, such as
+// groupcache.ByteView, and the cache is bounded by total bytes as well as
+// by its number of entries.
+package groupcachelru
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+// Value is a cached value that knows its size in bytes.
+// groupcache.ByteView satisfies it.
+type Value interface {
+	Len() int
+}
+
+// CacheStats are returned by stats accessors, and mirror
+// groupcache.CacheStats.
+type CacheStats struct {
+	Bytes     int64
+	Items     int64
+	Gets      int64
+	Hits      int64
+	Evictions int64
+}
+
+// Cache is a thread-safe cache bounded by the total size of its keys and
+// values.
+type Cache[V Value] struct {
+	mu       sync.Mutex
+	lru      *simplelru.LRU[string, V]
+	maxBytes int64
+	nbytes   int64
+	nget     int64
+	nhit     int64
+	nevict   int64
+}
+
+// New constructs a Cache holding up to maxEntries entries whose keys and
+// values total no more than maxBytes.  A maxBytes of 0 means the cache is
+// bounded only by maxEntries.
+func New[V Value](maxEntries int, maxBytes int64) (*Cache[V], error) {
+	if maxBytes < 0 {
+		return nil, errors.New("maxBytes must not be negative")
+	}
+	c := &Cache[V]{maxBytes: maxBytes}
+	lru, err := simplelru.NewLRU[string, V](maxEntries, c.onEvicted)
+	if err != nil {
+		return nil, err
+	}
+	c.lru = lru
+	return c, nil
+}
+
+func (c *Cache[V]) onEvicted(key string, value V) {
+	c.nbytes -= int64(len(key)) + int64(value.Len())
+	c.nevict++
+}
+
+// Add adds a value to the cache, evicting old entries as needed to stay
+// within the byte limit.
+func (c *Cache[V]) Add(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.lru.Peek(key); ok {
+		c.nbytes -= int64(old.Len())
+		c.nbytes += int64(value.Len())
+	} else {
+		c.nbytes += int64(len(key)) + int64(value.Len())
+	}
+	c.lru.Add(key, value)
+	for c.maxBytes > 0 && c.nbytes > c.maxBytes {
+		if _, _, ok := c.lru.RemoveOldest(); !ok {
+			break
+		}
+	}
+}
+
+// Get looks up a key's value from the cache.
+func (c *Cache[V]) Get(key string) (value V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nget++
+	value, ok = c.lru.Get(key)
+	if ok {
+		c.nhit++
+	}
+	return value, ok
+}
+
+// RemoveOldest evicts an old entry, as groupcache does to its hot cache
+// when the combined caches grow too large.
+func (c *Cache[V]) RemoveOldest() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.RemoveOldest()
+}
+
+// Bytes returns the total size of the cache's keys and values.
+func (c *Cache[V]) Bytes() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nbytes
+}
+
+// Items returns the number of entries in the cache.
+func (c *Cache[V]) Items() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int64(c.lru.Len())
+}
+
+// Stats returns a snapshot of the cache's counters.
+func (c *Cache[V]) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Bytes:     c.nbytes,
+		Items:     int64(c.lru.Len()),
+		Gets:      c.nget,
+		Hits:      c.nhit,
+		Evictions: c.nevict,
+	}
+}
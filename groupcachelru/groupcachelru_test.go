@@ -0,0 +1,55 @@
+package groupcachelru
+
+import (
+	"strconv"
+	"testing"
+)
+
+type byteView []byte
+
+func (b byteView) Len() int { return len(b) }
+
+func TestCache(t *testing.T) {
+	c, err := New[byteView](128, 0)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c.Add("a", byteView("hello"))
+	if v, ok := c.Get("a"); !ok || string(v) != "hello" {
+		t.Fatalf("bad value: %v, %v", v, ok)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Fatalf("expected a miss")
+	}
+	c.Add("a", byteView("hi"))
+
+	stats := c.Stats()
+	if stats.Bytes != 3 || stats.Items != 1 || stats.Gets != 2 || stats.Hits != 1 {
+		t.Fatalf("bad stats: %+v", stats)
+	}
+}
+
+func TestCacheMaxBytes(t *testing.T) {
+	c, err := New[byteView](128, 100)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		c.Add(strconv.Itoa(i%10), make(byteView, 9))
+	}
+	// 10 keys of 1 byte with 9 byte values fits exactly
+	if c.Items() != 10 || c.Bytes() != 100 {
+		t.Fatalf("bad size: %v items, %v bytes", c.Items(), c.Bytes())
+	}
+	c.Add("big", make(byteView, 50))
+	if c.Bytes() > 100 {
+		t.Fatalf("expected the cache to stay within its byte limit: %v", c.Bytes())
+	}
+	if c.Stats().Evictions == 0 {
+		t.Fatalf("expected evictions")
+	}
+	c.RemoveOldest()
+	if c.Items() == 0 {
+		t.Fatalf("expected entries left")
+	}
+}
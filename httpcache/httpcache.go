@@ -0,0 +1,422 @@
+// Package httpcache provides an http.RoundTripper that caches responses
+// to GET requests in a ShardedCache, so that HTTP clients get caching
+// without a proxy in front of them.
+//
+// It acts as a private cache, as a browser's is: responses are cached
+// unless they say no-store, stay fresh for as long as their max-age or
+// Expires say, and once stale are revalidated with If-None-Match and
+// If-Modified-Since if they have an ETag or Last-Modified.  A response
+// with a stale-while-revalidate directive is served stale for that long
+// while it's revalidated in the background.  Options override each of
+// these for servers that don't send useful headers.
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/bpowers/approx-lru"
+)
+
+const (
+	defaultMaxBodySize = 1 << 20
+
+	// StatusHeader is set on responses served from the cache, to "HIT"
+	// for fresh ones, "STALE" for stale ones being revalidated in the
+	// background, and "REVALIDATED" for ones the origin confirmed
+	// unchanged.
+	StatusHeader = "X-Cache"
+)
+
+// cacheableStatus are the status codes whose responses are cached.
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// safeMethods are the methods that don't change what they're applied to.
+var safeMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// Option configures optional behavior of a Transport.
+type Option func(t *Transport)
+
+// WithMaxBodySize sets the size of the largest response body cached.
+// Larger responses are passed through uncached.  The default is 1MiB.
+func WithMaxBodySize(n int64) Option {
+	return func(t *Transport) {
+		t.maxBody = n
+	}
+}
+
+// WithDefaultTTL caches responses that don't say how long they stay
+// fresh, with neither max-age nor Expires, for d.  By default they're
+// only cached if they can be revalidated.
+func WithDefaultTTL(d time.Duration) Option {
+	return func(t *Transport) {
+		t.defaultTTL = d
+	}
+}
+
+// WithTTL caches every response, other than no-store ones, for d,
+// ignoring what it says about its freshness.
+func WithTTL(d time.Duration) Option {
+	return func(t *Transport) {
+		t.ttl = d
+		t.forceTTL = true
+	}
+}
+
+// WithStaleWhileRevalidate serves stale responses for up to d past their
+// freshness while they're revalidated in the background, ignoring any
+// stale-while-revalidate directive they have.
+func WithStaleWhileRevalidate(d time.Duration) Option {
+	return func(t *Transport) {
+		t.swr = d
+		t.forceSWR = true
+	}
+}
+
+// WithShards sets the number of shards of the underlying ShardedCache.
+func WithShards(n int) Option {
+	return func(t *Transport) {
+		t.shards = n
+	}
+}
+
+// Transport is an http.RoundTripper that caches responses from the one
+// it wraps.
+type Transport struct {
+	next  http.RoundTripper
+	cache *lru.ShardedCache[*entry]
+
+	maxBody    int64
+	defaultTTL time.Duration
+	ttl        time.Duration
+	forceTTL   bool
+	swr        time.Duration
+	forceSWR   bool
+	shards     int
+	now        func() time.Time
+
+	// mu guards revalidating, the keys being revalidated in the
+	// background, and background counts the goroutines doing it.
+	mu           sync.Mutex
+	revalidating map[string]bool
+	background   sync.WaitGroup
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// entry is a cached response.
+type entry struct {
+	status int
+	proto  string
+	header http.Header
+	body   []byte
+	// stored is when the response was generated, by its Age on
+	// arrival, fresh how long it stays fresh after that, and swr how
+	// much longer it can be served while it's revalidated.
+	stored time.Time
+	fresh  time.Duration
+	swr    time.Duration
+	// vary holds the request headers named by the response's Vary, and
+	// their values in the request it answered.
+	vary http.Header
+}
+
+// New returns a Transport caching up to size responses from next, or
+// http.DefaultTransport if next is nil.
+func New(next http.RoundTripper, size int, opts ...Option) (*Transport, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	t := &Transport{
+		next:         next,
+		maxBody:      defaultMaxBodySize,
+		now:          time.Now,
+		revalidating: make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	cache, err := lru.NewSharded[*entry](size, t.shards)
+	if err != nil {
+		return nil, err
+	}
+	t.cache = cache
+	return t, nil
+}
+
+// Len returns the number of cached responses.
+func (t *Transport) Len() int {
+	return t.cache.Len()
+}
+
+// Purge drops every cached response.
+func (t *Transport) Purge() {
+	t.cache.Purge()
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.URL.String()
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		resp, err := t.next.RoundTrip(req)
+		if err == nil && !safeMethods[req.Method] && resp.StatusCode < 400 {
+			// a successful unsafe request invalidates what we have
+			t.cache.Remove(key)
+		}
+		return resp, err
+	}
+	reqCC := parseCacheControl(req.Header)
+	if reqCC.has("no-store") {
+		return t.next.RoundTrip(req)
+	}
+	e, ok := t.cache.Get(key)
+	if !ok || !e.varyMatches(req) {
+		return t.fetch(req, key)
+	}
+	age := t.now().Sub(e.stored)
+	switch {
+	case age < e.fresh && !reqCC.has("no-cache"):
+		return e.response(req, "HIT", age), nil
+	case age < e.fresh+e.swr && !reqCC.has("no-cache"):
+		t.revalidateInBackground(req, key, e)
+		return e.response(req, "STALE", age), nil
+	}
+	return t.revalidate(req, key, e)
+}
+
+// Wait waits for background revalidations to finish, for tests and
+// graceful shutdown.
+func (t *Transport) Wait() {
+	t.background.Wait()
+}
+
+// fetch makes req, caching the response if it can.
+func (t *Transport) fetch(req *http.Request, key string) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	return t.store(req, key, resp)
+}
+
+// revalidate makes a conditional request for e, returning it if the
+// origin says it's unchanged, or the new response otherwise.
+func (t *Transport) revalidate(req *http.Request, key string, e *entry) (*http.Response, error) {
+	etag, modified := e.header.Get("ETag"), e.header.Get("Last-Modified")
+	if etag == "" && modified == "" {
+		return t.fetch(req, key)
+	}
+	cond := req.Clone(req.Context())
+	if etag != "" {
+		cond.Header.Set("If-None-Match", etag)
+	}
+	if modified != "" {
+		cond.Header.Set("If-Modified-Since", modified)
+	}
+	resp, err := t.next.RoundTrip(cond)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusNotModified {
+		return t.store(req, key, resp)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	refreshed := e.refresh(t, resp)
+	t.cache.Add(key, refreshed)
+	return refreshed.response(req, "REVALIDATED", t.now().Sub(refreshed.stored)), nil
+}
+
+// revalidateInBackground revalidates e for req, unless that's already
+// being done.
+func (t *Transport) revalidateInBackground(req *http.Request, key string, e *entry) {
+	t.mu.Lock()
+	if t.revalidating[key] {
+		t.mu.Unlock()
+		return
+	}
+	t.revalidating[key] = true
+	t.mu.Unlock()
+	// the caller's context ends with its request, so revalidate on our
+	// own, keeping its values
+	bg := req.Clone(context.WithoutCancel(req.Context()))
+	t.background.Add(1)
+	go func() {
+		defer t.background.Done()
+		defer func() {
+			t.mu.Lock()
+			delete(t.revalidating, key)
+			t.mu.Unlock()
+		}()
+		if resp, err := t.revalidate(bg, key, e); err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}()
+}
+
+// store caches resp to req if it's cacheable, returning a response with
+// an unread body for the caller.
+func (t *Transport) store(req *http.Request, key string, resp *http.Response) (*http.Response, error) {
+	cc := parseCacheControl(resp.Header)
+	if !cacheableStatus[resp.StatusCode] || cc.has("no-store") || resp.Header.Get("Vary") == "*" {
+		return resp, nil
+	}
+	fresh, swr, ok := t.lifetime(resp.Header, cc)
+	if !ok {
+		return resp, nil
+	}
+	if resp.ContentLength > t.maxBody {
+		return resp, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, t.maxBody+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("httpcache: reading response: %w", err)
+	}
+	if int64(len(body)) > t.maxBody {
+		// too big to cache, so hand back what we read along with the rest
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	e := &entry{
+		status: resp.StatusCode,
+		proto:  resp.Proto,
+		header: resp.Header.Clone(),
+		body:   body,
+		stored: t.now().Add(-age(resp.Header)),
+		fresh:  fresh,
+		swr:    swr,
+		vary:   varyHeaders(req, resp.Header),
+	}
+	t.cache.Add(key, e)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// lifetime returns how long a response stays fresh, and then how long it
+// may be served stale while it's revalidated, or false if it shouldn't
+// be cached at all: if it says nothing of its freshness, has no default,
+// and can't be revalidated.
+func (t *Transport) lifetime(h http.Header, cc cacheControl) (fresh, swr time.Duration, ok bool) {
+	if t.forceTTL {
+		fresh = t.ttl
+	} else if fresh, ok = freshness(h, cc, t.now()); !ok {
+		fresh = t.defaultTTL
+	}
+	if t.forceSWR {
+		swr = t.swr
+	} else {
+		swr, _ = cc.seconds("stale-while-revalidate")
+	}
+	if cc.has("must-revalidate") && !t.forceSWR {
+		swr = 0
+	}
+	revalidatable := h.Get("ETag") != "" || h.Get("Last-Modified") != ""
+	return fresh, swr, fresh > 0 || revalidatable
+}
+
+// refresh returns a copy of e updated by the headers of a 304 response
+// confirming it, and freshened from now.
+func (e *entry) refresh(t *Transport, resp *http.Response) *entry {
+	refreshed := *e
+	refreshed.header = e.header.Clone()
+	for name, values := range resp.Header {
+		refreshed.header[name] = values
+	}
+	cc := parseCacheControl(refreshed.header)
+	if fresh, swr, ok := t.lifetime(refreshed.header, cc); ok {
+		refreshed.fresh, refreshed.swr = fresh, swr
+	}
+	refreshed.stored = t.now().Add(-age(resp.Header))
+	return &refreshed
+}
+
+// response returns a response to req from e, marked with status.
+func (e *entry) response(req *http.Request, status string, age time.Duration) *http.Response {
+	header := e.header.Clone()
+	header.Set(StatusHeader, status)
+	header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	proto := e.proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	major, minor, _ := http.ParseHTTPVersion(proto)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.status, http.StatusText(e.status)),
+		StatusCode:    e.status,
+		Proto:         proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+// varyHeaders returns the headers of req named by the Vary header h.
+func varyHeaders(req *http.Request, h http.Header) http.Header {
+	var vary http.Header
+	for _, line := range h.Values("Vary") {
+		for _, name := range headerFields(line) {
+			if vary == nil {
+				vary = make(http.Header)
+			}
+			vary[http.CanonicalHeaderKey(name)] = req.Header.Values(name)
+		}
+	}
+	return vary
+}
+
+// varyMatches reports whether req has the same values of the headers e
+// varies by as the request e answered.
+func (e *entry) varyMatches(req *http.Request) bool {
+	for name, values := range e.vary {
+		got := req.Header.Values(name)
+		if len(got) != len(values) {
+			return false
+		}
+		for i := range got {
+			if got[i] != values[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// headerFields splits a comma-separated header value into its trimmed,
+// non-empty fields.
+func headerFields(s string) []string {
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
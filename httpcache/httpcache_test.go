@@ -0,0 +1,232 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// origin is a test server that counts its requests, and answers them
+// with handler.
+type origin struct {
+	*httptest.Server
+	requests atomic.Int32
+}
+
+func newOrigin(t *testing.T, handler http.HandlerFunc) *origin {
+	o := &origin{}
+	o.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		o.requests.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(o.Close)
+	return o
+}
+
+// clock is a settable time source for a Transport.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTransport(t *testing.T, opts ...Option) (*Transport, *clock) {
+	tr, err := New(nil, 64, opts...)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c := &clock{now: time.Now()}
+	tr.now = func() time.Time { return c.now }
+	return tr, c
+}
+
+// get fetches url through tr, returning the response's body and its
+// cache status.
+func get(t *testing.T, tr http.RoundTripper, url string, header ...string) (body, status string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return string(b), resp.Header.Get(StatusHeader)
+}
+
+func TestFresh(t *testing.T) {
+	o := newOrigin(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		io.WriteString(w, "hello")
+	})
+	tr, clock := newTransport(t)
+	if body, status := get(t, tr, o.URL); body != "hello" || status != "" {
+		t.Fatalf("expected an uncached hello, got %q %q", body, status)
+	}
+	if body, status := get(t, tr, o.URL); body != "hello" || status != "HIT" {
+		t.Fatalf("expected a cached hello, got %q %q", body, status)
+	}
+	if n := o.requests.Load(); n != 1 {
+		t.Fatalf("expected 1 request to the origin, got %d", n)
+	}
+	// once stale, without a validator, it's fetched again
+	clock.advance(time.Minute)
+	if _, status := get(t, tr, o.URL); status != "" {
+		t.Fatalf("expected a stale response to be refetched, got %q", status)
+	}
+	if n := o.requests.Load(); n != 2 {
+		t.Fatalf("expected 2 requests to the origin, got %d", n)
+	}
+}
+
+func TestNoStore(t *testing.T) {
+	o := newOrigin(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store, max-age=60")
+		io.WriteString(w, "secret")
+	})
+	tr, _ := newTransport(t)
+	get(t, tr, o.URL)
+	get(t, tr, o.URL)
+	if n := o.requests.Load(); n != 2 || tr.Len() != 0 {
+		t.Fatalf("expected no-store responses not to be cached")
+	}
+}
+
+func TestRevalidate(t *testing.T) {
+	o := newOrigin(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		io.WriteString(w, "versioned")
+	})
+	tr, _ := newTransport(t)
+	get(t, tr, o.URL)
+	if body, status := get(t, tr, o.URL); body != "versioned" || status != "REVALIDATED" {
+		t.Fatalf("expected a revalidated response, got %q %q", body, status)
+	}
+	if n := o.requests.Load(); n != 2 {
+		t.Fatalf("expected 2 requests to the origin, got %d", n)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	var version atomic.Int32
+	o := newOrigin(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=10, stale-while-revalidate=60")
+		io.WriteString(w, strings.Repeat("v", int(version.Add(1))))
+	})
+	tr, clock := newTransport(t)
+	get(t, tr, o.URL)
+	clock.advance(20 * time.Second)
+	if body, status := get(t, tr, o.URL); body != "v" || status != "STALE" {
+		t.Fatalf("expected a stale response, got %q %q", body, status)
+	}
+	tr.Wait()
+	if body, status := get(t, tr, o.URL); body != "vv" || status != "HIT" {
+		t.Fatalf("expected the revalidated response, got %q %q", body, status)
+	}
+	// past the stale window, it's refetched before responding
+	clock.advance(time.Hour)
+	if body, status := get(t, tr, o.URL); body != "vvv" || status != "" {
+		t.Fatalf("expected a fresh response, got %q %q", body, status)
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	big := strings.Repeat("x", 100)
+	o := newOrigin(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		// flush so the body is chunked, with no Content-Length
+		io.WriteString(w, big[:50])
+		w.(http.Flusher).Flush()
+		io.WriteString(w, big[50:])
+	})
+	tr, _ := newTransport(t, WithMaxBodySize(64))
+	if body, _ := get(t, tr, o.URL); body != big {
+		t.Fatalf("expected the whole body, got %d bytes", len(body))
+	}
+	if tr.Len() != 0 {
+		t.Fatalf("expected a large body not to be cached")
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	o := newOrigin(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=0")
+		io.WriteString(w, "uncooperative")
+	})
+	tr, clock := newTransport(t, WithTTL(time.Minute))
+	get(t, tr, o.URL)
+	clock.advance(30 * time.Second)
+	if _, status := get(t, tr, o.URL); status != "HIT" {
+		t.Fatalf("expected WithTTL to override max-age, got %q", status)
+	}
+
+	o = newOrigin(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "silent")
+	})
+	tr, _ = newTransport(t)
+	get(t, tr, o.URL)
+	if tr.Len() != 0 {
+		t.Fatalf("expected a response without freshness or validators not to be cached")
+	}
+	tr, _ = newTransport(t, WithDefaultTTL(time.Minute))
+	get(t, tr, o.URL)
+	if _, status := get(t, tr, o.URL); status != "HIT" {
+		t.Fatalf("expected WithDefaultTTL to cache it, got %q", status)
+	}
+}
+
+func TestVary(t *testing.T) {
+	o := newOrigin(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		io.WriteString(w, r.Header.Get("Accept-Language"))
+	})
+	tr, _ := newTransport(t)
+	get(t, tr, o.URL, "Accept-Language", "en")
+	if body, status := get(t, tr, o.URL, "Accept-Language", "fr"); body != "fr" || status != "" {
+		t.Fatalf("expected a different language to miss, got %q %q", body, status)
+	}
+	if body, status := get(t, tr, o.URL, "Accept-Language", "fr"); body != "fr" || status != "HIT" {
+		t.Fatalf("expected the same language to hit, got %q %q", body, status)
+	}
+}
+
+func TestUnsafeInvalidates(t *testing.T) {
+	o := newOrigin(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+	})
+	tr, _ := newTransport(t)
+	get(t, tr, o.URL)
+	req, err := http.NewRequest(http.MethodPost, o.URL, strings.NewReader("update"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+	if tr.Len() != 0 {
+		t.Fatalf("expected a POST to invalidate the cached GET")
+	}
+}
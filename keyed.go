@@ -0,0 +1,139 @@
+package lru
+
+import (
+	"bytes"
+	"hash/maphash"
+	"sync/atomic"
+)
+
+// Hasher hashes and compares keys of a type that can't be used as a map
+// key, such as a slice, or that is expensive to, such as a large struct,
+// for a KeyedCache.  Keys that are Equal must have the same Hash.
+type Hasher[K any] interface {
+	Hash(key K) uint64
+	Equal(a, b K) bool
+}
+
+// BytesHasher is a Hasher for []byte keys.  Its zero value is ready to
+// use; it seeds its hash the first time it's used.
+type BytesHasher struct {
+	seed atomic.Pointer[maphash.Seed]
+}
+
+func (h *BytesHasher) Hash(key []byte) uint64 {
+	seed := h.seed.Load()
+	if seed == nil {
+		s := maphash.MakeSeed()
+		h.seed.CompareAndSwap(nil, &s)
+		seed = h.seed.Load()
+	}
+	return maphash.Bytes(*seed, key)
+}
+
+func (h *BytesHasher) Equal(a, b []byte) bool {
+	return bytes.Equal(a, b)
+}
+
+// KeyedEntry is what a KeyedCache stores under a key's hash, so that a
+// lookup can tell its key from another with the same hash.
+type KeyedEntry[K, V any] struct {
+	Key   K
+	Value V
+}
+
+// KeyedCache is a thread-safe cache keyed by a type that isn't
+// comparable, using a Hasher instead of building a comparable encoding of
+// each key.  Entries are stored under their key's hash, so two keys with
+// the same hash share a slot: adding one replaces the other, as if it had
+// been evicted.  With a 64-bit hash that is rare enough not to matter to
+// the hit ratio.
+type KeyedCache[K, V any] struct {
+	cache  *Cache[uint64, KeyedEntry[K, V]]
+	hasher Hasher[K]
+
+	collisions atomic.Uint64
+}
+
+// NewKeyed creates a KeyedCache of the given size, hashing and comparing
+// keys with hasher.  opts configure the underlying Cache, which is keyed
+// by hash.
+func NewKeyed[K, V any](size int, hasher Hasher[K], opts ...Option[uint64, KeyedEntry[K, V]]) (*KeyedCache[K, V], error) {
+	cache, err := New[uint64, KeyedEntry[K, V]](size, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyedCache[K, V]{cache: cache, hasher: hasher}, nil
+}
+
+// Cache returns the underlying cache, which is keyed by hash, for its
+// stats, persistence and so on.
+func (c *KeyedCache[K, V]) Cache() *Cache[uint64, KeyedEntry[K, V]] {
+	return c.cache
+}
+
+// Add adds a value to the cache, replacing any entry for a key with the
+// same hash.  Returns true if an eviction occurred.
+func (c *KeyedCache[K, V]) Add(key K, value V) (evicted bool) {
+	h := c.hasher.Hash(key)
+	if prev, ok := c.cache.Peek(h); ok && !c.hasher.Equal(prev.Key, key) {
+		c.collisions.Add(1)
+	}
+	return c.cache.Add(h, KeyedEntry[K, V]{key, value})
+}
+
+// Get looks up a key's value from the cache.
+func (c *KeyedCache[K, V]) Get(key K) (value V, ok bool) {
+	return c.match(key, c.cache.Get)
+}
+
+// Peek returns a key's value without updating its "recently used"-ness.
+func (c *KeyedCache[K, V]) Peek(key K) (value V, ok bool) {
+	return c.match(key, c.cache.Peek)
+}
+
+// Contains reports whether key is in the cache, without updating its
+// "recently used"-ness.
+func (c *KeyedCache[K, V]) Contains(key K) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+// match looks key's hash up with lookup, and returns the value found if
+// it was stored for key rather than for another key with the same hash.
+func (c *KeyedCache[K, V]) match(key K, lookup func(uint64) (KeyedEntry[K, V], bool)) (value V, ok bool) {
+	ent, ok := lookup(c.hasher.Hash(key))
+	if !ok {
+		return value, false
+	}
+	if !c.hasher.Equal(ent.Key, key) {
+		c.collisions.Add(1)
+		return value, false
+	}
+	return ent.Value, true
+}
+
+// Remove removes key from the cache, returning whether it was present.
+func (c *KeyedCache[K, V]) Remove(key K) (present bool) {
+	h := c.hasher.Hash(key)
+	if ent, ok := c.cache.Peek(h); !ok || !c.hasher.Equal(ent.Key, key) {
+		return false
+	}
+	return c.cache.Remove(h)
+}
+
+// Purge is used to completely clear the cache.
+func (c *KeyedCache[K, V]) Purge() {
+	c.cache.Purge()
+}
+
+// Len returns the number of items in the cache.
+func (c *KeyedCache[K, V]) Len() int {
+	return c.cache.Len()
+}
+
+// Collisions returns the number of times a key was looked up or added
+// while another key with the same hash held its slot.  If it's more than
+// a tiny fraction of lookups, the Hasher is hashing poorly.
+func (c *KeyedCache[K, V]) Collisions() uint64 {
+	return c.collisions.Load()
+}
@@ -0,0 +1,76 @@
+package lru
+
+import (
+	"testing"
+)
+
+// collidingHasher hashes every key of the same length alike.
+type collidingHasher struct{}
+
+func (collidingHasher) Hash(key []int) uint64 { return uint64(len(key)) }
+
+func (collidingHasher) Equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestKeyedCache(t *testing.T) {
+	l, err := NewKeyed[[]byte, int](128, &BytesHasher{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add([]byte("a"), 1)
+	l.Add([]byte("b"), 2)
+
+	// keys are compared by content, not identity
+	if v, ok := l.Get([]byte("a")); !ok || v != 1 {
+		t.Fatalf("bad value: %v, %v", v, ok)
+	}
+	if v, ok := l.Peek([]byte("b")); !ok || v != 2 {
+		t.Fatalf("bad value: %v, %v", v, ok)
+	}
+	if l.Contains([]byte("c")) {
+		t.Fatalf("expected c to be missing")
+	}
+	if !l.Remove([]byte("a")) || l.Contains([]byte("a")) || l.Len() != 1 {
+		t.Fatalf("expected a to be removed")
+	}
+	if l.Collisions() != 0 {
+		t.Fatalf("unexpected collisions: %d", l.Collisions())
+	}
+}
+
+func TestKeyedCacheCollisions(t *testing.T) {
+	l, err := NewKeyed[[]int, string](128, collidingHasher{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add([]int{1, 2}, "a")
+
+	// a key with the same hash misses, and can't remove the other
+	if _, ok := l.Get([]int{3, 4}); ok {
+		t.Fatalf("expected a colliding key to miss")
+	}
+	if l.Remove([]int{3, 4}) || !l.Contains([]int{1, 2}) {
+		t.Fatalf("expected a colliding key not to remove the other")
+	}
+
+	// adding it replaces the other
+	l.Add([]int{3, 4}, "b")
+	if v, ok := l.Get([]int{3, 4}); !ok || v != "b" {
+		t.Fatalf("bad value: %v, %v", v, ok)
+	}
+	if l.Contains([]int{1, 2}) || l.Len() != 1 {
+		t.Fatalf("expected the colliding key to be replaced")
+	}
+	if n := l.Collisions(); n != 3 {
+		t.Fatalf("expected 3 collisions, got %d", n)
+	}
+}
@@ -0,0 +1,55 @@
+package lru
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+// LatencyStats holds histograms of how long Add and Get took, in
+// nanoseconds, including time spent waiting for locks.
+type LatencyStats struct {
+	Add simplelru.Histogram
+	Get simplelru.Histogram
+}
+
+// Merge adds the counts in other to s.
+func (s *LatencyStats) Merge(other *LatencyStats) {
+	s.Add.Merge(&other.Add)
+	s.Get.Merge(&other.Get)
+}
+
+// latencyRecorder is updated without holding the cache lock, since the
+// point is to measure how long acquiring it takes.
+type latencyRecorder struct {
+	add atomicHistogram
+	get atomicHistogram
+}
+
+func (r *latencyRecorder) stats() LatencyStats {
+	return LatencyStats{
+		Add: r.add.snapshot(),
+		Get: r.get.snapshot(),
+	}
+}
+
+type atomicHistogram struct {
+	buckets [simplelru.HistogramBuckets]uint64
+}
+
+// since records the time elapsed since start.
+func (h *atomicHistogram) since(start time.Time) {
+	ns := time.Since(start)
+	if ns < 0 {
+		ns = 0
+	}
+	atomic.AddUint64(&h.buckets[simplelru.HistogramBucket(uint64(ns))], 1)
+}
+
+func (h *atomicHistogram) snapshot() (s simplelru.Histogram) {
+	for i := range h.buckets {
+		s.Buckets[i] = atomic.LoadUint64(&h.buckets[i])
+	}
+	return s
+}
@@ -0,0 +1,64 @@
+package lru
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestLatencyStats(t *testing.T) {
+	l, err := New[int, int](128, WithLatencyTracking[int, int]())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		l.Add(i, i)
+	}
+	for i := 0; i < 20; i++ {
+		l.Get(i)
+	}
+
+	stats := l.LatencyStats()
+	if stats.Add.Count() != 10 || stats.Get.Count() != 20 {
+		t.Fatalf("bad latency counts: add=%d get=%d", stats.Add.Count(), stats.Get.Count())
+	}
+}
+
+func TestLatencyStatsDisabled(t *testing.T) {
+	l, err := New[int, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Get(1)
+	if stats := l.LatencyStats(); stats.Add.Count() != 0 || stats.Get.Count() != 0 {
+		t.Fatalf("expected no latencies to be recorded")
+	}
+}
+
+func TestShardedLatencyStats(t *testing.T) {
+	l, err := NewSharded[int](128, 4, WithLatencyTracking[string, int]())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		l.Add(strconv.Itoa(i), i)
+		l.Get(strconv.Itoa(i))
+	}
+
+	shards := l.ShardLatencyStats()
+	if len(shards) != 4 {
+		t.Fatalf("expected stats for 4 shards, got %d", len(shards))
+	}
+	var adds uint64
+	for _, s := range shards {
+		adds += s.Add.Count()
+	}
+	if adds != 100 {
+		t.Fatalf("expected 100 adds across shards, got %d", adds)
+	}
+	if stats := l.LatencyStats(); stats.Get.Count() != 100 {
+		t.Fatalf("expected 100 gets, got %d", stats.Get.Count())
+	}
+}
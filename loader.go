@@ -0,0 +1,519 @@
+package lru
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Loader loads the value for a key that is missing from a cache.
+type Loader[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// ErrNoLoader is returned by GetOrLoad on a cache created without
+// WithLoader or WithBatchLoader.
+var ErrNoLoader = errors.New("lru: no loader configured")
+
+// ErrNotLoaded is returned by GetOrLoad for a key that was loaded by the
+// loader given WithBatchLoader, which returned no value for it.
+var ErrNotLoaded = errors.New("lru: loader returned no value")
+
+// WithLoader makes the cache read-through: Get calls load to populate
+// misses, and adds the loaded value to the cache.  Concurrent misses for
+// the same key share a single call to load.  Errors from load are not
+// cached; Get reports them as a miss, and GetOrLoad returns them.  If
+// load panics, every caller waiting on it panics.
+func WithLoader[K comparable, V any](load Loader[K, V]) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.loader = load
+	}
+}
+
+// WithBatchLoader lets GetOrLoadMany load all of the keys it misses with
+// a single call to load, for backends that support multi-gets.  Keys
+// being loaded in a batch are shared with concurrent loads of them, in
+// either direction, as WithLoader's are.  If the cache isn't also created
+// WithLoader, single keys are loaded with batches of one.
+func WithBatchLoader[K comparable, V any](load BulkLoader[K, V]) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.batchLoader = load
+	}
+}
+
+// loadGroup deduplicates concurrent loads of the same key.
+type loadGroup[K comparable, V any] struct {
+	load  Loader[K, V]
+	batch BulkLoader[K, V]
+	mu    sync.Mutex
+	calls map[K]*loadCall[V]
+
+	// negative holds keys recently found not to exist, if the cache was
+	// created WithNegativeFilter.
+	negative *negativeFilter[K]
+}
+
+type loadCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+
+	// waiters is the number of callers still waiting for the load, which
+	// is canceled when they have all given up.  It is protected by the
+	// group's mutex.
+	waiters int
+	cancel  context.CancelFunc
+
+	// panicked holds what the loader panicked with, if it did, to be
+	// re-raised by every caller waiting on the load.
+	panicked any
+
+	// batch is set for the calls of a batch load, which is canceled once
+	// every caller waiting on any of them has given up.
+	batch *loadBatch
+
+	// storing is set, under the group's mutex, once the loaded value is
+	// about to be added, which happens without the mutex held so that
+	// eviction callbacks can call back into the cache.  From then on the
+	// call can't be abandoned, so no newer load can store its value
+	// first, only for it to be overwritten with this one.
+	storing bool
+}
+
+// loadBatch tracks the callers waiting on the calls of a batch load.  It
+// is protected by the group's mutex.
+type loadBatch struct {
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// abandoned reports whether every caller waiting on call's batch has
+// given up, in which case the call should be ignored as it will be
+// canceled.  Abandoned calls that aren't part of a batch are removed from
+// the group straight away.
+func (call *loadCall[V]) abandoned() bool {
+	return call.batch != nil && call.batch.waiters == 0 && !call.storing
+}
+
+// join adds a caller waiting on call.
+func (call *loadCall[V]) join() {
+	call.waiters++
+	if call.batch != nil {
+		call.batch.waiters++
+	}
+}
+
+func newLoadGroup[K comparable, V any](load Loader[K, V], batch BulkLoader[K, V]) *loadGroup[K, V] {
+	if load == nil && batch == nil {
+		return nil
+	}
+	g := &loadGroup[K, V]{
+		load:  load,
+		batch: batch,
+		calls: make(map[K]*loadCall[V]),
+	}
+	if load == nil {
+		g.load = g.loadOne
+	}
+	return g
+}
+
+// loadOne loads key with a batch of one.
+func (g *loadGroup[K, V]) loadOne(ctx context.Context, key K) (V, error) {
+	values, err := g.batch(ctx, []K{key})
+	value, ok := values[key]
+	if err == nil && !ok {
+		err = ErrNotLoaded
+	}
+	return value, err
+}
+
+// do loads key, or waits for a concurrent load of it to finish.  The
+// loader runs in its own goroutine with a context that keeps ctx's values
+// but not its cancellation, so that one caller giving up doesn't fail the
+// load for the others; it is canceled only once every caller waiting on it
+// has given up.  Before calling the loader, peek checks that another load
+// didn't just finish, and the loaded value is stored with add, which must
+// pin it, before anyone else can start a new load.  It stays pinned until
+// the load is done, so that a flurry of concurrent additions can't evict
+// it before the callers waiting on it are woken.  If the loader panics,
+// every caller waiting on it panics with the same value.
+func (g *loadGroup[K, V]) do(ctx context.Context, key K, peek func(K) (V, bool), add func(K, V) bool, unpin func(K)) (V, error) {
+	if err := ctx.Err(); err != nil {
+		var zero V
+		return zero, err
+	}
+	if g.negative != nil && g.negative.contains(key) {
+		var zero V
+		return zero, ErrNotFound
+	}
+	g.mu.Lock()
+	call, ok := g.calls[key]
+	if ok && !call.abandoned() {
+		call.join()
+	} else {
+		var loadCtx context.Context
+		call = &loadCall[V]{done: make(chan struct{}), waiters: 1}
+		loadCtx, call.cancel = context.WithCancel(context.WithoutCancel(ctx))
+		g.calls[key] = call
+		go g.run(loadCtx, key, call, peek, add, unpin)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		if call.panicked != nil {
+			panic(call.panicked)
+		}
+		return call.value, call.err
+	case <-ctx.Done():
+		g.leave(key, call)
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// leave removes a caller that gave up waiting on call, canceling the load
+// if it was the last.
+func (g *loadGroup[K, V]) leave(key K, call *loadCall[V]) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	call.waiters--
+	if call.batch != nil {
+		call.batch.waiters--
+		if call.batch.waiters == 0 {
+			call.cancel()
+		}
+		return
+	}
+	if call.waiters == 0 {
+		call.cancel()
+		// let the next caller start afresh rather than wait on a
+		// canceled load, unless it's already storing its value
+		if g.calls[key] == call && !call.storing {
+			delete(g.calls, key)
+		}
+	}
+}
+
+// doMany loads keys, which must be distinct, with a single call to the
+// batch loader, sharing loads of them already in flight, as do does for a
+// single key.  Keys the batch loader returns no value for, or that aren't
+// found, are left out of the result.  If the group has no batch loader,
+// keys are loaded one at a time with do.
+func (g *loadGroup[K, V]) doMany(ctx context.Context, keys []K, peek func(K) (V, bool), add func(K, V) bool, unpin func(K)) (map[K]V, error) {
+	values := make(map[K]V, len(keys))
+	if g.batch == nil {
+		for _, key := range keys {
+			value, err := g.do(ctx, key, peek, add, unpin)
+			if notFound(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			values[key] = value
+		}
+		return values, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if g.negative != nil {
+		found := keys[:0:0]
+		for _, key := range keys {
+			if !g.negative.contains(key) {
+				found = append(found, key)
+			}
+		}
+		keys = found
+	}
+
+	batch := &loadBatch{}
+	loadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	batch.cancel = cancel
+	calls := make([]*loadCall[V], len(keys))
+	var mine []K
+	var mineCalls []*loadCall[V]
+	g.mu.Lock()
+	for i, key := range keys {
+		call, ok := g.calls[key]
+		if ok && !call.abandoned() {
+			call.join()
+		} else {
+			call = &loadCall[V]{done: make(chan struct{}), cancel: cancel, batch: batch}
+			call.join()
+			g.calls[key] = call
+			mine = append(mine, key)
+			mineCalls = append(mineCalls, call)
+		}
+		calls[i] = call
+	}
+	g.mu.Unlock()
+	if len(mine) > 0 {
+		go g.runMany(loadCtx, mine, mineCalls, peek, add, unpin)
+	} else {
+		cancel()
+	}
+
+	for i, call := range calls {
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			for j := i; j < len(calls); j++ {
+				g.leave(keys[j], calls[j])
+			}
+			return nil, ctx.Err()
+		}
+		if call.panicked != nil {
+			panic(call.panicked)
+		}
+		switch {
+		case call.err == nil:
+			values[keys[i]] = call.value
+		case notFound(call.err):
+		default:
+			return nil, call.err
+		}
+	}
+	return values, nil
+}
+
+// runMany is run for a batch of keys and their calls.
+func (g *loadGroup[K, V]) runMany(ctx context.Context, keys []K, calls []*loadCall[V], peek func(K) (V, bool), add func(K, V) bool, unpin func(K)) {
+	var added []K
+	defer func() {
+		r := recover()
+		g.mu.Lock()
+		for i, key := range keys {
+			if g.calls[key] == calls[i] {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		calls[0].cancel()
+		for _, key := range added {
+			unpin(key)
+		}
+		for _, call := range calls {
+			if r != nil {
+				call.panicked = r
+			}
+			close(call.done)
+		}
+	}()
+
+	var need []K
+	var needCalls []*loadCall[V]
+	for i, key := range keys {
+		if value, ok := peek(key); ok {
+			calls[i].value = value
+		} else {
+			need = append(need, key)
+			needCalls = append(needCalls, calls[i])
+		}
+	}
+	if len(need) == 0 {
+		return
+	}
+	values, err := g.batch(ctx, need)
+	var store []K
+	var storeValues []V
+	g.mu.Lock()
+	for i, key := range need {
+		call := needCalls[i]
+		if err != nil {
+			call.err = err
+			continue
+		}
+		value, ok := values[key]
+		if !ok {
+			call.err = ErrNotLoaded
+			if g.negative != nil {
+				g.negative.add(key)
+			}
+			continue
+		}
+		call.value = value
+		// as in run, don't overwrite a fresher value stored by a newer
+		// load if every caller gave up on this one
+		if ctx.Err() == nil && g.calls[key] == call {
+			call.storing = true
+			store = append(store, key)
+			storeValues = append(storeValues, value)
+		}
+	}
+	g.mu.Unlock()
+	for i, key := range store {
+		add(key, storeValues[i])
+		added = append(added, key)
+	}
+}
+
+func (g *loadGroup[K, V]) run(ctx context.Context, key K, call *loadCall[V], peek func(K) (V, bool), add func(K, V) bool, unpin func(K)) {
+	added := false
+	defer func() {
+		if r := recover(); r != nil {
+			call.panicked = r
+		}
+		g.mu.Lock()
+		if g.calls[key] == call {
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		call.cancel()
+		if added {
+			unpin(key)
+		}
+		close(call.done)
+	}()
+
+	if value, ok := peek(key); ok {
+		call.value = value
+		return
+	}
+	value, err := g.load(ctx, key)
+	if err != nil {
+		call.err = err
+		if g.negative != nil && notFound(err) {
+			g.negative.add(key)
+		}
+		return
+	}
+	call.value = value
+	g.mu.Lock()
+	// if every caller gave up on this load, a newer one may have stored
+	// a fresher value that we mustn't overwrite.
+	store := ctx.Err() == nil && g.calls[key] == call
+	call.storing = store
+	g.mu.Unlock()
+	// add runs eviction callbacks, which may call back into the cache,
+	// so it's called without the mutex held
+	if store {
+		add(key, value)
+		added = true
+	}
+}
+
+// GetOrLoad looks up a key's value from the cache, loading it on a miss
+// with the loader given WithLoader.  ctx is passed to the loader, and if
+// it is done before the value is loaded, GetOrLoad returns ctx.Err().
+// The load itself is only canceled once every caller waiting on it has
+// given up, so a canceled caller doesn't fail the load for others.
+func (c *Cache[K, V]) GetOrLoad(ctx context.Context, key K) (V, error) {
+	if value, ok := c.get(key); ok {
+		return value, nil
+	}
+	if c.loads == nil {
+		var zero V
+		return zero, ErrNoLoader
+	}
+	if c.isClosed() {
+		var zero V
+		return zero, ErrClosed
+	}
+	value, err := c.loads.do(ctx, key, c.peek, c.addPinned, c.Unpin)
+	if err != nil {
+		return value, err
+	}
+	return c.cloned(value), nil
+}
+
+// GetOrLoadMany looks up the values for keys from the cache, loading those
+// it misses with a single call to the loader given WithBatchLoader, or one
+// call each to the loader given WithLoader otherwise.  Keys the batch
+// loader returns no value for are left out of the result.  ctx is treated
+// as by GetOrLoad.
+func (c *Cache[K, V]) GetOrLoadMany(ctx context.Context, keys []K) (map[K]V, error) {
+	values := make(map[K]V, len(keys))
+	missing := missingKeys(keys, values, c.get)
+	if len(missing) == 0 {
+		return values, nil
+	}
+	if c.loads == nil {
+		return nil, ErrNoLoader
+	}
+	if c.isClosed() {
+		return nil, ErrClosed
+	}
+	loaded, err := c.loads.doMany(ctx, missing, c.peek, c.addPinned, c.Unpin)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range loaded {
+		values[key] = c.cloned(value)
+	}
+	return values, nil
+}
+
+// missingKeys looks keys up with get, storing those it finds in values and
+// returning the distinct keys it doesn't.
+func missingKeys[K comparable, V any](keys []K, values map[K]V, get func(K) (V, bool)) []K {
+	var missing []K
+	seen := make(map[K]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		if value, ok := get(key); ok {
+			values[key] = value
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+// GetOrLoad looks up a key's value from the cache, loading it on a miss
+// with the loader given WithLoader.  See Cache.GetOrLoad.
+func (c *ShardedCache[V]) GetOrLoad(ctx context.Context, key string) (V, error) {
+	if value, ok := c.get(key); ok {
+		return value, nil
+	}
+	if c.loads == nil {
+		var zero V
+		return zero, ErrNoLoader
+	}
+	if c.closed.Load() {
+		var zero V
+		return zero, ErrClosed
+	}
+	value, err := c.loads.do(ctx, key, c.peek, c.addPinned, c.Unpin)
+	if err != nil {
+		return value, err
+	}
+	return c.cloned(value), nil
+}
+
+// GetOrLoadMany looks up the values for keys from the cache, loading those
+// it misses as Cache.GetOrLoadMany does.
+func (c *ShardedCache[V]) GetOrLoadMany(ctx context.Context, keys []string) (map[string]V, error) {
+	values, missing := c.GetMany(keys)
+	if len(missing) == 0 {
+		return values, nil
+	}
+	if c.loads == nil {
+		return nil, ErrNoLoader
+	}
+	if c.closed.Load() {
+		return nil, ErrClosed
+	}
+	loaded, err := c.loads.doMany(ctx, missing, c.peek, c.addPinned, c.Unpin)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range loaded {
+		values[key] = c.cloned(value)
+	}
+	return values, nil
+}
+
+// cancelAll cancels every load in flight, for closing the cache.  Callers
+// waiting on them still wait for the loader to return.
+func (g *loadGroup[K, V]) cancelAll() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for key, call := range g.calls {
+		call.cancel()
+		delete(g.calls, key)
+	}
+}
@@ -0,0 +1,431 @@
+package lru
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoader(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	load := func(ctx context.Context, key int) (int, error) {
+		calls.Add(1)
+		<-release
+		return key * 2, nil
+	}
+	l, err := New[int, int](128, WithLoader[int, int](load))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, ok := l.Get(21); !ok || v != 42 {
+				t.Errorf("bad value: %v, %v", v, ok)
+			}
+		}()
+	}
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected 1 loader call, got %d", n)
+	}
+	if v, ok := l.Peek(21); !ok || v != 42 {
+		t.Fatalf("expected the loaded value to be cached")
+	}
+}
+
+func TestLoaderError(t *testing.T) {
+	calls := 0
+	load := func(ctx context.Context, key string) (int, error) {
+		calls++
+		return 0, errors.New("boom")
+	}
+	l, err := NewSharded[int](1024, 16, WithLoader[string, int](load))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := l.Get("a"); ok {
+		t.Fatalf("expected a miss")
+	}
+	if _, ok := l.Get("a"); ok {
+		t.Fatalf("expected a miss")
+	}
+	if calls != 2 || l.Contains("a") {
+		t.Fatalf("expected errors not to be cached")
+	}
+}
+
+func TestLoaderPanic(t *testing.T) {
+	l, err := New[int, int](128, WithLoader[int, int](func(ctx context.Context, key int) (int, error) {
+		panic("boom")
+	}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("expected the loader's panic, got %v", r)
+			}
+		}()
+		l.Get(1)
+	}()
+	if len(l.loads.calls) != 0 {
+		t.Fatalf("expected the panicking load to be cleaned up")
+	}
+}
+
+func TestGetOrLoadNoLoader(t *testing.T) {
+	l, err := New[int, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := l.GetOrLoad(context.Background(), 1); err != ErrNoLoader {
+		t.Fatalf("expected ErrNoLoader, got %v", err)
+	}
+	l.Add(1, 1)
+	if v, err := l.GetOrLoad(context.Background(), 1); err != nil || v != 1 {
+		t.Fatalf("bad value: %v, %v", v, err)
+	}
+}
+
+func TestGetOrLoadCancel(t *testing.T) {
+	release := make(chan struct{})
+	loadErr := make(chan error, 1)
+	load := func(ctx context.Context, key string) (int, error) {
+		if key != "a" {
+			<-ctx.Done()
+			loadErr <- ctx.Err()
+			return 0, ctx.Err()
+		}
+		<-release
+		return 42, nil
+	}
+	l, err := NewSharded[int](1024, 16, WithLoader[string, int](load))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// one caller giving up doesn't fail the load for another
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error)
+	go func() {
+		_, err := l.GetOrLoad(ctx, "a")
+		canceled <- err
+	}()
+	waited := make(chan int)
+	go func() {
+		v, err := l.GetOrLoad(context.Background(), "a")
+		if err != nil {
+			t.Errorf("err: %v", err)
+		}
+		waited <- v
+	}()
+	for !loadInFlight(l.loads, "a", 2) {
+		runtime.Gosched()
+	}
+	cancel()
+	if err := <-canceled; err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	close(release)
+	if v := <-waited; v != 42 {
+		t.Fatalf("bad value: %v", v)
+	}
+
+	// a caller that has already given up doesn't start a load
+	if _, err := l.GetOrLoad(ctx, "b"); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(l.loads.calls) != 0 {
+		t.Fatalf("expected no load to start")
+	}
+
+	// the load is canceled when every caller has given up
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		for !loadInFlight(l.loads, "b", 1) {
+			runtime.Gosched()
+		}
+		cancel()
+	}()
+	if _, err := l.GetOrLoad(ctx, "b"); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if err := <-loadErr; err != context.Canceled {
+		t.Fatalf("expected the load to be canceled, got %v", err)
+	}
+	if l.Contains("b") {
+		t.Fatalf("expected the canceled load not to be cached")
+	}
+}
+
+func TestGetOrLoadAbandoned(t *testing.T) {
+	// a load whose callers all gave up mustn't overwrite the value from
+	// a newer load, even if its loader ignores ctx.
+	started, release := make(chan struct{}), make(chan struct{})
+	var calls atomic.Int32
+	load := func(ctx context.Context, key int) (int, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-release
+			return 1, nil
+		}
+		return 2, nil
+	}
+	l, err := New[int, int](128, WithLoader[int, int](load))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	abandoned := make(chan *loadCall[int], 1)
+	go func() {
+		<-started
+		l.loads.mu.Lock()
+		abandoned <- l.loads.calls[1]
+		l.loads.mu.Unlock()
+		cancel()
+	}()
+	if _, err := l.GetOrLoad(ctx, 1); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if v, err := l.GetOrLoad(context.Background(), 1); err != nil || v != 2 {
+		t.Fatalf("bad value: %v, %v", v, err)
+	}
+
+	close(release)
+	<-(<-abandoned).done
+	if v, ok := l.Peek(1); !ok || v != 2 {
+		t.Fatalf("expected the abandoned load not to overwrite 2, got %v", v)
+	}
+}
+
+func loadInFlight[K comparable, V any](g *loadGroup[K, V], key K, waiters int) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	call, ok := g.calls[key]
+	return ok && call.waiters == waiters
+}
+
+func TestLoaderUnpins(t *testing.T) {
+	l, err := New[int, int](2, WithLoader[int, int](func(ctx context.Context, key int) (int, error) {
+		return key, nil
+	}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// loaded entries are only pinned until the load is done,
+	// so the loaded entry is the oldest and the first to go
+	if v, ok := l.Get(1); !ok || v != 1 {
+		t.Fatalf("bad value: %v, %v", v, ok)
+	}
+	l.Add(2, 2)
+	l.Add(3, 3)
+	if l.Contains(1) || !l.Contains(2) {
+		t.Fatalf("expected the loaded entry to have been unpinned")
+	}
+}
+
+func TestBatchLoader(t *testing.T) {
+	var batches atomic.Int32
+	release := make(chan struct{})
+	batch := func(ctx context.Context, keys []string) (map[string]int, error) {
+		batches.Add(1)
+		<-release
+		values := make(map[string]int)
+		for _, k := range keys {
+			if n, err := strconv.Atoi(k); err == nil {
+				values[k] = n
+			}
+		}
+		return values, nil
+	}
+	l, err := NewSharded[int](1024, 16, WithBatchLoader[string, int](batch))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add("1", 1)
+
+	done := make(chan map[string]int)
+	go func() {
+		values, err := l.GetOrLoadMany(context.Background(), []string{"1", "2", "3", "3", "x"})
+		if err != nil {
+			t.Errorf("err: %v", err)
+		}
+		done <- values
+	}()
+	for !loadInFlight(l.loads, "2", 1) {
+		runtime.Gosched()
+	}
+	// a concurrent single-key load of a key in the batch shares it
+	single := make(chan int)
+	go func() {
+		v, err := l.GetOrLoad(context.Background(), "3")
+		if err != nil {
+			t.Errorf("err: %v", err)
+		}
+		single <- v
+	}()
+	for !loadInFlight(l.loads, "3", 2) {
+		runtime.Gosched()
+	}
+	close(release)
+
+	values := <-done
+	if len(values) != 3 || values["1"] != 1 || values["2"] != 2 || values["3"] != 3 {
+		t.Fatalf("bad values: %v", values)
+	}
+	if v := <-single; v != 3 {
+		t.Fatalf("bad value: %v", v)
+	}
+	if n := batches.Load(); n != 1 {
+		t.Fatalf("expected 1 batch, got %d", n)
+	}
+	if !l.Contains("2") || l.Contains("x") {
+		t.Fatalf("expected loaded values to be cached")
+	}
+
+	// without WithLoader, single keys are loaded in batches of one
+	if _, err := l.GetOrLoad(context.Background(), "y"); err != ErrNotLoaded {
+		t.Fatalf("expected ErrNotLoaded, got %v", err)
+	}
+	if v, err := l.GetOrLoad(context.Background(), "4"); err != nil || v != 4 {
+		t.Fatalf("bad value: %v, %v", v, err)
+	}
+}
+
+func TestBatchLoaderCancel(t *testing.T) {
+	started := make(chan struct{})
+	batch := func(ctx context.Context, keys []int) (map[int]int, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	l, err := New[int, int](128, WithBatchLoader[int, int](batch))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	if _, err := l.GetOrLoadMany(ctx, []int{1, 2}); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestGetOrLoadManyWithLoader(t *testing.T) {
+	l, err := New[int, int](128, WithLoader[int, int](func(ctx context.Context, key int) (int, error) {
+		if key < 0 {
+			return 0, errors.New("negative")
+		}
+		return key * 2, nil
+	}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	values, err := l.GetOrLoadMany(context.Background(), []int{1, 2})
+	if err != nil || len(values) != 2 || values[2] != 4 {
+		t.Fatalf("bad: %v, %v", values, err)
+	}
+	if _, err := l.GetOrLoadMany(context.Background(), []int{1, -1}); err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	plain, err := New[int, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := plain.GetOrLoadMany(context.Background(), []int{1}); err != ErrNoLoader {
+		t.Fatalf("expected ErrNoLoader, got %v", err)
+	}
+}
+
+// finishes fails t if fn doesn't return within a few seconds, as when it
+// deadlocks.
+func finishes(t *testing.T, fn func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the call to return, but it's stuck")
+	}
+}
+
+func TestLoaderEvictCallbackReenters(t *testing.T) {
+	load := func(ctx context.Context, key int) (int, error) {
+		return key * 2, nil
+	}
+	var l *Cache[int, int]
+	var reentered atomic.Bool
+	l, err := NewWithEvict[int, int](1, func(key int, value int) {
+		// loading another key from the callback of an eviction caused
+		// by a load
+		if key == 1 && reentered.CompareAndSwap(false, true) {
+			if v, err := l.GetOrLoad(context.Background(), 3); err != nil || v != 6 {
+				t.Errorf("bad value: %v, %v", v, err)
+			}
+		}
+	}, WithLoader[int, int](load))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	finishes(t, func() {
+		if v, err := l.GetOrLoad(context.Background(), 2); err != nil || v != 4 {
+			t.Errorf("bad value: %v, %v", v, err)
+		}
+	})
+	if !reentered.Load() {
+		t.Fatalf("expected the callback to be called")
+	}
+}
+
+func TestBatchLoaderEvictCallbackReenters(t *testing.T) {
+	batch := func(ctx context.Context, keys []string) (map[string]int, error) {
+		values := make(map[string]int)
+		for _, k := range keys {
+			values[k] = len(k)
+		}
+		return values, nil
+	}
+	var l *ShardedCache[int]
+	var reentered atomic.Bool
+	l, err := NewShardedWithEvict[int](1, 1, func(key string, value int) {
+		if key == "a" && reentered.CompareAndSwap(false, true) {
+			if _, err := l.GetOrLoadMany(context.Background(), []string{"ccc"}); err != nil {
+				t.Errorf("err: %v", err)
+			}
+		}
+	}, WithBatchLoader[string, int](batch))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add("a", 1)
+	finishes(t, func() {
+		if _, err := l.GetOrLoadMany(context.Background(), []string{"bb"}); err != nil {
+			t.Errorf("err: %v", err)
+		}
+	})
+	if !reentered.Load() {
+		t.Fatalf("expected the callback to be called")
+	}
+}
@@ -0,0 +1,568 @@
+package lru
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// RemovalCause is why an entry left a LoadingCache.
+type RemovalCause uint8
+
+const (
+	// RemovalEvicted means the entry was evicted to stay within the
+	// cache's size or weight.
+	RemovalEvicted RemovalCause = iota
+	// RemovalExplicit means the entry was invalidated.
+	RemovalExplicit
+	// RemovalReplaced means the entry's value was replaced by Put, a
+	// load or a refresh.
+	RemovalReplaced
+	// RemovalExpired means the entry expired, given WithExpireAfterWrite,
+	// WithExpireAfterAccess or WithExpiryFunc.
+	RemovalExpired
+)
+
+func (c RemovalCause) String() string {
+	switch c {
+	case RemovalEvicted:
+		return "evicted"
+	case RemovalExplicit:
+		return "explicit"
+	case RemovalReplaced:
+		return "replaced"
+	case RemovalExpired:
+		return "expired"
+	default:
+		return fmt.Sprintf("RemovalCause(%d)", uint8(c))
+	}
+}
+
+// BulkLoader loads the values for several keys that are missing from a
+// cache at once.  Keys it has no value for may be left out of the result.
+type BulkLoader[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// LoadingStats is a point-in-time snapshot of a LoadingCache's counters.
+type LoadingStats struct {
+	Hits   uint64
+	Misses uint64
+	// LoadSuccesses and LoadFailures count calls to the loader and bulk
+	// loader, including refreshes, and TotalLoadTime is the time spent
+	// in them.
+	LoadSuccesses uint64
+	LoadFailures  uint64
+	TotalLoadTime time.Duration
+	// Refreshes counts the refreshes started WithRefreshAfterWrite.
+	Refreshes   uint64
+	Evictions   uint64
+	Expirations uint64
+}
+
+// HitRatio returns the fraction of lookups that were hits, or 0 if there
+// have been no lookups.
+func (s LoadingStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+type loadingCounters struct {
+	hits, misses                atomic.Uint64
+	loadSuccesses, loadFailures atomic.Uint64
+	loadTime                    atomic.Int64
+	refreshes                   atomic.Uint64
+	evictions, expirations      atomic.Uint64
+}
+
+// LoadingOption configures optional behavior of a LoadingCache at
+// construction time.
+type LoadingOption[K comparable, V any] func(o *loadingOptions[K, V])
+
+type loadingOptions[K comparable, V any] struct {
+	bulkLoad          BulkLoader[K, V]
+	refreshAfter      time.Duration
+	expireAfterWrite  time.Duration
+	expireAfterAccess time.Duration
+	expiry            func(value V) time.Time
+	maxWeight         int64
+	weigh             func(key K, value V) int64
+	onRemove          func(key K, value V, cause RemovalCause)
+	now               func() time.Time
+}
+
+// WithBulkLoader makes GetAll load all of the keys it misses with a
+// single call to load, instead of one call to the cache's loader each.
+// Bulk loads aren't shared with concurrent loads of the same keys.
+func WithBulkLoader[K comparable, V any](load BulkLoader[K, V]) LoadingOption[K, V] {
+	return func(o *loadingOptions[K, V]) {
+		o.bulkLoad = load
+	}
+}
+
+// WithRefreshAfterWrite reloads entries that were written more than d
+// ago when they're next read.  The read returns the current value without
+// waiting, and the reload replaces it in the background; if the reload
+// fails, the current value is kept and the next read tries again.
+func WithRefreshAfterWrite[K comparable, V any](d time.Duration) LoadingOption[K, V] {
+	return func(o *loadingOptions[K, V]) {
+		o.refreshAfter = d
+	}
+}
+
+// WithExpireAfterWrite treats entries that were written more than d ago
+// as absent.  Expired entries are removed when they're next looked up,
+// or by CleanUp.
+func WithExpireAfterWrite[K comparable, V any](d time.Duration) LoadingOption[K, V] {
+	return func(o *loadingOptions[K, V]) {
+		o.expireAfterWrite = d
+	}
+}
+
+// WithExpireAfterAccess treats entries that haven't been written or read
+// for d as absent.  Expired entries are removed when they're next looked
+// up, or by CleanUp.
+func WithExpireAfterAccess[K comparable, V any](d time.Duration) LoadingOption[K, V] {
+	return func(o *loadingOptions[K, V]) {
+		o.expireAfterAccess = d
+	}
+}
+
+// WithExpiryFunc treats entries as absent from the time expiry returns
+// for their values, for values that carry their own expiry, like DNS
+// records, OAuth tokens and signed URLs.  expiry is called when a value
+// is written, and a zero time means the value doesn't expire.  It can be
+// combined with WithExpireAfterWrite and WithExpireAfterAccess, in which
+// case entries expire at the earliest of their times.  Expired entries
+// are removed when they're next looked up, or by CleanUp.
+func WithExpiryFunc[K comparable, V any](expiry func(value V) time.Time) LoadingOption[K, V] {
+	return func(o *loadingOptions[K, V]) {
+		o.expiry = expiry
+	}
+}
+
+// WithWeigher bounds the total weight of the cache's entries, as given by
+// weigh, to maxWeight, on top of the bound on their number.  Entries are
+// weighed when they're written; when the total exceeds maxWeight, entries
+// are evicted until it doesn't.
+func WithWeigher[K comparable, V any](maxWeight int64, weigh func(key K, value V) int64) LoadingOption[K, V] {
+	return func(o *loadingOptions[K, V]) {
+		o.maxWeight = maxWeight
+		o.weigh = weigh
+	}
+}
+
+// WithRemovalListener calls fn whenever an entry leaves the cache, with
+// the reason it did.  It is called without the cache's lock held, so it
+// may call back into the cache.
+func WithRemovalListener[K comparable, V any](fn func(key K, value V, cause RemovalCause)) LoadingOption[K, V] {
+	return func(o *loadingOptions[K, V]) {
+		o.onRemove = fn
+	}
+}
+
+// WithClock makes the cache read the time from now instead of time.Now,
+// for tests of expiry and refresh.
+func WithClock[K comparable, V any](now func() time.Time) LoadingOption[K, V] {
+	return func(o *loadingOptions[K, V]) {
+		o.now = now
+	}
+}
+
+// loadingEntry is what a LoadingCache stores in its Cache for each key.
+type loadingEntry[V any] struct {
+	value   V
+	weight  int64
+	written int64
+	// expires is when the entry expires WithExpiryFunc, or 0 if it
+	// doesn't.
+	expires int64
+	// accessed is when the entry was last written or read, for
+	// WithExpireAfterAccess.
+	accessed atomic.Int64
+	// cause is set before the entry is removed on purpose, so that the
+	// eviction callback can tell why it was; the zero value is
+	// RemovalEvicted.
+	cause      atomic.Uint32
+	refreshing atomic.Bool
+}
+
+// LoadingCache is a thread-safe cache that loads missing values, with
+// optional expiry, refresh, weights and a removal listener.  It is built
+// on Cache, and evicts by approximate recency in the same way.
+type LoadingCache[K comparable, V any] struct {
+	cache  *Cache[K, *loadingEntry[V]]
+	load   Loader[K, V]
+	loads  *loadGroup[K, V]
+	opts   loadingOptions[K, V]
+	weight atomic.Int64
+	stats  loadingCounters
+}
+
+// NewLoading creates a LoadingCache of up to size entries, which calls
+// load to populate misses.  Concurrent misses for the same key share a
+// single call to load, as WithLoader's do.
+func NewLoading[K comparable, V any](size int, load Loader[K, V], opts ...LoadingOption[K, V]) (*LoadingCache[K, V], error) {
+	if load == nil {
+		return nil, errors.New("must provide a loader")
+	}
+	c := &LoadingCache[K, V]{}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+	if c.opts.now == nil {
+		c.opts.now = time.Now
+	}
+	if c.opts.weigh != nil && c.opts.maxWeight <= 0 {
+		return nil, errors.New("must provide a positive maximum weight")
+	}
+	c.load = c.timed(load)
+	c.loads = newLoadGroup(c.load, nil)
+	var err error
+	c.cache, err = NewWithEvict[K, *loadingEntry[V]](size, c.removed)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// timed wraps load to record its outcome and duration.
+func (c *LoadingCache[K, V]) timed(load Loader[K, V]) Loader[K, V] {
+	return func(ctx context.Context, key K) (V, error) {
+		start := time.Now()
+		value, err := load(ctx, key)
+		c.recordLoad(start, err)
+		return value, err
+	}
+}
+
+func (c *LoadingCache[K, V]) recordLoad(start time.Time, err error) {
+	c.stats.loadTime.Add(int64(time.Since(start)))
+	if err != nil {
+		c.stats.loadFailures.Add(1)
+	} else {
+		c.stats.loadSuccesses.Add(1)
+	}
+}
+
+// removed is the underlying Cache's eviction callback, which it calls
+// without holding its lock.
+func (c *LoadingCache[K, V]) removed(key K, e *loadingEntry[V]) {
+	c.weight.Add(-e.weight)
+	cause := RemovalCause(e.cause.Load())
+	switch cause {
+	case RemovalEvicted:
+		c.stats.evictions.Add(1)
+	case RemovalExpired:
+		c.stats.expirations.Add(1)
+	}
+	if c.opts.onRemove != nil {
+		c.opts.onRemove(key, e.value, cause)
+	}
+}
+
+func (c *LoadingCache[K, V]) newEntry(key K, value V) *loadingEntry[V] {
+	now := c.opts.now().UnixNano()
+	e := &loadingEntry[V]{value: value, written: now}
+	e.accessed.Store(now)
+	if c.opts.weigh != nil {
+		e.weight = c.opts.weigh(key, value)
+	}
+	if c.opts.expiry != nil {
+		if t := c.opts.expiry(value); !t.IsZero() {
+			e.expires = t.UnixNano()
+		}
+	}
+	return e
+}
+
+func (c *LoadingCache[K, V]) expired(e *loadingEntry[V], now int64) bool {
+	if e.expires != 0 && now >= e.expires {
+		return true
+	}
+	if d := c.opts.expireAfterWrite; d > 0 && now-e.written >= int64(d) {
+		return true
+	}
+	if d := c.opts.expireAfterAccess; d > 0 && now-e.accessed.Load() >= int64(d) {
+		return true
+	}
+	return false
+}
+
+// lookup returns key's entry if it's present and hasn't expired, removing
+// it if it has.
+func (c *LoadingCache[K, V]) lookup(key K) (*loadingEntry[V], bool) {
+	e, ok := c.cache.Get(key)
+	if ok {
+		now := c.opts.now().UnixNano()
+		if !c.expired(e, now) {
+			if c.opts.expireAfterAccess > 0 {
+				e.accessed.Store(now)
+			}
+			c.stats.hits.Add(1)
+			return e, true
+		}
+		c.removeEntry(key, e, RemovalExpired)
+	}
+	c.stats.misses.Add(1)
+	return nil, false
+}
+
+// peek is lookup for loads, which mustn't count as a lookup or update an
+// entry's access time.
+func (c *LoadingCache[K, V]) peek(key K) (value V, ok bool) {
+	e, ok := c.cache.Peek(key)
+	if !ok || c.expired(e, c.opts.now().UnixNano()) {
+		return value, false
+	}
+	return e.value, true
+}
+
+// removeEntry removes key for the given cause if e is still its entry.
+func (c *LoadingCache[K, V]) removeEntry(key K, e *loadingEntry[V], cause RemovalCause) {
+	c.cache.lock.Lock()
+	if cur, ok := c.cache.lru.Peek(key); ok && cur == e {
+		e.cause.Store(uint32(cause))
+		c.cache.lru.Remove(key)
+	}
+	c.cache.unlock()
+}
+
+// put stores value under key, pinning it if pin is set, and replacing e if
+// it isn't nil only if e is still key's entry.  It returns false if the
+// value wasn't stored.
+func (c *LoadingCache[K, V]) put(key K, value V, pin bool, e *loadingEntry[V]) bool {
+	entry := c.newEntry(key, value)
+	c.cache.lock.Lock()
+	old, replaced := c.cache.lru.Peek(key)
+	if c.cache.closed || (e != nil && old != e) {
+		c.cache.unlock()
+		return false
+	}
+	if replaced {
+		old.cause.Store(uint32(RemovalReplaced))
+	}
+	c.cache.lru.Add(key, entry)
+	if pin {
+		c.cache.lru.Pin(key)
+	}
+	c.weight.Add(entry.weight)
+	c.cache.unlock()
+
+	if replaced {
+		c.removed(key, old)
+	}
+	c.enforceWeight()
+	return true
+}
+
+// enforceWeight evicts entries until the total weight is within bounds.
+func (c *LoadingCache[K, V]) enforceWeight() {
+	for c.opts.maxWeight > 0 && c.weight.Load() > c.opts.maxWeight {
+		c.cache.lock.Lock()
+		_, _, ok := c.cache.lru.RemoveOldest()
+		c.cache.unlock()
+		if !ok {
+			return
+		}
+	}
+}
+
+// Get returns key's value, loading it if it's missing or expired.  If
+// the cache was created WithRefreshAfterWrite and the value is due for a
+// refresh, Get returns it and starts reloading it in the background.  ctx
+// is passed to the loader, and if it is done before the value is loaded,
+// Get returns ctx.Err().
+func (c *LoadingCache[K, V]) Get(ctx context.Context, key K) (V, error) {
+	if e, ok := c.lookup(key); ok {
+		c.maybeRefresh(key, e)
+		return e.value, nil
+	}
+	if c.cache.isClosed() {
+		var zero V
+		return zero, ErrClosed
+	}
+	return c.loads.do(ctx, key, c.peek, c.addPinned, c.cache.Unpin)
+}
+
+func (c *LoadingCache[K, V]) addPinned(key K, value V) bool {
+	return c.put(key, value, true, nil)
+}
+
+// maybeRefresh starts reloading key in the background if e is due for a
+// refresh and isn't already being refreshed.
+func (c *LoadingCache[K, V]) maybeRefresh(key K, e *loadingEntry[V]) {
+	d := c.opts.refreshAfter
+	if d <= 0 || c.opts.now().UnixNano()-e.written < int64(d) || !e.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	c.stats.refreshes.Add(1)
+	go func() {
+		value, err := c.load(context.Background(), key)
+		// a failed refresh is retried by the next read, and a
+		// successful one replaces e unless it was already replaced
+		if err != nil || !c.put(key, value, false, e) {
+			e.refreshing.Store(false)
+		}
+	}()
+}
+
+// GetIfPresent returns key's value if it's present and hasn't expired,
+// without loading it.
+func (c *LoadingCache[K, V]) GetIfPresent(key K) (value V, ok bool) {
+	e, ok := c.lookup(key)
+	if !ok {
+		return value, false
+	}
+	c.maybeRefresh(key, e)
+	return e.value, true
+}
+
+// GetAll returns the values for keys, loading those that are missing with
+// the bulk loader if the cache was created WithBulkLoader, or with the
+// loader otherwise.  Keys that the bulk loader has no value for are left
+// out of the result.
+func (c *LoadingCache[K, V]) GetAll(ctx context.Context, keys []K) (map[K]V, error) {
+	values := make(map[K]V, len(keys))
+	var missing []K
+	for _, key := range keys {
+		if e, ok := c.lookup(key); ok {
+			c.maybeRefresh(key, e)
+			values[key] = e.value
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+	if c.cache.isClosed() {
+		return nil, ErrClosed
+	}
+	if c.opts.bulkLoad == nil {
+		for _, key := range missing {
+			value, err := c.loads.do(ctx, key, c.peek, c.addPinned, c.cache.Unpin)
+			if err != nil {
+				return nil, err
+			}
+			values[key] = value
+		}
+		return values, nil
+	}
+
+	start := time.Now()
+	loaded, err := c.opts.bulkLoad(ctx, missing)
+	c.recordLoad(start, err)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range loaded {
+		c.put(key, value, false, nil)
+		values[key] = value
+	}
+	return values, nil
+}
+
+// Put stores value under key, replacing any value already there.
+func (c *LoadingCache[K, V]) Put(key K, value V) {
+	c.put(key, value, false, nil)
+}
+
+// Invalidate removes key from the cache.
+func (c *LoadingCache[K, V]) Invalidate(key K) {
+	c.cache.lock.Lock()
+	if e, ok := c.cache.lru.Peek(key); ok {
+		e.cause.Store(uint32(RemovalExplicit))
+		c.cache.lru.Remove(key)
+	}
+	c.cache.unlock()
+}
+
+// InvalidateAll removes every entry from the cache.
+func (c *LoadingCache[K, V]) InvalidateAll() {
+	c.cache.lock.Lock()
+	c.cache.lru.Range(func(key K, e *loadingEntry[V]) bool {
+		e.cause.Store(uint32(RemovalExplicit))
+		return true
+	})
+	c.cache.lru.Purge()
+	c.cache.unlock()
+}
+
+// CleanUp removes every expired entry.  Expired entries are otherwise
+// only removed when they're looked up, or evicted to make room.
+func (c *LoadingCache[K, V]) CleanUp() {
+	now := c.opts.now().UnixNano()
+	c.cache.RemoveFunc(func(key K, e *loadingEntry[V]) bool {
+		if !c.expired(e, now) {
+			return false
+		}
+		e.cause.Store(uint32(RemovalExpired))
+		return true
+	})
+}
+
+// Len returns the number of entries in the cache, including expired ones
+// that haven't been removed yet.
+func (c *LoadingCache[K, V]) Len() int {
+	return c.cache.Len()
+}
+
+// Weight returns the total weight of the cache's entries, or 0 if it
+// wasn't created WithWeigher.
+func (c *LoadingCache[K, V]) Weight() int64 {
+	return c.weight.Load()
+}
+
+// UpdateWeight weighs key's value again, for values that have grown or
+// shrunk in place since they were written, such as appended-to buffers,
+// and evicts entries if the total now exceeds the maximum weight.  It
+// returns false if key isn't cached, or if the cache wasn't created
+// WithWeigher.
+func (c *LoadingCache[K, V]) UpdateWeight(key K) bool {
+	if c.opts.weigh == nil {
+		return false
+	}
+	e, ok := c.cache.Peek(key)
+	if !ok {
+		return false
+	}
+	weight := c.opts.weigh(key, e.value)
+	c.cache.lock.Lock()
+	if cur, ok := c.cache.lru.Peek(key); !ok || cur != e {
+		// replaced or removed while it was being weighed
+		c.cache.unlock()
+		return false
+	}
+	c.weight.Add(weight - e.weight)
+	e.weight = weight
+	c.cache.unlock()
+	c.enforceWeight()
+	return true
+}
+
+// Stats returns a snapshot of the cache's counters.
+func (c *LoadingCache[K, V]) Stats() LoadingStats {
+	return LoadingStats{
+		Hits:          c.stats.hits.Load(),
+		Misses:        c.stats.misses.Load(),
+		LoadSuccesses: c.stats.loadSuccesses.Load(),
+		LoadFailures:  c.stats.loadFailures.Load(),
+		TotalLoadTime: time.Duration(c.stats.loadTime.Load()),
+		Refreshes:     c.stats.refreshes.Load(),
+		Evictions:     c.stats.evictions.Load(),
+		Expirations:   c.stats.expirations.Load(),
+	}
+}
+
+// Close tears the cache down as Cache.Close does, without calling the
+// removal listener for the entries it drops.
+func (c *LoadingCache[K, V]) Close() error {
+	if err := c.cache.Close(); err != nil {
+		return err
+	}
+	c.loads.cancelAll()
+	return nil
+}
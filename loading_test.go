@@ -0,0 +1,428 @@
+package lru
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock for tests that only moves when told to.
+type fakeClock struct {
+	now atomic.Int64
+}
+
+func (c *fakeClock) Now() time.Time {
+	return time.Unix(0, c.now.Load())
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now.Add(int64(d))
+}
+
+// removalLog records the calls to a removal listener.
+type removalLog struct {
+	mu     sync.Mutex
+	causes map[int]RemovalCause
+}
+
+func (l *removalLog) record(key, value int, cause RemovalCause) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.causes == nil {
+		l.causes = make(map[int]RemovalCause)
+	}
+	l.causes[key] = cause
+}
+
+func (l *removalLog) cause(key int) (RemovalCause, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cause, ok := l.causes[key]
+	return cause, ok
+}
+
+func double(ctx context.Context, key int) (int, error) {
+	return key * 2, nil
+}
+
+func TestLoadingCache(t *testing.T) {
+	var calls atomic.Int32
+	load := func(ctx context.Context, key int) (int, error) {
+		calls.Add(1)
+		if key < 0 {
+			return 0, errors.New("negative")
+		}
+		return key * 2, nil
+	}
+	var removals removalLog
+	c, err := NewLoading[int, int](128, load, WithRemovalListener[int, int](removals.record))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if v, err := c.Get(ctx, 21); err != nil || v != 42 {
+			t.Fatalf("bad: %v, %v", v, err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 load, got %d", calls.Load())
+	}
+	if _, err := c.Get(ctx, -1); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if _, ok := c.GetIfPresent(-1); ok {
+		t.Fatalf("errors shouldn't be cached")
+	}
+
+	c.Put(21, 7)
+	if v, ok := c.GetIfPresent(21); !ok || v != 7 {
+		t.Fatalf("bad: %v", v)
+	}
+	if cause, _ := removals.cause(21); cause != RemovalReplaced {
+		t.Fatalf("bad cause: %v", cause)
+	}
+	c.Invalidate(21)
+	if cause, _ := removals.cause(21); cause != RemovalExplicit {
+		t.Fatalf("bad cause: %v", cause)
+	}
+
+	for i := 0; i < 256; i++ {
+		c.Put(i, i)
+	}
+	if c.Len() != 128 {
+		t.Fatalf("bad len: %d", c.Len())
+	}
+	c.InvalidateAll()
+	if c.Len() != 0 {
+		t.Fatalf("bad len: %d", c.Len())
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 3 {
+		t.Fatalf("bad hits or misses: %+v", stats)
+	}
+	if stats.LoadSuccesses != 1 || stats.LoadFailures != 1 {
+		t.Fatalf("bad loads: %+v", stats)
+	}
+	if stats.Evictions != 128 {
+		t.Fatalf("bad evictions: %+v", stats)
+	}
+}
+
+func TestLoadingCacheExpiry(t *testing.T) {
+	var clock fakeClock
+	var removals removalLog
+	c, err := NewLoading[int, int](128, double,
+		WithClock[int, int](clock.Now),
+		WithExpireAfterWrite[int, int](time.Minute),
+		WithExpireAfterAccess[int, int](10*time.Second),
+		WithRemovalListener[int, int](removals.record))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	c.Put(1, 1)
+	c.Put(2, 2)
+	for i := 0; i < 5; i++ {
+		clock.Advance(5 * time.Second)
+		if _, ok := c.GetIfPresent(1); !ok {
+			t.Fatalf("1 is still being accessed")
+		}
+	}
+	if _, ok := c.GetIfPresent(2); ok {
+		t.Fatalf("2 should have expired after access")
+	}
+	if cause, _ := removals.cause(2); cause != RemovalExpired {
+		t.Fatalf("bad cause: %v", cause)
+	}
+
+	clock.Advance(40 * time.Second)
+	// expired entries are loaded afresh
+	if v, err := c.Get(context.Background(), 1); err != nil || v != 2 {
+		t.Fatalf("1 should have expired after write: %v, %v", v, err)
+	}
+
+	c.Put(3, 3)
+	clock.Advance(time.Hour)
+	c.CleanUp()
+	if c.Len() != 0 {
+		t.Fatalf("expected every entry to be cleaned up: %d", c.Len())
+	}
+	if stats := c.Stats(); stats.Expirations != 4 {
+		t.Fatalf("bad expirations: %+v", stats)
+	}
+}
+
+func TestLoadingCacheExpiryFunc(t *testing.T) {
+	var clock fakeClock
+	// each value is the number of seconds it's valid for, and 0 never
+	// expires
+	load := func(ctx context.Context, key int) (int, error) {
+		return key, nil
+	}
+	expiry := func(value int) time.Time {
+		if value == 0 {
+			return time.Time{}
+		}
+		return clock.Now().Add(time.Duration(value) * time.Second)
+	}
+	c, err := NewLoading[int, int](128, load,
+		WithClock[int, int](clock.Now),
+		WithExpiryFunc[int, int](expiry))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ctx := context.Background()
+	for _, key := range []int{0, 10, 60} {
+		if _, err := c.Get(ctx, key); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	clock.Advance(10 * time.Second)
+	if _, ok := c.GetIfPresent(10); ok {
+		t.Fatalf("10 should have expired")
+	}
+	if _, ok := c.GetIfPresent(60); !ok {
+		t.Fatalf("60 shouldn't have expired yet")
+	}
+
+	// a write computes the expiry afresh
+	c.Put(60, 5)
+	clock.Advance(5 * time.Second)
+	if _, ok := c.GetIfPresent(60); ok {
+		t.Fatalf("60 should have expired with its new value")
+	}
+
+	clock.Advance(time.Hour)
+	c.CleanUp()
+	if _, ok := c.GetIfPresent(0); !ok || c.Len() != 1 {
+		t.Fatalf("expected only the value with no expiry to be left, got %d", c.Len())
+	}
+	if stats := c.Stats(); stats.Expirations != 2 {
+		t.Fatalf("bad expirations: %+v", stats)
+	}
+}
+
+func TestLoadingCacheRefresh(t *testing.T) {
+	var clock fakeClock
+	var version atomic.Int32
+	refreshed := make(chan struct{}, 1)
+	load := func(ctx context.Context, key int) (int, error) {
+		v := version.Add(1)
+		if v > 1 {
+			refreshed <- struct{}{}
+		}
+		return int(v), nil
+	}
+	c, err := NewLoading[int, int](128, load,
+		WithClock[int, int](clock.Now),
+		WithRefreshAfterWrite[int, int](time.Minute))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ctx := context.Background()
+	if v, _ := c.Get(ctx, 1); v != 1 {
+		t.Fatalf("bad: %v", v)
+	}
+	clock.Advance(2 * time.Minute)
+	// the stale value is returned while it's refreshed
+	if v, _ := c.Get(ctx, 1); v != 1 {
+		t.Fatalf("bad: %v", v)
+	}
+	<-refreshed
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if v, _ := c.GetIfPresent(1); v == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("value wasn't refreshed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if stats := c.Stats(); stats.Refreshes != 1 {
+		t.Fatalf("bad refreshes: %+v", stats)
+	}
+}
+
+func TestLoadingCacheWeigher(t *testing.T) {
+	var removals removalLog
+	c, err := NewLoading[int, int](128, double,
+		WithWeigher[int, int](100, func(key, value int) int64 { return int64(value) }),
+		WithRemovalListener[int, int](removals.record))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		c.Put(i, 10)
+		if w := c.Weight(); w > 100 {
+			t.Fatalf("weight %d exceeds the maximum", w)
+		}
+	}
+	if c.Len() != 10 || c.Weight() != 100 {
+		t.Fatalf("bad len %d or weight %d", c.Len(), c.Weight())
+	}
+	c.Put(0, 50)
+	if c.Weight() > 100 {
+		t.Fatalf("weight %d exceeds the maximum", c.Weight())
+	}
+	if stats := c.Stats(); stats.Evictions < 10 {
+		t.Fatalf("bad evictions: %+v", stats)
+	}
+
+	if _, err := NewLoading[int, int](128, double, WithWeigher[int, int](0, func(key, value int) int64 { return 1 })); err == nil {
+		t.Fatalf("expected an error for a zero maximum weight")
+	}
+}
+
+func TestLoadingCacheUpdateWeight(t *testing.T) {
+	load := func(ctx context.Context, key int) (*[]byte, error) {
+		b := make([]byte, 10)
+		return &b, nil
+	}
+	weigh := func(key int, value *[]byte) int64 { return int64(len(*value)) }
+	c, err := NewLoading[int, *[]byte](128, load, WithWeigher[int, *[]byte](100, weigh))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		if _, err := c.Get(ctx, i); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if c.Weight() != 50 {
+		t.Fatalf("bad weight %d", c.Weight())
+	}
+
+	// growing a value past the budget evicts once it's weighed again
+	b, _ := c.GetIfPresent(0)
+	*b = append(*b, make([]byte, 60)...)
+	if c.Weight() != 50 {
+		t.Fatalf("expected the weight not to change until updated, got %d", c.Weight())
+	}
+	if !c.UpdateWeight(0) {
+		t.Fatalf("expected key 0 to be updated")
+	}
+	if w := c.Weight(); w > 100 {
+		t.Fatalf("weight %d exceeds the maximum", w)
+	}
+	if c.Len() >= 5 {
+		t.Fatalf("expected an eviction, got len %d", c.Len())
+	}
+
+	if c.UpdateWeight(1000) {
+		t.Fatalf("expected a missing key not to be updated")
+	}
+	unweighed, err := NewLoading[int, *[]byte](128, load)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := unweighed.Get(ctx, 0); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unweighed.UpdateWeight(0) {
+		t.Fatalf("expected no update without WithWeigher")
+	}
+}
+
+func TestLoadingCacheGetAll(t *testing.T) {
+	var batches atomic.Int32
+	bulk := func(ctx context.Context, keys []string) (map[string]int, error) {
+		batches.Add(1)
+		values := make(map[string]int)
+		for _, k := range keys {
+			if n, err := strconv.Atoi(k); err == nil {
+				values[k] = n
+			}
+		}
+		return values, nil
+	}
+	load := func(ctx context.Context, key string) (int, error) {
+		return strconv.Atoi(key)
+	}
+	c, err := NewLoading[string, int](128, load, WithBulkLoader[string, int](bulk))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c.Put("1", 1)
+	values, err := c.GetAll(context.Background(), []string{"1", "2", "3", "x"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(values) != 3 || values["2"] != 2 || values["3"] != 3 {
+		t.Fatalf("bad values: %v", values)
+	}
+	if batches.Load() != 1 {
+		t.Fatalf("expected 1 bulk load, got %d", batches.Load())
+	}
+	if _, ok := c.GetIfPresent("3"); !ok {
+		t.Fatalf("expected bulk loaded values to be cached")
+	}
+
+	// without a bulk loader, each key is loaded separately
+	c, err = NewLoading[string, int](128, load)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c.GetAll(context.Background(), []string{"1", "x"}); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if values, err := c.GetAll(context.Background(), []string{"1", "2"}); err != nil || len(values) != 2 {
+		t.Fatalf("bad: %v, %v", values, err)
+	}
+}
+
+func TestLoadingCacheClose(t *testing.T) {
+	c, err := NewLoading[int, int](128, double)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c.Put(1, 1)
+	if err := c.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c.Get(context.Background(), 2); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	if c.Close() != ErrClosed {
+		t.Fatalf("expected closing twice to fail")
+	}
+}
+
+func TestLoadingCacheRemovalListenerReenters(t *testing.T) {
+	var c *LoadingCache[int, int]
+	var reentered atomic.Bool
+	c, err := NewLoading[int, int](1, double, WithRemovalListener[int, int](func(key, value int, cause RemovalCause) {
+		// loading another key from the listener of an eviction caused
+		// by a load
+		if key == 1 && reentered.CompareAndSwap(false, true) {
+			if v, err := c.Get(context.Background(), 3); err != nil || v != 6 {
+				t.Errorf("bad value: %v, %v", v, err)
+			}
+		}
+	}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := c.Get(ctx, 1); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	finishes(t, func() {
+		if v, err := c.Get(ctx, 2); err != nil || v != 4 {
+			t.Errorf("bad value: %v, %v", v, err)
+		}
+	})
+	if !reentered.Load() {
+		t.Fatalf("expected the listener to be called")
+	}
+}
@@ -1,55 +1,308 @@
 package lru
 
 import (
-	"sync"
+	"context"
+	"log/slog"
+	"time"
 
 	"github.com/bpowers/approx-lru/simplelru"
 )
 
 // Cache is a thread-safe fixed size LRU cache.
 type Cache[K comparable, V any] struct {
-	lock sync.RWMutex
-	lru  simplelru.LRU[K, V]
+	lock    cacheLock
+	lru     simplelru.LRU[K, V]
+	latency *latencyRecorder
+	logger  *slog.Logger
+
+	keyCodec   Codec[K]
+	valueCodec Codec[V]
+	loads      *loadGroup[K, V]
+	writes     *storeWriter[K, V]
+	behind     *writeBehind[K, V]
+
+	// onEvict is called for the entries in evicted once the lock is
+	// released.
+	onEvict evictCallbacks[K, V]
+	evicted []evictedEntry[K, V]
+	recover bool
+	// bulkEvict is given WithBulkOnEvict, and evictInfo WithOnEvictInfo.
+	bulkEvict func(entries []simplelru.Entry[K, V])
+	evictInfo func(key K, value V, info simplelru.EvictionInfo)
+
+	// keyLocks serializes Do by key.
+	keyLocks keyLocks[K]
+	// tooLarge reports whether a value is larger than WithMaxValueSize
+	// allows, if it was given.
+	tooLarge func(value V) bool
+
+	// sizing runs the sizer given WithDynamicSizer.
+	sizing *dynamicSizing
+	// evictor runs evictions WithBackgroundEviction.
+	evictor *evictor
+
+	// memory is set for caches created by NewWithMemoryFraction.
+	memory *memoryFraction[K, V]
+
+	// copyOnRead is set WithCopyOnRead, and clone is given WithClone.
+	copyOnRead bool
+	clone      func(value V) V
+
+	// quarantine holds entries removed WithQuarantine.
+	quarantine *quarantine[K, V]
+
+	closed bool
+}
+
+type evictedEntry[K comparable, V any] struct {
+	key   K
+	value V
+	// info is set only WithOnEvictInfo.
+	info simplelru.EvictionInfo
+	// queued is set for an entry removed by a write-behind Delete, whose
+	// deletion is left for the workers to write rather than written
+	// when the entry is released.
+	queued bool
 }
 
+// removed is the info given WithOnEvictInfo for entries released from
+// quarantine, which only keeps keys and values.
+var removed = simplelru.EvictionInfo{Reason: simplelru.EvictRemove}
+
 // New creates an LRU of the given size.
-func New[K comparable, V any](size int) (*Cache[K, V], error) {
-	return NewWithEvict[K, V](size, nil)
+func New[K comparable, V any](size int, opts ...Option[K, V]) (*Cache[K, V], error) {
+	return NewWithEvict[K, V](size, nil, opts...)
 }
 
 // NewWithEvict constructs a fixed size cache with the given eviction
-// callback.
-func NewWithEvict[K comparable, V any](size int, onEvicted func(key K, value V)) (*Cache[K, V], error) {
-	lru, err := simplelru.NewLRU[K, V](size, simplelru.EvictCallback[K, V](onEvicted))
+// callback.  The callback is called after the operation that evicted the
+// entry has released the cache's lock, so it may call back into the
+// cache, for example to add a tombstone for the evicted key.
+func NewWithEvict[K comparable, V any](size int, onEvicted func(key K, value V), opts ...Option[K, V]) (*Cache[K, V], error) {
+	o, err := newOptions(opts)
 	if err != nil {
 		return nil, err
 	}
+	if err := o.checkNoLock(); err != nil {
+		return nil, err
+	}
 	c := &Cache[K, V]{
-		lru: *lru,
+		logger:     o.logger,
+		keyCodec:   o.keyCodec,
+		valueCodec: o.valueCodec,
+		loads:      newLoadGroup(o.loader, o.batchLoader),
+		recover:    o.recover,
+		tooLarge:   o.tooLarge(),
+	}
+	c.lock.off = o.noLock
+	c.onEvict.init(onEvicted)
+	c.bulkEvict = o.bulkEvict
+	c.evictInfo = o.evictInfo
+	c.copyOnRead = o.copyOnRead
+	c.clone = o.clone
+	if c.behind, err = newWriteBehind(o); err != nil {
+		return nil, err
 	}
+	if err := c.loads.setNegativeFilter(o); err != nil {
+		return nil, err
+	}
+	if c.quarantine, err = newQuarantine(o, func(key K, value V) {
+		c.releaseAll([]evictedEntry[K, V]{{key: key, value: value, info: removed}})
+	}); err != nil {
+		return nil, err
+	}
+	if c.behind == nil {
+		c.writes = newStoreWriter(o.store)
+	}
+	var deferEvict simplelru.EvictCallback[K, V]
+	lruOpts := o.lruOptions(1)
+	if c.evictInfo != nil {
+		// everything leaving the cache is queued with its info, for
+		// every callback
+		lruOpts = append(lruOpts, simplelru.WithOnEvictInfo[K, V](c.deferEvictInfo))
+	} else if onEvicted != nil || c.behind != nil || c.bulkEvict != nil {
+		deferEvict = c.deferEvict
+	}
+	if o.randSource != nil {
+		lruOpts = append(lruOpts, simplelru.WithRandSource[K, V](o.randSource))
+	}
+	lru, err := simplelru.NewLRU[K, V](size, deferEvict, lruOpts...)
+	if err != nil {
+		return nil, err
+	}
+	c.lru = *lru
+	if o.latency {
+		c.latency = &latencyRecorder{}
+	}
+	if c.logger != nil {
+		c.logger.Info("lru: created cache", "size", size)
+	}
+	c.sizing = startDynamicSizing(c, o.sizer, o.sizingInterval)
+	c.evictor = startEvictor(o, c.trim)
 	return c, nil
 }
 
+// deferEvict queues an evicted entry for unlock to pass to onEvict.
+func (c *Cache[K, V]) deferEvict(key K, value V) {
+	c.evicted = append(c.evicted, evictedEntry[K, V]{key: key, value: value})
+}
+
+// deferEvictInfo queues an entry leaving the cache, with its info, for
+// unlock to pass to the eviction callbacks.
+func (c *Cache[K, V]) deferEvictInfo(key K, value V, info simplelru.EvictionInfo) {
+	c.evicted = append(c.evicted, evictedEntry[K, V]{key: key, value: value, info: info})
+}
+
+// unlock releases the write lock, then calls the eviction callback for
+// the entries evicted while it was held, after writing any writes queued
+// for them WithWriteBehind.  It wakes the background evictor if the cache
+// has grown past its size.
+func (c *Cache[K, V]) unlock() {
+	evicted := c.evicted
+	c.evicted = nil
+	if c.evictor != nil && overflowing(&c.lru) {
+		c.evictor.signal()
+	}
+	c.lock.Unlock()
+	c.releaseAll(evicted)
+}
+
+// releaseAll releases each of evicted, then passes them all to the
+// callback given WithBulkOnEvict.  The lock must not be held.
+func (c *Cache[K, V]) releaseAll(evicted []evictedEntry[K, V]) {
+	for _, e := range evicted {
+		c.release(e)
+	}
+	if c.bulkEvict != nil && len(evicted) > 0 {
+		callBulkOnEvict(c.bulkEvict, evicted, c.recover, c.logger)
+	}
+}
+
+// release calls the eviction callback for e, after writing any writes
+// queued for it WithWriteBehind.  The lock must not be held.
+func (c *Cache[K, V]) release(e evictedEntry[K, V]) {
+	if c.behind != nil && !e.queued {
+		c.behind.flushEvicted(e.key)
+	}
+	for _, onEvict := range c.onEvict.load() {
+		callOnEvict(onEvict, e, c.recover, c.logger)
+	}
+	if c.evictInfo != nil {
+		callOnEvictInfo(c.evictInfo, e, c.recover, c.logger)
+	}
+}
+
+// callOnEvict calls onEvict for e, recovering from and logging its panic
+// if recoverPanics is set.
+func callOnEvict[K comparable, V any](onEvict func(K, V), e evictedEntry[K, V], recoverPanics bool, logger *slog.Logger) {
+	if recoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				if logger == nil {
+					logger = slog.Default()
+				}
+				logger.Error("lru: callback panicked", "callback", "onEvict", "key", e.key, "panic", r)
+			}
+		}()
+	}
+	onEvict(e.key, e.value)
+}
+
 // Purge is used to completely clear the cache.
 func (c *Cache[K, V]) Purge() {
 	c.lock.Lock()
+	n := c.lru.Len()
 	c.lru.Purge()
-	c.lock.Unlock()
+	c.unlock()
+	if c.quarantine != nil {
+		c.quarantine.purge()
+	}
+	if c.logger != nil {
+		c.logger.Info("lru: purged cache", "entries", n)
+	}
+}
+
+// InvalidateAll makes every entry in the cache absent in constant time,
+// without calling the eviction callback.  See
+// simplelru.LRU.InvalidateAll.
+func (c *Cache[K, V]) InvalidateAll() {
+	c.lock.Lock()
+	n := c.lru.Len()
+	c.lru.InvalidateAll()
+	c.unlock()
+	if c.logger != nil {
+		c.logger.Info("lru: invalidated cache", "entries", n)
+	}
 }
 
 // Add adds a value to the cache. Returns true if an eviction occurred.
 func (c *Cache[K, V]) Add(key K, value V) (evicted bool) {
+	if c.latency != nil {
+		defer c.latency.add.since(time.Now())
+	}
 	c.lock.Lock()
-	evicted = c.lru.Add(key, value)
-	c.lock.Unlock()
+	if !c.closed {
+		evicted = c.lru.Add(key, value)
+	}
+	c.unlock()
 	return evicted
 }
 
-// Get looks up a key's value from the cache.
+// AddReturningEvicted adds a value to the cache as Add does, and returns
+// the entry it evicted, if any, for the caller to deal with inline.  The
+// eviction callback is still called for it.  See
+// simplelru.LRU.AddReturningEvicted.
+func (c *Cache[K, V]) AddReturningEvicted(key K, value V) (evictedKey K, evictedValue V, evicted bool) {
+	if c.latency != nil {
+		defer c.latency.add.since(time.Now())
+	}
+	c.lock.Lock()
+	if !c.closed {
+		evictedKey, evictedValue, evicted = c.lru.AddReturningEvicted(key, value)
+	}
+	c.unlock()
+	return evictedKey, evictedValue, evicted
+}
+
+// TryAdd adds a value to the cache as Add does, but returns
+// ErrValueTooLarge if it's larger than WithMaxValueSize allows, in which
+// case it isn't added, and the key's old value is removed, or ErrFrozen if
+// the cache is frozen.
+func (c *Cache[K, V]) TryAdd(key K, value V) (evicted bool, err error) {
+	if c.Frozen() {
+		return false, ErrFrozen
+	}
+	if c.tooLarge != nil && c.tooLarge(value) {
+		c.Add(key, value)
+		return false, ErrValueTooLarge
+	}
+	return c.Add(key, value), nil
+}
+
+// Get looks up a key's value from the cache.  If the cache was created
+// WithLoader, misses are loaded.
 func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
+	value, ok = c.get(key)
+	if !ok && c.loads != nil && !c.isClosed() {
+		var err error
+		value, err = c.loads.do(context.Background(), key, c.peek, c.addPinned, c.Unpin)
+		if ok = err == nil; ok {
+			value = c.cloned(value)
+		}
+	}
+	return value, ok
+}
+
+func (c *Cache[K, V]) get(key K) (value V, ok bool) {
+	if c.latency != nil {
+		defer c.latency.get.since(time.Now())
+	}
 	c.lock.Lock()
 	value, ok = c.lru.Get(key)
-	c.lock.Unlock()
+	c.unlock()
+	if ok {
+		value = c.cloned(value)
+	}
 	return value, ok
 }
 
@@ -65,20 +318,49 @@ func (c *Cache[K, V]) Contains(key K) bool {
 // Peek returns the key value (or undefined if not found) without updating
 // the "recently used"-ness of the key.
 func (c *Cache[K, V]) Peek(key K) (value V, ok bool) {
+	if value, ok = c.peek(key); ok {
+		value = c.cloned(value)
+	}
+	return value, ok
+}
+
+// peek is Peek without WithClone, for loads, whose results are cloned
+// once they're returned.
+func (c *Cache[K, V]) peek(key K) (value V, ok bool) {
 	c.lock.RLock()
 	value, ok = c.lru.Peek(key)
 	c.lock.RUnlock()
 	return value, ok
 }
 
+// LastUsed returns roughly when key was last used, without updating its
+// "recently used"-ness.  See simplelru.LRU.LastUsed.
+func (c *Cache[K, V]) LastUsed(key K) (t time.Time, ok bool) {
+	c.lock.RLock()
+	t, ok = c.lru.LastUsed(key)
+	c.lock.RUnlock()
+	return t, ok
+}
+
+// ApproxAge returns roughly how long ago key was last used, rounded up,
+// without updating its "recently used"-ness, so that callers can
+// revalidate entries past some age without storing a timestamp in each
+// value.  See simplelru.LRU.ApproxAge.
+func (c *Cache[K, V]) ApproxAge(key K) (age time.Duration, ok bool) {
+	c.lock.RLock()
+	age, ok = c.lru.ApproxAge(key)
+	c.lock.RUnlock()
+	return age, ok
+}
+
 // ContainsOrAdd checks if a key is in the cache without updating the
 // recent-ness or deleting it for being stale, and if not, adds the value.
 // Returns whether found and whether an eviction occurred.
 func (c *Cache[K, V]) ContainsOrAdd(key K, value V) (ok, evicted bool) {
 	c.lock.Lock()
-	defer c.lock.Unlock()
+	defer c.unlock()
 
-	if c.lru.Contains(key) {
+	if c.lru.Contains(key) || c.closed {
 		return true, false
 	}
 	evicted = c.lru.Add(key, value)
@@ -90,30 +372,104 @@ func (c *Cache[K, V]) ContainsOrAdd(key K, value V) (ok, evicted bool) {
 // Returns whether found and whether an eviction occurred.
 func (c *Cache[K, V]) PeekOrAdd(key K, value V) (previous V, ok, evicted bool) {
 	c.lock.Lock()
-	defer c.lock.Unlock()
+	defer c.unlock()
 
 	previous, ok = c.lru.Peek(key)
 	if ok {
-		return previous, true, false
+		return c.cloned(previous), true, false
+	}
+	if c.closed {
+		return previous, false, false
 	}
 
 	evicted = c.lru.Add(key, value)
 	return previous, false, evicted
 }
 
-// Remove removes the provided key from the cache.
+// Remove removes the provided key from the cache.  A cache created
+// WithQuarantine holds the entry aside, and calls the eviction callback
+// once its time is up, unless it's restored first.
 func (c *Cache[K, V]) Remove(key K) (present bool) {
 	c.lock.Lock()
-	present = c.lru.Remove(key)
-	c.lock.Unlock()
-	return
+	if c.quarantine == nil {
+		present = c.lru.Remove(key)
+		c.unlock()
+		return present
+	}
+	value, present := c.lru.Peek(key)
+	if present {
+		// take back the callback Remove queues; the quarantine calls it
+		n := len(c.evicted)
+		present = c.lru.Remove(key)
+		c.evicted = c.evicted[:n]
+	}
+	c.unlock()
+	if present {
+		c.quarantine.put(key, value)
+	}
+	return present
+}
+
+// Pin protects key from eviction until it is unpinned as many times as it
+// was pinned, returning false if key isn't in the cache.  See
+// simplelru.LRU.Pin.
+func (c *Cache[K, V]) Pin(key K) bool {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.lru.Pin(key)
+}
+
+// PinFor pins key as Pin does, but only for d, so that a forgotten pin
+// lapses rather than protecting key forever.  See simplelru.LRU.PinFor.
+func (c *Cache[K, V]) PinFor(key K, d time.Duration) bool {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.lru.PinFor(key, d)
+}
+
+// Unpin undoes one call to Pin for key.
+func (c *Cache[K, V]) Unpin(key K) {
+	c.lock.Lock()
+	c.lru.Unpin(key)
+	c.unlock()
+}
+
+// addPinned adds a value to the cache and pins it, for loaders to protect
+// what they load until it has been handed to the callers waiting on it.
+func (c *Cache[K, V]) addPinned(key K, value V) bool {
+	c.lock.Lock()
+	defer c.unlock()
+	if c.closed {
+		return false
+	}
+	evicted := c.lru.Add(key, value)
+	c.lru.Pin(key)
+	return evicted
+}
+
+// RemoveFunc removes every entry for which fn returns true, and returns
+// how many it removed.  The cache is locked while fn is called for each
+// entry, so fn must not call back into it.
+func (c *Cache[K, V]) RemoveFunc(fn func(key K, value V) bool) (removed int) {
+	c.lock.Lock()
+	removed = c.lru.RemoveFunc(fn)
+	c.unlock()
+	return removed
 }
 
 // Resize changes the cache size.
 func (c *Cache[K, V]) Resize(size int) (evicted int) {
 	c.lock.Lock()
+	if c.closed {
+		c.unlock()
+		return 0
+	}
+	oldSize := c.lru.Cap()
 	evicted = c.lru.Resize(size)
-	c.lock.Unlock()
+	c.unlock()
+	if c.logger != nil {
+		c.logger.Info("lru: resized cache", "oldSize", oldSize, "size", size, "evicted", evicted)
+	}
 	return evicted
 }
 
@@ -124,3 +480,167 @@ func (c *Cache[K, V]) Len() int {
 	c.lock.RUnlock()
 	return length
 }
+
+// Holes returns the number of slots wasted on removed entries.  See
+// simplelru.LRU.Holes.
+func (c *Cache[K, V]) Holes() int {
+	c.lock.RLock()
+	holes := c.lru.Holes()
+	c.lock.RUnlock()
+	return holes
+}
+
+// Occupancy returns how full the cache's slots are.  See
+// simplelru.Occupancy.
+func (c *Cache[K, V]) Occupancy() simplelru.Occupancy {
+	c.lock.RLock()
+	o := c.lru.Occupancy()
+	c.lock.RUnlock()
+	return o
+}
+
+// Compact reclaims the slots wasted on removed entries, returning how
+// many it reclaimed.  See simplelru.LRU.Compact.
+func (c *Cache[K, V]) Compact() (reclaimed int) {
+	c.lock.Lock()
+	reclaimed = c.lru.Compact()
+	c.lock.Unlock()
+	return reclaimed
+}
+
+// CostLen returns the total cost of the items in the cache, as measured
+// by the function given WithCost, or 0 if none was.
+func (c *Cache[K, V]) CostLen() int64 {
+	c.lock.RLock()
+	cost := c.lru.Cost()
+	c.lock.RUnlock()
+	return cost
+}
+
+// UpdateCost measures key's value again with the function given
+// WithCost, for values that have grown or shrunk in place since they were
+// added, and adjusts CostLen to match.  It returns false if key isn't
+// cached, or if the cache wasn't created WithCost.
+func (c *Cache[K, V]) UpdateCost(key K) bool {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.lru.UpdateCost(key)
+}
+
+// Cap returns the maximum number of items the cache can hold.
+func (c *Cache[K, V]) Cap() int {
+	c.lock.RLock()
+	capacity := c.lru.Cap()
+	c.lock.RUnlock()
+	return capacity
+}
+
+// Range calls fn for each entry in the cache, in no particular order,
+// until fn returns false.  It doesn't update the recent-ness of entries.
+// The cache is locked for the duration, so fn must not call back into it.
+func (c *Cache[K, V]) Range(fn func(key K, value V) bool) {
+	c.lock.RLock()
+	c.lru.Range(fn)
+	c.lock.RUnlock()
+}
+
+// WarmUp bulk-loads entries, ordered least recently used first, for
+// preloading the cache at startup.  See simplelru.LRU.WarmUp.
+func (c *Cache[K, V]) WarmUp(entries []simplelru.Entry[K, V]) {
+	c.lock.Lock()
+	if !c.closed {
+		c.lru.WarmUp(entries)
+	}
+	c.unlock()
+}
+
+// Validate checks the cache's internal invariants, as
+// simplelru.LRU.Validate does.  It is for tests, fuzzing and canary
+// checks, and holds the lock while it walks every entry.
+func (c *Cache[K, V]) Validate() error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.lru.Validate()
+}
+
+// Stats returns a snapshot of the cache's counters.
+func (c *Cache[K, V]) Stats() simplelru.Stats {
+	c.lock.RLock()
+	stats := c.lru.Stats()
+	c.lock.RUnlock()
+	return stats
+}
+
+// StatsSnapshot returns the cache's counters along with the time they
+// were read.  Comparing two snapshots with Delta gives the counts, rates
+// and hit ratio over the interval between them.
+func (c *Cache[K, V]) StatsSnapshot() simplelru.StatsSnapshot {
+	c.lock.RLock()
+	snap := c.lru.StatsSnapshot()
+	c.lock.RUnlock()
+	return snap
+}
+
+// HitRatio returns the hit ratio over roughly the last window lookups,
+// or 0 if there have been no lookups.  Unlike Stats().HitRatio(), this
+// tracks recent behavior rather than the lifetime of the cache.
+func (c *Cache[K, V]) HitRatio(window int) float64 {
+	c.lock.RLock()
+	ratio := c.lru.HitRatio(window)
+	c.lock.RUnlock()
+	return ratio
+}
+
+// ClassStats returns a snapshot of the per-class counters, keyed by class
+// name.  It returns nil if the cache was not created WithClassifier.
+func (c *Cache[K, V]) ClassStats() map[string]simplelru.ClassStats {
+	c.lock.RLock()
+	classes := c.lru.ClassStats()
+	c.lock.RUnlock()
+	return classes
+}
+
+// HotKeys returns up to k of the most frequently looked-up keys, most
+// frequent first.  It returns nil unless the cache was created
+// WithHotKeys.
+func (c *Cache[K, V]) HotKeys(k int) []simplelru.HotKey[K] {
+	c.lock.RLock()
+	hot := c.lru.HotKeys(k)
+	c.lock.RUnlock()
+	return hot
+}
+
+// DecayHotKeys halves the counts tracked WithHotKeys now.  See
+// simplelru.LRU.DecayHotKeys.
+func (c *Cache[K, V]) DecayHotKeys() {
+	c.lock.Lock()
+	c.lru.DecayHotKeys()
+	c.lock.Unlock()
+}
+
+// EstimateHitRatioAt returns the hit ratio the cache would be expected to
+// achieve if it had the given size.  It returns 0 unless the cache was
+// created WithMissRatioCurve.
+func (c *Cache[K, V]) EstimateHitRatioAt(size int) float64 {
+	c.lock.RLock()
+	ratio := c.lru.EstimateHitRatioAt(size)
+	c.lock.RUnlock()
+	return ratio
+}
+
+// ShadowStats returns a snapshot of the counters of each shadow cache
+// given WithShadow, by name, or nil if there are none.
+func (c *Cache[K, V]) ShadowStats() map[string]simplelru.ShadowStats {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.lru.ShadowStats()
+}
+
+// LatencyStats returns histograms of Add and Get latencies.  It returns
+// empty histograms unless the cache was created WithLatencyTracking.
+func (c *Cache[K, V]) LatencyStats() LatencyStats {
+	if c.latency == nil {
+		return LatencyStats{}
+	}
+	return c.latency.stats()
+}
@@ -1,12 +1,19 @@
 package lru
 
 import (
+	"bytes"
 	crand "crypto/rand"
 	"encoding/binary"
+	"log/slog"
 	"math/rand"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/bpowers/approx-lru/simplelru"
+	"github.com/bpowers/approx-lru/workloads"
 )
 
 func newRand() *rand.Rand {
@@ -80,6 +87,28 @@ func BenchmarkLRU_Freq(b *testing.B) {
 	b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
 }
 
+func BenchmarkLRU_Zipf(b *testing.B) {
+	l, err := New[uint64, struct{}](8192)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+
+	trace := workloads.Take(workloads.Zipf(1, 1.1, 1, 1<<20), b.N)
+
+	b.ResetTimer()
+
+	var hit, miss int
+	for _, key := range trace {
+		if _, ok := l.Get(key); ok {
+			hit++
+		} else {
+			miss++
+			l.Add(key, struct{}{})
+		}
+	}
+	b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(hit+miss))
+}
+
 func BenchmarkLRU_Big(b *testing.B) {
 	var rngMu sync.Mutex
 	rng := newRand()
@@ -327,6 +356,25 @@ func TestLRUPeek(t *testing.T) {
 	}
 }
 
+func TestLRUApproxAge(t *testing.T) {
+	l, err := New[int, int](2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	start := time.Now()
+	l.Add(1, 1)
+	if _, ok := l.ApproxAge(2); ok {
+		t.Fatalf("expected no age for a missing key")
+	}
+	age, ok := l.ApproxAge(1)
+	if !ok || age < 0 || age > time.Since(start)+time.Second {
+		t.Fatalf("bad age: %v, %v", age, ok)
+	}
+	if last, ok := l.LastUsed(1); !ok || last.After(time.Now()) {
+		t.Fatalf("bad last use: %v, %v", last, ok)
+	}
+}
+
 // test that Resize can upsize and downsize
 func TestLRUResize(t *testing.T) {
 	onEvictCounter := 0
@@ -366,3 +414,168 @@ func TestLRUResize(t *testing.T) {
 		t.Errorf("Cache should have contained 2 elements")
 	}
 }
+
+// test that notable events are logged
+func TestLRULogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	l, err := New[int, int](2, WithLogger[int, int](logger))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 2000; i++ {
+		l.Add(i, i)
+	}
+	l.Resize(1)
+	l.Purge()
+
+	out := buf.String()
+	for _, msg := range []string{"created cache", "resized cache", "purged cache"} {
+		if strings.Count(out, msg) != 1 {
+			t.Errorf("expected %q to be logged once:\n%s", msg, out)
+		}
+	}
+	if n := strings.Count(out, "evicted entry"); n != 2 {
+		t.Errorf("expected 2 sampled evictions to be logged, got %d:\n%s", n, out)
+	}
+}
+
+func TestLRUWarmUp(t *testing.T) {
+	l, err := New[int, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	entries := make([]simplelru.Entry[int, int], 200)
+	for i := range entries {
+		entries[i] = simplelru.Entry[int, int]{Key: i, Value: i}
+	}
+	l.WarmUp(entries)
+	if l.Len() != 128 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if v, ok := l.Peek(199); !ok || v != 199 {
+		t.Fatalf("expected the most recent entry to be loaded")
+	}
+}
+
+func TestLRUReentrantEvict(t *testing.T) {
+	var l *Cache[int, int]
+	var tombstones int
+	l, err := NewWithEvict[int, int](128, func(k, v int) {
+		// the callback runs without the lock, so it can modify the cache
+		if v >= 0 {
+			l.Add(-k-1, -1)
+			tombstones++
+		}
+		l.Contains(k)
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 256; i++ {
+		l.Add(i, i)
+	}
+	if tombstones == 0 {
+		t.Fatalf("expected evictions to add tombstones")
+	}
+	if l.Len() != 128 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+}
+
+func TestLRURemoveFunc(t *testing.T) {
+	l, err := New[string, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add("user/1", 1)
+	l.Add("user/2", 2)
+	l.Add("post/1", 3)
+	n := l.RemoveFunc(func(k string, _ int) bool {
+		return strings.HasPrefix(k, "user/")
+	})
+	if n != 2 || l.Len() != 1 || !l.Contains("post/1") {
+		t.Fatalf("bad removals %d or len %d", n, l.Len())
+	}
+}
+
+type panicListener struct {
+	simplelru.NopListener[int, int]
+}
+
+func (panicListener) OnHit(key, value int) {
+	panic("boom")
+}
+
+func TestLRUWithRecover(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	l, err := NewWithEvict[int, int](1, func(k, v int) { panic("bang") },
+		WithListener[int, int](panicListener{}), WithRecover[int, int](), WithLogger[int, int](logger))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Get(1)
+	l.Add(2, 2)
+	// the cache isn't left locked
+	if v, ok := l.Peek(2); !ok || v != 2 {
+		t.Fatalf("bad value: %v, %v", v, ok)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "callback=OnHit") || !strings.Contains(out, "callback=onEvict") {
+		t.Fatalf("expected the panics to be logged:\n%s", out)
+	}
+}
+
+func TestAddReturningEvicted(t *testing.T) {
+	l, err := New[int, int](1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 10)
+	if k, v, evicted := l.AddReturningEvicted(2, 20); !evicted || k != 1 || v != 10 {
+		t.Fatalf("expected 1 to be evicted, got %v %v %v", k, v, evicted)
+	}
+
+	sharded, err := NewSharded[int](1, 1, WithWriteBuffer[string, int](16, 0))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer sharded.Close()
+	sharded.Add("a", 1)
+	if k, v, evicted := sharded.AddReturningEvicted("b", 2); !evicted || k != "a" || v != 1 {
+		t.Fatalf("expected a to be evicted, got %v %v %v", k, v, evicted)
+	}
+}
+
+func TestPinFor(t *testing.T) {
+	l, err := New[string, int](8)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sharded, err := NewSharded[int](8, 1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, c := range []interface {
+		Add(key string, value int) bool
+		Contains(key string) bool
+		PinFor(key string, d time.Duration) bool
+	}{l, sharded} {
+		for i := 0; i < 8; i++ {
+			c.Add(strconv.Itoa(i), i)
+		}
+		if !c.PinFor("0", time.Hour) || !c.PinFor("1", time.Millisecond) {
+			t.Fatalf("expected pinning to succeed")
+		}
+		time.Sleep(5 * time.Millisecond)
+		for i := 8; i < 100; i++ {
+			c.Add(strconv.Itoa(i), i)
+		}
+		if !c.Contains("0") || c.Contains("1") {
+			t.Fatalf("expected only the entry whose pin hasn't lapsed to survive")
+		}
+	}
+}
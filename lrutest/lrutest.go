@@ -0,0 +1,204 @@
+// Package lrutest provides utilities for testing caches built on this
+// module, such as wrappers around lru.Cache: a differential harness that
+// replays operations against a cache and an exact LRU side by side,
+// checking that the cache never returns a wrong value and measuring how
+// far its evictions diverge from exact recency order.
+package lrutest
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+// Cache is the subset of a cache's methods that Compare exercises.
+type Cache[K comparable, V comparable] interface {
+	Add(key K, value V) bool
+	Get(key K) (value V, ok bool)
+	Remove(key K) bool
+	Len() int
+}
+
+// Validator is implemented by caches that can check their own internal
+// invariants, like lru.Cache and simplelru.LRU.  Compare calls Validate
+// after every operation on caches that implement it.
+type Validator interface {
+	Validate() error
+}
+
+// OpKind is the kind of an Op.
+type OpKind uint8
+
+const (
+	// OpAdd adds Value under Key.
+	OpAdd OpKind = iota
+	// OpGet looks Key up.
+	OpGet
+	// OpRemove removes Key.
+	OpRemove
+)
+
+func (k OpKind) String() string {
+	switch k {
+	case OpAdd:
+		return "add"
+	case OpGet:
+		return "get"
+	case OpRemove:
+		return "remove"
+	default:
+		return fmt.Sprintf("OpKind(%d)", uint8(k))
+	}
+}
+
+// Op is a single cache operation.  Value is only used by OpAdd.
+type Op[K comparable, V comparable] struct {
+	Kind  OpKind
+	Key   K
+	Value V
+}
+
+func (op Op[K, V]) String() string {
+	if op.Kind == OpAdd {
+		return fmt.Sprintf("add(%v, %v)", op.Key, op.Value)
+	}
+	return fmt.Sprintf("%v(%v)", op.Kind, op.Key)
+}
+
+// Report describes how a cache's behavior diverged from an exact LRU of
+// the same size over a sequence of operations.
+type Report struct {
+	Ops  int
+	Gets int
+	// Hits and ExactHits count the Gets that hit in the cache under test
+	// and in the exact LRU.
+	Hits      int
+	ExactHits int
+	// Disagreements counts the Gets that hit in one cache but not the
+	// other.  An approximate LRU evicts some entries an exact one would
+	// keep, and vice versa, so this is usually non-zero.
+	Disagreements int
+}
+
+// HitRatio returns the cache under test's hit ratio, or 0 if there were
+// no Gets.
+func (r Report) HitRatio() float64 {
+	if r.Gets == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(r.Gets)
+}
+
+// ExactHitRatio returns the exact LRU's hit ratio, or 0 if there were no
+// Gets.
+func (r Report) ExactHitRatio() float64 {
+	if r.Gets == 0 {
+		return 0
+	}
+	return float64(r.ExactHits) / float64(r.Gets)
+}
+
+// Compare applies ops to c and to an exact LRU of the given size, which
+// should be c's capacity.  It returns an error, naming the offending
+// operation, if c returns a value other than the one last added for a
+// key, returns a key that was removed, holds more than size entries, or
+// fails Validate.  Otherwise it returns a Report of how c's hits diverged
+// from the exact LRU's.  c should start out empty.
+func Compare[K comparable, V comparable](c Cache[K, V], size int, ops []Op[K, V]) (Report, error) {
+	var report Report
+	exact, err := simplelru.NewExactLRU[K, V](size, nil)
+	if err != nil {
+		return report, err
+	}
+	validator, _ := c.(Validator)
+	// live holds the last value added for each key that hasn't been
+	// removed since; anything c returns must agree with it.
+	live := make(map[K]V)
+	for i, op := range ops {
+		report.Ops++
+		switch op.Kind {
+		case OpAdd:
+			c.Add(op.Key, op.Value)
+			exact.Add(op.Key, op.Value)
+			live[op.Key] = op.Value
+		case OpGet:
+			report.Gets++
+			v, ok := c.Get(op.Key)
+			_, exactOK := exact.Get(op.Key)
+			if ok {
+				report.Hits++
+				if expected, present := live[op.Key]; !present {
+					return report, fmt.Errorf("op %d, %v: returned %v for a key that isn't present", i, op, v)
+				} else if v != expected {
+					return report, fmt.Errorf("op %d, %v: returned %v, expected %v", i, op, v, expected)
+				}
+			}
+			if exactOK {
+				report.ExactHits++
+			}
+			if ok != exactOK {
+				report.Disagreements++
+			}
+		case OpRemove:
+			present := c.Remove(op.Key)
+			exact.Remove(op.Key)
+			if _, ok := live[op.Key]; present && !ok {
+				return report, fmt.Errorf("op %d, %v: removed a key that isn't present", i, op)
+			}
+			delete(live, op.Key)
+		default:
+			return report, fmt.Errorf("op %d: unknown kind %v", i, op.Kind)
+		}
+		if n := c.Len(); n > size {
+			return report, fmt.Errorf("op %d, %v: holds %d entries, more than %d", i, op, n, size)
+		}
+		if validator != nil {
+			if err := validator.Validate(); err != nil {
+				return report, fmt.Errorf("op %d, %v: %w", i, op, err)
+			}
+		}
+	}
+	return report, nil
+}
+
+// RandomOps returns n operations on keys in [0, keys), with values equal
+// to the operation's index, drawn from r.  Keys are skewed so that low
+// keys are more popular, giving an LRU something to keep; roughly half of
+// the operations are Gets, and one in twenty is a Remove.
+func RandomOps(r *rand.Rand, n, keys int) []Op[int, int] {
+	if keys < 2 {
+		keys = 2
+	}
+	zipf := rand.NewZipf(r, 1.1, 1, uint64(keys-1))
+	ops := make([]Op[int, int], n)
+	for i := range ops {
+		op := Op[int, int]{Key: int(zipf.Uint64()), Value: i}
+		switch p := r.Intn(20); {
+		case p == 0:
+			op.Kind = OpRemove
+		case p < 10:
+			op.Kind = OpGet
+		default:
+			op.Kind = OpAdd
+		}
+		ops[i] = op
+	}
+	return ops
+}
+
+// OpsFromBytes decodes data into operations, two bytes per operation: the
+// first picks the kind and the second the key.  Values are the
+// operation's index.  It lets a fuzz target turn its input into a
+// sequence of operations for Compare.
+func OpsFromBytes(data []byte) []Op[int, int] {
+	ops := make([]Op[int, int], 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		ops = append(ops, Op[int, int]{
+			Kind:  OpKind(data[i] % 3),
+			Key:   int(data[i+1]),
+			Value: len(ops),
+		})
+	}
+	return ops
+}
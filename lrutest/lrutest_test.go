@@ -0,0 +1,104 @@
+package lrutest
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	lru "github.com/bpowers/approx-lru"
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+func TestCompare(t *testing.T) {
+	ops := RandomOps(rand.New(rand.NewSource(1)), 20000, 2048)
+
+	l, err := simplelru.NewLRU[int, int](512, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	report, err := Compare[int, int](l, 512, ops)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if report.Ops != len(ops) || report.Gets == 0 {
+		t.Fatalf("bad report: %+v", report)
+	}
+	// the approximation shouldn't cost much hit ratio on a skewed workload
+	if diff := report.ExactHitRatio() - report.HitRatio(); diff > 0.05 || diff < -0.05 {
+		t.Fatalf("hit ratio %v too far from exact %v", report.HitRatio(), report.ExactHitRatio())
+	}
+
+	exact, err := simplelru.NewExactLRU[int, int](512, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	report, err = Compare[int, int](exact, 512, ops)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if report.Disagreements != 0 || report.Hits != report.ExactHits {
+		t.Fatalf("expected an exact LRU not to diverge: %+v", report)
+	}
+}
+
+// stale is a broken cache that never forgets a key.
+type stale struct {
+	values map[int]int
+}
+
+func (s *stale) Add(key, value int) bool {
+	if _, ok := s.values[key]; !ok {
+		s.values[key] = value
+	}
+	return false
+}
+
+func (s *stale) Get(key int) (int, bool) {
+	v, ok := s.values[key]
+	return v, ok
+}
+
+func (s *stale) Remove(key int) bool {
+	_, ok := s.values[key]
+	return ok
+}
+
+func (s *stale) Len() int {
+	return len(s.values)
+}
+
+func TestCompareBroken(t *testing.T) {
+	ops := []Op[int, int]{
+		{Kind: OpAdd, Key: 1, Value: 1},
+		{Kind: OpAdd, Key: 1, Value: 2},
+		{Kind: OpGet, Key: 1},
+	}
+	_, err := Compare[int, int](&stale{make(map[int]int)}, 4, ops)
+	if err == nil || !strings.Contains(err.Error(), "op 2, get(1)") {
+		t.Fatalf("expected a stale value to be reported, got %v", err)
+	}
+
+	ops = []Op[int, int]{
+		{Kind: OpAdd, Key: 1, Value: 1},
+		{Kind: OpRemove, Key: 1},
+		{Kind: OpGet, Key: 1},
+	}
+	_, err = Compare[int, int](&stale{make(map[int]int)}, 4, ops)
+	if err == nil || !strings.Contains(err.Error(), "isn't present") {
+		t.Fatalf("expected a removed key to be reported, got %v", err)
+	}
+}
+
+func FuzzCompare(f *testing.F) {
+	f.Add([]byte{0, 1, 0, 2, 1, 1, 2, 1, 1, 1})
+	f.Add([]byte("add, get and remove some keys, twice over"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		l, err := lru.New[int, int](16)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if _, err := Compare[int, int](l, 16, OpsFromBytes(data)); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package lru
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+// Managed is the subset of a cache's methods that a Manager uses, which
+// Cache and ShardedCache implement.
+type Managed interface {
+	Len() int
+	Cap() int
+	Resize(size int) (evicted int)
+	Stats() simplelru.Stats
+	Close() error
+}
+
+var (
+	_ Managed = (*Cache[int, int])(nil)
+	_ Managed = (*ShardedCache[int])(nil)
+)
+
+// Manager owns a set of named caches, and divides a budget of entries
+// between them in proportion to their weights, so that a service with
+// many caches can size them together and see their stats in one place.
+type Manager struct {
+	mu     sync.Mutex
+	budget int
+	caches map[string]*managedCache
+	closed bool
+}
+
+type managedCache struct {
+	cache  Managed
+	weight int
+}
+
+// NewManager creates a Manager with a budget of the given number of
+// entries.
+func NewManager(budget int) (*Manager, error) {
+	if budget <= 0 {
+		return nil, errors.New("must provide a positive budget")
+	}
+	return &Manager{
+		budget: budget,
+		caches: make(map[string]*managedCache),
+	}, nil
+}
+
+// Register adds cache to the manager under name, and resizes every cache
+// so that each gets its weight's share of the budget.  The manager owns
+// cache from then on, and closes it on Close.
+func (m *Manager) Register(name string, cache Managed, weight int) error {
+	if weight <= 0 {
+		return errors.New("must provide a positive weight")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	if _, ok := m.caches[name]; ok {
+		return fmt.Errorf("lru: cache %q already registered", name)
+	}
+	m.caches[name] = &managedCache{cache: cache, weight: weight}
+	m.rebalance()
+	return nil
+}
+
+// Unregister removes the cache registered under name, returning it, and
+// gives its share of the budget to the others.  The caller becomes
+// responsible for closing it.
+func (m *Manager) Unregister(name string) (Managed, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mc, ok := m.caches[name]
+	if !ok {
+		return nil, false
+	}
+	delete(m.caches, name)
+	m.rebalance()
+	return mc.cache, true
+}
+
+// Get returns the cache registered under name.
+func (m *Manager) Get(name string) (Managed, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mc, ok := m.caches[name]
+	if !ok {
+		return nil, false
+	}
+	return mc.cache, true
+}
+
+// Names returns the names of the registered caches, sorted.
+func (m *Manager) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.caches))
+	for name := range m.caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetBudget changes the budget, resizing every cache to its new share.
+func (m *Manager) SetBudget(budget int) error {
+	if budget <= 0 {
+		return errors.New("must provide a positive budget")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.budget = budget
+	m.rebalance()
+	return nil
+}
+
+// rebalance resizes every cache to its share of the budget.  Each gets
+// at least one entry, so a budget smaller than the number of caches is
+// exceeded.
+func (m *Manager) rebalance() {
+	total := 0
+	for _, mc := range m.caches {
+		total += mc.weight
+	}
+	for _, mc := range m.caches {
+		size := int(int64(m.budget) * int64(mc.weight) / int64(total))
+		if size < 1 {
+			size = 1
+		}
+		mc.cache.Resize(size)
+	}
+}
+
+// Stats returns a snapshot of each registered cache's counters, by name.
+func (m *Manager) Stats() map[string]simplelru.Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := make(map[string]simplelru.Stats, len(m.caches))
+	for name, mc := range m.caches {
+		stats[name] = mc.cache.Stats()
+	}
+	return stats
+}
+
+// TotalStats returns the counters of every registered cache merged
+// together.
+func (m *Manager) TotalStats() (stats simplelru.Stats) {
+	for _, s := range m.Stats() {
+		stats.Merge(&s)
+	}
+	return stats
+}
+
+// Len returns the number of entries in all of the registered caches.
+func (m *Manager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, mc := range m.caches {
+		n += mc.cache.Len()
+	}
+	return n
+}
+
+// Close closes every registered cache, returning their errors joined
+// together.  Close returns ErrClosed if the manager was already closed.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	m.closed = true
+	var errs []error
+	for name, mc := range m.caches {
+		if err := mc.cache.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,82 @@
+package lru
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestManager(t *testing.T) {
+	m, err := NewManager(1024)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	small, err := New[int, int](16)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sharded, err := NewSharded[int](16, 4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := m.Register("small", small, 1); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if small.Cap() != 1024 {
+		t.Fatalf("a lone cache should get the whole budget: %d", small.Cap())
+	}
+	if err := m.Register("sharded", sharded, 3); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if small.Cap() != 256 || sharded.Cap() != 768 {
+		t.Fatalf("bad shares: %d, %d", small.Cap(), sharded.Cap())
+	}
+	if err := m.Register("small", small, 1); err == nil {
+		t.Fatalf("expected registering a name twice to fail")
+	}
+
+	for i := 0; i < 1000; i++ {
+		small.Add(i, i)
+		small.Get(i)
+		sharded.Add(strconv.Itoa(i), i)
+	}
+	stats := m.Stats()
+	if stats["small"].Hits != 1000 {
+		t.Fatalf("bad stats: %+v", stats["small"])
+	}
+	if total := m.TotalStats(); total.Hits != 1000 || total.Evictions != stats["small"].Evictions+stats["sharded"].Evictions {
+		t.Fatalf("bad total stats: %+v", total)
+	}
+	if m.Len() != small.Len()+sharded.Len() {
+		t.Fatalf("bad len: %d", m.Len())
+	}
+
+	if err := m.SetBudget(512); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if small.Cap() != 128 || sharded.Cap() != 384 || small.Len() > 128 || sharded.Len() > 384 {
+		t.Fatalf("bad shares: %d, %d", small.Cap(), sharded.Cap())
+	}
+
+	if c, ok := m.Unregister("small"); !ok || c != small {
+		t.Fatalf("expected to unregister small")
+	}
+	if sharded.Cap() != 512 {
+		t.Fatalf("expected sharded to get the whole budget: %d", sharded.Cap())
+	}
+	if names := m.Names(); len(names) != 1 || names[0] != "sharded" {
+		t.Fatalf("bad names: %v", names)
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if sharded.Close() != ErrClosed {
+		t.Fatalf("expected the manager to close its caches")
+	}
+	if m.Close() != ErrClosed {
+		t.Fatalf("expected closing twice to fail")
+	}
+	if small.Close() != nil {
+		t.Fatalf("unregistered caches shouldn't be closed")
+	}
+}
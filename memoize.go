@@ -0,0 +1,20 @@
+package lru
+
+import "context"
+
+// Memoize returns a function that calls f, remembering the results for up
+// to size of the most recently used keys.  Concurrent calls with the same
+// key share a single call to f, and errors aren't remembered, so a failed
+// call is retried by the next.  opts configure the LoadingCache the
+// results are kept in; WithExpireAfterWrite gives them a time to live.
+func Memoize[K comparable, V any](f func(key K) (V, error), size int, opts ...LoadingOption[K, V]) (func(key K) (V, error), error) {
+	cache, err := NewLoading[K, V](size, func(_ context.Context, key K) (V, error) {
+		return f(key)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return func(key K) (V, error) {
+		return cache.Get(context.Background(), key)
+	}, nil
+}
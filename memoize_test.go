@@ -0,0 +1,76 @@
+package lru
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoize(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	square, err := Memoize(func(n int) (int, error) {
+		calls.Add(1)
+		<-release
+		if n < 0 {
+			return 0, errors.New("negative")
+		}
+		return n * n, nil
+	}, 16)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// concurrent calls share one call to f
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := square(3); err != nil || v != 9 {
+				t.Errorf("expected 9, got %d %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if v, err := square(3); err != nil || v != 9 {
+		t.Fatalf("expected 9, got %d %v", v, err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected 1 call, got %d", n)
+	}
+
+	// errors aren't remembered
+	for i := 0; i < 2; i++ {
+		if _, err := square(-1); err == nil {
+			t.Fatalf("expected an error")
+		}
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("expected failed calls to be retried, got %d calls", n)
+	}
+}
+
+func TestMemoizeTTL(t *testing.T) {
+	now := time.Unix(0, 0)
+	calls := 0
+	f, err := Memoize(func(key string) (int, error) {
+		calls++
+		return calls, nil
+	}, 16, WithExpireAfterWrite[string, int](time.Minute), WithClock[string, int](func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f("a")
+	if v, _ := f("a"); v != 1 {
+		t.Fatalf("expected a remembered result, got %d", v)
+	}
+	now = now.Add(time.Minute)
+	if v, _ := f("a"); v != 2 {
+		t.Fatalf("expected an expired result to be recomputed, got %d", v)
+	}
+}
@@ -0,0 +1,148 @@
+package lru
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"math"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"sync"
+)
+
+// sizeSample is how many entries ResizeToMemory measures to estimate the
+// average entry size.
+const sizeSample = 1024
+
+// memoryLimit returns the memory available to the process, in bytes.  It
+// is a variable so that tests can fake it.
+var memoryLimit = processMemoryLimit
+
+// processMemoryLimit returns the Go runtime's soft memory limit, as set by
+// GOMEMLIMIT or debug.SetMemoryLimit, or failing that the cgroup memory
+// limit, or failing that the machine's total memory.
+func processMemoryLimit() (int64, error) {
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		return limit, nil
+	}
+	for _, path := range []string{
+		"/sys/fs/cgroup/memory.max",
+		"/sys/fs/cgroup/memory/memory.limit_in_bytes",
+	} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		limit, err := strconv.ParseInt(string(bytes.TrimSpace(data)), 10, 64)
+		// cgroup v2 says "max", and v1 a huge number, for no limit
+		if err == nil && limit > 0 && limit < 1<<62 {
+			return limit, nil
+		}
+	}
+	if total, ok := totalMemory(); ok {
+		return total, nil
+	}
+	return 0, errors.New("lru: can't determine the memory limit")
+}
+
+// totalMemory returns the machine's total memory from /proc/meminfo.
+func totalMemory() (int64, bool) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := bytes.Fields(scanner.Bytes())
+		if len(fields) >= 2 && string(fields[0]) == "MemTotal:" {
+			kb, err := strconv.ParseInt(string(fields[1]), 10, 64)
+			return kb * 1024, err == nil
+		}
+	}
+	return 0, false
+}
+
+// memoryFraction sizes a cache to a fraction of the memory limit.
+type memoryFraction[K comparable, V any] struct {
+	// mu serializes ResizeToMemory.
+	mu       sync.Mutex
+	fraction float64
+	// entrySize is the average entry size, as estimated when the cache
+	// was created or last resized.
+	entrySize int
+	sizer     func(key K, value V) int
+}
+
+// entries returns how many entries fit in the fraction of the memory
+// limit.
+func (m *memoryFraction[K, V]) entries() (int, error) {
+	limit, err := memoryLimit()
+	if err != nil {
+		return 0, err
+	}
+	n := int(float64(limit) * m.fraction / float64(m.entrySize))
+	if n < 1 {
+		n = 1
+	}
+	return n, nil
+}
+
+// NewWithMemoryFraction creates a cache sized to hold fraction of the
+// process's memory limit, for binaries deployed on machines of very
+// different sizes.  The limit is the Go runtime's soft memory limit, as
+// set by GOMEMLIMIT, or failing that the cgroup memory limit, or failing
+// that the machine's total memory.
+//
+// The number of entries is the budget divided by the average entry size,
+// which starts out as entrySize and is re-estimated with sizer, which
+// returns an entry's size in bytes, by ResizeToMemory.  sizer may be nil
+// to always use entrySize.
+func NewWithMemoryFraction[K comparable, V any](fraction float64, entrySize int, sizer func(key K, value V) int, opts ...Option[K, V]) (*Cache[K, V], error) {
+	if fraction <= 0 || fraction > 1 {
+		return nil, errors.New("must provide a fraction between 0 and 1")
+	}
+	if entrySize <= 0 {
+		return nil, errors.New("must provide a positive entry size")
+	}
+	m := &memoryFraction[K, V]{fraction: fraction, entrySize: entrySize, sizer: sizer}
+	size, err := m.entries()
+	if err != nil {
+		return nil, err
+	}
+	c, err := New[K, V](size, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.memory = m
+	return c, nil
+}
+
+// ResizeToMemory re-reads the memory limit and re-estimates the average
+// entry size from a sample of the entries, and resizes a cache created by
+// NewWithMemoryFraction to match.  It returns how many entries were
+// evicted.  For other caches it does nothing.
+func (c *Cache[K, V]) ResizeToMemory() (evicted int, err error) {
+	if c.memory == nil {
+		return 0, nil
+	}
+	c.memory.mu.Lock()
+	defer c.memory.mu.Unlock()
+	if c.memory.sizer != nil {
+		total, n := 0, 0
+		c.Range(func(key K, value V) bool {
+			total += c.memory.sizer(key, value)
+			n++
+			return n < sizeSample
+		})
+		if n > 0 && total > 0 {
+			c.memory.entrySize = (total + n - 1) / n
+		}
+	}
+	size, err := c.memory.entries()
+	if err != nil {
+		return 0, err
+	}
+	return c.Resize(size), nil
+}
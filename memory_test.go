@@ -0,0 +1,61 @@
+package lru
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMemoryFraction(t *testing.T) {
+	limit := int64(1 << 20)
+	memoryLimit = func() (int64, error) { return limit, nil }
+	defer func() { memoryLimit = processMemoryLimit }()
+
+	sizer := func(key int, value string) int { return 8 + len(value) }
+	l, err := NewWithMemoryFraction[int, string](0.25, 256, sizer)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// a quarter of 1MiB holds 1024 entries of 256 bytes
+	if l.Cap() != 1024 {
+		t.Fatalf("bad cap: %v", l.Cap())
+	}
+
+	value := strings.Repeat("x", 1016)
+	for i := 0; i < 1024; i++ {
+		l.Add(i, value)
+	}
+	evicted, err := l.ResizeToMemory()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// entries turned out to be 1KiB
+	if l.Cap() != 256 || evicted != 768 {
+		t.Fatalf("bad cap: %v, evicted %v", l.Cap(), evicted)
+	}
+
+	limit = 4 << 20
+	if _, err := l.ResizeToMemory(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if l.Cap() != 1024 {
+		t.Fatalf("expected the cache to grow with the limit, got %v", l.Cap())
+	}
+
+	if _, err := NewWithMemoryFraction[int, string](1.5, 256, sizer); err == nil {
+		t.Fatalf("expected a bad fraction to fail")
+	}
+	plain, _ := New[int, int](8)
+	if n, err := plain.ResizeToMemory(); n != 0 || err != nil || plain.Cap() != 8 {
+		t.Fatalf("expected other caches to be left alone")
+	}
+}
+
+func TestProcessMemoryLimit(t *testing.T) {
+	limit, err := processMemoryLimit()
+	if err != nil {
+		t.Skipf("no memory limit: %v", err)
+	}
+	if limit <= 0 {
+		t.Fatalf("bad limit: %v", limit)
+	}
+}
@@ -0,0 +1,199 @@
+package lru
+
+import (
+	"errors"
+	"math"
+	"sync"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+// ErrNotFound is returned by a loader to report authoritatively that a key
+// doesn't exist, as opposed to failing to load it.  Loaders may wrap it.
+// A cache created WithNegativeFilter remembers keys that loaders report
+// as not found.
+var ErrNotFound = errors.New("lru: not found")
+
+// WithNegativeFilter remembers, in a bloom filter, keys that recently
+// failed to load because the loader returned ErrNotFound, or because the
+// loader given WithBatchLoader returned no value for them.  Loads of them
+// fail with ErrNotFound without calling the loader, and without taking up
+// a slot in the cache, so repeated lookups of keys that don't exist don't
+// reach the backend.
+//
+// The filter holds about capacity keys with the given false positive
+// rate; once it's full, it starts afresh, keeping the keys it held before
+// for one more generation.  A false positive makes a key that does exist
+// fail to load until then, or until ResetNegativeFilter is called, so
+// callers that create keys should reset it, or pick a rate they can live
+// with.  Keys in the cache are served from it regardless of the filter.
+func WithNegativeFilter[K comparable, V any](capacity int, falsePositiveRate float64) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.negativeCapacity = capacity
+		o.negativeRate = falsePositiveRate
+	}
+}
+
+// NegativeFilterStats is a point-in-time snapshot of a negative filter's
+// counters.
+type NegativeFilterStats struct {
+	// Added is the number of keys added to the filter.
+	Added uint64
+	// Rejected is the number of loads the filter rejected.
+	Rejected uint64
+	// Rotations is the number of times the filter filled up and started
+	// afresh.
+	Rotations uint64
+	// Len is the number of keys added to the current generation.
+	Len int
+}
+
+// negativeFilter is a pair of bloom filters, of which keys are added to
+// the current and looked up in both, so that keys fall out of the filter
+// two generations after they were added.
+type negativeFilter[K comparable] struct {
+	mu       sync.Mutex
+	cur      []uint64
+	prev     []uint64
+	hashes   int
+	capacity int
+	stats    NegativeFilterStats
+}
+
+func newNegativeFilter[K comparable](capacity int, rate float64) (*negativeFilter[K], error) {
+	if capacity <= 0 {
+		return nil, nil
+	}
+	if rate <= 0 || rate >= 1 {
+		return nil, errors.New("must provide a false positive rate between 0 and 1")
+	}
+	// the optimal number of bits and hash functions for capacity keys
+	bits := math.Ceil(-float64(capacity) * math.Log(rate) / (math.Ln2 * math.Ln2))
+	words := int(bits+63) / 64
+	hashes := int(math.Round(float64(words*64) / float64(capacity) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+	return &negativeFilter[K]{
+		cur:      make([]uint64, words),
+		prev:     make([]uint64, words),
+		hashes:   hashes,
+		capacity: capacity,
+	}, nil
+}
+
+// probes calls fn with the bit index of each of key's probes.
+func (f *negativeFilter[K]) probes(key K, fn func(bit uint64)) {
+	// double hashing: derive the probes from two halves of a mixed hash
+	h := simplelru.HashKey(key)
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h1, h2 := h&0xffffffff, h>>32|1
+	n := uint64(len(f.cur) * 64)
+	for i := 0; i < f.hashes; i++ {
+		fn((h1 + uint64(i)*h2) % n)
+	}
+}
+
+// contains reports whether key may have been added, counting it as
+// rejected if so.
+func (f *negativeFilter[K]) contains(key K) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	inCur, inPrev := true, true
+	f.probes(key, func(bit uint64) {
+		inCur = inCur && f.cur[bit/64]&(1<<(bit%64)) != 0
+		inPrev = inPrev && f.prev[bit/64]&(1<<(bit%64)) != 0
+	})
+	if inCur || inPrev {
+		f.stats.Rejected++
+		return true
+	}
+	return false
+}
+
+func (f *negativeFilter[K]) add(key K) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stats.Len >= f.capacity {
+		f.prev, f.cur = f.cur, f.prev
+		clear(f.cur)
+		f.stats.Len = 0
+		f.stats.Rotations++
+	}
+	f.probes(key, func(bit uint64) {
+		f.cur[bit/64] |= 1 << (bit % 64)
+	})
+	f.stats.Len++
+	f.stats.Added++
+}
+
+func (f *negativeFilter[K]) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	clear(f.cur)
+	clear(f.prev)
+	f.stats.Len = 0
+}
+
+func (f *negativeFilter[K]) snapshot() NegativeFilterStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stats
+}
+
+// setNegativeFilter creates the filter given WithNegativeFilter, if any.
+// g may be nil, if the cache has no loader.
+func (g *loadGroup[K, V]) setNegativeFilter(o *options[K, V]) error {
+	f, err := newNegativeFilter[K](o.negativeCapacity, o.negativeRate)
+	if err != nil || f == nil {
+		return err
+	}
+	if g == nil {
+		return errors.New("negative filter needs a loader")
+	}
+	g.negative = f
+	return nil
+}
+
+// notFound reports whether err means the loader found no value for a
+// key.
+func notFound(err error) bool {
+	return errors.Is(err, ErrNotFound) || err == ErrNotLoaded
+}
+
+// NegativeFilterStats returns a snapshot of the counters of the filter
+// given WithNegativeFilter, or zero stats for a cache without one.
+func (c *Cache[K, V]) NegativeFilterStats() NegativeFilterStats {
+	if c.loads == nil || c.loads.negative == nil {
+		return NegativeFilterStats{}
+	}
+	return c.loads.negative.snapshot()
+}
+
+// ResetNegativeFilter forgets every key in the filter given
+// WithNegativeFilter, for example once keys have been created that it may
+// hold.
+func (c *Cache[K, V]) ResetNegativeFilter() {
+	if c.loads != nil && c.loads.negative != nil {
+		c.loads.negative.reset()
+	}
+}
+
+// NegativeFilterStats returns a snapshot of the counters of the filter
+// given WithNegativeFilter, or zero stats for a cache without one.
+func (c *ShardedCache[V]) NegativeFilterStats() NegativeFilterStats {
+	if c.loads == nil || c.loads.negative == nil {
+		return NegativeFilterStats{}
+	}
+	return c.loads.negative.snapshot()
+}
+
+// ResetNegativeFilter forgets every key in the filter given
+// WithNegativeFilter.
+func (c *ShardedCache[V]) ResetNegativeFilter() {
+	if c.loads != nil && c.loads.negative != nil {
+		c.loads.negative.reset()
+	}
+}
@@ -0,0 +1,109 @@
+package lru
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNegativeFilter(t *testing.T) {
+	var loads atomic.Int32
+	load := func(ctx context.Context, key int) (int, error) {
+		loads.Add(1)
+		if key < 0 {
+			return 0, fmt.Errorf("loading %d: %w", key, ErrNotFound)
+		}
+		return key * 2, nil
+	}
+	l, err := New[int, int](128, WithLoader[int, int](load), WithNegativeFilter[int, int](100, 0.001))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		if _, err := l.GetOrLoad(ctx, -1); err == nil {
+			t.Fatalf("expected ErrNotFound")
+		}
+	}
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("expected the loader to be called once, got %d", n)
+	}
+	if l.Contains(-1) || l.Len() != 0 {
+		t.Fatalf("a missing key shouldn't take up a slot")
+	}
+	if v, err := l.GetOrLoad(ctx, 1); err != nil || v != 2 {
+		t.Fatalf("bad value: %v, %v", v, err)
+	}
+	stats := l.NegativeFilterStats()
+	if stats.Added != 1 || stats.Rejected != 9 || stats.Len != 1 {
+		t.Fatalf("bad stats: %+v", stats)
+	}
+
+	l.ResetNegativeFilter()
+	if _, err := l.GetOrLoad(ctx, -1); err == nil {
+		t.Fatalf("expected ErrNotFound")
+	}
+	if n := loads.Load(); n != 3 {
+		t.Fatalf("expected Reset to forget -1, got %d loads", n)
+	}
+
+	// keys fall out two generations after they were added
+	for i := -2; i > -250; i-- {
+		l.GetOrLoad(ctx, i)
+	}
+	if l.NegativeFilterStats().Rotations != 2 {
+		t.Fatalf("bad rotations: %+v", l.NegativeFilterStats())
+	}
+	before := loads.Load()
+	l.GetOrLoad(ctx, -1)
+	if loads.Load() != before+1 {
+		t.Fatalf("expected -1 to have fallen out of the filter")
+	}
+
+	if _, err := New[int, int](128, WithNegativeFilter[int, int](100, 0.01)); err == nil {
+		t.Fatalf("expected a negative filter without a loader to fail")
+	}
+	if _, err := New[int, int](128, WithLoader[int, int](load), WithNegativeFilter[int, int](100, 0)); err == nil {
+		t.Fatalf("expected a bad false positive rate to fail")
+	}
+}
+
+func TestNegativeFilterBatch(t *testing.T) {
+	var loaded atomic.Int32
+	load := func(ctx context.Context, keys []string) (map[string]int, error) {
+		loaded.Add(int32(len(keys)))
+		values := make(map[string]int)
+		for _, key := range keys {
+			if n, err := strconv.Atoi(key); err == nil && n%2 == 0 {
+				values[key] = n
+			}
+		}
+		return values, nil
+	}
+	l, err := NewSharded[int](128, 4, WithBatchLoader[string, int](load), WithNegativeFilter[string, int](100, 0.001))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ctx := context.Background()
+	keys := []string{"0", "1", "2", "3"}
+	for i := 0; i < 3; i++ {
+		values, err := l.GetOrLoadMany(ctx, keys)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if len(values) != 2 || values["2"] != 2 {
+			t.Fatalf("bad values: %v", values)
+		}
+	}
+	if n := loaded.Load(); n != 4 {
+		t.Fatalf("expected each key to be loaded once, got %d", n)
+	}
+	if _, err := l.GetOrLoad(ctx, "1"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if stats := l.NegativeFilterStats(); stats.Added != 2 || stats.Rejected != 5 {
+		t.Fatalf("bad stats: %+v", stats)
+	}
+}
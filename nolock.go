@@ -0,0 +1,73 @@
+package lru
+
+import (
+	"errors"
+	"sync"
+)
+
+// WithoutLocking makes a Cache skip its lock, for caches only ever used
+// from one goroutine at a time, such as in a single-goroutine pipeline
+// or under WASM, so that hot loops don't pay for an uncontended mutex on
+// every operation.  Using such a cache from more than one goroutine at
+// once corrupts it.  Options that touch the cache from goroutines of
+// their own, like WithDynamicSizer, WithBackgroundEviction,
+// WithWriteBehind, WithQuarantine and the loaders, can't be combined with
+// it.  It has no effect on a
+// ShardedCache.
+func WithoutLocking[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.noLock = true
+	}
+}
+
+// checkNoLock returns an error if WithoutLocking was given along with an
+// option that would use the cache concurrently.
+func (o *options[K, V]) checkNoLock() error {
+	if !o.noLock {
+		return nil
+	}
+	switch {
+	case o.sizer != nil:
+		return errors.New("WithoutLocking can't be used WithDynamicSizer")
+	case o.background:
+		return errors.New("WithoutLocking can't be used WithBackgroundEviction")
+	case o.behindQueue > 0:
+		return errors.New("WithoutLocking can't be used WithWriteBehind")
+	case o.quarantineFor != 0 || o.quarantineSize != 0:
+		return errors.New("WithoutLocking can't be used WithQuarantine")
+	case o.loader != nil || o.batchLoader != nil:
+		return errors.New("WithoutLocking can't be used with a loader")
+	}
+	return nil
+}
+
+// cacheLock is a Cache's lock, which does nothing if off is set
+// WithoutLocking.
+type cacheLock struct {
+	mu  sync.RWMutex
+	off bool
+}
+
+func (l *cacheLock) Lock() {
+	if !l.off {
+		l.mu.Lock()
+	}
+}
+
+func (l *cacheLock) Unlock() {
+	if !l.off {
+		l.mu.Unlock()
+	}
+}
+
+func (l *cacheLock) RLock() {
+	if !l.off {
+		l.mu.RLock()
+	}
+}
+
+func (l *cacheLock) RUnlock() {
+	if !l.off {
+		l.mu.RUnlock()
+	}
+}
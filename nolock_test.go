@@ -0,0 +1,59 @@
+package lru
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithoutLocking(t *testing.T) {
+	evicted := 0
+	l, err := NewWithEvict[int, int](128, func(key, value int) {
+		evicted++
+	}, WithoutLocking[int, int]())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 256; i++ {
+		l.Add(i, i)
+	}
+	if l.Len() != 128 || evicted != 128 {
+		t.Fatalf("expected 128 entries and evictions, got %d and %d", l.Len(), evicted)
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	load := func(ctx context.Context, key int) (int, error) { return key, nil }
+	for _, opt := range []Option[int, int]{
+		WithLoader[int, int](load),
+		WithQuarantine[int, int](time.Second, 8),
+		WithDynamicSizer[int, int](DynamicSizerFunc(func(SizingStats) int { return 0 }), time.Second),
+	} {
+		if _, err := New[int, int](128, WithoutLocking[int, int](), opt); err == nil {
+			t.Fatalf("expected an error")
+		}
+	}
+}
+
+func BenchmarkLRU_WithoutLocking(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		opts []Option[int, int]
+	}{
+		{"locked", nil},
+		{"unlocked", []Option[int, int]{WithoutLocking[int, int]()}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			l, err := New[int, int](8192, bc.opts...)
+			if err != nil {
+				b.Fatalf("err: %v", err)
+			}
+			for i := 0; i < b.N; i++ {
+				if _, ok := l.Get(i % 16384); !ok {
+					l.Add(i%16384, i)
+				}
+			}
+		})
+	}
+}
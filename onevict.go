@@ -0,0 +1,161 @@
+package lru
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+// evictCallbacks holds a cache's eviction callbacks: the one given
+// NewWithEvict, and any given SetOnEvict or AddOnEvict since.  The list
+// is replaced as a whole when it changes, so that entries evicted
+// concurrently, whose callbacks are called after the cache's lock is
+// released, see either the old list or the new one.
+type evictCallbacks[K comparable, V any] struct {
+	// mu serializes changes, along with the hooking of the cache's LRUs
+	// that follows each.
+	mu  sync.Mutex
+	fns atomic.Pointer[[]func(key K, value V)]
+}
+
+// init sets the callback given the cache's constructor, if any.
+func (e *evictCallbacks[K, V]) init(fn func(key K, value V)) {
+	if fn != nil {
+		e.fns.Store(&[]func(key K, value V){fn})
+	}
+}
+
+// load returns the current callbacks.
+func (e *evictCallbacks[K, V]) load() []func(key K, value V) {
+	if fns := e.fns.Load(); fns != nil {
+		return *fns
+	}
+	return nil
+}
+
+// set replaces the callbacks with fn, or removes them if fn is nil, then
+// calls hook with whether there are any.
+func (e *evictCallbacks[K, V]) set(fn func(key K, value V), hook func(enabled bool)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if fn == nil {
+		e.fns.Store(nil)
+	} else {
+		e.fns.Store(&[]func(key K, value V){fn})
+	}
+	hook(fn != nil)
+}
+
+// add appends fn to the callbacks, then calls hook.
+func (e *evictCallbacks[K, V]) add(fn func(key K, value V), hook func(enabled bool)) {
+	if fn == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	old := e.load()
+	fns := append(old[:len(old):len(old)], fn)
+	e.fns.Store(&fns)
+	hook(true)
+}
+
+// SetOnEvict replaces the cache's eviction callbacks, the one given
+// NewWithEvict and any added since, with onEvict, or removes them if
+// onEvict is nil.  It's for hooking up caches created before their
+// callbacks are available, such as during early startup.  Entries evicted
+// while it runs are passed to either the old callbacks or the new one;
+// those evicted before the cache had any callback never are.
+func (c *Cache[K, V]) SetOnEvict(onEvict func(key K, value V)) {
+	c.onEvict.set(onEvict, c.hookEvictions)
+}
+
+// AddOnEvict adds onEvict to the cache's eviction callbacks, to be called
+// after those already set, as SetOnEvict describes.
+func (c *Cache[K, V]) AddOnEvict(onEvict func(key K, value V)) {
+	c.onEvict.add(onEvict, c.hookEvictions)
+}
+
+// hookEvictions has the LRU queue evicted entries for release if there
+// are callbacks to call for them, including one given WithBulkOnEvict, or
+// writes to flush, and not otherwise.
+func (c *Cache[K, V]) hookEvictions(enabled bool) {
+	if c.evictInfo != nil {
+		// the LRU queues everything WithOnEvictInfo
+		return
+	}
+	var deferEvict simplelru.EvictCallback[K, V]
+	if enabled || c.behind != nil || c.bulkEvict != nil {
+		deferEvict = c.deferEvict
+	}
+	c.lock.Lock()
+	c.lru.SetOnEvict(deferEvict)
+	c.unlock()
+}
+
+// SetOnEvict replaces the cache's eviction callbacks, as
+// Cache.SetOnEvict does.
+func (c *ShardedCache[V]) SetOnEvict(onEvict func(key string, value V)) {
+	c.onEvict.set(onEvict, c.hookEvictions)
+}
+
+// AddOnEvict adds onEvict to the cache's eviction callbacks, as
+// Cache.AddOnEvict does.
+func (c *ShardedCache[V]) AddOnEvict(onEvict func(key string, value V)) {
+	c.onEvict.add(onEvict, c.hookEvictions)
+}
+
+// hookEvictions hooks each shard's LRU as Cache.hookEvictions does.
+func (c *ShardedCache[V]) hookEvictions(enabled bool) {
+	if c.evictInfo != nil {
+		return
+	}
+	hook := enabled || c.behind != nil || c.bulkEvict != nil
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.lock()
+		if hook {
+			shard.lru.SetOnEvict(shard.deferEvict)
+		} else {
+			shard.lru.SetOnEvict(nil)
+		}
+		c.unlock(shard)
+	}
+}
+
+// callBulkOnEvict passes evicted to onEvict, recovering from and logging
+// its panic if recoverPanics is set, as callOnEvict does.
+func callBulkOnEvict[K comparable, V any](onEvict func([]simplelru.Entry[K, V]), evicted []evictedEntry[K, V], recoverPanics bool, logger *slog.Logger) {
+	if recoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				if logger == nil {
+					logger = slog.Default()
+				}
+				logger.Error("lru: callback panicked", "callback", "bulkOnEvict", "entries", len(evicted), "panic", r)
+			}
+		}()
+	}
+	entries := make([]simplelru.Entry[K, V], len(evicted))
+	for i, e := range evicted {
+		entries[i] = simplelru.Entry[K, V]{Key: e.key, Value: e.value}
+	}
+	onEvict(entries)
+}
+
+// callOnEvictInfo passes e to onEvict, recovering from and logging its
+// panic if recoverPanics is set, as callOnEvict does.
+func callOnEvictInfo[K comparable, V any](onEvict func(K, V, simplelru.EvictionInfo), e evictedEntry[K, V], recoverPanics bool, logger *slog.Logger) {
+	if recoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				if logger == nil {
+					logger = slog.Default()
+				}
+				logger.Error("lru: callback panicked", "callback", "onEvictInfo", "key", e.key, "panic", r)
+			}
+		}()
+	}
+	onEvict(e.key, e.value, e.info)
+}
@@ -0,0 +1,211 @@
+package lru
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+func TestSetOnEvict(t *testing.T) {
+	l, err := New[int, int](4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		l.Add(i, i)
+	}
+
+	var first, second []int
+	l.SetOnEvict(func(key int, value int) {
+		first = append(first, key)
+	})
+	l.Add(8, 8)
+	if len(first) != 1 {
+		t.Fatalf("expected one eviction to be reported, got %v", first)
+	}
+	l.AddOnEvict(func(key int, value int) {
+		second = append(second, key)
+	})
+	l.Add(9, 9)
+	if len(first) != 2 || len(second) != 1 || first[1] != second[0] {
+		t.Fatalf("expected both callbacks to see the eviction, got %v and %v", first, second)
+	}
+
+	// setting replaces every callback, and nil removes them
+	l.SetOnEvict(nil)
+	l.Add(10, 10)
+	if len(first) != 2 || len(second) != 1 {
+		t.Fatalf("expected no callbacks after removing them, got %v and %v", first, second)
+	}
+}
+
+func TestSetOnEvictReplacesConstructorCallback(t *testing.T) {
+	var old, replaced int
+	l, err := NewWithEvict[int, int](1, func(key int, value int) {
+		old++
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.SetOnEvict(func(key int, value int) {
+		replaced++
+	})
+	l.Add(3, 3)
+	if old != 1 || replaced != 1 {
+		t.Fatalf("expected the callback to be replaced: old %d, replaced %d", old, replaced)
+	}
+}
+
+func TestShardedSetOnEvictConcurrent(t *testing.T) {
+	l, err := NewSharded[int](64, 4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				l.Add(strconv.Itoa(w*1_000_000+i), i)
+			}
+		}(w)
+	}
+
+	var evictions atomic.Int64
+	l.AddOnEvict(func(key string, value int) {
+		evictions.Add(1)
+	})
+	for evictions.Load() < 1000 {
+		l.Add("spin", 0)
+	}
+	close(stop)
+	wg.Wait()
+
+	// once the writers have stopped, every eviction is reported
+	before := evictions.Load()
+	for i := 0; i < 100; i++ {
+		l.Add("after"+strconv.Itoa(i), i)
+	}
+	if got := evictions.Load() - before; got != 100 {
+		t.Fatalf("expected 100 evictions reported, got %d", got)
+	}
+}
+
+func TestBulkOnEvict(t *testing.T) {
+	var calls [][]simplelru.Entry[int, int]
+	perEntry := 0
+	l, err := NewWithEvict[int, int](8, func(int, int) { perEntry++ }, WithBulkOnEvict[int, int](func(entries []simplelru.Entry[int, int]) {
+		calls = append(calls, entries)
+	}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		l.Add(i, i)
+	}
+	if len(calls) != 0 {
+		t.Fatalf("expected no calls without evictions, got %d", len(calls))
+	}
+	l.Resize(2)
+	if len(calls) != 1 || len(calls[0]) != 6 {
+		t.Fatalf("expected one call with 6 entries, got %v", calls)
+	}
+	l.Purge()
+	if len(calls) != 2 || len(calls[1]) != 2 || perEntry != 8 {
+		t.Fatalf("expected one call for the purge, got %v, and 8 per-entry calls, got %d", calls, perEntry)
+	}
+	for _, e := range calls[1] {
+		if e.Key != e.Value {
+			t.Fatalf("bad entry %+v", e)
+		}
+	}
+}
+
+func TestShardedBulkOnEvict(t *testing.T) {
+	var mu sync.Mutex
+	var calls, entries int
+	l, err := NewSharded[int](64, 4, WithBulkOnEvict[string, int](func(evicted []simplelru.Entry[string, int]) {
+		mu.Lock()
+		calls++
+		entries += len(evicted)
+		mu.Unlock()
+	}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 64; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+	// adding overflowed some shards, which made calls of their own
+	n := l.Len()
+	calls, entries = 0, 0
+	l.Purge()
+	if calls > 4 || entries != n {
+		t.Fatalf("expected at most a call per shard for %d entries, got %d calls for %d", n, calls, entries)
+	}
+}
+
+func TestOnEvictInfo(t *testing.T) {
+	var reasons []simplelru.EvictReason
+	var hits uint64
+	perEntry := 0
+	l, err := NewWithEvict[int, int](2, func(int, int) { perEntry++ }, WithOnEvictInfo[int, int](func(key int, value int, info simplelru.EvictionInfo) {
+		reasons = append(reasons, info.Reason)
+		hits += info.Hits
+	}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Get(1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	l.Remove(3)
+	if len(reasons) != 2 || reasons[0] != simplelru.EvictCapacity || reasons[1] != simplelru.EvictRemove || perEntry != 2 {
+		t.Fatalf("bad reasons %v, or %d per-entry calls", reasons, perEntry)
+	}
+
+	// callbacks set later are called from the same queue
+	later := 0
+	l.AddOnEvict(func(int, int) { later++ })
+	l.Purge()
+	if len(reasons) != 3 || reasons[2] != simplelru.EvictPurge || later != 1 || perEntry != 3 {
+		t.Fatalf("bad reasons %v, or %d and %d per-entry calls", reasons, perEntry, later)
+	}
+	if hits != 1 {
+		t.Fatalf("expected one hit to be reported, got %d", hits)
+	}
+}
+
+func TestShardedOnEvictInfo(t *testing.T) {
+	var mu sync.Mutex
+	reasons := map[simplelru.EvictReason]int{}
+	l, err := NewSharded[int](16, 4, WithOnEvictInfo[string, int](func(key string, value int, info simplelru.EvictionInfo) {
+		mu.Lock()
+		reasons[info.Reason]++
+		mu.Unlock()
+	}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+	n := l.Len()
+	l.Purge()
+	if reasons[simplelru.EvictCapacity] != 100-n || reasons[simplelru.EvictPurge] != n {
+		t.Fatalf("bad reasons %v", reasons)
+	}
+}
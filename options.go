@@ -0,0 +1,490 @@
+package lru
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+// Option configures optional behavior of a Cache or ShardedCache at
+// construction time.
+type Option[K comparable, V any] func(o *options[K, V])
+
+type options[K comparable, V any] struct {
+	classify    func(key K) string
+	hotKeysSize int
+	hotDecay    int
+	trace       *simplelru.TraceWriter
+	traceSample int
+	mrcSizes    []int
+	mrcSample   int
+	shadows     map[string]simplelru.ShadowConfig
+	latency     bool
+	contention  bool
+	twoChoices  bool
+	noLock      bool
+	copyOnRead  bool
+	clone       func(value V) V
+	logger      *slog.Logger
+	listeners   []simplelru.Listener[K, V]
+	sampleEvery int
+	sampler     func(key K, age int64, reason simplelru.EvictReason)
+	keyCodec    Codec[K]
+	valueCodec  Codec[V]
+	loader      Loader[K, V]
+	batchLoader BulkLoader[K, V]
+	store       Store[K, V]
+	// behindQueue and behindWorkers configure WithWriteBehind.
+	behindQueue   int
+	behindWorkers int
+	// negativeCapacity and negativeRate configure WithNegativeFilter.
+	negativeCapacity int
+	negativeRate     float64
+	randSource       rand.Source
+	recover          bool
+	// churnSample is given WithChurnTracking.
+	churnSample int
+	// accuracySample is given WithAccuracyTracking.
+	accuracySample int
+	// admissionRate and admissionBurst configure WithAdmissionRate.
+	admissionRate  float64
+	admissionBurst int
+	// sizer and sizingInterval configure WithDynamicSizer.
+	sizer          DynamicSizer
+	sizingInterval time.Duration
+	// maxValue and valueSize configure WithMaxValueSize.
+	maxValue  int
+	valueSize func(value V) int
+	cost      func(key K, value V) int64
+	// costVictims is set WithCostAwareEviction.
+	costVictims bool
+	// quotas are given WithQuotas.
+	quotas map[string]simplelru.Quota
+	// bulkEvict is given WithBulkOnEvict, and evictInfo WithOnEvictInfo.
+	bulkEvict func(entries []simplelru.Entry[K, V])
+	evictInfo func(key K, value V, info simplelru.EvictionInfo)
+	// onAdmit is given WithOnAdmit.
+	onAdmit func(key K, value V, wouldEvict simplelru.Entry[K, V]) bool
+	// quarantineFor and quarantineSize configure WithQuarantine.
+	quarantineFor  time.Duration
+	quarantineSize int
+	// background and headroom configure WithBackgroundEviction.
+	background bool
+	headroom   int
+	// bufferSize and bufferWindow configure WithWriteBuffer.
+	bufferSize   int
+	bufferWindow time.Duration
+
+	// mrc, admission and shadowCaches are shared between shards, and
+	// are created by newOptions.
+	mrc          *simplelru.MissRatioCurve
+	admission    *simplelru.AdmissionLimiter
+	shadowCaches map[string]*simplelru.Shadow
+}
+
+func newOptions[K comparable, V any](opts []Option[K, V]) (*options[K, V], error) {
+	o := &options[K, V]{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.keyCodec == nil {
+		o.keyCodec = GobCodec[K]{}
+	}
+	if o.valueCodec == nil {
+		o.valueCodec = GobCodec[V]{}
+	}
+	if o.background && o.headroom < 1 {
+		return nil, errEvictHeadroom
+	}
+	if o.quotas != nil && o.classify == nil {
+		return nil, errQuotaClassifier
+	}
+	if o.costVictims && o.cost == nil {
+		return nil, errCostAwareEviction
+	}
+	if len(o.mrcSizes) > 0 {
+		mrc, err := simplelru.NewMissRatioCurve(o.mrcSizes, o.mrcSample)
+		if err != nil {
+			return nil, err
+		}
+		o.mrc = mrc
+	}
+	if o.admissionRate != 0 || o.admissionBurst != 0 {
+		admission, err := simplelru.NewAdmissionLimiter(o.admissionRate, o.admissionBurst)
+		if err != nil {
+			return nil, err
+		}
+		o.admission = admission
+	}
+	for name, config := range o.shadows {
+		shadow, err := simplelru.NewShadow(config)
+		if err != nil {
+			return nil, fmt.Errorf("shadow %q: %w", name, err)
+		}
+		if o.shadowCaches == nil {
+			o.shadowCaches = make(map[string]*simplelru.Shadow)
+		}
+		o.shadowCaches[name] = shadow
+	}
+	return o, nil
+}
+
+// lruOptions returns the options to pass to each underlying
+// simplelru.LRU, of which there are shardCount.
+func (o *options[K, V]) lruOptions(shardCount int) []simplelru.Option[K, V] {
+	var opts []simplelru.Option[K, V]
+	if o.classify != nil {
+		opts = append(opts, simplelru.WithClassifier[K, V](o.classify))
+	}
+	if o.hotKeysSize > 0 {
+		// keys are partitioned between shards, so each shard needs to
+		// track the full number of keys for the merged result to be
+		// accurate.
+		opts = append(opts, simplelru.WithHotKeys[K, V](o.hotKeysSize))
+	}
+	if o.hotDecay > 0 {
+		// each shard sees about 1/shardCount of the lookups
+		opts = append(opts, simplelru.WithHotKeysDecay[K, V](max(o.hotDecay/shardCount, 1)))
+	}
+	if o.trace != nil {
+		opts = append(opts, simplelru.WithTrace[K, V](o.trace, o.traceSample))
+	}
+	if o.mrc != nil {
+		opts = append(opts, simplelru.WithMissRatioCurve[K, V](o.mrc))
+	}
+	for name, shadow := range o.shadowCaches {
+		opts = append(opts, simplelru.WithShadow[K, V](name, shadow))
+	}
+	if o.admission != nil {
+		opts = append(opts, simplelru.WithAdmissionLimiter[K, V](o.admission))
+	}
+	if o.valueSize != nil {
+		opts = append(opts, simplelru.WithMaxValueSize[K, V](o.maxValue, o.valueSize))
+	}
+	if o.onAdmit != nil {
+		opts = append(opts, simplelru.WithOnAdmit[K, V](o.onAdmit))
+	}
+	if o.cost != nil {
+		opts = append(opts, simplelru.WithCost[K, V](o.cost))
+	}
+	if o.costVictims {
+		opts = append(opts, simplelru.WithCostAwareEviction[K, V]())
+	}
+	if o.quotas != nil {
+		opts = append(opts, simplelru.WithQuotas[K, V](shardQuotas(o.quotas, shardCount)))
+	}
+	if o.logger != nil {
+		opts = append(opts, simplelru.WithLogger[K, V](o.logger))
+	}
+	for _, l := range o.listeners {
+		opts = append(opts, simplelru.WithListener[K, V](l))
+	}
+	if o.sampler != nil {
+		opts = append(opts, simplelru.WithEvictionSampler[K, V](o.sampleEvery, o.sampler))
+	}
+	if o.recover {
+		opts = append(opts, simplelru.WithRecover[K, V]())
+	}
+	if o.churnSample > 0 {
+		opts = append(opts, simplelru.WithChurnTracking[K, V](o.churnSample))
+	}
+	if o.accuracySample > 0 {
+		opts = append(opts, simplelru.WithAccuracyTracking[K, V](o.accuracySample))
+	}
+	if o.background {
+		opts = append(opts, simplelru.WithDeferredEviction[K, V](max(o.headroom/shardCount, 1)))
+	}
+	return opts
+}
+
+// WithClassifier tracks hits, misses and evictions separately for each
+// class of keys, as named by classify.  Per-class counters are available
+// from ClassStats.
+func WithClassifier[K comparable, V any](classify func(key K) string) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.classify = classify
+	}
+}
+
+// WithHotKeys tracks (approximately) the capacity most frequently
+// looked-up keys, available from HotKeys.
+func WithHotKeys[K comparable, V any](capacity int) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.hotKeysSize = capacity
+	}
+}
+
+// WithHotKeysDecay halves the counts tracked WithHotKeys after every
+// every lookups, so that HotKeys ranks keys by recent rather than
+// lifetime popularity.  See simplelru.WithHotKeysDecay.
+func WithHotKeysDecay[K comparable, V any](every int) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.hotDecay = every
+	}
+}
+
+// WithTrace records a sample of Get, Add and Remove operations to w, for
+// offline analysis of cache sizing.  Roughly one in sampleEvery keys is
+// traced.  The caller is responsible for calling w.Flush.
+func WithTrace[K comparable, V any](w *simplelru.TraceWriter, sampleEvery int) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.trace = w
+		o.traceSample = sampleEvery
+	}
+}
+
+// WithMissRatioCurve estimates the hit ratio the cache would achieve at
+// each of the given sizes, available from EstimateHitRatioAt, by feeding
+// roughly one in sampleEvery keys through scaled-down simulated caches.
+func WithMissRatioCurve[K comparable, V any](sizes []int, sampleEvery int) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.mrcSizes = sizes
+		o.mrcSample = sampleEvery
+	}
+}
+
+// WithAdmissionRate limits the rate at which new keys can evict existing
+// entries, admitting perSecond new keys a second on average and up to
+// burst at once, so that a flood of one-off keys can't flush the working
+// set.  Once the cache is full, adding a key beyond the limit does
+// nothing; hits, and updates of keys already cached, are unaffected.
+// Rejected keys are counted in Stats' Rejections.  A ShardedCache's shards
+// share one limit.
+func WithAdmissionRate[K comparable, V any](perSecond float64, burst int) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.admissionRate = perSecond
+		o.admissionBurst = burst
+	}
+}
+
+// WithMaxValueSize rejects values larger than max, as measured by size,
+// so that one pathological value can't evict many useful ones.  Add and
+// every other way of adding to the cache skip a rejected value, removing
+// any value the key already had, and count it in Stats' Rejections; use
+// TryAdd to find out that a value was rejected.  The sizes of the values
+// added are recorded in Stats' ValueSize.
+func WithMaxValueSize[K comparable, V any](max int, size func(value V) int) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.maxValue = max
+		o.valueSize = size
+	}
+}
+
+// WithBulkOnEvict calls onEvict once with all the entries an operation
+// evicted, rather than once per entry as the callback given NewWithEvict
+// is, for callbacks that make a round trip to some other system: a
+// Resize, Purge or batch of background evictions WithBackgroundEviction
+// makes a single call, or one per shard of a ShardedCache.  It's called
+// after the lock is released, after the per-entry callbacks, and never
+// with an empty slice; the entries' LastUsed fields aren't set.  onEvict
+// may keep the slice.
+func WithBulkOnEvict[K comparable, V any](onEvict func(entries []simplelru.Entry[K, V])) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.bulkEvict = onEvict
+	}
+}
+
+// WithOnEvictInfo calls onEvict for every entry the callback given
+// NewWithEvict would be called for, with the entry's idle time, age, hit
+// count and the reason it left, after the lock is released and after the
+// per-entry callbacks.  Entries released from WithQuarantine carry only
+// their reason.  It costs a map entry per entry in the cache.  See
+// simplelru.WithOnEvictInfo.
+func WithOnEvictInfo[K comparable, V any](onEvict func(key K, value V, info simplelru.EvictionInfo)) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.evictInfo = onEvict
+	}
+}
+
+// WithOnAdmit calls onAdmit before adding a key the cache doesn't hold,
+// with the entry adding it would evict, and skips adding the key if it
+// returns false, counting it in Stats' Rejections.  It's called with the
+// cache's lock held, so it must not call back into the cache, and a
+// ShardedCache calls it from every shard concurrently.  See
+// simplelru.WithOnAdmit.
+func WithOnAdmit[K comparable, V any](onAdmit func(key K, value V, wouldEvict simplelru.Entry[K, V]) bool) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.onAdmit = onAdmit
+	}
+}
+
+// WithCost gives every entry a cost, such as its size in bytes, as
+// measured by cost, and keeps running totals that CostLen returns, and
+// ShardStats reports per shard.  It's for accounting only: the cache
+// still holds its size in entries regardless of their cost.  Each entry's
+// cost is measured when it's added; UpdateCost measures it again.
+func WithCost[K comparable, V any](cost func(key K, value V) int64) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.cost = cost
+	}
+}
+
+var errCostAwareEviction = errors.New("WithCostAwareEviction requires WithCost")
+
+// WithCostAwareEviction biases the choice of entries to evict toward
+// those that cost the most, as measured by the function given WithCost,
+// which it requires, and were used least recently, as
+// simplelru.WithCostAwareEviction describes, so that when values' sizes
+// vary by orders of magnitude, the cache sheds its largest stale entries
+// first.
+func WithCostAwareEviction[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.costVictims = true
+	}
+}
+
+// ErrValueTooLarge is returned by TryAdd for a value larger than
+// WithMaxValueSize allows.
+var ErrValueTooLarge = errors.New("lru: value too large")
+
+// tooLarge returns a function reporting whether a value is larger than
+// WithMaxValueSize allows, or nil if it wasn't given.
+func (o *options[K, V]) tooLarge() func(value V) bool {
+	if o.valueSize == nil {
+		return nil
+	}
+	max, size := o.maxValue, o.valueSize
+	return func(value V) bool {
+		return size(value) > max
+	}
+}
+
+// WithShadow maintains a keys-only shadow cache with a candidate
+// configuration, fed the same lookups, additions and removals as the
+// cache, so that ShadowStats can report the hit ratio the candidate would
+// achieve in production without serving from it.  It can be given more
+// than once with different names, to compare several candidates.  For a
+// ShardedCache, config.Size is the candidate's total size, and its shards
+// share the shadow, and its lock.
+func WithShadow[K comparable, V any](name string, config simplelru.ShadowConfig) Option[K, V] {
+	return func(o *options[K, V]) {
+		if o.shadows == nil {
+			o.shadows = make(map[string]simplelru.ShadowConfig)
+		}
+		o.shadows[name] = config
+	}
+}
+
+// WithLatencyTracking records how long each Add and Get takes, including
+// lock acquisition, available from LatencyStats.  Without it, the cache
+// doesn't read the clock at all.
+func WithLatencyTracking[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.latency = true
+	}
+}
+
+// WithContentionTracking counts how often acquiring each shard's lock of
+// a ShardedCache had to wait, and for how long, available from
+// ShardStats.  It has no effect on a Cache.
+func WithContentionTracking[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.contention = true
+	}
+}
+
+// WithTwoChoices lets a ShardedCache place each new key in the emptier
+// of two shards, chosen by its hash, rather than always the same one,
+// which evens out occupancy when keys are skewed toward some shards.  It
+// costs lookups of missing keys, and of keys in their second shard, a
+// second shard lock, and Add takes both shards' locks.  It has no effect
+// on a Cache.
+func WithTwoChoices[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.twoChoices = true
+	}
+}
+
+// WithChurnTracking follows roughly one in sampleEvery entries from when
+// they're added to when they're evicted, for the lifetimes and
+// one-hit-wonder counts in Stats.  See simplelru.WithChurnTracking.
+func WithChurnTracking[K comparable, V any](sampleEvery int) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.churnSample = sampleEvery
+	}
+}
+
+// WithAccuracyTracking compares the cache with an exact LRU fed roughly
+// one in sampleEvery keys, for the extra-miss and premature-eviction
+// counts in Stats.  In a sharded cache each shard is compared with its
+// own exact LRU.  See simplelru.WithAccuracyTracking.
+func WithAccuracyTracking[K comparable, V any](sampleEvery int) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.accuracySample = sampleEvery
+	}
+}
+
+// WithLogger logs notable cache events to logger with structured fields:
+// construction, resizes and purges at info level, and a sample of
+// evictions at debug level.
+func WithLogger[K comparable, V any](logger *slog.Logger) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.logger = logger
+	}
+}
+
+// WithListener registers l to be notified of hits, misses, additions,
+// evictions and expirations.  It can be given more than once.  For a
+// ShardedCache, l is called concurrently from different shards.
+func WithListener[K comparable, V any](l simplelru.Listener[K, V]) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.listeners = append(o.listeners, l)
+	}
+}
+
+// WithEvictionSampler calls fn for one in every evictions with the evicted
+// key, how long it had gone unused (in ticks of the cache's logical clock)
+// and why it was evicted.  For a ShardedCache, sampling is per shard and
+// fn is called concurrently from different shards.
+func WithEvictionSampler[K comparable, V any](every int, fn func(key K, age int64, reason simplelru.EvictReason)) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.sampleEvery = every
+		o.sampler = fn
+	}
+}
+
+// WithRandSource makes the cache's random choices reproducible by drawing
+// them from src rather than from sources seeded from crypto/rand.  A Cache
+// uses src to choose eviction victims.  A ShardedCache draws a seed for
+// each shard, and one for hashing keys to shards in place of
+// hash/maphash, from src when it's created, and doesn't use it after.
+func WithRandSource[K comparable, V any](src rand.Source) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.randSource = src
+	}
+}
+
+// WithSeed is WithRandSource with a math/rand source seeded with seed.
+func WithSeed[K comparable, V any](seed int64) Option[K, V] {
+	return WithRandSource[K, V](rand.NewSource(seed))
+}
+
+// WithRecover recovers from panics in the eviction callback and
+// listeners, logging them at error level to the logger given WithLogger,
+// or slog's default logger.  Without it, a panicking listener unwinds
+// through whichever call triggered it and can leave the cache locked.
+func WithRecover[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.recover = true
+	}
+}
+
+// PrefixClassifier returns a classifier for WithClassifier that names each
+// key by the longest of the given prefixes it starts with, or "" if it
+// matches none of them.
+func PrefixClassifier(prefixes ...string) func(key string) string {
+	return func(key string) string {
+		class := ""
+		for _, prefix := range prefixes {
+			if len(prefix) > len(class) && strings.HasPrefix(key, prefix) {
+				class = prefix
+			}
+		}
+		return class
+	}
+}
@@ -0,0 +1,317 @@
+package lru
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+func TestPrefixClassifier(t *testing.T) {
+	classify := PrefixClassifier("user/", "user/admin/", "post/")
+	cases := map[string]string{
+		"user/42":       "user/",
+		"user/admin/7":  "user/admin/",
+		"post/1":        "post/",
+		"comment/1":     "",
+		"":              "",
+		"user":          "",
+		"post/user/abc": "post/",
+	}
+	for key, expected := range cases {
+		if actual := classify(key); actual != expected {
+			t.Errorf("classify(%q) = %q, expected %q", key, actual, expected)
+		}
+	}
+}
+
+func TestShardedClassStats(t *testing.T) {
+	l, err := NewSharded[int](64, 4, WithClassifier[string, int](PrefixClassifier("a/", "b/")))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add("a/1", 1)
+	l.Get("a/1")
+	l.Get("b/1")
+	l.Get("c/1")
+
+	classes := l.ClassStats()
+	if a := classes["a/"]; a.Hits != 1 || a.Misses != 0 {
+		t.Errorf("bad a/ stats: %+v", a)
+	}
+	if b := classes["b/"]; b.Hits != 0 || b.Misses != 1 {
+		t.Errorf("bad b/ stats: %+v", b)
+	}
+	if other := classes[""]; other.Misses != 1 {
+		t.Errorf("bad unclassified stats: %+v", other)
+	}
+}
+
+func TestShardedStatsSnapshot(t *testing.T) {
+	l, err := NewSharded[int](64, 4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add("a", 1)
+	l.Get("a")
+	prev := l.StatsSnapshot()
+	l.Get("a")
+	l.Get("b")
+	l.Get("c")
+
+	d := l.StatsSnapshot().Delta(prev)
+	if d.Hits != 1 || d.Misses != 2 {
+		t.Errorf("bad delta: %+v", d.Stats)
+	}
+	if d.Elapsed < 0 {
+		t.Errorf("bad elapsed: %v", d.Elapsed)
+	}
+}
+
+func TestShardedChurnTracking(t *testing.T) {
+	l, err := NewSharded[int](64, 4, WithChurnTracking[string, int](1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+	s := l.Stats()
+	if s.Inserts != 1000 || s.SampledEvictions != s.Evictions || s.Evictions == 0 {
+		t.Fatalf("bad stats: %+v", s)
+	}
+	if r := s.OneHitWonderRatio(); r != 1 {
+		t.Fatalf("expected every evicted entry to be a one-hit wonder, got %v", r)
+	}
+}
+
+func TestShardedWithSeed(t *testing.T) {
+	run := func() []simplelru.Entry[string, int] {
+		l, err := NewSharded[int](256, 16, WithSeed[string, int](42))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		for i := 0; i < 4096; i++ {
+			l.Add(strconv.Itoa(i%700), i)
+			l.Get(strconv.Itoa(i % 31))
+		}
+		return l.ExportOrdered()
+	}
+	a, b := run(), run()
+	if len(a) != len(b) {
+		t.Fatalf("expected the same entries, got %d and %d", len(a), len(b))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("expected the same entries, got %v and %v at %d", a[i], b[i], i)
+		}
+	}
+}
+
+func TestShardedAdmissionRate(t *testing.T) {
+	// a rate this low never refills during the test
+	l, err := NewSharded[int](64, 4, WithAdmissionRate[string, int](0.001, 10))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 64; i++ {
+		l.Add("hot"+strconv.Itoa(i), i)
+	}
+	n := l.Len()
+	for i := 0; i < 10000; i++ {
+		l.Add("crawl"+strconv.Itoa(i), i)
+	}
+	stats := l.Stats()
+	// the shards share the burst, whichever of them fill up first
+	if stats.Evictions > 10 || stats.Rejections < uint64(10000-10-(64-n)) {
+		t.Fatalf("bad stats: %+v", stats)
+	}
+	if l.Len() < n {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if _, err := New[int, int](64, WithAdmissionRate[int, int](-1, 10)); err == nil {
+		t.Fatalf("expected a negative rate to fail")
+	}
+}
+
+func TestShardedShadow(t *testing.T) {
+	l, err := NewSharded[int](64, 4, WithShadow[string, int]("big", simplelru.ShadowConfig{Size: 1024}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for round := 0; round < 4; round++ {
+		for i := 0; i < 512; i++ {
+			key := strconv.Itoa(i)
+			if _, ok := l.Get(key); !ok {
+				l.Add(key, i)
+			}
+		}
+	}
+	s := l.ShadowStats()["big"]
+	if s.Misses != 512 || s.Hits != 3*512 {
+		t.Fatalf("bad shadow stats: %+v", s)
+	}
+	if _, err := New[int, int](64, WithShadow[int, int]("bad", simplelru.ShadowConfig{})); err == nil {
+		t.Fatalf("expected a bad shadow config to fail")
+	}
+}
+
+func TestShardedCost(t *testing.T) {
+	cost := func(key string, value []byte) int64 { return int64(len(value)) }
+	l, err := NewSharded[[]byte](64, 4, WithCost[string, []byte](cost))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add("a", make([]byte, 100))
+	l.Add("b", make([]byte, 10))
+	l.Add("c", make([]byte, 1))
+	if n := l.CostLen(); n != 111 {
+		t.Fatalf("expected a cost of 111, got %d", n)
+	}
+
+	var total int64
+	for _, s := range l.ShardStats() {
+		total += s.Cost
+	}
+	if total != 111 {
+		t.Fatalf("expected shard costs to sum to 111, got %d", total)
+	}
+
+	l.Remove("a")
+	if n := l.CostLen(); n != 11 {
+		t.Fatalf("expected a cost of 11, got %d", n)
+	}
+}
+
+func TestUpdateCost(t *testing.T) {
+	cost := func(key string, value *bytes.Buffer) int64 { return int64(value.Len()) }
+	l, err := New[string, *bytes.Buffer](64, WithCost[string, *bytes.Buffer](cost))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sharded, err := NewSharded[*bytes.Buffer](64, 4, WithCost[string, *bytes.Buffer](cost))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, c := range []interface {
+		Add(key string, value *bytes.Buffer) bool
+		UpdateCost(key string) bool
+		CostLen() int64
+	}{l, sharded} {
+		buf := bytes.NewBufferString("abc")
+		c.Add("a", buf)
+		buf.WriteString("defgh")
+		if n := c.CostLen(); n != 3 {
+			t.Fatalf("expected a cost of 3, got %d", n)
+		}
+		if !c.UpdateCost("a") {
+			t.Fatalf("expected a to be updated")
+		}
+		if n := c.CostLen(); n != 8 {
+			t.Fatalf("expected a cost of 8, got %d", n)
+		}
+		if c.UpdateCost("missing") {
+			t.Fatalf("expected a missing key not to be updated")
+		}
+	}
+}
+
+func TestMaxValueSize(t *testing.T) {
+	size := func(v []byte) int { return len(v) }
+	l, err := New[string, []byte](64, WithMaxValueSize[string, []byte](16, size))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := l.TryAdd("small", []byte("ok")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := l.TryAdd("big", make([]byte, 17)); err != ErrValueTooLarge {
+		t.Fatalf("expected ErrValueTooLarge, got %v", err)
+	}
+	l.Add("big", make([]byte, 100))
+	if l.Contains("big") || l.Len() != 1 {
+		t.Fatalf("expected big values to be rejected")
+	}
+
+	sharded, err := NewSharded[[]byte](64, 4, WithMaxValueSize[string, []byte](16, size))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sharded.Add("small", []byte("ok"))
+	if _, err := sharded.TryAdd("small", make([]byte, 32)); err != ErrValueTooLarge {
+		t.Fatalf("expected ErrValueTooLarge, got %v", err)
+	}
+	if sharded.Contains("small") {
+		t.Fatalf("expected the stale value to be removed")
+	}
+	if s := sharded.Stats(); s.Rejections != 1 || s.ValueSize.Count() != 2 {
+		t.Fatalf("bad stats: %+v", s)
+	}
+	if s := l.Stats(); s.ValueSize.Count() != 3 || s.ValueSize.Quantile(1) != 127 {
+		t.Fatalf("bad value sizes: %v", s.ValueSize.Buckets)
+	}
+}
+
+func TestShardedAccuracyTracking(t *testing.T) {
+	l, err := NewSharded[int](64, 4, WithAccuracyTracking[string, int](1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		k := strconv.Itoa(i % 100)
+		if _, ok := l.Get(k); !ok {
+			l.Add(k, i)
+		}
+	}
+	s := l.Stats()
+	if s.AccuracyLookups != s.Hits+s.Misses || s.AccuracyEvictions != s.Evictions || s.Evictions == 0 {
+		t.Fatalf("bad stats: %+v", s)
+	}
+}
+
+func TestShardedOnAdmit(t *testing.T) {
+	onAdmit := func(key string, value int, wouldEvict simplelru.Entry[string, int]) bool {
+		return !strings.HasPrefix(key, "untrusted:")
+	}
+	l, err := NewSharded[int](64, 4, WithOnAdmit[string, int](onAdmit))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add("trusted:1", 1)
+	l.Add("untrusted:1", 1)
+	if !l.Contains("trusted:1") || l.Contains("untrusted:1") {
+		t.Fatalf("expected only the trusted key to be added")
+	}
+	if s := l.Stats(); s.Rejections != 1 {
+		t.Fatalf("bad stats: %+v", s)
+	}
+}
+
+func TestCostAwareEviction(t *testing.T) {
+	cost := func(key string, value []byte) int64 { return int64(len(value)) }
+	if _, err := New[string, []byte](8, WithCostAwareEviction[string, []byte]()); err == nil {
+		t.Fatalf("expected WithCostAwareEviction to require WithCost")
+	}
+	l, err := NewSharded[[]byte](64, 4, WithCost[string, []byte](cost), WithCostAwareEviction[string, []byte]())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// half the entries are large, and they should be the ones evicted
+	for i := 0; i < 64; i++ {
+		size := 1
+		if i%2 == 0 {
+			size = 1000
+		}
+		l.Add(strconv.Itoa(i), make([]byte, size))
+	}
+	for i := 64; i < 80; i++ {
+		l.Add(strconv.Itoa(i), make([]byte, 1))
+	}
+	if n := l.CostLen(); n > 32*1000+48-8*1000 {
+		t.Fatalf("expected mostly large entries to be evicted, cost is %d", n)
+	}
+}
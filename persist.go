@@ -0,0 +1,454 @@
+package lru
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/bpowers/approx-lru/simplelru"
+	"golang.org/x/exp/slices"
+)
+
+// snapshotVersion is written at the start of every snapshot, so that
+// changes to the format can be detected and older snapshots migrated.
+//
+// Version 1 snapshots gob-encoded keys and values directly.  Version 2
+// encodes them with the cache's codecs.  Version 3 also records the
+// parameters of the cache that wrote the snapshot in its header.
+const snapshotVersion = 3
+
+// maxSnapshotPrealloc bounds how many entries we allocate room for up
+// front based on a snapshot's header, so a corrupt header can't make us
+// allocate unbounded memory before reading any entries.
+const maxSnapshotPrealloc = 1 << 16
+
+// snapshotPolicy names the eviction policy of caches in this package,
+// for SnapshotInfo.
+const snapshotPolicy = "approximate-lru"
+
+var (
+	_ io.WriterTo   = (*Cache[int, int])(nil)
+	_ io.ReaderFrom = (*Cache[int, int])(nil)
+)
+
+// SnapshotInfo describes a snapshot written by Save, and the cache that
+// wrote it.  It is the snapshot's gob-encoded header, so fields can be
+// added without changing the version as long as their zero values are a
+// sensible default.
+type SnapshotInfo struct {
+	// Version is the version of the snapshot format.
+	Version int
+	// Len is the number of entries in the snapshot.
+	Len int
+	// Size is the capacity of the cache that wrote the snapshot, or 0
+	// before version 3.
+	Size int
+	// Policy names the eviction policy of the cache that wrote the
+	// snapshot, or is empty before version 3.
+	Policy string
+}
+
+// ReadSnapshotInfo reads the header of a snapshot written by Save, without
+// reading its entries.
+func ReadSnapshotInfo(r io.Reader) (SnapshotInfo, error) {
+	var info SnapshotInfo
+	if err := gob.NewDecoder(&countingReader{r: r}).Decode(&info); err != nil {
+		return info, err
+	}
+	if info.Version < 1 || info.Version > snapshotVersion {
+		return info, fmt.Errorf("unsupported snapshot version %d", info.Version)
+	}
+	if info.Len < 0 {
+		return info, fmt.Errorf("invalid snapshot length %d", info.Len)
+	}
+	return info, nil
+}
+
+// encodedEntry is how entries are framed in a snapshot, after their keys
+// and values have been encoded by the cache's codecs.
+type encodedEntry struct {
+	Key   []byte
+	Value []byte
+}
+
+type snapshotEntry[K comparable, V any] struct {
+	Key   K `json:"key"`
+	Value V `json:"value"`
+}
+
+// Save writes the cache's keys and values to w, least recently used
+// first, so that Load can restore their relative recency.  Keys and
+// values are encoded by the codecs given WithKeyCodec and WithValueCodec,
+// and framed with encoding/gob.  The cache is only locked while its
+// entries are copied, not while they are encoded.
+func (c *Cache[K, V]) Save(w io.Writer) error {
+	_, err := c.WriteTo(w)
+	return err
+}
+
+// WriteTo implements io.WriterTo, writing the same snapshot as Save and
+// returning the number of bytes written.
+func (c *Cache[K, V]) WriteTo(w io.Writer) (n int64, err error) {
+	if c.isClosed() {
+		return 0, ErrClosed
+	}
+	return c.writeSnapshot(w, c.snapshot())
+}
+
+// SnapshotAsync copies the cache's entries, holding its lock only for the
+// copy, and then writes them to w as Save does in a new goroutine.  The
+// returned channel receives the result of the write.  Later changes to
+// the cache don't affect the snapshot.
+func (c *Cache[K, V]) SnapshotAsync(w io.Writer) <-chan error {
+	done := make(chan error, 1)
+	if c.isClosed() {
+		done <- ErrClosed
+		return done
+	}
+	snap := c.snapshot()
+	go func() {
+		_, err := c.writeSnapshot(w, snap)
+		done <- err
+	}()
+	return done
+}
+
+// cacheSnapshot is a copy of a cache's entries and its capacity at the
+// time.
+type cacheSnapshot[K comparable, V any] struct {
+	simplelru.Snapshot[K, V]
+	size int
+}
+
+// snapshot copies the cache's entries under the lock, leaving the work of
+// sorting them to the caller.
+func (c *Cache[K, V]) snapshot() cacheSnapshot[K, V] {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return cacheSnapshot[K, V]{c.lru.Snapshot(), c.lru.Cap()}
+}
+
+func (c *Cache[K, V]) writeSnapshot(w io.Writer, snap cacheSnapshot[K, V]) (n int64, err error) {
+	entries := snap.Entries()
+	cw := &countingWriter{w: w}
+	enc := gob.NewEncoder(cw)
+	header := SnapshotInfo{
+		Version: snapshotVersion,
+		Len:     len(entries),
+		Size:    snap.size,
+		Policy:  snapshotPolicy,
+	}
+	if err := enc.Encode(header); err != nil {
+		return cw.n, err
+	}
+	for _, e := range entries {
+		key, err := c.keyCodec.Encode(e.Key)
+		if err != nil {
+			return cw.n, err
+		}
+		value, err := c.valueCodec.Encode(e.Value)
+		if err != nil {
+			return cw.n, err
+		}
+		if err := enc.Encode(encodedEntry{Key: key, Value: value}); err != nil {
+			return cw.n, err
+		}
+	}
+	return cw.n, nil
+}
+
+// Load adds the entries from a snapshot written by Save to the cache,
+// preserving their relative recency: the most recently used entries of
+// the snapshot become the most recently used entries of the cache.  If
+// the snapshot holds more entries than the cache can, the least recently
+// used are skipped.  Existing entries are kept, but may be evicted.
+func (c *Cache[K, V]) Load(r io.Reader) error {
+	_, err := c.ReadFrom(r)
+	return err
+}
+
+// ReadFrom implements io.ReaderFrom, loading a snapshot as Load does and
+// returning the number of bytes read.  It reads no further than the end
+// of the snapshot, so snapshots can be read one after another from a
+// stream such as a network connection.
+func (c *Cache[K, V]) ReadFrom(r io.Reader) (n int64, err error) {
+	if c.isClosed() {
+		return 0, ErrClosed
+	}
+	cr := &countingReader{r: r}
+	dec := gob.NewDecoder(cr)
+	var header SnapshotInfo
+	if err := dec.Decode(&header); err != nil {
+		return cr.n, err
+	}
+	if header.Len < 0 {
+		return cr.n, fmt.Errorf("invalid snapshot length %d", header.Len)
+	}
+	var entries []snapshotEntry[K, V]
+	switch header.Version {
+	case 1:
+		entries, err = readSnapshotV1[K, V](dec, header.Len)
+	case 2, 3:
+		entries, err = c.readSnapshotV2(dec, header.Len)
+	default:
+		err = fmt.Errorf("unsupported snapshot version %d", header.Version)
+	}
+	if err != nil {
+		return cr.n, err
+	}
+
+	return cr.n, c.restore(entries)
+}
+
+// readSnapshotV1 reads the entries of a version 1 snapshot, in which keys
+// and values were gob-encoded directly.
+func readSnapshotV1[K comparable, V any](dec *gob.Decoder, n int) ([]snapshotEntry[K, V], error) {
+	entries := make([]snapshotEntry[K, V], 0, min(n, maxSnapshotPrealloc))
+	for i := 0; i < n; i++ {
+		var e snapshotEntry[K, V]
+		if err := dec.Decode(&e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// readSnapshotV2 reads the entries of a version 2 or 3 snapshot, in
+// which keys and values are encoded by the cache's codecs.
+func (c *Cache[K, V]) readSnapshotV2(dec *gob.Decoder, n int) (entries []snapshotEntry[K, V], err error) {
+	entries = make([]snapshotEntry[K, V], 0, min(n, maxSnapshotPrealloc))
+	for i := 0; i < n; i++ {
+		var e encodedEntry
+		if err := dec.Decode(&e); err != nil {
+			return nil, err
+		}
+		var entry snapshotEntry[K, V]
+		if entry.Key, err = c.keyCodec.Decode(e.Key); err != nil {
+			return nil, err
+		}
+		if entry.Value, err = c.valueCodec.Decode(e.Value); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// restore adds entries, ordered least recently used first, to the cache.
+func (c *Cache[K, V]) restore(entries []snapshotEntry[K, V]) error {
+	c.lock.Lock()
+	defer c.unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	if skip := len(entries) - c.lru.Cap(); skip > 0 {
+		entries = entries[skip:]
+	}
+	for _, e := range entries {
+		c.lru.Add(e.Key, e.Value)
+	}
+	return nil
+}
+
+// ExportOrdered returns a copy of the cache's entries, most recently used
+// first, so that a process receiving them can import a prefix into a
+// smaller cache and keep the most valuable entries.
+func (c *Cache[K, V]) ExportOrdered() []simplelru.Entry[K, V] {
+	entries := c.snapshot().Entries()
+	reverse(entries)
+	return entries
+}
+
+// ExportOrdered returns a copy of the cache's entries, most recently used
+// first.  Shards keep separate logical clocks, so entries are ordered by
+// their rank within their shard: the newest tenth of every shard comes
+// before the next tenth of any shard, and so on.  With keys spread evenly
+// across shards this closely approximates a global recency order.
+func (c *ShardedCache[V]) ExportOrdered() []simplelru.Entry[string, V] {
+	type ranked struct {
+		rank  float64
+		entry simplelru.Entry[string, V]
+	}
+	var all []ranked
+	for i := range c.shards {
+		// copy the shard a chunk at a time rather than holding its lock
+		// for a snapshot of all of it
+		var entries []simplelru.Entry[string, V]
+		walkShard(&c.shards[i], make([]simplelru.Entry[string, V], 0, iterChunk), func(chunk []simplelru.Entry[string, V]) bool {
+			entries = append(entries, chunk...)
+			return true
+		})
+		slices.SortFunc(entries, func(a, b simplelru.Entry[string, V]) bool {
+			return a.LastUsed < b.LastUsed
+		})
+		for j, e := range entries {
+			// entries are oldest first, so the newest has rank 0
+			rank := float64(len(entries)-1-j) / float64(len(entries))
+			all = append(all, ranked{rank, e})
+		}
+	}
+	slices.SortStableFunc(all, func(a, b ranked) bool {
+		return a.rank < b.rank
+	})
+	entries := make([]simplelru.Entry[string, V], len(all))
+	for i := range all {
+		entries[i] = all[i].entry
+	}
+	return entries
+}
+
+// OldestN returns (up to) the n least recently used entries, least
+// recently used first, without copying or sorting the rest, for eviction
+// dashboards and for deciding what to persist when a full snapshot is too
+// large.
+func (c *Cache[K, V]) OldestN(n int) []simplelru.Entry[K, V] {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.lru.OldestN(n)
+}
+
+// NewestN returns (up to) the n most recently used entries, most recently
+// used first.  See OldestN.
+func (c *Cache[K, V]) NewestN(n int) []simplelru.Entry[K, V] {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.lru.NewestN(n)
+}
+
+// EvictionOrder returns an iterator over the cache's entries, least
+// recently used first, for seeing what the cache is likeliest to evict
+// next.  See simplelru.LRU.EvictionOrder.
+func (c *Cache[K, V]) EvictionOrder() func(yield func(simplelru.Entry[K, V]) bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.lru.EvictionOrder()
+}
+
+// EvictionCandidates returns the entries n evictions from the cache as it
+// stands would choose, without evicting them.  See
+// simplelru.LRU.EvictionCandidates.
+func (c *Cache[K, V]) EvictionCandidates(n int) []simplelru.EvictionCandidate[K, V] {
+	// sampling advances the cache's random source
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.EvictionCandidates(n)
+}
+
+// OldestN returns (up to) the n least recently used entries, least
+// recently used first.  As with ExportOrdered, entries are ordered by
+// their rank within their shard, so the result approximates the globally
+// oldest entries.
+func (c *ShardedCache[V]) OldestN(n int) []simplelru.Entry[string, V] {
+	return c.extremes(n, (*simplelru.LRU[string, V]).OldestN)
+}
+
+// NewestN returns (up to) the n most recently used entries, most recently
+// used first.  See OldestN.
+func (c *ShardedCache[V]) NewestN(n int) []simplelru.Entry[string, V] {
+	return c.extremes(n, (*simplelru.LRU[string, V]).NewestN)
+}
+
+// extremes takes the first n entries of each shard by extreme, and merges
+// them by rank.
+func (c *ShardedCache[V]) extremes(n int, extreme func(*simplelru.LRU[string, V], int) []simplelru.Entry[string, V]) []simplelru.Entry[string, V] {
+	if n <= 0 {
+		return nil
+	}
+	type ranked struct {
+		rank  float64
+		entry simplelru.Entry[string, V]
+	}
+	var all []ranked
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.rlock()
+		entries := extreme(&shard.lru, n)
+		size := shard.lru.Len()
+		shard.mu.RUnlock()
+		for j, e := range entries {
+			all = append(all, ranked{float64(j) / float64(size), e})
+		}
+	}
+	slices.SortStableFunc(all, func(a, b ranked) bool {
+		return a.rank < b.rank
+	})
+	if len(all) > n {
+		all = all[:n]
+	}
+	entries := make([]simplelru.Entry[string, V], len(all))
+	for i := range all {
+		entries[i] = all[i].entry
+	}
+	return entries
+}
+
+// ExportJSON writes the cache's keys and values to w as an indented JSON
+// array of {"key": ..., "value": ...} objects, most recently used first.
+// It is meant for small caches whose contents operators want to inspect
+// or edit by hand; Save is more compact.
+func (c *Cache[K, V]) ExportJSON(w io.Writer) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+	entries := c.ExportOrdered()
+	out := make([]snapshotEntry[K, V], len(entries))
+	for i, e := range entries {
+		out[i] = snapshotEntry[K, V]{Key: e.Key, Value: e.Value}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// ImportJSON adds the entries in a JSON array written by ExportJSON to the
+// cache.  Recency follows the order of the array: the first entry becomes
+// the most recently used.  If the array holds more entries than the cache
+// can, those at the end are skipped.
+func (c *Cache[K, V]) ImportJSON(r io.Reader) error {
+	var entries []snapshotEntry[K, V]
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return err
+	}
+	reverse(entries)
+	return c.restore(entries)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// countingReader implements io.ByteReader so that gob doesn't wrap it in
+// a bufio.Reader, which would read past the end of the snapshot.
+type countingReader struct {
+	r   io.Reader
+	n   int64
+	buf [1]byte
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func (r *countingReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(r, r.buf[:]); err != nil {
+		return 0, err
+	}
+	return r.buf[0], nil
+}
+
+func reverse[E any](s []E) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
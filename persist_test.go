@@ -0,0 +1,402 @@
+package lru
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSaveLoad(t *testing.T) {
+	l, err := New[string, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add("a", 1)
+	l.Add("b", 2)
+	l.Add("c", 3)
+	l.Get("a")
+
+	var buf bytes.Buffer
+	if err := l.Save(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	restored, err := New[string, int](2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := restored.Load(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// the least recently used entry, b, doesn't fit
+	if restored.Len() != 2 || restored.Contains("b") {
+		t.Fatalf("expected only the 2 most recent entries to be restored")
+	}
+	if v, ok := restored.Get("a"); !ok || v != 1 {
+		t.Errorf("bad value for a: %v, %v", v, ok)
+	}
+	if v, ok := restored.Get("c"); !ok || v != 3 {
+		t.Errorf("bad value for c: %v, %v", v, ok)
+	}
+}
+
+func TestLoadRecency(t *testing.T) {
+	l, err := New[int, int](3)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	l.Get(1)
+
+	var buf bytes.Buffer
+	if err := l.Save(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	restored, err := New[int, int](3)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := restored.Load(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// 2 was least recently used before saving, so it should be evicted first
+	restored.Add(4, 4)
+	if restored.Contains(2) {
+		t.Errorf("expected 2 to be evicted")
+	}
+}
+
+func TestLoadBadVersion(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New[int, int](3)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := l.Save(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	b := buf.Bytes()
+	if err := l.Load(bytes.NewReader(b[:len(b)/2])); err == nil {
+		t.Errorf("expected an error loading a truncated snapshot")
+	}
+}
+
+func TestExportImportJSON(t *testing.T) {
+	l, err := New[string, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add("a", 1)
+	l.Add("b", 2)
+	l.Add("c", 3)
+
+	var buf bytes.Buffer
+	if err := l.ExportJSON(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var exported []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &exported); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(exported) != 3 || exported[0]["key"] != "c" || exported[2]["key"] != "a" {
+		t.Fatalf("expected newest-first export, got %s", buf.String())
+	}
+
+	restored, err := New[string, int](2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := restored.ImportJSON(strings.NewReader(`[
+		{"key": "x", "value": 10},
+		{"key": "y", "value": 20},
+		{"key": "z", "value": 30}
+	]`)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if restored.Len() != 2 || restored.Contains("z") {
+		t.Fatalf("expected only the first 2 entries to be imported")
+	}
+	if v, ok := restored.Get("x"); !ok || v != 10 {
+		t.Errorf("bad value for x: %v, %v", v, ok)
+	}
+}
+
+func TestWriteToReadFrom(t *testing.T) {
+	l, err := New[int, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		l.Add(i, i)
+	}
+
+	// write two snapshots back to back, as if over a connection
+	var buf bytes.Buffer
+	n, err := l.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("WriteTo returned %d, wrote %d bytes", n, buf.Len())
+	}
+	if _, err := l.WriteTo(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		restored, err := New[int, int](128)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		m, err := restored.ReadFrom(&buf)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if m != n {
+			t.Fatalf("ReadFrom returned %d, expected %d", m, n)
+		}
+		if restored.Len() != 100 {
+			t.Fatalf("bad len: %v", restored.Len())
+		}
+	}
+}
+
+func TestExportOrdered(t *testing.T) {
+	l, err := New[int, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		l.Add(i, i)
+	}
+	l.Get(3)
+
+	entries := l.ExportOrdered()
+	if len(entries) != 10 {
+		t.Fatalf("bad len: %v", len(entries))
+	}
+	if entries[0].Key != 3 || entries[1].Key != 9 || entries[9].Key != 0 {
+		t.Fatalf("expected newest-first order, got %v", entries)
+	}
+}
+
+func TestOldestNewestN(t *testing.T) {
+	l, err := New[int, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		l.Add(i, i)
+	}
+	l.Get(0)
+	if newest := l.NewestN(2); len(newest) != 2 || newest[0].Key != 0 || newest[1].Key != 9 {
+		t.Fatalf("bad newest: %v", newest)
+	}
+	if oldest := l.OldestN(2); len(oldest) != 2 || oldest[0].Key != 1 || oldest[1].Key != 2 {
+		t.Fatalf("bad oldest: %v", oldest)
+	}
+
+	sharded, err := NewSharded[int](1024, 8)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 800; i++ {
+		sharded.Add(strconv.Itoa(i), i)
+	}
+	oldest := sharded.OldestN(100)
+	if len(oldest) != 100 {
+		t.Fatalf("bad len: %v", len(oldest))
+	}
+	for _, e := range oldest {
+		if e.Value >= 400 {
+			t.Fatalf("expected only old entries, got %v", e)
+		}
+	}
+	for _, e := range sharded.NewestN(100) {
+		if e.Value < 400 {
+			t.Fatalf("expected only new entries, got %v", e)
+		}
+	}
+	if sharded.NewestN(0) != nil {
+		t.Fatalf("expected no entries")
+	}
+}
+
+func TestShardedExportOrdered(t *testing.T) {
+	l, err := NewSharded[int](1024, 8)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 800; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+
+	entries := l.ExportOrdered()
+	if len(entries) != 800 {
+		t.Fatalf("bad len: %v", len(entries))
+	}
+	// the newest quarter of the export should be mostly recent additions
+	recent := 0
+	for _, e := range entries[:200] {
+		if e.Value >= 600 {
+			recent++
+		}
+	}
+	if recent < 150 {
+		t.Fatalf("expected most of the first 200 entries to be recent, got %d", recent)
+	}
+}
+
+func TestReadSnapshotInfo(t *testing.T) {
+	l, err := New[int, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	var buf bytes.Buffer
+	if err := l.Save(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	info, err := ReadSnapshotInfo(&buf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expected := SnapshotInfo{Version: snapshotVersion, Len: 1, Size: 128, Policy: snapshotPolicy}
+	if info != expected {
+		t.Fatalf("bad info: %+v", info)
+	}
+}
+
+func TestLoadSnapshotV1(t *testing.T) {
+	// version 1 snapshots had a two field header, and gob-encoded entries
+	// directly.
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if err := enc.Encode(struct{ Version, Len int }{1, 2}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, e := range []snapshotEntry[string, int]{{"a", 1}, {"b", 2}} {
+		if err := enc.Encode(e); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	info, err := ReadSnapshotInfo(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info.Version != 1 || info.Len != 2 || info.Policy != "" {
+		t.Fatalf("bad info: %+v", info)
+	}
+
+	l, err := New[string, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := l.Load(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if v, ok := l.Get("b"); !ok || v != 2 {
+		t.Errorf("bad value for b: %v, %v", v, ok)
+	}
+}
+
+func TestSnapshotAsync(t *testing.T) {
+	l, err := New[int, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		l.Add(i, i)
+	}
+
+	var buf bytes.Buffer
+	done := l.SnapshotAsync(&buf)
+	// changes after SnapshotAsync returns aren't part of the snapshot
+	for i := 100; i < 200; i++ {
+		l.Add(i, i)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	restored, err := New[int, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := restored.Load(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if restored.Len() != 100 || !restored.Contains(0) || restored.Contains(100) {
+		t.Fatalf("expected the snapshot to hold exactly the first 100 entries")
+	}
+}
+
+func TestLoadBadLength(t *testing.T) {
+	l, err := New[int, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, n := range []int{-1, math.MaxInt} {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(SnapshotInfo{Version: snapshotVersion, Len: n}); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if err := l.Load(&buf); err == nil {
+			t.Errorf("expected an error loading a snapshot of length %d", n)
+		}
+	}
+}
+
+func TestLoadSnapshotV2(t *testing.T) {
+	// version 2 snapshots encoded entries with codecs, but their header
+	// lacked the cache's parameters.
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if err := enc.Encode(struct{ Version, Len int }{2, 1}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := enc.Encode(encodedEntry{Key: []byte("a"), Value: []byte{2}}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l, err := New[string, int](128, WithKeyCodec[string, int](StringCodec{}), WithValueCodec[string, int](IntCodec[int]{}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := l.Load(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if v, ok := l.Get("a"); !ok || v != 1 {
+		t.Errorf("bad value for a: %v, %v", v, ok)
+	}
+}
+
+func TestSnapshotAsyncResize(t *testing.T) {
+	l, err := New[int, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		l.Add(i, i)
+	}
+	// run under -race: writing the snapshot mustn't read the cache
+	done := l.SnapshotAsync(io.Discard)
+	l.Resize(64)
+	if err := <-done; err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := l.SaveCBOR(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
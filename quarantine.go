@@ -0,0 +1,194 @@
+package lru
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// WithQuarantine makes Remove hold removed entries aside for d before
+// calling the eviction callback, so that Restore can put them back, for
+// invalidation pipelines whose mistakes are expensive to refetch.  At most
+// size entries are held: past that, the longest held is released early.
+// Quarantined entries are absent from the cache, don't count against its
+// size, and are released by Purge; Close drops them without calling the
+// callback.  RemoveFunc and RemovePrefix don't quarantine what they
+// remove.
+func WithQuarantine[K comparable, V any](d time.Duration, size int) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.quarantineFor = d
+		o.quarantineSize = size
+	}
+}
+
+// quarantine holds removed entries until their time is up, then passes
+// them to release.
+type quarantine[K comparable, V any] struct {
+	mu    sync.Mutex
+	d     time.Duration
+	size  int
+	order list.List // of *quarantined[K, V], longest held first
+	items map[K]*list.Element
+
+	release func(key K, value V)
+}
+
+type quarantined[K comparable, V any] struct {
+	key   K
+	value V
+	timer *time.Timer
+}
+
+func newQuarantine[K comparable, V any](o *options[K, V], release func(key K, value V)) (*quarantine[K, V], error) {
+	if o.quarantineFor == 0 && o.quarantineSize == 0 {
+		return nil, nil
+	}
+	if o.quarantineFor <= 0 || o.quarantineSize <= 0 {
+		return nil, errors.New("must provide a positive quarantine duration and size")
+	}
+	return &quarantine[K, V]{
+		d:       o.quarantineFor,
+		size:    o.quarantineSize,
+		items:   make(map[K]*list.Element),
+		release: release,
+	}, nil
+}
+
+// put quarantines key's removed value, replacing any value already held
+// for key.
+func (q *quarantine[K, V]) put(key K, value V) {
+	var released []*quarantined[K, V]
+	q.mu.Lock()
+	if el, ok := q.items[key]; ok {
+		released = append(released, q.remove(el))
+	}
+	if q.order.Len() >= q.size {
+		released = append(released, q.remove(q.order.Front()))
+	}
+	ent := &quarantined[K, V]{key: key, value: value}
+	el := q.order.PushBack(ent)
+	q.items[key] = el
+	ent.timer = time.AfterFunc(q.d, func() { q.expire(el) })
+	q.mu.Unlock()
+	for _, ent := range released {
+		q.release(ent.key, ent.value)
+	}
+}
+
+// remove takes el out of the quarantine.  q.mu must be held.
+func (q *quarantine[K, V]) remove(el *list.Element) *quarantined[K, V] {
+	ent := q.order.Remove(el).(*quarantined[K, V])
+	delete(q.items, ent.key)
+	ent.timer.Stop()
+	return ent
+}
+
+func (q *quarantine[K, V]) expire(el *list.Element) {
+	q.mu.Lock()
+	ent := el.Value.(*quarantined[K, V])
+	// el may have been restored or released since its timer fired
+	if q.items[ent.key] != el {
+		q.mu.Unlock()
+		return
+	}
+	q.remove(el)
+	q.mu.Unlock()
+	q.release(ent.key, ent.value)
+}
+
+// take removes and returns key's quarantined value without releasing it.
+func (q *quarantine[K, V]) take(key K) (value V, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	el, ok := q.items[key]
+	if !ok {
+		return value, false
+	}
+	return q.remove(el).value, true
+}
+
+// drain removes and returns every quarantined entry without releasing
+// them.
+func (q *quarantine[K, V]) drain() []evictedEntry[K, V] {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	entries := make([]evictedEntry[K, V], 0, q.order.Len())
+	for q.order.Len() > 0 {
+		ent := q.remove(q.order.Front())
+		entries = append(entries, evictedEntry[K, V]{key: ent.key, value: ent.value})
+	}
+	return entries
+}
+
+func (q *quarantine[K, V]) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.order.Len()
+}
+
+// purge releases every quarantined entry.
+func (q *quarantine[K, V]) purge() {
+	for _, e := range q.drain() {
+		q.release(e.key, e.value)
+	}
+}
+
+// Restore puts key back in the cache if it was removed WithQuarantine and
+// is still held, returning whether it did.  If key has been added again
+// since it was removed, its quarantined value is released instead.
+func (c *Cache[K, V]) Restore(key K) bool {
+	if c.quarantine == nil {
+		return false
+	}
+	value, ok := c.quarantine.take(key)
+	if !ok {
+		return false
+	}
+	c.lock.Lock()
+	if c.closed || c.lru.Contains(key) {
+		c.unlock()
+		c.releaseAll([]evictedEntry[K, V]{{key: key, value: value, info: removed}})
+		return false
+	}
+	c.lru.Add(key, value)
+	c.unlock()
+	return true
+}
+
+// Quarantined returns the number of entries held WithQuarantine.
+func (c *Cache[K, V]) Quarantined() int {
+	if c.quarantine == nil {
+		return 0
+	}
+	return c.quarantine.len()
+}
+
+// Restore puts key back in the cache if it was removed WithQuarantine and
+// is still held.  See Cache.Restore.
+func (c *ShardedCache[V]) Restore(key string) bool {
+	if c.quarantine == nil {
+		return false
+	}
+	value, ok := c.quarantine.take(key)
+	if !ok {
+		return false
+	}
+	shard := c.lockShardFor(key)
+	if c.closed.Load() || shard.lru.Contains(key) {
+		c.unlock(shard)
+		c.releaseAll([]evictedEntry[string, V]{{key: key, value: value, info: removed}})
+		return false
+	}
+	shard.lru.Add(key, value)
+	c.unlock(shard)
+	return true
+}
+
+// Quarantined returns the number of entries held WithQuarantine.
+func (c *ShardedCache[V]) Quarantined() int {
+	if c.quarantine == nil {
+		return 0
+	}
+	return c.quarantine.len()
+}
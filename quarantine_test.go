@@ -0,0 +1,113 @@
+package lru
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQuarantine(t *testing.T) {
+	var mu sync.Mutex
+	var evicted []string
+	l, err := NewWithEvict[string, int](8, func(key string, _ int) {
+		mu.Lock()
+		defer mu.Unlock()
+		evicted = append(evicted, key)
+	}, WithQuarantine[string, int](time.Hour, 2))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	evictedKeys := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), evicted...)
+	}
+
+	l.Add("a", 1)
+	if !l.Remove("a") || l.Contains("a") || l.Len() != 0 {
+		t.Fatalf("expected a to be removed")
+	}
+	if l.Quarantined() != 1 || len(evictedKeys()) != 0 {
+		t.Fatalf("expected a to be quarantined, evicted %v", evictedKeys())
+	}
+	if !l.Restore("a") {
+		t.Fatalf("expected a to be restored")
+	}
+	if v, ok := l.Get("a"); !ok || v != 1 {
+		t.Fatalf("bad value: %v, %v", v, ok)
+	}
+	if l.Restore("a") || l.Restore("missing") {
+		t.Fatalf("expected nothing to restore")
+	}
+
+	// past its size, the quarantine releases the longest held
+	for _, key := range []string{"a", "b", "c"} {
+		l.Add(key, 1)
+		l.Remove(key)
+	}
+	if keys := evictedKeys(); len(keys) != 1 || keys[0] != "a" {
+		t.Fatalf("expected a to be released, got %v", keys)
+	}
+
+	// a key added again since its removal can't be restored
+	l.Add("b", 2)
+	if l.Restore("b") {
+		t.Fatalf("expected b not to be restored over its new value")
+	}
+	if v, _ := l.Get("b"); v != 2 {
+		t.Fatalf("bad value: %v", v)
+	}
+	if keys := evictedKeys(); len(keys) != 2 || keys[1] != "b" {
+		t.Fatalf("expected b to be released, got %v", keys)
+	}
+
+	l.Purge()
+	if l.Quarantined() != 0 || len(evictedKeys()) != 4 {
+		t.Fatalf("expected purge to release c and the live b, got %v", evictedKeys())
+	}
+}
+
+func TestQuarantineExpires(t *testing.T) {
+	released := make(chan string, 1)
+	l, err := NewShardedWithEvict[int](8, 2, func(key string, _ int) {
+		released <- key
+	}, WithQuarantine[string, int](10*time.Millisecond, 4))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add("a", 1)
+	l.Remove("a")
+	select {
+	case key := <-released:
+		if key != "a" {
+			t.Fatalf("expected a to be released, got %q", key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected a to be released")
+	}
+	if l.Restore("a") || l.Quarantined() != 0 {
+		t.Fatalf("expected a to be gone")
+	}
+
+	// closing drops quarantined entries without the callback
+	l.Add("b", 1)
+	l.Remove("b")
+	if err := l.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if l.Quarantined() != 0 {
+		t.Fatalf("expected close to drop b")
+	}
+	select {
+	case key := <-released:
+		t.Fatalf("unexpected release of %q", key)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestQuarantineOptions(t *testing.T) {
+	if _, err := New[string, int](8, WithQuarantine[string, int](time.Second, 0)); err == nil {
+		t.Fatalf("expected an error for a zero size")
+	}
+}
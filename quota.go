@@ -0,0 +1,76 @@
+package lru
+
+import (
+	"errors"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+var errQuotaClassifier = errors.New("WithQuotas requires WithClassifier")
+
+// WithQuotas bounds the classes of keys named in quotas, as named by the
+// classifier given WithClassifier, which it requires, so that one noisy
+// class, such as a tenant whose keys are namespaced like "tenant42/...",
+// can't take over the cache however recently its keys were used.  Adding
+// a new key to a class at its quota evicts one of the class's own entries
+// instead of another class's, as simplelru.WithQuotas describes.  A
+// ShardedCache divides each quota between its shards, as it does its
+// size, so a class's keys must spread evenly across shards to reach it.
+func WithQuotas[K comparable, V any](quotas map[string]simplelru.Quota) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.quotas = quotas
+	}
+}
+
+// shardQuotas returns each shard's share of quotas.
+func shardQuotas(quotas map[string]simplelru.Quota, shardCount int) map[string]simplelru.Quota {
+	if shardCount == 1 {
+		return quotas
+	}
+	shares := make(map[string]simplelru.Quota, len(quotas))
+	for class, q := range quotas {
+		if q.Entries > 0 {
+			q.Entries = max((q.Entries+shardCount-1)/shardCount, 1)
+		}
+		if q.Cost > 0 {
+			q.Cost = max((q.Cost+int64(shardCount)-1)/int64(shardCount), 1)
+		}
+		shares[class] = q
+	}
+	return shares
+}
+
+// QuotaUsage returns the number of entries each class with a quota holds,
+// and what they cost, keyed by class name.  It returns nil if the cache
+// was not created WithQuotas.
+func (c *Cache[K, V]) QuotaUsage() map[string]simplelru.Quota {
+	c.lock.RLock()
+	usage := c.lru.QuotaUsage()
+	c.lock.RUnlock()
+	return usage
+}
+
+// QuotaUsage returns the usage of each class with a quota summed across
+// shards, as Cache.QuotaUsage does.
+func (c *ShardedCache[V]) QuotaUsage() map[string]simplelru.Quota {
+	var usage map[string]simplelru.Quota
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.mu.RLock()
+		shardUsage := shard.lru.QuotaUsage()
+		shard.mu.RUnlock()
+		if shardUsage == nil {
+			continue
+		}
+		if usage == nil {
+			usage = make(map[string]simplelru.Quota, len(shardUsage))
+		}
+		for class, u := range shardUsage {
+			sum := usage[class]
+			sum.Entries += u.Entries
+			sum.Cost += u.Cost
+			usage[class] = sum
+		}
+	}
+	return usage
+}
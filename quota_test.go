@@ -0,0 +1,52 @@
+package lru
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+func TestQuotas(t *testing.T) {
+	if _, err := New[string, int](8, WithQuotas[string, int](map[string]simplelru.Quota{"a/": {Entries: 1}})); err == nil {
+		t.Fatalf("expected WithQuotas to require WithClassifier")
+	}
+
+	l, err := New[string, int](32,
+		WithClassifier[string, int](PrefixClassifier("a/", "b/")),
+		WithQuotas[string, int](map[string]simplelru.Quota{"a/": {Entries: 4}}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		l.Add("b/"+strconv.Itoa(i), i)
+	}
+	for i := 0; i < 100; i++ {
+		l.Add("a/"+strconv.Itoa(i), i)
+	}
+	if usage := l.QuotaUsage(); usage["a/"].Entries != 4 {
+		t.Fatalf("expected a/ to be held to 4 entries, got %+v", usage)
+	}
+	if n := l.Len(); n != 12 {
+		t.Fatalf("expected 12 entries, got %d", n)
+	}
+}
+
+func TestShardedQuotas(t *testing.T) {
+	l, err := NewSharded[int](256, 4,
+		WithClassifier[string, int](PrefixClassifier("a/")),
+		WithQuotas[string, int](map[string]simplelru.Quota{"a/": {Entries: 32}}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		l.Add("a/"+strconv.Itoa(i), i)
+	}
+	// each shard holds its share of the quota
+	if usage := l.QuotaUsage(); usage["a/"].Entries > 32 {
+		t.Fatalf("expected a/ to be held to 32 entries, got %+v", usage)
+	}
+	if cs := l.ClassStats()["a/"]; cs.QuotaEvictions == 0 {
+		t.Fatalf("expected quota evictions, got %+v", cs)
+	}
+}
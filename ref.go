@@ -0,0 +1,105 @@
+package lru
+
+// Ref is a reference to a value held in a cache, returned by GetRef and
+// PeekRef so that large values needn't be copied on every hit.  Unless
+// the cache was created WithCopyOnRead or WithClone, the value it points to is the
+// cached value itself: the cache holds a read lock until Release is
+// called, so Release must be called exactly once, promptly, and without
+// calling the cache's other methods in between, which can deadlock once
+// a writer is waiting for the lock.  The value must not
+// be modified through the reference, since other goroutines may be
+// reading it too, nor used after Release.
+type Ref[V any] struct {
+	value   *V
+	release func()
+}
+
+// Value returns a pointer to the referenced value.
+func (r Ref[V]) Value() *V {
+	return r.value
+}
+
+// Release gives up the reference, releasing the cache's read lock.
+func (r Ref[V]) Release() {
+	if r.release != nil {
+		r.release()
+	}
+}
+
+// WithCopyOnRead makes GetRef and PeekRef return references to private
+// copies of cached values, without holding a lock, for callers who want
+// the API but would rather pay for a copy than risk aliasing bugs.
+func WithCopyOnRead[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.copyOnRead = true
+	}
+}
+
+// ref returns a Ref to value, which the caller has read-locked with
+// unlock, or to a copy of it if copyOnRead is set, cloned if clone is.
+func ref[V any](value *V, copyOnRead bool, clone func(V) V, unlock func()) Ref[V] {
+	if clone != nil {
+		v := clone(*value)
+		unlock()
+		return Ref[V]{value: &v}
+	}
+	if copyOnRead {
+		v := *value
+		unlock()
+		return Ref[V]{value: &v}
+	}
+	return Ref[V]{value: value, release: unlock}
+}
+
+// GetRef looks up key's value as Get does, updating its recency, and
+// returns a reference to it rather than a copy.  Unlike Get, it doesn't
+// load misses.  See Ref for the rules on using the reference.
+func (c *Cache[K, V]) GetRef(key K) (r Ref[V], ok bool) {
+	c.lock.Lock()
+	_, ok = c.lru.GetRef(key)
+	c.unlock()
+	if !ok {
+		return r, false
+	}
+	// another goroutine may remove key between the locks, in which case
+	// the lookup is reported as a miss
+	return c.PeekRef(key)
+}
+
+// PeekRef looks up key's value as Peek does, without updating its
+// recency, and returns a reference to it rather than a copy.  See Ref
+// for the rules on using the reference.
+func (c *Cache[K, V]) PeekRef(key K) (Ref[V], bool) {
+	c.lock.RLock()
+	value, ok := c.lru.PeekRef(key)
+	if !ok {
+		c.lock.RUnlock()
+		return Ref[V]{}, false
+	}
+	return ref(value, c.copyOnRead, c.clone, c.lock.RUnlock), true
+}
+
+// GetRef looks up key's value as Cache.GetRef does.
+func (c *ShardedCache[V]) GetRef(key string) (r Ref[V], ok bool) {
+	shard := c.findShard(key)
+	shard.lock()
+	_, ok = shard.lru.GetRef(key)
+	c.unlock(shard)
+	if !ok {
+		return r, false
+	}
+	return c.PeekRef(key)
+}
+
+// PeekRef looks up key's value as Cache.PeekRef does, holding only its
+// shard's read lock until the reference is released.
+func (c *ShardedCache[V]) PeekRef(key string) (Ref[V], bool) {
+	shard := c.findShard(key)
+	shard.rlock()
+	value, ok := shard.lru.PeekRef(key)
+	if !ok {
+		shard.mu.RUnlock()
+		return Ref[V]{}, false
+	}
+	return ref(value, c.copyOnRead, c.clone, shard.mu.RUnlock), true
+}
@@ -0,0 +1,99 @@
+package lru
+
+import "testing"
+
+type bigValue struct {
+	n   int
+	pad [4096]byte
+}
+
+func TestRef(t *testing.T) {
+	l, err := New[int, bigValue](16)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, bigValue{n: 1})
+	if _, ok := l.GetRef(2); ok {
+		t.Fatalf("expected a miss")
+	}
+	r, ok := l.GetRef(1)
+	if !ok || r.Value().n != 1 {
+		t.Fatalf("expected a reference to 1")
+	}
+	r.Release()
+	if stats := l.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Fatalf("expected a hit and a miss, got %+v", stats)
+	}
+
+	// the reference aliases the cached value
+	r, _ = l.PeekRef(1)
+	first := r.Value()
+	r.Release()
+	r, _ = l.PeekRef(1)
+	if r.Value() != first {
+		t.Fatalf("expected references to the same value")
+	}
+	r.Release()
+	// and holds a read lock, which Release gives up
+	l.Add(2, bigValue{n: 2})
+}
+
+func TestRefCopyOnRead(t *testing.T) {
+	l, err := New[int, bigValue](16, WithCopyOnRead[int, bigValue]())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, bigValue{n: 1})
+	r, ok := l.PeekRef(1)
+	if !ok {
+		t.Fatalf("expected a hit")
+	}
+	r.Value().n = 2
+	// no lock is held, so this doesn't deadlock
+	l.Add(3, bigValue{n: 3})
+	r.Release()
+	if v, _ := l.Peek(1); v.n != 1 {
+		t.Fatalf("expected the cached value to be unchanged, got %d", v.n)
+	}
+}
+
+func TestShardedRef(t *testing.T) {
+	l, err := NewSharded[bigValue](64, 4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add("a", bigValue{n: 1})
+	r, ok := l.GetRef("a")
+	if !ok || r.Value().n != 1 {
+		t.Fatalf("expected a reference to 1")
+	}
+	r.Release()
+	if _, ok := l.PeekRef("b"); ok {
+		t.Fatalf("expected a miss")
+	}
+	l.Add("b", bigValue{n: 2})
+}
+
+func BenchmarkRef(b *testing.B) {
+	l, err := New[int, bigValue](16)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	l.Add(1, bigValue{n: 1})
+	b.Run("Get", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if v, _ := l.Get(1); v.n != 1 {
+				b.Fatalf("unexpected value")
+			}
+		}
+	})
+	b.Run("GetRef", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r, _ := l.GetRef(1)
+			if r.Value().n != 1 {
+				b.Fatalf("unexpected value")
+			}
+			r.Release()
+		}
+	})
+}
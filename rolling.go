@@ -0,0 +1,185 @@
+package lru
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+// RollingCache is a thread-safe cache of the entries written in the last
+// window of time, for "cache only the last five minutes" uses that an
+// LRU handles poorly, since it keeps whatever is recent enough however
+// old it is.  It splits the window into generations, each a
+// simplelru.LRU: entries are written to the newest, and every
+// window/generations the oldest generation is dropped, all at once and
+// in constant time, and a new one started.  An entry is therefore never
+// returned more than window after it was written, though it may be
+// dropped up to window/generations sooner; more generations make that
+// closer to the window, at the cost of a lookup in each on a miss.
+type RollingCache[K comparable, V any] struct {
+	mu       sync.Mutex
+	gens     []*simplelru.LRU[K, V]
+	size     int
+	interval int64
+	// cur indexes the newest generation in gens, which is a ring, and
+	// epoch is the interval it was started in.
+	cur   int
+	epoch int64
+	// now is time.Now, except in tests.
+	now func() time.Time
+}
+
+// NewRolling creates a RollingCache of the entries written in the last
+// window, divided into the given number of generations, of which there
+// must be at least two.  Each generation holds up to size/generations
+// entries, evicting the least recently used of its own to make room.
+func NewRolling[K comparable, V any](size int, window time.Duration, generations int) (*RollingCache[K, V], error) {
+	if generations < 2 {
+		return nil, errors.New("must provide at least two generations")
+	}
+	if window <= 0 {
+		return nil, errors.New("must provide a positive window")
+	}
+	if size < generations {
+		return nil, errors.New("must provide a size of at least one entry per generation")
+	}
+	c := &RollingCache[K, V]{
+		gens:     make([]*simplelru.LRU[K, V], generations),
+		size:     size / generations,
+		interval: max(int64(window)/int64(generations), 1),
+		now:      time.Now,
+	}
+	for i := range c.gens {
+		gen, err := simplelru.NewLRU[K, V](c.size, nil)
+		if err != nil {
+			return nil, err
+		}
+		c.gens[i] = gen
+	}
+	c.epoch = c.now().UnixNano() / c.interval
+	return c, nil
+}
+
+// roll drops the generations that have aged out of the window.  The
+// lock must be held.
+func (c *RollingCache[K, V]) roll() {
+	epoch := c.now().UnixNano() / c.interval
+	for n := min(epoch-c.epoch, int64(len(c.gens))); n > 0; n-- {
+		c.rotate()
+	}
+	c.epoch = max(epoch, c.epoch)
+}
+
+// rotate drops the oldest generation, and makes it the newest.  The lock
+// must be held.
+func (c *RollingCache[K, V]) rotate() {
+	c.cur = (c.cur + 1) % len(c.gens)
+	c.gens[c.cur].Release()
+}
+
+// Rotate starts a new generation now, dropping the oldest, on top of the
+// rotations made as time passes.
+func (c *RollingCache[K, V]) Rotate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.roll()
+	c.rotate()
+}
+
+// Add adds a value to the newest generation, replacing any value the key
+// had in an older one.  Returns true if an eviction occurred.
+func (c *RollingCache[K, V]) Add(key K, value V) (evicted bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.roll()
+	for i, gen := range c.gens {
+		if i != c.cur {
+			gen.Remove(key)
+		}
+	}
+	return c.gens[c.cur].Add(key, value)
+}
+
+// Get looks up a key's value, newest generation first, updating its
+// recent-ness within its generation.
+func (c *RollingCache[K, V]) Get(key K) (value V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.roll()
+	if value, ok = c.gens[c.cur].Get(key); ok {
+		return value, true
+	}
+	return c.peekOlder(key)
+}
+
+// Peek returns the key's value without updating its recent-ness.
+func (c *RollingCache[K, V]) Peek(key K) (value V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.roll()
+	if value, ok = c.gens[c.cur].Peek(key); ok {
+		return value, true
+	}
+	return c.peekOlder(key)
+}
+
+// peekOlder looks key up in the generations before the newest, newest
+// first.  Entries in them are never written again, so there's no
+// recent-ness to update.  The lock must be held.
+func (c *RollingCache[K, V]) peekOlder(key K) (value V, ok bool) {
+	for i := 1; i < len(c.gens); i++ {
+		gen := c.gens[(c.cur-i+len(c.gens))%len(c.gens)]
+		if value, ok = gen.Peek(key); ok {
+			return value, true
+		}
+	}
+	return value, false
+}
+
+// Contains checks if a key is in the cache, without updating its
+// recent-ness.
+func (c *RollingCache[K, V]) Contains(key K) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+// Remove removes the provided key from the cache.
+func (c *RollingCache[K, V]) Remove(key K) (present bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.roll()
+	for _, gen := range c.gens {
+		if gen.Remove(key) {
+			present = true
+		}
+	}
+	return present
+}
+
+// Purge drops every generation.
+func (c *RollingCache[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, gen := range c.gens {
+		gen.Release()
+	}
+}
+
+// Len returns the number of items in the cache.
+func (c *RollingCache[K, V]) Len() (n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.roll()
+	for _, gen := range c.gens {
+		n += gen.Len()
+	}
+	return n
+}
+
+// Cap returns the maximum number of items the cache can hold, across its
+// generations.
+func (c *RollingCache[K, V]) Cap() int {
+	return c.size * len(c.gens)
+}
@@ -0,0 +1,92 @@
+package lru
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRollingCache(t *testing.T) {
+	var clock fakeClock
+	clock.Advance(time.Hour)
+	c, err := NewRolling[int, int](300, time.Minute, 3)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c.now = clock.Now
+	c.epoch = clock.Now().UnixNano() / c.interval
+
+	c.Add(1, 1)
+	clock.Advance(20 * time.Second)
+	c.Add(2, 2)
+	clock.Advance(20 * time.Second)
+	c.Add(3, 3)
+	if c.Len() != 3 {
+		t.Fatalf("expected 3 entries, got %d", c.Len())
+	}
+	for _, key := range []int{1, 2, 3} {
+		if v, ok := c.Get(key); !ok || v != key {
+			t.Fatalf("expected %d, got %v %v", key, v, ok)
+		}
+	}
+
+	// each generation is dropped a window after it started, however
+	// recently its entries were read
+	clock.Advance(20 * time.Second)
+	if c.Contains(1) || !c.Contains(2) || !c.Contains(3) {
+		t.Fatalf("expected only 1 to have aged out")
+	}
+
+	// rewriting a key moves it to the newest generation
+	c.Add(2, 20)
+	clock.Advance(20 * time.Second)
+	if v, ok := c.Peek(2); !ok || v != 20 {
+		t.Fatalf("expected the rewritten 2, got %v %v", v, ok)
+	}
+	if !c.Contains(3) || c.Len() != 2 {
+		t.Fatalf("expected 2 and 3 to be left, got %d", c.Len())
+	}
+	clock.Advance(20 * time.Second)
+	if c.Contains(3) || c.Len() != 1 {
+		t.Fatalf("expected only 2 to be left, got %d", c.Len())
+	}
+
+	// time jumping past the window drops everything
+	clock.Advance(time.Hour)
+	if c.Len() != 0 {
+		t.Fatalf("expected every entry to have aged out, got %d", c.Len())
+	}
+
+	c.Add(4, 4)
+	c.Rotate()
+	c.Rotate()
+	if !c.Contains(4) {
+		t.Fatalf("expected 4 to survive two rotations")
+	}
+	c.Rotate()
+	if c.Contains(4) {
+		t.Fatalf("expected 4 to be dropped by the third rotation")
+	}
+
+	c.Add(5, 5)
+	if !c.Remove(5) || c.Remove(5) {
+		t.Fatalf("expected 5 to be removed once")
+	}
+	c.Add(6, 6)
+	c.Purge()
+	if c.Len() != 0 || c.Cap() != 300 {
+		t.Fatalf("bad len %d or cap %d", c.Len(), c.Cap())
+	}
+
+	for _, bad := range []struct {
+		size, generations int
+		window            time.Duration
+	}{
+		{300, 1, time.Minute},
+		{300, 3, 0},
+		{2, 3, time.Minute},
+	} {
+		if _, err := NewRolling[int, int](bad.size, bad.window, bad.generations); err == nil {
+			t.Fatalf("expected an error for %+v", bad)
+		}
+	}
+}
@@ -1,35 +1,110 @@
 package lru
 
 import (
+	"context"
+	"fmt"
 	"hash/maphash"
+	"log/slog"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
 
 	"github.com/bpowers/approx-lru/simplelru"
 )
 
 const defaultShardCount = 256
 
+// iterChunk is the number of entries iteration copies out of a shard per
+// acquisition of its lock, which it holds for a few microseconds at most.
+const iterChunk = 256
+
+// shard is padded out to a multiple of shardAlign bytes, so that
+// goroutines working on neighboring shards don't contend on the same
+// cache lines.  Building with the lru_nopad tag turns the padding off,
+// for memory-constrained builds.
 type shard[V any] struct {
-	mu       sync.Mutex
-	lru      simplelru.LRU[string, V]
-	_padding [16]uint8
+	// the padding goes first, since a trailing zero-size field would
+	// itself be padded.
+	_ [shardPad]byte
+	shardFields[V]
+}
+
+// shardPad is the padding that rounds a shard up to shardAlign bytes.
+// The size of shardFields doesn't depend on V, since V only appears
+// behind pointers, so it can be computed once at compile time.
+const shardPad = (shardAlign - unsafe.Sizeof(shardFields[struct{}]{})%shardAlign) % shardAlign
+
+// shardFields is a shard's contents.
+//
+// Operations that don't modify the shard, like Peek and Contains, share
+// mu's read lock.  Get has to take the write lock, since it updates the
+// entry's recency, the shard's clock and its stats; and reads can't skip
+// the lock entirely with a per-slot sequence number, seqlock-style,
+// because looking a key up in a map that another goroutine is writing is
+// a fatal error in Go, not just a stale read that could be retried.
+type shardFields[V any] struct {
+	mu    sync.RWMutex
+	lru   simplelru.LRU[string, V]
+	instr *shardInstrumentation
+	// evicted holds entries evicted while mu is held, to be passed to
+	// the eviction callback once it's released.
+	evicted []evictedEntry[string, V]
+	// buf holds writes buffered WithWriteBuffer.
+	buf *writeBuffer[V]
+}
+
+// shardInstrumentation holds optional per-shard bookkeeping, behind a
+// pointer so that shards stay cache-line sized.
+type shardInstrumentation struct {
+	latency    *latencyRecorder
+	contention *contentionRecorder
 }
 
 // Cache is a thread-safe fixed size LRU cache.
 type ShardedCache[V any] struct {
-	templateHash maphash.Hash
-	shards       []shard[V]
-	size         int
+	seed maphash.Seed
+	// hashSeed is used in place of seed if the cache was created
+	// WithRandSource, since maphash can't be seeded deterministically.
+	hashSeed uint64
+	seeded   bool
+	shards   []shard[V]
+	mrc      *simplelru.MissRatioCurve
+	logger   *slog.Logger
+	loads    *loadGroup[string, V]
+	writes   *storeWriter[string, V]
+	behind   *writeBehind[string, V]
+	onEvict  evictCallbacks[string, V]
+	// bulkEvict is given WithBulkOnEvict, and evictInfo WithOnEvictInfo.
+	bulkEvict func(entries []simplelru.Entry[string, V])
+	evictInfo func(key string, value V, info simplelru.EvictionInfo)
+	// keyLocks serializes Do by key.
+	keyLocks keyLocks[string]
+	// tooLarge, sizing, evictor and quarantine are as for Cache.
+	tooLarge func(value V) bool
+	sizing   *dynamicSizing
+	evictor  *evictor
+	// flusher applies writes buffered WithWriteBuffer.
+	flusher    *bufferFlusher
+	quarantine *quarantine[string, V]
+	// twoChoices is set WithTwoChoices, if there's more than one shard.
+	twoChoices bool
+	copyOnRead bool
+	clone      func(value V) V
+	closed     atomic.Bool
+	recover    bool
 }
 
 // New creates an LRU of the given size.
-func NewSharded[V any](size, shardCount int) (*ShardedCache[V], error) {
-	return NewShardedWithEvict[V](size, shardCount, nil)
+func NewSharded[V any](size, shardCount int, opts ...Option[string, V]) (*ShardedCache[V], error) {
+	return NewShardedWithEvict[V](size, shardCount, nil, opts...)
 }
 
 // NewWithEvict constructs a fixed size cache with the given eviction
-// callback.
-func NewShardedWithEvict[V any](size, shardCount int, onEvicted func(key string, value V)) (*ShardedCache[V], error) {
+// callback.  As with NewWithEvict, the callback is called after the
+// shard's lock is released, so it may call back into the cache.
+func NewShardedWithEvict[V any](size, shardCount int, onEvicted func(key string, value V), opts ...Option[string, V]) (*ShardedCache[V], error) {
 	if shardCount <= 0 {
 		shardCount = defaultShardCount
 	}
@@ -38,82 +113,407 @@ func NewShardedWithEvict[V any](size, shardCount int, onEvicted func(key string,
 	}
 	perShardSize := size / shardCount
 	size = perShardSize * shardCount
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, err
+	}
 	c := &ShardedCache[V]{
-		shards: make([]shard[V], shardCount),
-		size:   size,
+		shards:   make([]shard[V], shardCount),
+		mrc:      o.mrc,
+		logger:   o.logger,
+		loads:    newLoadGroup(o.loader, o.batchLoader),
+		recover:  o.recover,
+		tooLarge: o.tooLarge(),
+	}
+	c.onEvict.init(onEvicted)
+	c.bulkEvict = o.bulkEvict
+	c.evictInfo = o.evictInfo
+	c.twoChoices = o.twoChoices && shardCount > 1
+	if o.bufferSize > 0 && c.twoChoices {
+		return nil, errBufferTwoChoices
+	}
+	c.copyOnRead = o.copyOnRead
+	c.clone = o.clone
+	if c.behind, err = newWriteBehind(o); err != nil {
+		return nil, err
 	}
-	c.templateHash.SetSeed(maphash.MakeSeed())
+	if err := c.loads.setNegativeFilter(o); err != nil {
+		return nil, err
+	}
+	if c.quarantine, err = newQuarantine(o, func(key string, value V) {
+		c.releaseAll([]evictedEntry[string, V]{{key: key, value: value, info: removed}})
+	}); err != nil {
+		return nil, err
+	}
+	if c.behind == nil {
+		c.writes = newStoreWriter(o.store)
+	}
+	c.seed = maphash.MakeSeed()
+	if o.randSource != nil {
+		c.hashSeed = uint64(o.randSource.Int63())
+		c.seeded = true
+	}
+	lruOpts := o.lruOptions(shardCount)
 	for i := 0; i < shardCount; i++ {
-		shard, err := simplelru.NewLRU[string, V](perShardSize, simplelru.EvictCallback[string, V](onEvicted))
+		// shards are guarded by RWMutexes, so Get can share the read
+		// lock
+		shardOpts := append(lruOpts[:len(lruOpts):len(lruOpts)], simplelru.WithSharedReads[string, V]())
+		if o.randSource != nil {
+			shardOpts = append(shardOpts, simplelru.WithSeed[string, V](o.randSource.Int63()))
+		}
+		var deferEvict simplelru.EvictCallback[string, V]
+		if c.evictInfo != nil {
+			shardOpts = append(shardOpts[:len(shardOpts):len(shardOpts)], simplelru.WithOnEvictInfo[string, V](c.shards[i].deferEvictInfo))
+		} else if onEvicted != nil || c.behind != nil || c.bulkEvict != nil {
+			deferEvict = c.shards[i].deferEvict
+		}
+		shard, err := simplelru.NewLRU[string, V](perShardSize, deferEvict, shardOpts...)
 		if err != nil {
 			return nil, err
 		}
 		c.shards[i].lru = *shard
+		if o.bufferSize > 0 {
+			shard := &c.shards[i]
+			shard.buf = &writeBuffer[V]{
+				pending: make(map[string]V, o.bufferSize),
+				size:    o.bufferSize,
+				closed:  &c.closed,
+				unlock:  func() { c.unlock(shard) },
+			}
+		}
+		if o.latency || o.contention {
+			instr := &shardInstrumentation{}
+			if o.latency {
+				instr.latency = &latencyRecorder{}
+			}
+			if o.contention {
+				instr.contention = &contentionRecorder{}
+			}
+			c.shards[i].instr = instr
+		}
 	}
+	if c.logger != nil {
+		c.logger.Info("lru: created sharded cache", "size", size, "shards", shardCount)
+	}
+	c.sizing = startDynamicSizing(c, o.sizer, o.sizingInterval)
+	c.evictor = startEvictor(o, c.trim)
+	c.flusher = startBufferFlusher(c, o.bufferWindow)
 	return c, nil
 }
 
 // Purge is used to completely clear the cache.
 func (c *ShardedCache[V]) Purge() {
+	n := 0
 	for i := 0; i < len(c.shards); i++ {
 		shard := &c.shards[i]
-		shard.mu.Lock()
+		shard.lock()
+		n += shard.lru.Len()
 		shard.lru.Purge()
-		shard.mu.Unlock()
+		c.unlock(shard)
+	}
+	if c.quarantine != nil {
+		c.quarantine.purge()
+	}
+	if c.logger != nil {
+		c.logger.Info("lru: purged sharded cache", "entries", n)
+	}
+}
+
+// InvalidateAll makes every entry in the cache absent without calling
+// the eviction callback, taking time proportional to the number of
+// shards rather than entries.  See simplelru.LRU.InvalidateAll.
+func (c *ShardedCache[V]) InvalidateAll() {
+	n := 0
+	for i := 0; i < len(c.shards); i++ {
+		shard := &c.shards[i]
+		shard.lock()
+		n += shard.lru.Len()
+		shard.lru.InvalidateAll()
+		c.unlock(shard)
+	}
+	if c.logger != nil {
+		c.logger.Info("lru: invalidated sharded cache", "entries", n)
+	}
+}
+
+// deferEvict queues an evicted entry for ShardedCache.unlock to pass to
+// the eviction callback.
+func (s *shard[V]) deferEvict(key string, value V) {
+	s.evicted = append(s.evicted, evictedEntry[string, V]{key: key, value: value})
+}
+
+// deferEvictInfo queues an entry leaving the shard, with its info, for
+// ShardedCache.unlock to pass to the eviction callbacks.
+func (s *shard[V]) deferEvictInfo(key string, value V, info simplelru.EvictionInfo) {
+	s.evicted = append(s.evicted, evictedEntry[string, V]{key: key, value: value, info: info})
+}
+
+// unlock releases shard's write lock, then calls the eviction callback for
+// the entries evicted while it was held, after writing any writes queued
+// for them WithWriteBehind.
+func (c *ShardedCache[V]) unlock(shard *shard[V]) {
+	evicted := shard.evicted
+	shard.evicted = nil
+	if c.evictor != nil && overflowing(&shard.lru) {
+		c.evictor.signal()
+	}
+	shard.mu.Unlock()
+	c.releaseAll(evicted)
+}
+
+// releaseAll releases each of evicted, then passes them all to the
+// callback given WithBulkOnEvict, as Cache.releaseAll does.
+func (c *ShardedCache[V]) releaseAll(evicted []evictedEntry[string, V]) {
+	for _, e := range evicted {
+		c.release(e)
+	}
+	if c.bulkEvict != nil && len(evicted) > 0 {
+		callBulkOnEvict(c.bulkEvict, evicted, c.recover, c.logger)
+	}
+}
+
+// release calls the eviction callback for e, as Cache.release does.
+func (c *ShardedCache[V]) release(e evictedEntry[string, V]) {
+	if c.behind != nil && !e.queued {
+		c.behind.flushEvicted(e.key)
+	}
+	for _, onEvict := range c.onEvict.load() {
+		callOnEvict(onEvict, e, c.recover, c.logger)
+	}
+	if c.evictInfo != nil {
+		callOnEvictInfo(c.evictInfo, e, c.recover, c.logger)
 	}
 }
 
 func (c *ShardedCache[V]) getShard(key string) *shard[V] {
-	hash := c.templateHash
-	hash.WriteString(key)
-	shardId := hash.Sum64() % uint64(len(c.shards))
-	return &c.shards[shardId]
+	return &c.shards[c.shardIndex(key)]
+}
+
+func (c *ShardedCache[V]) shardIndex(key string) uint64 {
+	return c.hash(key) % uint64(len(c.shards))
+}
+
+func (c *ShardedCache[V]) hash(key string) uint64 {
+	if c.seeded {
+		return simplelru.HashKey(key) ^ c.hashSeed
+	}
+	return maphash.String(c.seed, key)
+}
+
+// shardIndexes returns the two shards key may be in WithTwoChoices: the
+// one it hashes to, and a different one picked by the rest of its hash.
+func (c *ShardedCache[V]) shardIndexes(key string) (first, second uint64) {
+	h := c.hash(key)
+	n := uint64(len(c.shards))
+	first = h % n
+	second = (h / n) % (n - 1)
+	if second >= first {
+		second++
+	}
+	return first, second
+}
+
+// findShard returns the shard holding key, or if none does, the one it
+// hashes to.  Without WithTwoChoices that's always the shard it hashes
+// to; with it, finding a key in its second shard takes an extra read
+// lock.
+func (c *ShardedCache[V]) findShard(key string) *shard[V] {
+	if !c.twoChoices {
+		return c.getShard(key)
+	}
+	i, j := c.shardIndexes(key)
+	first := &c.shards[i]
+	first.rlock()
+	ok := first.lru.Contains(key)
+	first.mu.RUnlock()
+	if ok {
+		return first
+	}
+	second := &c.shards[j]
+	second.rlock()
+	ok = second.lru.Contains(key)
+	second.mu.RUnlock()
+	if ok {
+		return second
+	}
+	return first
+}
+
+// lockShardFor write-locks and returns the shard holding key, or if none
+// does, the one it should be added to.  WithTwoChoices, that's the
+// emptier of its two shards; both are locked, in order, while choosing,
+// so that two Adds of the same key can't put it in both.
+func (c *ShardedCache[V]) lockShardFor(key string) *shard[V] {
+	if !c.twoChoices {
+		shard := c.getShard(key)
+		shard.lock()
+		return shard
+	}
+	i, j := c.shardIndexes(key)
+	first, second := &c.shards[i], &c.shards[j]
+	if i < j {
+		first.lock()
+		second.lock()
+	} else {
+		second.lock()
+		first.lock()
+	}
+	chosen, other := first, second
+	switch {
+	case first.lru.Contains(key):
+	case second.lru.Contains(key) || second.lru.Len() < first.lru.Len():
+		chosen, other = second, first
+	}
+	// nothing has been evicted from other, so there's nothing to release
+	other.mu.Unlock()
+	return chosen
 }
 
 // Add adds a value to the cache. Returns true if an eviction occurred.
 func (c *ShardedCache[V]) Add(key string, value V) (evicted bool) {
-	shard := c.getShard(key)
-	shard.mu.Lock()
-	defer shard.mu.Unlock()
+	s := c.getShard(key)
+	if s.instr != nil && s.instr.latency != nil {
+		defer s.instr.latency.add.since(time.Now())
+	}
+	if s.buf != nil {
+		if c.closed.Load() {
+			return false
+		}
+		if s.buffer(key, value) {
+			return false
+		}
+		// the buffer is full, so apply it along with this write
+	}
+	shard := c.lockShardFor(key)
+	defer c.unlock(shard)
+	if c.closed.Load() {
+		return false
+	}
 	return shard.lru.Add(key, value)
 }
 
-// Get looks up a key's value from the cache.
+// AddReturningEvicted adds a value to the cache as Add does, and returns
+// the entry it evicted, if any.  It isn't buffered WithWriteBuffer, since
+// what it evicts isn't known until it's applied.  See
+// Cache.AddReturningEvicted.
+func (c *ShardedCache[V]) AddReturningEvicted(key string, value V) (evictedKey string, evictedValue V, evicted bool) {
+	s := c.getShard(key)
+	if s.instr != nil && s.instr.latency != nil {
+		defer s.instr.latency.add.since(time.Now())
+	}
+	shard := c.lockShardFor(key)
+	defer c.unlock(shard)
+	if c.closed.Load() {
+		return evictedKey, evictedValue, false
+	}
+	return shard.lru.AddReturningEvicted(key, value)
+}
+
+// TryAdd adds a value to the cache as Add does, but returns
+// ErrValueTooLarge if it's larger than WithMaxValueSize allows, or
+// ErrFrozen if the cache is frozen.  See Cache.TryAdd.
+func (c *ShardedCache[V]) TryAdd(key string, value V) (evicted bool, err error) {
+	if c.Frozen() {
+		return false, ErrFrozen
+	}
+	if c.tooLarge != nil && c.tooLarge(value) {
+		c.Add(key, value)
+		return false, ErrValueTooLarge
+	}
+	return c.Add(key, value), nil
+}
+
+// Get looks up a key's value from the cache.  If the cache was created
+// WithLoader, misses are loaded.  Gets share their shard's lock with
+// other readers, unless the cache records more about lookups than hits
+// and misses, as it does WithClassifier, say, or the shard has buffered
+// too many hits since it was last locked exclusively.  Buffered hits
+// update their keys' recency the next time the shard is locked, which
+// is always before anything evicts from it.
 func (c *ShardedCache[V]) Get(key string) (value V, ok bool) {
-	shard := c.getShard(key)
-	shard.mu.Lock()
-	defer shard.mu.Unlock()
-	return shard.lru.Get(key)
+	value, ok = c.get(key)
+	if !ok && c.loads != nil && !c.closed.Load() {
+		var err error
+		value, err = c.loads.do(context.Background(), key, c.peek, c.addPinned, c.Unpin)
+		if ok = err == nil; ok {
+			value = c.cloned(value)
+		}
+	}
+	return value, ok
+}
+
+func (c *ShardedCache[V]) get(key string) (value V, ok bool) {
+	if s := c.getShard(key); s.instr != nil && s.instr.latency != nil {
+		defer s.instr.latency.get.since(time.Now())
+	}
+	shard := c.findShard(key)
+	shard.rlock()
+	value, ok, shared := shard.lru.GetShared(key)
+	shard.mu.RUnlock()
+	if !shared {
+		shard.lock()
+		value, ok = shard.lru.Get(key)
+		c.unlock(shard)
+	}
+	if ok {
+		value = c.cloned(value)
+	}
+	return value, ok
 }
 
 // Contains checks if a key is in the cache, without updating the
 // recent-ness or deleting it for being stale.
 func (c *ShardedCache[V]) Contains(key string) bool {
-	shard := c.getShard(key)
-	shard.mu.Lock()
-	defer shard.mu.Unlock()
+	shard := c.findShard(key)
+	shard.rlock()
+	defer shard.mu.RUnlock()
 	return shard.lru.Contains(key)
 }
 
 // Peek returns the key value (or undefined if not found) without updating
 // the "recently used"-ness of the key.
 func (c *ShardedCache[V]) Peek(key string) (value V, ok bool) {
-	shard := c.getShard(key)
-	shard.mu.Lock()
-	defer shard.mu.Unlock()
+	if value, ok = c.peek(key); ok {
+		value = c.cloned(value)
+	}
+	return value, ok
+}
+
+// peek is Peek without WithClone, as Cache.peek.
+func (c *ShardedCache[V]) peek(key string) (value V, ok bool) {
+	shard := c.findShard(key)
+	shard.rlock()
+	defer shard.mu.RUnlock()
 	return shard.lru.Peek(key)
 }
 
+// LastUsed returns roughly when key was last used, without updating its
+// "recently used"-ness.  See simplelru.LRU.LastUsed.
+func (c *ShardedCache[V]) LastUsed(key string) (t time.Time, ok bool) {
+	shard := c.findShard(key)
+	shard.rlock()
+	defer shard.mu.RUnlock()
+	return shard.lru.LastUsed(key)
+}
+
+// ApproxAge returns roughly how long ago key was last used, rounded up,
+// without updating its "recently used"-ness.  See Cache.ApproxAge.
+func (c *ShardedCache[V]) ApproxAge(key string) (age time.Duration, ok bool) {
+	shard := c.findShard(key)
+	shard.rlock()
+	defer shard.mu.RUnlock()
+	return shard.lru.ApproxAge(key)
+}
+
 // ContainsOrAdd checks if a key is in the cache without updating the
 // recent-ness or deleting it for being stale, and if not, adds the value.
 // Returns whether found and whether an eviction occurred.
 func (c *ShardedCache[V]) ContainsOrAdd(key string, value V) (ok, evicted bool) {
-	shard := c.getShard(key)
-	shard.mu.Lock()
-	defer shard.mu.Unlock()
+	shard := c.lockShardFor(key)
+	defer c.unlock(shard)
 
-	if shard.lru.Contains(key) {
-		return true, false
+	if shard.lru.Contains(key) || c.closed.Load() {
+		return shard.lru.Contains(key), false
 	}
 	evicted = shard.lru.Add(key, value)
 	return false, evicted
@@ -123,37 +523,614 @@ func (c *ShardedCache[V]) ContainsOrAdd(key string, value V) (ok, evicted bool)
 // recent-ness or deleting it for being stale, and if not, adds the value.
 // Returns whether found and whether an eviction occurred.
 func (c *ShardedCache[V]) PeekOrAdd(key string, value V) (previous V, ok, evicted bool) {
-	shard := c.getShard(key)
-	shard.mu.Lock()
-	defer shard.mu.Unlock()
+	shard := c.lockShardFor(key)
+	defer c.unlock(shard)
 
 	previous, ok = shard.lru.Peek(key)
 	if ok {
-		return previous, true, false
+		return c.cloned(previous), true, false
+	}
+	if c.closed.Load() {
+		return previous, false, false
 	}
 
 	evicted = shard.lru.Add(key, value)
 	return previous, false, evicted
 }
 
-// Remove removes the provided key from the cache.
+// Remove removes the provided key from the cache, holding it aside if the
+// cache was created WithQuarantine.  See Cache.Remove.
 func (c *ShardedCache[V]) Remove(key string) (present bool) {
-	shard := c.getShard(key)
-	shard.mu.Lock()
-	defer shard.mu.Unlock()
-	return shard.lru.Remove(key)
+	shard := c.findShard(key)
+	shard.lock()
+	if c.quarantine == nil {
+		defer c.unlock(shard)
+		return shard.lru.Remove(key)
+	}
+	value, present := shard.lru.Peek(key)
+	if present {
+		// take back the callback Remove queues; the quarantine calls it
+		n := len(shard.evicted)
+		present = shard.lru.Remove(key)
+		shard.evicted = shard.evicted[:n]
+	}
+	c.unlock(shard)
+	if present {
+		c.quarantine.put(key, value)
+	}
+	return present
+}
+
+// Pin protects key from eviction until it is unpinned as many times as it
+// was pinned, returning false if key isn't in the cache.  See
+// simplelru.LRU.Pin.
+func (c *ShardedCache[V]) Pin(key string) bool {
+	shard := c.findShard(key)
+	shard.lock()
+	defer c.unlock(shard)
+	return shard.lru.Pin(key)
 }
 
-// we don't support resize
+// PinFor pins key for d, as Cache.PinFor does.
+func (c *ShardedCache[V]) PinFor(key string, d time.Duration) bool {
+	shard := c.findShard(key)
+	shard.lock()
+	defer c.unlock(shard)
+	return shard.lru.PinFor(key, d)
+}
+
+// Unpin undoes one call to Pin for key.
+func (c *ShardedCache[V]) Unpin(key string) {
+	shard := c.findShard(key)
+	shard.lock()
+	defer c.unlock(shard)
+	shard.lru.Unpin(key)
+}
+
+// addPinned adds a value to the cache and pins it, as Cache.addPinned.
+func (c *ShardedCache[V]) addPinned(key string, value V) bool {
+	shard := c.lockShardFor(key)
+	defer c.unlock(shard)
+	if c.closed.Load() {
+		return false
+	}
+	evicted := shard.lru.Add(key, value)
+	shard.lru.Pin(key)
+	return evicted
+}
+
+// RemovePrefix removes every key starting with prefix, such as all of a
+// tenant's keys when they're namespaced like "tenant42/...", and returns
+// how many it removed.  Keys are spread across shards by hash, so it
+// visits every entry in the cache, locking one shard at a time.
+func (c *ShardedCache[V]) RemovePrefix(prefix string) (removed int) {
+	return c.PurgeMatching(func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+// PurgeMatching removes every key for which match returns true, such as
+// one class of keys that is misbehaving, calling the eviction callback
+// for each, and returns how many it removed.  It visits every entry in
+// the cache, as Range does, calling match without any lock held and
+// removing the keys it matches a chunk at a time.
+func (c *ShardedCache[V]) PurgeMatching(match func(key string) bool) (purged int) {
+	var matched []string
+	c.walk(func(shard *shard[V], entries []simplelru.Entry[string, V]) bool {
+		matched = matched[:0]
+		for _, e := range entries {
+			if match(e.Key) {
+				matched = append(matched, e.Key)
+			}
+		}
+		if len(matched) == 0 {
+			return true
+		}
+		shard.lock()
+		for _, key := range matched {
+			if shard.lru.Remove(key) {
+				purged++
+			}
+		}
+		c.unlock(shard)
+		return true
+	})
+	if c.logger != nil {
+		c.logger.Info("lru: purged matching keys", "entries", purged)
+	}
+	return purged
+}
+
+// PurgeShard clears the i'th shard, as numbered by ShardStats and
+// ShardOf, calling the eviction callback for its entries, and returns
+// how many it removed.  The rest of the cache stays warm.
+func (c *ShardedCache[V]) PurgeShard(i int) (purged int) {
+	shard := &c.shards[i]
+	shard.lock()
+	purged = shard.lru.Len()
+	shard.lru.Purge()
+	c.unlock(shard)
+	if c.logger != nil {
+		c.logger.Info("lru: purged shard", "shard", i, "entries", purged)
+	}
+	return purged
+}
+
+// ShardOf returns the number of the shard that key belongs to, as
+// numbered by ShardStats and PurgeShard.  WithTwoChoices, that's the
+// shard holding key, or if none does, the first of its two.
+func (c *ShardedCache[V]) ShardOf(key string) int {
+	if c.twoChoices {
+		i, j := c.shardIndexes(key)
+		second := &c.shards[j]
+		second.rlock()
+		defer second.mu.RUnlock()
+		if second.lru.Contains(key) {
+			return int(j)
+		}
+		return int(i)
+	}
+	return int(c.shardIndex(key))
+}
+
+// Resize changes the cache size, which is divided evenly between the
+// shards: it is rounded down to a multiple of the number of shards, and
+// up to one entry per shard.  It returns the number of entries evicted.
+func (c *ShardedCache[V]) Resize(size int) (evicted int) {
+	perShardSize := size / len(c.shards)
+	if perShardSize < 1 {
+		perShardSize = 1
+	}
+	oldSize := c.Cap()
+	for i := 0; i < len(c.shards); i++ {
+		shard := &c.shards[i]
+		shard.lock()
+		if !c.closed.Load() {
+			evicted += shard.lru.Resize(perShardSize)
+		}
+		c.unlock(shard)
+	}
+	if c.logger != nil {
+		c.logger.Info("lru: resized sharded cache", "oldSize", oldSize, "size", perShardSize*len(c.shards), "evicted", evicted)
+	}
+	return evicted
+}
+
+// Cap returns the maximum number of items the cache can hold.
+func (c *ShardedCache[V]) Cap() int {
+	size := 0
+	for i := 0; i < len(c.shards); i++ {
+		shard := &c.shards[i]
+		shard.mu.RLock()
+		size += shard.lru.Cap()
+		shard.mu.RUnlock()
+	}
+	return size
+}
+
+// Range calls fn for each entry in the cache, shard by shard and in no
+// particular order, until fn returns false.  It doesn't update the
+// recent-ness of entries.  Entries are copied out of each shard a chunk
+// at a time, and fn is called without any lock held, so it may call back
+// into the cache, and iterating a large cache never blocks a shard for
+// long.  Unlike a snapshot, it sees entries as they are when their chunk
+// is copied; see simplelru.LRU.AppendChunk for what it sees of entries
+// added and removed meanwhile.
+func (c *ShardedCache[V]) Range(fn func(key string, value V) bool) {
+	c.walk(func(_ *shard[V], entries []simplelru.Entry[string, V]) bool {
+		for _, e := range entries {
+			if !fn(e.Key, e.Value) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// walk copies the cache's entries out of each shard, iterChunk at a time,
+// and passes them to fn, along with their shard, with no lock held, until
+// fn returns false.  The slice passed to fn is reused for the next chunk.
+func (c *ShardedCache[V]) walk(fn func(shard *shard[V], entries []simplelru.Entry[string, V]) bool) {
+	chunk := make([]simplelru.Entry[string, V], 0, iterChunk)
+	for i := range c.shards {
+		shard := &c.shards[i]
+		if !walkShard(shard, chunk, func(entries []simplelru.Entry[string, V]) bool {
+			return fn(shard, entries)
+		}) {
+			return
+		}
+	}
+}
+
+// walkShard is walk for a single shard, copying entries into chunk.  It
+// returns false if fn did.
+func walkShard[V any](shard *shard[V], chunk []simplelru.Entry[string, V], fn func(entries []simplelru.Entry[string, V]) bool) bool {
+	for from := 0; from >= 0; {
+		shard.rlock()
+		chunk, from = shard.lru.AppendChunk(chunk[:0], from, iterChunk)
+		shard.mu.RUnlock()
+		if len(chunk) > 0 && !fn(chunk) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetMany looks up the values of keys, taking each shard's lock once
+// rather than once per key.  It returns the values it found, and the keys
+// it didn't, in the order they were given, without duplicates.  Unlike
+// Get, it doesn't load misses.
+func (c *ShardedCache[V]) GetMany(keys []string) (found map[string]V, missing []string) {
+	perShard := make([][]string, len(c.shards))
+	for _, key := range keys {
+		i := c.shardIndex(key)
+		if c.twoChoices {
+			i = uint64(c.ShardOf(key))
+		}
+		perShard[i] = append(perShard[i], key)
+	}
+	found = make(map[string]V, len(keys))
+	for i := range c.shards {
+		if len(perShard[i]) == 0 {
+			continue
+		}
+		shard := &c.shards[i]
+		shard.lock()
+		for _, key := range perShard[i] {
+			if value, ok := shard.lru.Get(key); ok {
+				found[key] = c.cloned(value)
+			}
+		}
+		c.unlock(shard)
+	}
+	var seen map[string]struct{}
+	for _, key := range keys {
+		if _, ok := found[key]; ok {
+			continue
+		}
+		if seen == nil {
+			seen = make(map[string]struct{})
+		}
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			missing = append(missing, key)
+		}
+	}
+	return found, missing
+}
+
+// WarmUp bulk-loads entries, ordered least recently used first, for
+// preloading the cache at startup.  Entries are split up by shard, and
+// each shard is warmed with its share as simplelru.LRU.WarmUp does.
+// WithTwoChoices, each entry is placed as Add would place it instead,
+// taking its shards' locks once per entry.
+func (c *ShardedCache[V]) WarmUp(entries []simplelru.Entry[string, V]) {
+	if c.twoChoices {
+		for _, e := range entries {
+			shard := c.lockShardFor(e.Key)
+			if !c.closed.Load() {
+				shard.lru.WarmUp([]simplelru.Entry[string, V]{e})
+			}
+			shard.mu.Unlock()
+		}
+		return
+	}
+	perShard := make([][]simplelru.Entry[string, V], len(c.shards))
+	for _, e := range entries {
+		i := c.shardIndex(e.Key)
+		perShard[i] = append(perShard[i], e)
+	}
+	for i := range c.shards {
+		if len(perShard[i]) == 0 {
+			continue
+		}
+		shard := &c.shards[i]
+		shard.lock()
+		if !c.closed.Load() {
+			shard.lru.WarmUp(perShard[i])
+		}
+		shard.mu.Unlock()
+	}
+}
+
+// Validate checks each shard's internal invariants, as
+// simplelru.LRU.Validate does, and that every key is in the shard it
+// hashes to, or WithTwoChoices in one of its two and not the other.  It
+// is for tests, fuzzing and canary checks, and locks one shard at a time
+// while it walks every entry.
+func (c *ShardedCache[V]) Validate() error {
+	var seen map[string]uint64
+	if c.twoChoices {
+		seen = make(map[string]uint64)
+	}
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.mu.RLock()
+		err := shard.lru.Validate()
+		if err == nil {
+			shard.lru.Range(func(key string, _ V) bool {
+				err = c.validateShardOf(key, uint64(i), seen)
+				return err == nil
+			})
+		}
+		shard.mu.RUnlock()
+		if err != nil {
+			return fmt.Errorf("shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateShardOf checks that key, found in shard i, belongs there, and
+// WithTwoChoices that it wasn't already found in its other shard, as
+// recorded in seen.
+func (c *ShardedCache[V]) validateShardOf(key string, i uint64, seen map[string]uint64) error {
+	if !c.twoChoices {
+		if j := c.shardIndex(key); j != i {
+			return fmt.Errorf("key %q belongs in shard %d", key, j)
+		}
+		return nil
+	}
+	if first, second := c.shardIndexes(key); i != first && i != second {
+		return fmt.Errorf("key %q belongs in shard %d or %d", key, first, second)
+	}
+	if j, ok := seen[key]; ok {
+		return fmt.Errorf("key %q is also in shard %d", key, j)
+	}
+	seen[key] = i
+	return nil
+}
+
+// ShardStats describes the occupancy and counters of a single shard.
+type ShardStats struct {
+	Len int
+	Cap int
+	// Cost is the total cost of the shard's items, for caches created
+	// WithCost.
+	Cost  int64
+	Stats simplelru.Stats
+	// Contention is only populated for caches created
+	// WithContentionTracking.
+	Contention ContentionStats
+}
+
+// ShardStats returns a snapshot of each shard, which is useful for
+// spotting imbalanced or hot shards.
+func (c *ShardedCache[V]) ShardStats() []ShardStats {
+	stats := make([]ShardStats, len(c.shards))
+	for i := 0; i < len(c.shards); i++ {
+		shard := &c.shards[i]
+		shard.mu.RLock()
+		stats[i] = ShardStats{
+			Len:   shard.lru.Len(),
+			Cap:   shard.lru.Cap(),
+			Cost:  shard.lru.Cost(),
+			Stats: shard.lru.Stats(),
+		}
+		if shard.instr != nil && shard.instr.contention != nil {
+			stats[i].Contention = shard.instr.contention.stats()
+		}
+		shard.mu.RUnlock()
+	}
+	return stats
+}
+
+// Stats returns a snapshot of the cache's counters, summed across shards.
+func (c *ShardedCache[V]) Stats() (stats simplelru.Stats) {
+	for i := 0; i < len(c.shards); i++ {
+		shard := &c.shards[i]
+		shard.mu.RLock()
+		s := shard.lru.Stats()
+		shard.mu.RUnlock()
+		stats.Merge(&s)
+	}
+	return stats
+}
+
+// StatsSnapshot returns the cache's counters summed across shards, along
+// with the time they were read.
+func (c *ShardedCache[V]) StatsSnapshot() simplelru.StatsSnapshot {
+	return simplelru.StatsSnapshot{Stats: c.Stats(), At: time.Now()}
+}
+
+// ClassStats returns a snapshot of the per-class counters summed across
+// shards, keyed by class name.  It returns nil if the cache was not
+// created WithClassifier.
+func (c *ShardedCache[V]) ClassStats() map[string]simplelru.ClassStats {
+	var classes map[string]simplelru.ClassStats
+	for i := 0; i < len(c.shards); i++ {
+		shard := &c.shards[i]
+		shard.mu.RLock()
+		shardClasses := shard.lru.ClassStats()
+		shard.mu.RUnlock()
+		if shardClasses == nil {
+			continue
+		}
+		if classes == nil {
+			classes = make(map[string]simplelru.ClassStats, len(shardClasses))
+		}
+		for name, s := range shardClasses {
+			cs := classes[name]
+			cs.Hits += s.Hits
+			cs.Misses += s.Misses
+			cs.Evictions += s.Evictions
+			cs.QuotaEvictions += s.QuotaEvictions
+			classes[name] = cs
+		}
+	}
+	return classes
+}
+
+// HotKeys returns up to k of the most frequently looked-up keys across all
+// shards, most frequent first.  It returns nil unless the cache was
+// created WithHotKeys.
+func (c *ShardedCache[V]) HotKeys(k int) []simplelru.HotKey[string] {
+	lists := make([][]simplelru.HotKey[string], 0, len(c.shards))
+	for i := 0; i < len(c.shards); i++ {
+		shard := &c.shards[i]
+		shard.mu.RLock()
+		hot := shard.lru.HotKeys(k)
+		shard.mu.RUnlock()
+		if hot == nil {
+			return nil
+		}
+		lists = append(lists, hot)
+	}
+	return simplelru.MergeHotKeys(k, lists...)
+}
+
+// DecayHotKeys halves the counts tracked WithHotKeys now, in every shard.
+func (c *ShardedCache[V]) DecayHotKeys() {
+	for i := 0; i < len(c.shards); i++ {
+		shard := &c.shards[i]
+		shard.lock()
+		shard.lru.DecayHotKeys()
+		c.unlock(shard)
+	}
+}
+
+// EstimateHitRatioAt returns the hit ratio the cache would be expected to
+// achieve if it had the given total size.  It returns 0 unless the cache
+// was created WithMissRatioCurve.
+func (c *ShardedCache[V]) EstimateHitRatioAt(size int) float64 {
+	if c.mrc == nil {
+		return 0
+	}
+	return c.mrc.EstimateHitRatioAt(size)
+}
+
+// ShadowStats returns a snapshot of the counters of each shadow cache
+// given WithShadow, by name, or nil if there are none.  The shards share
+// the shadows, so they include every shard's operations.
+func (c *ShardedCache[V]) ShadowStats() map[string]simplelru.ShadowStats {
+	shard := &c.shards[0]
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	return shard.lru.ShadowStats()
+}
+
+// LatencyStats returns histograms of Add and Get latencies across all
+// shards.  It returns empty histograms unless the cache was created
+// WithLatencyTracking.
+func (c *ShardedCache[V]) LatencyStats() (stats LatencyStats) {
+	for _, s := range c.ShardLatencyStats() {
+		stats.Merge(&s)
+	}
+	return stats
+}
+
+// ShardLatencyStats returns histograms of Add and Get latencies for each
+// shard, which can reveal lock contention on hot shards.  It returns nil
+// unless the cache was created WithLatencyTracking.
+func (c *ShardedCache[V]) ShardLatencyStats() []LatencyStats {
+	if c.shards[0].instr == nil || c.shards[0].instr.latency == nil {
+		return nil
+	}
+	stats := make([]LatencyStats, len(c.shards))
+	for i := 0; i < len(c.shards); i++ {
+		stats[i] = c.shards[i].instr.latency.stats()
+	}
+	return stats
+}
+
+// HitRatio returns the hit ratio over roughly the last window lookups
+// across all shards, or 0 if there have been no lookups.  The window is
+// split evenly between shards, and each shard rounds its share up to a
+// whole bucket of 256 lookups, so the smallest window HitRatio can
+// honor is 256 lookups per shard: with the default 256 shards, any
+// window under 65536 looks back about 65536 lookups.
+func (c *ShardedCache[V]) HitRatio(window int) float64 {
+	perShard := window / len(c.shards)
+	if perShard < 1 {
+		perShard = 1
+	}
+	var hits, misses uint64
+	for i := 0; i < len(c.shards); i++ {
+		shard := &c.shards[i]
+		shard.mu.RLock()
+		h, m := shard.lru.WindowCounts(perShard)
+		shard.mu.RUnlock()
+		hits += h
+		misses += m
+	}
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
 
 // Len returns the number of items in the cache.
 func (c *ShardedCache[V]) Len() int {
 	size := 0
 	for i := 0; i < len(c.shards); i++ {
 		shard := &c.shards[i]
-		shard.mu.Lock()
+		shard.mu.RLock()
 		size += shard.lru.Len()
-		shard.mu.Unlock()
+		shard.mu.RUnlock()
 	}
 	return size
 }
+
+// Holes returns the number of slots wasted on removed entries, summed
+// across shards.  See simplelru.LRU.Holes.
+func (c *ShardedCache[V]) Holes() (holes int) {
+	for i := 0; i < len(c.shards); i++ {
+		shard := &c.shards[i]
+		shard.mu.RLock()
+		holes += shard.lru.Holes()
+		shard.mu.RUnlock()
+	}
+	return holes
+}
+
+// Occupancy returns how full the cache's slots are, summed across
+// shards.  See simplelru.Occupancy.
+func (c *ShardedCache[V]) Occupancy() (o simplelru.Occupancy) {
+	for i := 0; i < len(c.shards); i++ {
+		shard := &c.shards[i]
+		shard.mu.RLock()
+		so := shard.lru.Occupancy()
+		shard.mu.RUnlock()
+		o.Live += so.Live
+		o.Slots += so.Slots
+		o.Cap += so.Cap
+	}
+	return o
+}
+
+// Compact reclaims the slots wasted on removed entries, one shard at a
+// time, returning how many it reclaimed.  See simplelru.LRU.Compact.
+func (c *ShardedCache[V]) Compact() (reclaimed int) {
+	for i := 0; i < len(c.shards); i++ {
+		shard := &c.shards[i]
+		shard.lock()
+		reclaimed += shard.lru.Compact()
+		c.unlock(shard)
+	}
+	return reclaimed
+}
+
+// CostLen returns the total cost of the items in the cache, summed across
+// shards, as measured by the function given WithCost, or 0 if none
+// was.
+func (c *ShardedCache[V]) CostLen() int64 {
+	var cost int64
+	for i := 0; i < len(c.shards); i++ {
+		shard := &c.shards[i]
+		shard.mu.RLock()
+		cost += shard.lru.Cost()
+		shard.mu.RUnlock()
+	}
+	return cost
+}
+
+// UpdateCost measures key's value again with the function given
+// WithCost, for values that have grown or shrunk in place since they were
+// added, and adjusts CostLen to match.  It returns false if key isn't
+// cached, or if the cache wasn't created WithCost.
+func (c *ShardedCache[V]) UpdateCost(key string) bool {
+	shard := c.findShard(key)
+	shard.lock()
+	defer c.unlock(shard)
+	return shard.lru.UpdateCost(key)
+}
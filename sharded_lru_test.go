@@ -2,9 +2,12 @@ package lru
 
 import (
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"unsafe"
+
+	"github.com/bpowers/approx-lru/simplelru"
 )
 
 func TestNewSharded(t *testing.T) {
@@ -12,8 +15,12 @@ func TestNewSharded(t *testing.T) {
 }
 
 func TestShardSize(t *testing.T) {
-	if 128 != unsafe.Sizeof(shard[int]{}) {
-		t.Fatalf("expected shard to be 128-bytes in size")
+	size := unsafe.Sizeof(shard[int]{})
+	if size%shardAlign != 0 {
+		t.Fatalf("expected shard size %d to be a multiple of %d", size, shardAlign)
+	}
+	if unpadded := unsafe.Sizeof(shardFields[int]{}); size-unpadded >= shardAlign {
+		t.Fatalf("expected at most %d bytes of padding, got %d", shardAlign-1, size-unpadded)
 	}
 }
 
@@ -69,3 +76,487 @@ func BenchmarkLRU_BigSharded(b *testing.B) {
 		// b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
 	})
 }
+
+func TestShardedHitRatio(t *testing.T) {
+	l, err := NewSharded[int](1024, 16)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+	for i := 0; i < 200; i++ {
+		l.Get(strconv.Itoa(i))
+	}
+
+	stats := l.Stats()
+	if stats.Hits != 100 || stats.Misses != 100 {
+		t.Fatalf("bad stats: %+v", stats)
+	}
+	if r := l.HitRatio(1 << 20); r != 0.5 {
+		t.Fatalf("expected hit ratio of 0.5, got %v", r)
+	}
+}
+
+func TestShardedHotKeys(t *testing.T) {
+	l, err := NewSharded[int](1024, 16, WithHotKeys[string, int](4))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		l.Get("hot")
+		l.Get(strconv.Itoa(i))
+	}
+
+	hot := l.HotKeys(1)
+	if len(hot) != 1 || hot[0].Key != "hot" || hot[0].Count != 100 {
+		t.Fatalf("bad hot keys: %+v", hot)
+	}
+	if hot := l.HotKeys(-1); hot != nil {
+		t.Fatalf("expected no hot keys for k=-1, got %+v", hot)
+	}
+}
+
+func TestShardedEstimateHitRatioAt(t *testing.T) {
+	l, err := NewSharded[int](256, 16, WithMissRatioCurve[string, int]([]int{2000}, 1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 10000; i++ {
+		key := strconv.Itoa(i % 1000)
+		if _, ok := l.Get(key); !ok {
+			l.Add(key, i)
+		}
+	}
+
+	if r := l.EstimateHitRatioAt(2000); r < 0.89 || r > 0.91 {
+		t.Errorf("expected a hit ratio near 0.9 at 2000, got %v", r)
+	}
+}
+
+func TestShardedWarmUp(t *testing.T) {
+	l, err := NewSharded[int](1024, 16)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	entries := make([]simplelru.Entry[string, int], 512)
+	for i := range entries {
+		entries[i] = simplelru.Entry[string, int]{Key: strconv.Itoa(i), Value: i}
+	}
+	l.WarmUp(entries)
+	if l.Len() == 0 || l.Len() > 512 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if v, ok := l.Peek("511"); !ok || v != 511 {
+		t.Fatalf("expected the most recent entry to be loaded")
+	}
+}
+
+func TestShardedGetMany(t *testing.T) {
+	l, err := NewSharded[int](1024, 8, WithContentionTracking[string, int]())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+	var keys []string
+	for i := 90; i < 110; i++ {
+		keys = append(keys, strconv.Itoa(i))
+	}
+	keys = append(keys, "95", "105")
+	acquisitions := func() (n uint64) {
+		for _, s := range l.ShardStats() {
+			n += s.Contention.Acquisitions
+		}
+		return n
+	}
+	before := acquisitions()
+	found, missing := l.GetMany(keys)
+	// each shard's lock is taken once, not once per key
+	if n := acquisitions() - before; n > 8 {
+		t.Fatalf("too many lock acquisitions: %d", n)
+	}
+	if len(found) != 10 || found["95"] != 95 {
+		t.Fatalf("bad found: %v", found)
+	}
+	if len(missing) != 10 || missing[0] != "100" || missing[9] != "109" {
+		t.Fatalf("bad missing: %v", missing)
+	}
+	if s := l.Stats(); s.Hits != 11 || s.Misses != 11 {
+		t.Fatalf("bad stats: %+v", s)
+	}
+}
+
+func TestShardedPeekSharesLock(t *testing.T) {
+	l, err := NewSharded[int](1024, 16)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add("a", 1)
+
+	// Peek and Contains only need the read lock, so they don't wait for
+	// another reader to finish
+	shard := l.getShard("a")
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	if v, ok := l.Peek("a"); !ok || v != 1 {
+		t.Fatalf("bad value: %v, %v", v, ok)
+	}
+	if !l.Contains("a") {
+		t.Fatalf("expected a to be present")
+	}
+	// and so does Get, which buffers its hit
+	if v, ok := l.Get("a"); !ok || v != 1 {
+		t.Fatalf("bad value: %v, %v", v, ok)
+	}
+}
+
+func TestShardedSharedGets(t *testing.T) {
+	// a shard this small is searched in full for victims, so eviction is
+	// exact
+	l, err := NewSharded[int](8, 1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+
+	// hits made under the read lock still keep their keys from being
+	// evicted, whoever adds next
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				l.Get(strconv.Itoa(i % 4))
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 8; i < 12; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+	for i := 0; i < 4; i++ {
+		if !l.Contains(strconv.Itoa(i)) {
+			t.Fatalf("expected hot key %d to survive", i)
+		}
+	}
+	if s := l.Stats(); s.Hits != 8000 {
+		t.Fatalf("expected every hit counted, got %+v", s)
+	}
+	if r := l.HitRatio(1 << 20); r != 1 {
+		t.Fatalf("expected hit ratio of 1, got %v", r)
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestShardedReentrantEvict(t *testing.T) {
+	var l *ShardedCache[int]
+	var mu sync.Mutex
+	evicted := make(map[string]bool)
+	l, err := NewShardedWithEvict[int](16, 4, func(k string, v int) {
+		mu.Lock()
+		evicted[k] = true
+		mu.Unlock()
+		if v >= 0 {
+			l.Add("tombstone/"+k, -1)
+		}
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 64; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+	l.Purge()
+	if len(evicted) < 64 {
+		t.Fatalf("expected every entry to be evicted, got %d", len(evicted))
+	}
+}
+
+func TestShardedValidate(t *testing.T) {
+	l, err := NewSharded[int](256, 16)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 1024; i++ {
+		l.Add(strconv.Itoa(i), i)
+		if i%3 == 0 {
+			l.Remove(strconv.Itoa(i / 2))
+		}
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// put a key in the wrong shard
+	i := (l.shardIndex("a") + 1) % uint64(len(l.shards))
+	l.shards[i].lru.Add("a", 1)
+	if err := l.Validate(); err == nil {
+		t.Fatalf("expected a misplaced key to fail validation")
+	}
+}
+
+func TestShardedInvalidateAll(t *testing.T) {
+	l, err := NewSharded[int](1024, 16)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 512; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+	l.InvalidateAll()
+	if l.Len() != 0 || l.Contains("1") {
+		t.Fatalf("expected the cache to be empty, has %d", l.Len())
+	}
+	l.Add("1", 2)
+	if v, ok := l.Get("1"); !ok || v != 2 {
+		t.Fatalf("bad value: %v, %v", v, ok)
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestShardedCompact(t *testing.T) {
+	l, err := NewSharded[int](256, 4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 1024; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+	n := l.RemovePrefix("1")
+	if l.Holes() != n {
+		t.Fatalf("expected %d holes, got %d", n, l.Holes())
+	}
+	if reclaimed := l.Compact(); reclaimed != n || l.Holes() != 0 {
+		t.Fatalf("expected to reclaim %d slots, got %d", n, reclaimed)
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestShardedOccupancy(t *testing.T) {
+	l, err := NewSharded[int](256, 4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 1024; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+	n := l.RemovePrefix("1")
+	o := l.Occupancy()
+	if o.Live != 256-n || o.Slots != 256 || o.Cap != 256 {
+		t.Fatalf("unexpected occupancy %+v", o)
+	}
+	if stats := l.Stats(); stats.ProbesPerEviction() == 0 {
+		t.Fatalf("expected victim searches to be counted")
+	}
+}
+
+func TestShardedPurgeShard(t *testing.T) {
+	l, err := NewSharded[int](1024, 8)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 256; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+	shard := l.ShardOf("42")
+	expected := l.ShardStats()[shard].Len
+	if n := l.PurgeShard(shard); n != expected {
+		t.Fatalf("expected %d entries purged, got %d", expected, n)
+	}
+	if l.Len() != 256-expected || l.Contains("42") {
+		t.Fatalf("expected only shard %d to be purged", shard)
+	}
+	for i := 0; i < 256; i++ {
+		key := strconv.Itoa(i)
+		if l.Contains(key) != (l.ShardOf(key) != shard) {
+			t.Fatalf("%s: wrong shard purged", key)
+		}
+	}
+}
+
+func TestShardedPurgeMatching(t *testing.T) {
+	l, err := NewSharded[int](1024, 8)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 256; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+	n := l.PurgeMatching(func(key string) bool {
+		return strings.HasSuffix(key, "7")
+	})
+	if n != 25 || l.Len() != 231 || l.Contains("17") || !l.Contains("18") {
+		t.Fatalf("bad purge of %d, leaving %d", n, l.Len())
+	}
+}
+
+func TestShardedRangeChunked(t *testing.T) {
+	// one shard, so that ranging over it takes several chunks
+	l, err := NewSharded[int](4*iterChunk, 1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4*iterChunk; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+
+	// fn runs without the shard's lock, so it can call back into the
+	// cache
+	seen := map[string]bool{}
+	l.Range(func(key string, value int) bool {
+		if seen[key] {
+			t.Fatalf("%s seen twice", key)
+		}
+		seen[key] = true
+		if value%2 == 0 {
+			l.Remove(key)
+		}
+		return true
+	})
+	if len(seen) != 4*iterChunk || l.Len() != 2*iterChunk {
+		t.Fatalf("saw %d entries, leaving %d", len(seen), l.Len())
+	}
+
+	n := 0
+	l.Range(func(string, int) bool {
+		n++
+		return n < iterChunk+1
+	})
+	if n != iterChunk+1 {
+		t.Fatalf("expected Range to stop when fn returned false, visited %d", n)
+	}
+
+	purged := l.PurgeMatching(func(key string) bool {
+		// match can call back into the cache too
+		v, _ := l.Peek(key)
+		return v%4 == 1
+	})
+	if purged != iterChunk || l.Len() != iterChunk {
+		t.Fatalf("bad purge of %d, leaving %d", purged, l.Len())
+	}
+}
+
+func TestShardedRemovePrefix(t *testing.T) {
+	var evicted []string
+	var mu sync.Mutex
+	l, err := NewShardedWithEvict[int](1024, 16, func(k string, v int) {
+		mu.Lock()
+		evicted = append(evicted, k)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		l.Add("tenant1/"+strconv.Itoa(i), i)
+		l.Add("tenant10/"+strconv.Itoa(i), i)
+		l.Add("tenant2/"+strconv.Itoa(i), i)
+	}
+	if n := l.RemovePrefix("tenant1/"); n != 100 {
+		t.Fatalf("expected 100 removals, got %d", n)
+	}
+	if l.Contains("tenant1/5") || !l.Contains("tenant10/5") || !l.Contains("tenant2/5") {
+		t.Fatalf("removed the wrong keys")
+	}
+	if l.Len() != 200 || len(evicted) != 100 {
+		t.Fatalf("bad len %d or evictions %d", l.Len(), len(evicted))
+	}
+}
+
+func BenchmarkShardIndex(b *testing.B) {
+	l, err := NewSharded[int](1024, defaultShardCount)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "key/" + strconv.Itoa(i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	var sum uint64
+	for i := 0; i < b.N; i++ {
+		sum += l.shardIndex(keys[i%len(keys)])
+	}
+	_ = sum
+}
+
+func TestShardedResize(t *testing.T) {
+	l, err := NewSharded[int](1024, 16)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 1024; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+	n := l.Len()
+	evicted := l.Resize(512 + 7)
+	if l.Cap() != 512 {
+		t.Fatalf("expected the size to be rounded down: %d", l.Cap())
+	}
+	if l.Len() > 512 || evicted != n-l.Len() {
+		t.Fatalf("bad len %d or evictions %d", l.Len(), evicted)
+	}
+	l.Resize(2048)
+	for i := 0; i < 2048; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+	if l.Cap() != 2048 || l.Len() < 1024 {
+		t.Fatalf("bad cap %d or len %d", l.Cap(), l.Len())
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestShardedTwoChoices(t *testing.T) {
+	l, err := NewSharded[int](64, 4, WithTwoChoices[string, int]())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// keys that all hash to shard 0 overflow it without two choices
+	var keys []string
+	for i := 0; len(keys) < 32; i++ {
+		key := strconv.Itoa(i)
+		if l.shardIndex(key) == 0 {
+			keys = append(keys, key)
+		}
+	}
+	for i, key := range keys {
+		if l.Add(key, i) {
+			t.Fatalf("%s: unexpected eviction", key)
+		}
+	}
+	if l.Len() != len(keys) {
+		t.Fatalf("expected %d entries, got %d", len(keys), l.Len())
+	}
+	for i, key := range keys {
+		if v, ok := l.Get(key); !ok || v != i {
+			t.Fatalf("%s: expected %d, got %d %v", key, i, v, ok)
+		}
+		l.Add(key, -i)
+	}
+	if l.Len() != len(keys) {
+		t.Fatalf("expected updates to stay in place, got %d entries", l.Len())
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !l.Remove(keys[31]) || l.Contains(keys[31]) {
+		t.Fatalf("expected %s to be removed", keys[31])
+	}
+}
@@ -0,0 +1,75 @@
+package sim
+
+import (
+	"github.com/bpowers/approx-lru/simplelru"
+	"github.com/bpowers/approx-lru/workloads"
+)
+
+// GeneratorSource generates lookups of the keys from a
+// workloads.Generator.
+type GeneratorSource struct {
+	g workloads.Generator
+	n int
+}
+
+// Generate returns a Source of n lookups of the keys g generates.
+func Generate(g workloads.Generator, n int) *GeneratorSource {
+	return &GeneratorSource{g: g, n: n}
+}
+
+// Next implements Source.
+func (s *GeneratorSource) Next() (Request, bool) {
+	if s.n <= 0 {
+		return Request{}, false
+	}
+	s.n--
+	return Request{Op: simplelru.TraceGet, Key: s.g.Next()}, true
+}
+
+// Zipf returns a Source of n lookups over keys in [0, keys), following
+// the Zipf distribution of workloads.Zipf.
+func Zipf(seed int64, s, v float64, keys uint64, n int) *GeneratorSource {
+	return Generate(workloads.Zipf(seed, s, v, keys), n)
+}
+
+// Uniform returns a Source of n lookups of keys picked uniformly from [0,
+// keys).
+func Uniform(seed int64, keys uint64, n int) *GeneratorSource {
+	return Generate(workloads.Uniform(seed, keys), n)
+}
+
+// Scan returns a Source of n lookups of the keys start, start+1, ..., as
+// workloads.Scan generates.
+func Scan(start uint64, n int) *GeneratorSource {
+	return Generate(workloads.Scan(start), n)
+}
+
+// Loop returns a Source of n lookups cycling through keys [0, keys), as
+// workloads.Loop generates.
+func Loop(keys uint64, n int) *GeneratorSource {
+	return Generate(workloads.Loop(keys), n)
+}
+
+// Interleave returns a Source that takes one request from each source in
+// turn, skipping sources once they are exhausted.  It can be used to mix a
+// scan into an otherwise skewed workload.
+func Interleave(sources ...Source) Source {
+	return &interleaved{sources: sources}
+}
+
+type interleaved struct {
+	sources []Source
+	next    int
+}
+
+func (s *interleaved) Next() (Request, bool) {
+	for len(s.sources) > 0 {
+		i := s.next % len(s.sources)
+		if req, ok := s.sources[i].Next(); ok {
+			s.next = i + 1
+			return req, true
+		}
+		s.sources = append(s.sources[:i], s.sources[i+1:]...)
+	}
+	return Request{}, false
+}
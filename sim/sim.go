@@ -0,0 +1,206 @@
+// Package sim replays access traces against several cache configurations
+// at once and reports the hit ratio each would achieve, to answer "what
+// size (or policy) do I need for the hit ratio I want?" before deploying.
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+// Policy is a cache eviction policy to simulate.
+type Policy int
+
+const (
+	// Approximate is this package's sampled approximate LRU.
+	Approximate Policy = iota
+	// Exact is a strict LRU.
+	Exact
+	// Random evicts an entry chosen uniformly at random, equivalent to
+	// Approximate with a single probe.
+	Random
+	// SetAssociative is simplelru.SetAssociativeLRU, which evicts the
+	// least recently used entry in the key's set.
+	SetAssociative
+)
+
+func (p Policy) String() string {
+	switch p {
+	case Approximate:
+		return "approximate"
+	case Exact:
+		return "exact"
+	case Random:
+		return "random"
+	case SetAssociative:
+		return "set-associative"
+	default:
+		return fmt.Sprintf("Policy(%d)", int(p))
+	}
+}
+
+// Config describes a single cache configuration to simulate.
+type Config struct {
+	Size   int
+	Policy Policy
+	// Probes is the number of entries sampled per eviction for the
+	// Approximate policy.  Zero means the default.
+	Probes int
+	// Ways is the number of entries per set for the SetAssociative
+	// policy.  Zero means simplelru.DefaultWays.
+	Ways int
+	// SampleEvery is the sampling rate of the requests being replayed,
+	// such as the sampleEvery given to simplelru.WithTrace.  A trace of
+	// one in every N keys only holds 1/N of the working set, so the
+	// simulated cache holds Size/N entries to match.  Zero or one means
+	// the requests aren't sampled.
+	SampleEvery int
+}
+
+// scaledSize returns the number of entries to simulate, accounting for
+// sampling.
+func (c Config) scaledSize() int {
+	if c.SampleEvery <= 1 {
+		return c.Size
+	}
+	return (c.Size + c.SampleEvery - 1) / c.SampleEvery
+}
+
+func (c Config) String() string {
+	s := fmt.Sprintf("%s/size=%d", c.Policy, c.Size)
+	if c.Policy == Approximate && c.Probes > 0 {
+		s += fmt.Sprintf("/probes=%d", c.Probes)
+	}
+	if c.Policy == SetAssociative && c.Ways > 0 {
+		s += fmt.Sprintf("/ways=%d", c.Ways)
+	}
+	if c.SampleEvery > 1 {
+		s += fmt.Sprintf("/sample=%d", c.SampleEvery)
+	}
+	return s
+}
+
+// Result is the outcome of simulating a single Config.
+type Result struct {
+	Config Config
+	Hits   uint64
+	Misses uint64
+}
+
+// HitRatio returns the fraction of lookups that were hits.
+func (r Result) HitRatio() float64 {
+	if r.Hits+r.Misses == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(r.Hits+r.Misses)
+}
+
+// Request is a single operation against the simulated caches.
+type Request struct {
+	Op  simplelru.TraceOp
+	Key uint64
+}
+
+// Source produces a stream of requests.  Next returns false when the
+// stream is exhausted.
+type Source interface {
+	Next() (Request, bool)
+}
+
+// Run replays src against every config in a single pass, and returns a
+// Result for each config in the same order.  Lookups that miss are
+// followed by an insertion, as a read-through cache would do.
+func Run(src Source, configs ...Config) ([]Result, error) {
+	caches := make([]simplelru.LRUCache[uint64, struct{}], len(configs))
+	results := make([]Result, len(configs))
+	for i, config := range configs {
+		cache, err := newCache(config)
+		if err != nil {
+			return nil, fmt.Errorf("config %s: %w", config, err)
+		}
+		caches[i] = cache
+		results[i].Config = config
+	}
+
+	for {
+		req, ok := src.Next()
+		if !ok {
+			break
+		}
+		for i, cache := range caches {
+			switch req.Op {
+			case simplelru.TraceGet:
+				if _, ok := cache.Get(req.Key); ok {
+					results[i].Hits++
+				} else {
+					results[i].Misses++
+					cache.Add(req.Key, struct{}{})
+				}
+			case simplelru.TraceAdd:
+				cache.Add(req.Key, struct{}{})
+			case simplelru.TraceRemove:
+				cache.Remove(req.Key)
+			}
+		}
+	}
+
+	if errSrc, ok := src.(interface{ Err() error }); ok {
+		if err := errSrc.Err(); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+func newCache(config Config) (simplelru.LRUCache[uint64, struct{}], error) {
+	size := config.scaledSize()
+	switch config.Policy {
+	case Approximate:
+		return simplelru.NewLRU[uint64, struct{}](size, nil, simplelru.WithProbes[uint64, struct{}](config.Probes))
+	case Exact:
+		return simplelru.NewExactLRU[uint64, struct{}](size, nil)
+	case Random:
+		return simplelru.NewLRU[uint64, struct{}](size, nil, simplelru.WithProbes[uint64, struct{}](1))
+	case SetAssociative:
+		return simplelru.NewSetAssociativeLRU[uint64, struct{}](size, config.Ways, nil)
+	default:
+		return nil, errors.New("unknown policy")
+	}
+}
+
+// TraceSource is a Source reading a trace recorded with
+// simplelru.TraceWriter.
+type TraceSource struct {
+	r   *simplelru.TraceReader
+	err error
+}
+
+// FromTrace returns a Source that replays the trace read from r.  If the
+// trace was sampled, set SampleEvery on each Config to the same rate.
+func FromTrace(r io.Reader) *TraceSource {
+	return &TraceSource{r: simplelru.NewTraceReader(r)}
+}
+
+// Next implements Source.
+func (s *TraceSource) Next() (Request, bool) {
+	if s.err != nil {
+		return Request{}, false
+	}
+	rec, err := s.r.Read()
+	if err != nil {
+		if err != io.EOF {
+			s.err = err
+		}
+		return Request{}, false
+	}
+	return Request{Op: rec.Op, Key: rec.KeyHash}, true
+}
+
+// Err returns the first error encountered reading the trace, other than
+// io.EOF.
+func (s *TraceSource) Err() error {
+	return s.err
+}
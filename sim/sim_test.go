@@ -0,0 +1,120 @@
+package sim
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+func TestRunZipf(t *testing.T) {
+	configs := []Config{
+		{Size: 100, Policy: Exact},
+		{Size: 1000, Policy: Exact},
+		{Size: 1000, Policy: Approximate},
+		{Size: 1000, Policy: Random},
+		{Size: 1000, Policy: SetAssociative, Ways: 16},
+	}
+	results, err := Run(Zipf(1, 1.1, 1, 10000, 100000), configs...)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(results) != len(configs) {
+		t.Fatalf("expected %d results, got %d", len(configs), len(results))
+	}
+	for i, r := range results {
+		if r.Config != configs[i] {
+			t.Errorf("result %d is for the wrong config", i)
+		}
+		if r.Hits+r.Misses != 100000 {
+			t.Errorf("%s: expected 100000 lookups, got %d", r.Config, r.Hits+r.Misses)
+		}
+	}
+	if results[0].HitRatio() >= results[1].HitRatio() {
+		t.Errorf("a bigger cache should have a better hit ratio: %v vs %v", results[0].HitRatio(), results[1].HitRatio())
+	}
+	if exact, approx := results[1].HitRatio(), results[2].HitRatio(); approx < exact-0.05 {
+		t.Errorf("approximate LRU should be close to exact: %v vs %v", approx, exact)
+	}
+	if exact, set := results[1].HitRatio(), results[4].HitRatio(); set < exact-0.05 {
+		t.Errorf("set-associative LRU should be close to exact: %v vs %v", set, exact)
+	}
+}
+
+func TestRunLoop(t *testing.T) {
+	results, err := Run(Loop(11, 1100), Config{Size: 10, Policy: Exact})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// a loop one larger than an exact LRU always misses
+	if results[0].Hits != 0 {
+		t.Errorf("expected no hits, got %d", results[0].Hits)
+	}
+}
+
+func TestRunSampled(t *testing.T) {
+	// a trace of 1 in 10 keys covers a tenth of the working set, so a
+	// 100-entry cache replays as a 10-entry one
+	results, err := Run(Loop(11, 1100),
+		Config{Size: 100, Policy: Exact, SampleEvery: 10},
+		Config{Size: 110, Policy: Exact, SampleEvery: 10})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if results[0].Hits != 0 {
+		t.Errorf("expected no hits, got %d", results[0].Hits)
+	}
+	if results[1].Misses != 11 {
+		t.Errorf("expected only compulsory misses, got %d", results[1].Misses)
+	}
+}
+
+func TestRunTrace(t *testing.T) {
+	var buf bytes.Buffer
+	w := simplelru.NewTraceWriter(&buf)
+	l, err := simplelru.NewLRU[int, int](10, nil, simplelru.WithTrace[int, int](w, 1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, ok := l.Get(i % 5); !ok {
+			l.Add(i%5, i)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	src := FromTrace(&buf)
+	results, err := Run(src, Config{Size: 10, Policy: Exact}, Config{Size: 2, Policy: Exact})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if results[0].Hits != 95 || results[0].Misses != 5 {
+		t.Errorf("replay should match the original cache: %+v", results[0])
+	}
+	if results[1].Hits != 0 {
+		t.Errorf("expected a 2-entry cache to always miss: %+v", results[1])
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	src := Interleave(Scan(100, 2), Loop(1, 3))
+	var keys []uint64
+	for {
+		req, ok := src.Next()
+		if !ok {
+			break
+		}
+		keys = append(keys, req.Key)
+	}
+	expected := []uint64{100, 0, 101, 0, 0}
+	if len(keys) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, keys)
+	}
+	for i := range keys {
+		if keys[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, keys)
+		}
+	}
+}
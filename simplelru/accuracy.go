@@ -0,0 +1,128 @@
+package simplelru
+
+// WithAccuracyTracking runs a keys-only exact LRU alongside the cache,
+// fed roughly one in sampleEvery keys, chosen by their hashes, and scaled
+// down in size to match, for Stats' AccuracyLookups, ExtraMisses,
+// ExtraHits, AccuracyEvictions and PrematureEvictions: how far the
+// approximation diverges from true LRU on the cache's real workload.  It
+// costs a list element and a map entry for each key sampled, and is meant
+// for diagnosing a cache rather than leaving on everywhere.  With a
+// sampleEvery of 1 every key is followed, and the counts are exact.
+func WithAccuracyTracking[K comparable, V any](sampleEvery int) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		every := uint64(max(sampleEvery, 1))
+		// accuracySize is always positive, so this can't fail
+		exact, _ := NewExactLRU[K, struct{}](accuracySize(int(c.size), every), nil)
+		c.ext.accuracy = &accuracyTracker[K]{every: every, exact: exact}
+	}
+}
+
+// accuracyTracker feeds a sample of keys to an exact LRU, to compare its
+// hits and evictions with the cache's.
+type accuracyTracker[K comparable] struct {
+	every uint64
+	exact *ExactLRU[K, struct{}]
+}
+
+// accuracySize returns the size of the exact LRU for a cache of size
+// entries sampling one in every keys.
+func accuracySize(size int, every uint64) int {
+	return max((size+int(every)-1)/int(every), 1)
+}
+
+// sampled reports whether key is followed, returning the tracker if so.
+func (x *extension[K, V]) sampled(key K) *accuracyTracker[K] {
+	if t := x.accuracy; t != nil && HashKey(key)%t.every == 0 {
+		return t
+	}
+	return nil
+}
+
+// compareLookup compares the outcome of a lookup of key with the exact
+// LRU's.  A key the exact LRU misses is added to it, as if the caller
+// filled it on the miss; otherwise a hit in the cache would keep the key
+// out of the exact LRU for good.
+func (x *extension[K, V]) compareLookup(key K, hit bool) {
+	t := x.sampled(key)
+	if t == nil {
+		return
+	}
+	_, exactHit := t.exact.Get(key)
+	x.stats.AccuracyLookups++
+	if exactHit && !hit {
+		x.stats.ExtraMisses++
+	} else if hit && !exactHit {
+		x.stats.ExtraHits++
+	}
+	if !exactHit {
+		t.exact.Add(key, struct{}{})
+	}
+}
+
+// compareAdd adds key to the exact LRU, as it was added to the cache.
+func (x *extension[K, V]) compareAdd(key K) {
+	if t := x.sampled(key); t != nil {
+		t.exact.Add(key, struct{}{})
+	}
+}
+
+// compareRemove removes key from the exact LRU, as it was removed from
+// the cache.
+func (x *extension[K, V]) compareRemove(key K) {
+	if t := x.sampled(key); t != nil {
+		t.exact.Remove(key)
+	}
+}
+
+// compareEviction counts key, evicted from the cache for capacity, as
+// premature if the exact LRU still holds it.  The exact LRU keeps it, so
+// that a later lookup shows whether the eviction cost a hit.
+func (x *extension[K, V]) compareEviction(key K, reason EvictReason) {
+	if reason != EvictCapacity {
+		return
+	}
+	if t := x.sampled(key); t != nil {
+		x.stats.AccuracyEvictions++
+		if t.exact.Contains(key) {
+			x.stats.PrematureEvictions++
+		}
+	}
+}
+
+// resizeAccuracy resizes the exact LRU for a cache of size entries.
+func (x *extension[K, V]) resizeAccuracy(size int) {
+	if t := x.accuracy; t != nil {
+		t.exact.Resize(accuracySize(size, t.every))
+	}
+}
+
+// resetAccuracy empties the exact LRU.
+func (x *extension[K, V]) resetAccuracy() {
+	if x.accuracy != nil {
+		x.accuracy.exact.Purge()
+	}
+}
+
+// ExtraMissRate returns the fraction of the lookups sampled
+// WithAccuracyTracking that missed but would have hit in an exact LRU,
+// less those that hit but would have missed, or 0 if there have been no
+// sampled lookups.  It's the hit ratio the approximation costs; it can
+// be negative, since sampling evictions sometimes beats strict recency.
+func (s Stats) ExtraMissRate() float64 {
+	if s.AccuracyLookups == 0 {
+		return 0
+	}
+	return (float64(s.ExtraMisses) - float64(s.ExtraHits)) / float64(s.AccuracyLookups)
+}
+
+// PrematureEvictionRate returns the fraction of the capacity evictions
+// sampled WithAccuracyTracking that evicted an entry an exact LRU would
+// have kept, or 0 if none have been sampled.  Some premature evictions
+// are inherent to sampling; raising WithProbes lowers the rate at the
+// cost of slower evictions.
+func (s Stats) PrematureEvictionRate() float64 {
+	if s.AccuracyEvictions == 0 {
+		return 0
+	}
+	return float64(s.PrematureEvictions) / float64(s.AccuracyEvictions)
+}
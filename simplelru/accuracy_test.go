@@ -0,0 +1,88 @@
+package simplelru
+
+import "testing"
+
+func TestLRU_AccuracyTracking(t *testing.T) {
+	l, err := NewLRU[int, int](16, nil, WithAccuracyTracking[int, int](1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// cycling through one more key than fits defeats a true LRU, which
+	// always evicts the key about to be needed, so every hit the
+	// approximation gets is one an exact LRU wouldn't have
+	for round := 0; round < 100; round++ {
+		for i := 0; i < 17; i++ {
+			if _, ok := l.Get(i); !ok {
+				l.Add(i, i)
+			}
+		}
+	}
+	stats := l.Stats()
+	if stats.AccuracyLookups != stats.Hits+stats.Misses {
+		t.Fatalf("expected every lookup to be compared: %+v", stats)
+	}
+	if stats.ExtraMisses != 0 || stats.ExtraHits != stats.Hits || stats.Hits == 0 {
+		t.Fatalf("bad extra hits and misses: %+v", stats)
+	}
+	if r := stats.ExtraMissRate(); r >= 0 {
+		t.Fatalf("expected a negative extra miss rate, got %v", r)
+	}
+	if stats.AccuracyEvictions != stats.Evictions || stats.PrematureEvictions == 0 {
+		t.Fatalf("bad evictions: %+v", stats)
+	}
+	if r := stats.PrematureEvictionRate(); r <= 0 || r > 1 {
+		t.Fatalf("unexpected premature eviction rate %v", r)
+	}
+
+	// removals, resizes and purges keep the exact LRU in step
+	l.Remove(16)
+	if l.ext.accuracy.exact.Contains(16) {
+		t.Fatalf("expected Remove to remove from the exact LRU")
+	}
+	l.Resize(8)
+	if n := l.ext.accuracy.exact.Len(); n > 8 {
+		t.Fatalf("expected the exact LRU to shrink, has %d keys", n)
+	}
+	l.Purge()
+	if n := l.ext.accuracy.exact.Len(); n != 0 {
+		t.Fatalf("expected Purge to empty the exact LRU, has %d keys", n)
+	}
+
+	// without WithAccuracyTracking, nothing is compared
+	plain, err := NewLRU[int, int](4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		plain.Add(i, i)
+		plain.Get(i)
+	}
+	if s := plain.Stats(); s.AccuracyLookups != 0 || s.AccuracyEvictions != 0 || s.ExtraMissRate() != 0 || s.PrematureEvictionRate() != 0 {
+		t.Fatalf("bad stats: %+v", s)
+	}
+}
+
+func TestLRU_AccuracyTrackingSampled(t *testing.T) {
+	l, err := NewLRU[int, int](1024, nil, WithAccuracyTracking[int, int](8))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if n := l.ext.accuracy.exact.size; n != 128 {
+		t.Fatalf("expected the exact LRU to be scaled to 128 keys, got %d", n)
+	}
+	for i := 0; i < 10000; i++ {
+		k := i % 2048
+		if _, ok := l.Get(k); !ok {
+			l.Add(k, k)
+		}
+	}
+	stats := l.Stats()
+	lookups := stats.Hits + stats.Misses
+	if stats.AccuracyLookups == 0 || stats.AccuracyLookups >= lookups/2 {
+		t.Fatalf("expected about one in 8 lookups to be compared: %+v", stats)
+	}
+	if stats.AccuracyEvictions == 0 || stats.AccuracyEvictions >= stats.Evictions/2 {
+		t.Fatalf("expected about one in 8 evictions to be compared: %+v", stats)
+	}
+}
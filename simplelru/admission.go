@@ -0,0 +1,128 @@
+package simplelru
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// AdmissionLimiter caps the rate at which new keys can displace existing
+// entries, with a token bucket.  Once a cache is full, adding a key it
+// doesn't hold takes a token, and is rejected if there are none, so a
+// flood of one-off keys (a crawler, say) can't flush the working set
+// faster than the limit.  Updates of keys the cache holds, additions to a
+// cache with room to spare, and lookups aren't limited.
+//
+// An AdmissionLimiter is safe for concurrent use, so a single instance can
+// be shared by every shard of a sharded cache.
+type AdmissionLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+
+	admitted uint64
+	rejected uint64
+}
+
+// NewAdmissionLimiter returns a limiter admitting perSecond new keys a
+// second on average, and up to burst at once.
+func NewAdmissionLimiter(perSecond float64, burst int) (*AdmissionLimiter, error) {
+	if perSecond <= 0 || burst <= 0 {
+		return nil, errors.New("must provide a positive rate and burst")
+	}
+	return &AdmissionLimiter{
+		rate:   perSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+	}, nil
+}
+
+// admit takes a token if there is one.
+func (l *AdmissionLimiter) admit() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	if l.tokens < 1 {
+		l.rejected++
+		return false
+	}
+	l.tokens--
+	l.admitted++
+	return true
+}
+
+// Stats returns how many new keys the limiter has admitted and rejected.
+func (l *AdmissionLimiter) Stats() (admitted, rejected uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.admitted, l.rejected
+}
+
+// WithAdmissionLimiter limits the rate at which new keys can evict
+// existing entries to l's.  Add returns false without adding a key that l
+// rejects.
+func WithAdmissionLimiter[K comparable, V any](l *AdmissionLimiter) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		c.ext.admission = l
+	}
+}
+
+// WithOnAdmit calls onAdmit before adding a key the cache doesn't hold,
+// and rejects the key if it returns false, for admission policies of the
+// caller's own: never caching values from some source, say, or never
+// displacing certain entries.  wouldEvict is the entry adding the key
+// would evict, or the zero Entry if it would evict none; its LastUsed is
+// in ticks of the cache's logical clock, so its recency can be compared
+// with that of entries passed before.  A rejected key isn't added, and is
+// counted in Stats' Rejections.  onAdmit is called synchronously while
+// the cache is being modified, so it must be fast and must not call back
+// into the cache.  Updates of keys the cache holds aren't vetted.
+func WithOnAdmit[K comparable, V any](onAdmit func(key K, value V, wouldEvict Entry[K, V]) bool) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		c.ext.onAdmit = onAdmit
+	}
+}
+
+// admit asks the function given WithOnAdmit whether to add key, returning
+// the slot it would go in if adding it means evicting, or -1 if not.
+// victim, if not -1, is the slot a quota already requires evicting.
+func (c *LRU[K, V]) admit(key K, value V, victim int) (int, bool) {
+	var wouldEvict Entry[K, V]
+	if victim < 0 && int64(len(c.data)) >= c.slots() {
+		victim = c.findVictim()
+	}
+	if victim >= 0 {
+		if ent := c.data[victim]; ent.lastUsed != 0 && !c.invalidated(victim) {
+			wouldEvict = Entry[K, V]{ent.key, ent.value, ent.lastUsed}
+		}
+	}
+	if !c.ext.onAdmit(key, value, wouldEvict) {
+		c.ext.stats.Rejections++
+		return -1, false
+	}
+	return victim, true
+}
+
+// WithMaxValueSize rejects values larger than max, as measured by size,
+// so that one pathological value can't evict many useful ones.  Add
+// doesn't add a rejected value, and removes any value the key already had,
+// which would otherwise be stale.  The sizes of the values added are
+// recorded in Stats' ValueSize; for the histogram alone, pass a max no
+// value reaches, like math.MaxInt.
+func WithMaxValueSize[K comparable, V any](max int, size func(value V) int) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		c.ext.maxValue = max
+		c.ext.valueSize = size
+	}
+}
@@ -0,0 +1,143 @@
+package simplelru
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdmissionLimiter(t *testing.T) {
+	limiter, err := NewAdmissionLimiter(10, 5)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	now := time.Unix(0, 0)
+	limiter.now = func() time.Time { return now }
+	l, err := NewLRU[int, int](8, nil, WithAdmissionLimiter[int, int](limiter))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// filling the cache is free
+	for i := 0; i < 8; i++ {
+		l.Add(i, i)
+	}
+	// then the burst is admitted, and the rest rejected
+	for i := 8; i < 20; i++ {
+		l.Add(i, i)
+	}
+	if l.Len() != 8 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	for i := 8; i < 13; i++ {
+		if !l.Contains(i) {
+			t.Fatalf("expected %d to be admitted", i)
+		}
+	}
+	for i := 13; i < 20; i++ {
+		if l.Contains(i) {
+			t.Fatalf("expected %d to be rejected", i)
+		}
+	}
+	if admitted, rejected := limiter.Stats(); admitted != 5 || rejected != 7 {
+		t.Fatalf("bad stats: %v, %v", admitted, rejected)
+	}
+	if s := l.Stats(); s.Rejections != 7 || s.Evictions != 5 {
+		t.Fatalf("bad stats: %+v", s)
+	}
+
+	// updates of cached keys aren't limited
+	l.Add(12, 100)
+	if v, _ := l.Peek(12); v != 100 {
+		t.Fatalf("bad value: %v", v)
+	}
+
+	// tokens refill at the rate, up to the burst
+	now = now.Add(200 * time.Millisecond)
+	l.Add(20, 20)
+	l.Add(21, 21)
+	l.Add(22, 22)
+	if !l.Contains(20) || !l.Contains(21) || l.Contains(22) {
+		t.Fatalf("expected 2 keys to be admitted")
+	}
+	now = now.Add(time.Hour)
+	for i := 30; i < 40; i++ {
+		l.Add(i, i)
+	}
+	if admitted, _ := limiter.Stats(); admitted != 12 {
+		t.Fatalf("expected the burst to cap the tokens, got %d admitted", admitted)
+	}
+
+	if _, err := NewAdmissionLimiter(0, 1); err == nil {
+		t.Fatalf("expected a zero rate to fail")
+	}
+}
+
+func TestMaxValueSize(t *testing.T) {
+	l, err := NewLRU[int, string](8, nil, WithMaxValueSize[int, string](4, func(v string) int { return len(v) }))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, "ok")
+	l.Add(2, "too long")
+	if !l.Contains(1) || l.Contains(2) {
+		t.Fatalf("expected only the small value to be added")
+	}
+	// an oversized update removes the stale value
+	l.Add(1, "way too long")
+	if l.Contains(1) {
+		t.Fatalf("expected the old value to be removed")
+	}
+	s := l.Stats()
+	if s.Rejections != 2 {
+		t.Fatalf("bad stats: %+v", s)
+	}
+	// every value measured is recorded: "ok" in [2, 4), "too long" in
+	// [8, 16) and "way too long" in [8, 16)
+	if s.ValueSize.Count() != 3 || s.ValueSize.Buckets[2] != 1 || s.ValueSize.Buckets[4] != 2 {
+		t.Fatalf("bad value sizes: %v", s.ValueSize.Buckets)
+	}
+	if q := s.ValueSize.Quantile(0.5); q != 15 {
+		t.Fatalf("expected a median size of at most 15, got %d", q)
+	}
+}
+
+func TestOnAdmit(t *testing.T) {
+	// protected keys are never displaced, and negative values never cached
+	protected := map[int]bool{0: true, 1: true}
+	var vetoed []int
+	onAdmit := func(key int, value int, wouldEvict Entry[int, int]) bool {
+		if value < 0 || protected[wouldEvict.Key] && wouldEvict.LastUsed != 0 {
+			vetoed = append(vetoed, key)
+			return false
+		}
+		return true
+	}
+	l, err := NewLRU[int, int](4, nil, WithOnAdmit[int, int](onAdmit), WithProbes[int, int](4))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(-1, -1)
+	if l.Contains(-1) || len(vetoed) != 1 {
+		t.Fatalf("expected a negative value to be rejected")
+	}
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+	// with every slot probed, the victim is always key 0 until it's used
+	if l.Add(4, 4) || l.Contains(4) || !l.Contains(0) {
+		t.Fatalf("expected adding 4 to be vetoed")
+	}
+	l.Get(0)
+	l.Get(1)
+	if !l.Add(4, 4) || l.Contains(2) {
+		t.Fatalf("expected adding 4 to evict 2")
+	}
+	// updates aren't vetted
+	l.Add(4, -4)
+	if v, _ := l.Peek(4); v != -4 {
+		t.Fatalf("expected 4 to be updated, got %d", v)
+	}
+	if s := l.Stats(); s.Rejections != 2 || s.Evictions != 1 {
+		t.Fatalf("bad stats: %+v", s)
+	}
+}
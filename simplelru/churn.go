@@ -0,0 +1,102 @@
+package simplelru
+
+// WithChurnTracking follows roughly one in sampleEvery entries, chosen by
+// their keys' hashes, from when they're added to when they're evicted,
+// for Stats' SampledEvictions, SampledLifetime and OneHitWonders: how
+// long entries last, and how many are evicted without ever being read,
+// which show whether the cache is too small or admits too much.  It costs
+// a map entry for each entry followed, and a map lookup for each hit.
+func WithChurnTracking[K comparable, V any](sampleEvery int) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		c.ext.churn = &churnTracker[K]{every: uint64(max(sampleEvery, 1))}
+	}
+}
+
+// churnTracker follows a sample of entries through their lifetimes.
+type churnTracker[K comparable] struct {
+	every   uint64
+	entries map[K]churnEntry
+}
+
+type churnEntry struct {
+	// added is the tick at which the entry was added.
+	added int64
+	read  bool
+}
+
+// recordInsert counts a key added that wasn't already cached, at tick
+// now.
+func (x *extension[K, V]) recordInsert(key K, now int64) {
+	x.stats.Inserts++
+	x.recordAdded(key, now)
+	t := x.churn
+	if t == nil || HashKey(key)%t.every != 0 {
+		return
+	}
+	if t.entries == nil {
+		t.entries = make(map[K]churnEntry)
+	}
+	t.entries[key] = churnEntry{added: now}
+}
+
+// recordRead notes that key was read.
+func (x *extension[K, V]) recordRead(key K) {
+	if t := x.churn; t != nil && len(t.entries) > 0 {
+		if e, ok := t.entries[key]; ok && !e.read {
+			e.read = true
+			t.entries[key] = e
+		}
+	}
+}
+
+// recordLifetime counts the lifetime of key, if it was followed, as it's
+// evicted at tick now.
+func (x *extension[K, V]) recordLifetime(key K, now int64) {
+	if t := x.churn; t != nil && len(t.entries) > 0 {
+		if e, ok := t.entries[key]; ok {
+			x.stats.SampledEvictions++
+			x.stats.SampledLifetime += uint64(now - e.added)
+			if !e.read {
+				x.stats.OneHitWonders++
+			}
+		}
+	}
+}
+
+// forgetChurn stops following key, which has left the cache.
+func (x *extension[K, V]) forgetChurn(key K) {
+	if t := x.churn; t != nil && len(t.entries) > 0 {
+		delete(t.entries, key)
+	}
+}
+
+// resetChurn stops following every entry.
+func (x *extension[K, V]) resetChurn() {
+	if x.churn != nil {
+		x.churn.entries = nil
+	}
+}
+
+// AvgLifetime returns the average number of ticks of the cache's logical
+// clock (which advances once per Add or Get) that the entries sampled
+// WithChurnTracking lasted from being added to being evicted, or 0 if
+// none have been evicted.
+func (s Stats) AvgLifetime() float64 {
+	if s.SampledEvictions == 0 {
+		return 0
+	}
+	return float64(s.SampledLifetime) / float64(s.SampledEvictions)
+}
+
+// OneHitWonderRatio returns the fraction of the entries sampled
+// WithChurnTracking that were evicted without being read after they were
+// added, or 0 if none have been evicted.  A high ratio means most of what
+// the cache admits is never used, so it might do better with an
+// admission policy; a low one with a short AvgLifetime means it's too
+// small to keep what it's given.
+func (s Stats) OneHitWonderRatio() float64 {
+	if s.SampledEvictions == 0 {
+		return 0
+	}
+	return float64(s.OneHitWonders) / float64(s.SampledEvictions)
+}
@@ -0,0 +1,58 @@
+package simplelru
+
+import "testing"
+
+func TestLRU_ChurnTracking(t *testing.T) {
+	l, err := NewLRU[int, int](16, nil, WithChurnTracking[int, int](1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// even keys are read once after they're added, odd ones never
+	for i := 0; i < 1000; i++ {
+		l.Add(i, i)
+		if i%2 == 0 {
+			l.Get(i)
+		}
+	}
+	l.Add(0, 0)
+	stats := l.Stats()
+	if stats.Inserts != 1001 {
+		t.Fatalf("expected 1001 inserts, got %d", stats.Inserts)
+	}
+	if stats.SampledEvictions != stats.Evictions || stats.Evictions != 1001-16 {
+		t.Fatalf("expected every eviction to be sampled: %+v", stats)
+	}
+	if r := stats.OneHitWonderRatio(); r < 0.4 || r > 0.6 {
+		t.Fatalf("expected about half the entries to be one-hit wonders, got %v", r)
+	}
+	if a := stats.AvgLifetime(); a < 16 || a > 64 {
+		t.Fatalf("unexpected average lifetime %v", a)
+	}
+
+	// updates aren't inserts, and removed entries aren't followed
+	l.Add(999, 1)
+	l.Remove(998)
+	if s := l.Stats(); s.Inserts != stats.Inserts {
+		t.Fatalf("expected an update not to count as an insert")
+	}
+	if n := len(l.ext.churn.entries); n != l.Len() {
+		t.Fatalf("expected %d entries followed, got %d", l.Len(), n)
+	}
+	l.Purge()
+	if len(l.ext.churn.entries) != 0 {
+		t.Fatalf("expected Purge to stop following entries")
+	}
+
+	// without WithChurnTracking, only inserts are counted
+	plain, err := NewLRU[int, int](4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		plain.Add(i, i)
+	}
+	if s := plain.Stats(); s.Inserts != 8 || s.SampledEvictions != 0 || s.AvgLifetime() != 0 || s.OneHitWonderRatio() != 0 {
+		t.Fatalf("bad stats: %+v", s)
+	}
+}
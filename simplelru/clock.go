@@ -0,0 +1,101 @@
+package simplelru
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// clockEvery is how many ticks of the logical clock pass between
+	// wall-clock samples at the finest level of a wallClock.
+	clockEvery = 1024
+	// clockLevels and clockSamples are the number of levels of a
+	// wallClock and the samples each keeps; each level samples
+	// clockSamples times less often than the one before, so together
+	// they cover about clockEvery * clockSamples^clockLevels ticks.
+	clockLevels  = 5
+	clockSamples = 32
+)
+
+// wallClock maps ticks of the logical clock to roughly when they
+// happened, so that entries' ages can be given in wall-clock time
+// without storing a timestamp per entry.  Level l records the time every
+// clockEvery*clockSamples^l ticks, in a ring of the most recent
+// clockSamples; recent ticks are resolved by the finest levels, and old
+// ones by coarser ones.
+type wallClock struct {
+	samples [clockLevels][clockSamples]int64
+	now     func() time.Time
+}
+
+func (w *wallClock) init(now func() time.Time) {
+	w.now = now
+	start := now().UnixNano()
+	for l := range w.samples {
+		w.samples[l][0] = start
+	}
+}
+
+// tick records the time of tick n, if it's a sampled tick.  It's called
+// for every tick.
+func (w *wallClock) tick(n int64) {
+	if n%clockEvery != 0 {
+		return
+	}
+	t := w.now().UnixNano()
+	g := int64(clockEvery)
+	for l := range w.samples {
+		if n%g != 0 {
+			return
+		}
+		w.samples[l][(n/g)%clockSamples] = t
+		g *= clockSamples
+	}
+}
+
+// at returns the time of the latest sample at or before tick, given that
+// the clock has reached cur, which is no later than tick happened.  It
+// returns false if tick is older than every level remembers.
+func (w *wallClock) at(tick, cur int64) (time.Time, bool) {
+	g := int64(clockEvery)
+	for l := range w.samples {
+		if j := tick / g; cur/g-j < clockSamples {
+			return time.Unix(0, w.samples[l][j%clockSamples]), true
+		}
+		g *= clockSamples
+	}
+	return time.Time{}, false
+}
+
+// LastUsed returns roughly when key was last used, without updating its
+// "recently used"-ness.  The cache keeps a logical clock rather than a
+// timestamp per entry, and samples the time every so many ticks of it,
+// so the time returned is that of the sample before the entry's last
+// use: the true time is no earlier, and is later by up to the time the
+// cache takes to do 1024 adds and hits (misses don't advance the clock),
+// or proportionally more for entries used long (millions of adds and
+// hits) ago.  Entries used so long ago that
+// the samples no longer cover them report the zero time.
+func (c *LRU[K, V]) LastUsed(key K) (t time.Time, ok bool) {
+	i, ok := c.items[key]
+	if !ok || c.invalidated(i) {
+		return t, false
+	}
+	t, _ = c.ext.clock.at(c.data[i].lastUsed, c.counter)
+	return t, true
+}
+
+// ApproxAge returns roughly how long ago key was last used, without
+// updating its "recently used"-ness, for freshness decisions like
+// revalidating entries older than some age.  It's rounded up, as
+// LastUsed's time is rounded down, so it errs on the side of older.
+func (c *LRU[K, V]) ApproxAge(key K) (age time.Duration, ok bool) {
+	t, ok := c.LastUsed(key)
+	if !ok {
+		return 0, false
+	}
+	if t.IsZero() {
+		return time.Duration(math.MaxInt64), true
+	}
+	return c.ext.clock.now().Sub(t), true
+}
@@ -0,0 +1,86 @@
+package simplelru
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU_ApproxAge(t *testing.T) {
+	l, err := NewLRU[int, int](128, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	now := time.Unix(1000, 0)
+	l.ext.clock.init(func() time.Time { return now })
+
+	if _, ok := l.ApproxAge(1); ok {
+		t.Fatalf("expected no age for a missing key")
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+	// every hit advances the logical clock, and the fake time by a
+	// millisecond
+	for i := 0; i < 10*clockEvery; i++ {
+		now = now.Add(time.Millisecond)
+		l.Get(2)
+	}
+	l.Add(3, 3)
+	added := now
+	for i := 0; i < 3*clockEvery; i++ {
+		now = now.Add(time.Millisecond)
+		l.Get(2)
+	}
+
+	// ages are rounded up by at most the time of clockEvery operations
+	check := func(key int, at time.Time) {
+		t.Helper()
+		age, ok := l.ApproxAge(key)
+		if !ok {
+			t.Fatalf("expected an age for %d", key)
+		}
+		actual := now.Sub(at)
+		if age < actual || age > actual+clockEvery*time.Millisecond {
+			t.Fatalf("%d: expected an age of about %v, got %v", key, actual, age)
+		}
+		last, _ := l.LastUsed(key)
+		if last.After(at) {
+			t.Fatalf("%d: expected last use no later than %v, got %v", key, at, last)
+		}
+	}
+	check(1, time.Unix(1000, 0))
+	check(3, added)
+
+	// Get updates the last use, and Peek doesn't
+	l.Get(1)
+	l.Peek(3)
+	check(1, now)
+	check(3, added)
+}
+
+func TestWallClockCoarseLevels(t *testing.T) {
+	var w wallClock
+	now := time.Unix(0, 0)
+	w.init(func() time.Time { return now })
+	const ticks = 4 * clockEvery * clockSamples * clockSamples
+	for n := int64(1); n < ticks; n++ {
+		now = now.Add(time.Microsecond)
+		w.tick(n)
+	}
+	// an old tick is resolved by a coarser level, to within its spacing
+	tick := int64(ticks / 2)
+	at, ok := w.at(tick, ticks)
+	if !ok {
+		t.Fatalf("expected tick %d to be covered", tick)
+	}
+	actual := time.Unix(0, 0).Add(time.Duration(tick) * time.Microsecond)
+	if at.After(actual) || actual.Sub(at) > clockEvery*clockSamples*clockSamples*time.Microsecond {
+		t.Fatalf("expected about %v, got %v", actual, at)
+	}
+	// a recent tick is resolved by the finest level
+	tick = ticks - 10*clockEvery - 1
+	at, _ = w.at(tick, ticks)
+	actual = time.Unix(0, 0).Add(time.Duration(tick) * time.Microsecond)
+	if at.After(actual) || actual.Sub(at) > clockEvery*time.Microsecond {
+		t.Fatalf("expected about %v, got %v", actual, at)
+	}
+}
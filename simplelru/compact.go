@@ -0,0 +1,81 @@
+package simplelru
+
+// Holes returns the number of slots that don't hold a live entry but
+// that eviction samples anyway: slots emptied by Remove, and entries
+// invalidated by InvalidateAll.  Once the cache has filled up, a new
+// entry only fills a hole if the eviction sample happens to include one,
+// and evicts a live entry otherwise, so many holes make the cache
+// effectively smaller; Compact reclaims them.
+func (c *LRU[K, V]) Holes() int {
+	return len(c.data) - c.Len()
+}
+
+// Occupancy describes how full a cache's slots are.
+type Occupancy struct {
+	// Live is the number of entries in the cache, Slots the number of
+	// slots it has filled so far, including holes, and Cap the most
+	// it can hold.
+	Live  int
+	Slots int
+	Cap   int
+}
+
+// LiveFraction returns the fraction of filled slots that hold a live
+// entry rather than a hole, or 1 if no slot has been filled.
+func (o Occupancy) LiveFraction() float64 {
+	if o.Slots == 0 {
+		return 1
+	}
+	return float64(o.Live) / float64(o.Slots)
+}
+
+// FillFactor returns the fraction of the cache's capacity holding live
+// entries.
+func (o Occupancy) FillFactor() float64 {
+	if o.Cap == 0 {
+		return 0
+	}
+	return float64(o.Live) / float64(o.Cap)
+}
+
+// Occupancy returns how full the cache's slots are.
+func (c *LRU[K, V]) Occupancy() Occupancy {
+	return Occupancy{Live: c.Len(), Slots: len(c.data), Cap: c.Cap()}
+}
+
+// Compact reclaims the cache's holes, by dropping invalidated entries
+// and moving live entries from the end of the slot array into the empty
+// slots before them, so that new entries are added to the free space at
+// the end rather than evicting live ones.  It returns the number of
+// slots reclaimed.  It visits every slot, so after heavy Remove traffic
+// call it when Holes is a significant fraction of the size.
+func (c *LRU[K, V]) Compact() (reclaimed int) {
+	n := len(c.data)
+	for i := 0; i < n; i++ {
+		if c.invalidated(i) {
+			c.dropInvalidated(i)
+		}
+		if c.data[i].lastUsed != 0 {
+			continue
+		}
+		// backfill the hole with the last live entry, which keeps the
+		// entries as randomly placed as they were
+		for n--; n > i; n-- {
+			if c.invalidated(n) {
+				c.dropInvalidated(n)
+			}
+			if c.data[n].lastUsed != 0 {
+				break
+			}
+		}
+		if n == i {
+			break
+		}
+		c.data[i] = c.data[n]
+		c.items[c.data[i].key] = i
+	}
+	reclaimed = len(c.data) - n
+	clear(c.data[n:])
+	c.data = c.data[:n]
+	return reclaimed
+}
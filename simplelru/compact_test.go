@@ -0,0 +1,83 @@
+package simplelru
+
+import "testing"
+
+func TestLRU_Compact(t *testing.T) {
+	l, err := NewLRU[int, int](128, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 128; i++ {
+		l.Add(i, i)
+	}
+	for i := 0; i < 128; i += 2 {
+		l.Remove(i)
+	}
+	if h := l.Holes(); h != 64 {
+		t.Fatalf("expected 64 holes, got %d", h)
+	}
+
+	if n := l.Compact(); n != 64 {
+		t.Fatalf("expected to reclaim 64 slots, got %d", n)
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if l.Holes() != 0 || l.Len() != 64 {
+		t.Fatalf("expected 64 entries and no holes, got %d and %d", l.Len(), l.Holes())
+	}
+	for i := 1; i < 128; i += 2 {
+		if v, ok := l.Peek(i); !ok || v != i {
+			t.Fatalf("%d: bad value %v, %v", i, v, ok)
+		}
+	}
+
+	// the reclaimed slots are filled before anything is evicted
+	for i := 128; i < 192; i++ {
+		if l.Add(i, i) {
+			t.Fatalf("unexpected eviction adding %d", i)
+		}
+	}
+	if l.Len() != 128 {
+		t.Fatalf("expected a full cache, got %d", l.Len())
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// invalidated entries are reclaimed too
+	l.InvalidateAll()
+	l.Add(1000, 1000)
+	if n := l.Compact(); n != 127 || l.Len() != 1 || !l.Contains(1000) {
+		t.Fatalf("expected to reclaim 127 slots, got %d leaving %d", n, l.Len())
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if l.Compact() != 0 {
+		t.Fatalf("expected nothing left to reclaim")
+	}
+}
+
+func TestLRU_Occupancy(t *testing.T) {
+	l, err := NewLRU[int, int](128, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if o := l.Occupancy(); o.LiveFraction() != 1 || o.FillFactor() != 0 {
+		t.Fatalf("unexpected empty occupancy %+v", o)
+	}
+	for i := 0; i < 64; i++ {
+		l.Add(i, i)
+	}
+	for i := 0; i < 64; i += 2 {
+		l.Remove(i)
+	}
+	o := l.Occupancy()
+	if o != (Occupancy{Live: 32, Slots: 64, Cap: 128}) {
+		t.Fatalf("unexpected occupancy %+v", o)
+	}
+	if o.LiveFraction() != 0.5 || o.FillFactor() != 0.25 {
+		t.Fatalf("expected fractions 0.5 and 0.25, got %v and %v", o.LiveFraction(), o.FillFactor())
+	}
+}
@@ -0,0 +1,143 @@
+package simplelru
+
+import "fmt"
+
+// WithCost gives every entry a cost, such as its size in bytes, as
+// measured by cost when it's added, and keeps a running total that Cost
+// returns.  It's for accounting only: the cache still holds its size in
+// entries regardless of their cost.  Each entry's cost is remembered, so
+// the total stays accurate for values that change after they're added,
+// and UpdateCost measures such a value again.
+func WithCost[K comparable, V any](cost func(key K, value V) int64) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		c.ext.costOf = cost
+	}
+}
+
+// WithCostAwareEviction chooses among the entries sampled for eviction at
+// random, weighting each by its cost, as measured by the function given
+// WithCost, times its age, rather than always choosing the oldest: large,
+// stale entries are the likeliest to go, as under GDSF.  It's for caches
+// bounded by their total cost, by calling RemoveOldest until Cost is low
+// enough, whose values' sizes vary by orders of magnitude, as in a CDN:
+// evicting one large entry makes room for many small ones.  An empty slot
+// sampled is still always chosen first.  It has no effect without
+// WithCost.
+func WithCostAwareEviction[K comparable, V any]() Option[K, V] {
+	return func(c *LRU[K, V]) {
+		c.ext.costVictims = true
+	}
+}
+
+// Cost returns the total cost of the cache's entries, as measured by the
+// function given WithCost, or 0 if none was.
+func (c *LRU[K, V]) Cost() int64 {
+	return c.ext.cost
+}
+
+// UpdateCost measures key's value again with the function given WithCost,
+// for values that have grown or shrunk in place since they were added,
+// such as appended-to buffers, and adjusts the total to match.  It
+// doesn't update the key's recent-ness.  It returns false if key isn't
+// cached, or if the cache wasn't created WithCost.
+func (c *LRU[K, V]) UpdateCost(key K) bool {
+	if c.ext.costOf == nil {
+		return false
+	}
+	i, ok := c.items[key]
+	if !ok || c.invalidated(i) {
+		return false
+	}
+	c.ext.subCost(key)
+	c.ext.addCost(key, c.data[i].value)
+	return true
+}
+
+// findCostVictim is findVictim WithCostAwareEviction.  It picks from the
+// sample in one pass, keeping each entry with the probability of its
+// weight among those seen so far.  If skipEmpty is set, empty slots are
+// passed over rather than chosen.
+func (c *LRU[K, V]) findCostVictim(base, probes, size int, skipEmpty bool) (off int) {
+	off = -1
+	var total float64
+	c.ext.stats.VictimSearches++
+	for j := 0; j < probes; j++ {
+		c.ext.stats.Probes++
+		i := (base + j) % size
+		ent := &c.data[i]
+		if ent.lastUsed == 0 || c.invalidated(i) {
+			c.ext.stats.EmptyProbes++
+			if ent.lastUsed != 0 || !skipEmpty {
+				return i
+			}
+			continue
+		}
+		if len(c.ext.pins) > 0 && c.pinned(i) {
+			continue
+		}
+		weight := float64(max(c.ext.costs[ent.key], 1)) * float64(c.counter-ent.lastUsed)
+		total += weight
+		if c.rng.Float64()*total < weight {
+			off = i
+		}
+	}
+	if off < 0 {
+		return c.findUnpinnedVictim(base, probes, size)
+	}
+	return off
+}
+
+func (x *extension[K, V]) addCost(key K, value V) {
+	var cost int64
+	if x.costOf != nil {
+		cost = x.costOf(key, value)
+		if x.costs == nil {
+			x.costs = make(map[K]int64)
+		}
+		x.costs[key] = cost
+		x.cost += cost
+	}
+	if x.quotas != nil {
+		x.chargeQuota(key, cost)
+	}
+}
+
+func (x *extension[K, V]) subCost(key K) {
+	var cost int64
+	if x.costOf != nil {
+		cost = x.costs[key]
+		x.cost -= cost
+		delete(x.costs, key)
+	}
+	if x.quotas != nil {
+		x.creditQuota(key, cost)
+	}
+}
+
+// validateCost checks that the running total cost matches the costs
+// recorded for the entries.  It can't measure the entries again, as
+// their values may have changed since.
+func (c *LRU[K, V]) validateCost() error {
+	if c.ext.costOf == nil {
+		return nil
+	}
+	var cost int64
+	live := 0
+	for i := range c.data {
+		if ent := &c.data[i]; ent.lastUsed != 0 && !c.invalidated(i) {
+			recorded, ok := c.ext.costs[ent.key]
+			if !ok {
+				return fmt.Errorf("key %v has no recorded cost", ent.key)
+			}
+			cost += recorded
+			live++
+		}
+	}
+	if live != len(c.ext.costs) {
+		return fmt.Errorf("%d costs recorded for %d entries", len(c.ext.costs), live)
+	}
+	if cost != c.ext.cost {
+		return fmt.Errorf("entries cost %d, but %d counted", cost, c.ext.cost)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package simplelru
+
+import "testing"
+
+func TestLRU_Cost(t *testing.T) {
+	cost := func(key int, value string) int64 { return int64(len(value)) }
+	l, err := NewLRU[int, string](4, nil, WithCost[int, string](cost))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	check := func(expected int64) {
+		t.Helper()
+		if cost := l.Cost(); cost != expected {
+			t.Fatalf("expected cost %d, got %d", expected, cost)
+		}
+		// Validate totals the costs recorded for the entries
+		if err := l.Validate(); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	l.Add(1, "a")
+	l.Add(2, "bb")
+	l.Add(3, "ccc")
+	check(6)
+
+	// replacing a value replaces its cost
+	l.Add(1, "aaaa")
+	check(9)
+
+	// evictions and removals subtract their entries' costs
+	for i := 10; i < 20; i++ {
+		l.Add(i, "xxxxx")
+		if err := l.Validate(); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	check(4 * 5)
+	l.Remove(19)
+	l.RemoveOldest()
+	check(2 * 5)
+	l.Resize(1)
+	check(5)
+
+	l.InvalidateAll()
+	check(0)
+	l.Add(6, "ff")
+	l.WarmUp([]Entry[int, string]{{Key: 7, Value: "ggg"}})
+	check(3)
+
+	l.Purge()
+	check(0)
+}
+
+func TestLRU_UpdateCost(t *testing.T) {
+	cost := func(key int, value *[]byte) int64 { return int64(len(*value)) }
+	l, err := NewLRU[int, *[]byte](4, nil, WithCost[int, *[]byte](cost))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	a, b := []byte("a"), []byte("bb")
+	l.Add(1, &a)
+	l.Add(2, &b)
+	if cost := l.Cost(); cost != 3 {
+		t.Fatalf("expected cost 3, got %d", cost)
+	}
+
+	// growing a value in place doesn't change its recorded cost until
+	// it's measured again, so removing it still balances the total
+	a = append(a, "aaaa"...)
+	if cost := l.Cost(); cost != 3 {
+		t.Fatalf("expected cost 3, got %d", cost)
+	}
+	if !l.UpdateCost(1) {
+		t.Fatalf("expected key 1 to be updated")
+	}
+	if cost := l.Cost(); cost != 7 {
+		t.Fatalf("expected cost 7, got %d", cost)
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	b = b[:0]
+	l.Remove(2)
+	if cost := l.Cost(); cost != 5 {
+		t.Fatalf("expected cost 5, got %d", cost)
+	}
+
+	if l.UpdateCost(2) {
+		t.Fatalf("expected a missing key not to be updated")
+	}
+	l.InvalidateAll()
+	if l.UpdateCost(1) || l.Cost() != 0 {
+		t.Fatalf("expected an invalidated key not to be updated")
+	}
+
+	plain, err := NewLRU[int, *[]byte](4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	plain.Add(1, &a)
+	if plain.UpdateCost(1) {
+		t.Fatalf("expected no update without WithCost")
+	}
+}
+
+func TestLRU_CostAwareEviction(t *testing.T) {
+	// even keys cost 1000 times as much as odd ones
+	cost := func(key int, value int) int64 {
+		if key%2 == 0 {
+			return 1000
+		}
+		return 1
+	}
+	largeEvicted := func(opts ...Option[int, int]) int {
+		opts = append(opts, WithCost[int, int](cost), WithSeed[int, int](1))
+		l, err := NewLRU[int, int](64, nil, opts...)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		for i := 0; i < 64; i++ {
+			l.Add(i, i)
+		}
+		large := 0
+		for i := 0; i < 16; i++ {
+			key, _, ok := l.RemoveOldest()
+			if !ok {
+				t.Fatalf("expected an entry to be evicted")
+			}
+			if key%2 == 0 {
+				large++
+			}
+		}
+		if err := l.Validate(); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return large
+	}
+
+	if n := largeEvicted(WithCostAwareEviction[int, int]()); n < 15 {
+		t.Fatalf("expected nearly every eviction to be of a large entry, got %d of 16", n)
+	}
+	if n := largeEvicted(); n > 12 {
+		t.Fatalf("expected evictions to ignore cost by default, got %d of 16 large", n)
+	}
+}
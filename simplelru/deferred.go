@@ -0,0 +1,60 @@
+package simplelru
+
+// WithDeferredEviction lets Add grow the cache up to headroom entries
+// past its size rather than evicting to make room, leaving it to Trim,
+// typically called from a background goroutine, to evict the excess.
+// This keeps victim selection off the path of Add for as long as Trim
+// keeps up; once the headroom is used up, Add evicts as usual.
+func WithDeferredEviction[K comparable, V any](headroom int) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		c.ext.headroom = int64(max(headroom, 0))
+	}
+}
+
+// slots returns the number of slots Add fills before it has to evict.
+func (c *LRU[K, V]) slots() int64 {
+	return c.size + c.ext.headroom
+}
+
+// Overflow returns the number of entries past its size that the cache
+// holds, which Trim will evict.  It is 0 unless the cache was created
+// WithDeferredEviction.
+func (c *LRU[K, V]) Overflow() int {
+	return max(c.Len()-int(c.size), 0)
+}
+
+// Trim evicts up to max of the entries past the cache's size, chosen as
+// Add would choose them, returning the number it evicted.  It moves the
+// entries at the end of the slot array into the slots it frees, so that
+// Add fills the free space at the end rather than evicting again.
+func (c *LRU[K, V]) Trim(max int) (evicted int) {
+	if c.ext.frozen {
+		return 0
+	}
+	for evicted < max && c.Overflow() > 0 {
+		i := c.findVictim()
+		if c.invalidated(i) {
+			c.dropInvalidated(i)
+		} else if ent := c.data[i]; ent.lastUsed != 0 {
+			c.evictElement(i, ent, EvictCapacity)
+			evicted++
+		}
+		c.popSlot(i)
+	}
+	return evicted
+}
+
+// popSlot fills the empty slot i with the last slot's entry, and drops
+// the last slot.
+func (c *LRU[K, V]) popSlot(i int) {
+	n := len(c.data) - 1
+	if i != n {
+		c.data[i] = c.data[n]
+		// invalidated entries are still indexed
+		if c.data[i].lastUsed != 0 {
+			c.items[c.data[i].key] = i
+		}
+		c.data[n] = entry[K, V]{}
+	}
+	c.data = c.data[:n]
+}
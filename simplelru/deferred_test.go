@@ -0,0 +1,68 @@
+package simplelru
+
+import "testing"
+
+func TestLRU_DeferredEviction(t *testing.T) {
+	evictions := 0
+	onEvict := func(key, value int) { evictions++ }
+	l, err := NewLRU[int, int](64, onEvict, WithDeferredEviction[int, int](16))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 80; i++ {
+		if l.Add(i, i) {
+			t.Fatalf("expected Add of %d not to evict", i)
+		}
+	}
+	if l.Len() != 80 || l.Overflow() != 16 || evictions != 0 {
+		t.Fatalf("bad len %d, overflow %d or evictions %d", l.Len(), l.Overflow(), evictions)
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// past the headroom, Add evicts inline
+	if !l.Add(80, 80) || l.Len() != 80 {
+		t.Fatalf("expected Add past the headroom to evict")
+	}
+
+	if n := l.Trim(10); n != 10 || l.Overflow() != 6 {
+		t.Fatalf("expected 10 evictions leaving 6, got %d leaving %d", n, l.Overflow())
+	}
+	if n := l.Trim(100); n != 6 || l.Overflow() != 0 || l.Len() != 64 {
+		t.Fatalf("expected 6 evictions, got %d leaving len %d", n, l.Len())
+	}
+	if evictions != 17 {
+		t.Fatalf("expected 17 evictions, got %d", evictions)
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// trimming compacts the slots, so there's headroom to add into
+	// again
+	l.Remove(80)
+	for i := 100; i < 116; i++ {
+		if l.Add(i, i) {
+			t.Fatalf("expected Add of %d not to evict", i)
+		}
+	}
+	if n := l.Trim(100); n != 15 || l.Len() != 64 {
+		t.Fatalf("expected 15 evictions, got %d leaving len %d", n, l.Len())
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// without WithDeferredEviction there's never anything to trim
+	plain, err := NewLRU[int, int](4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		plain.Add(i, i)
+	}
+	if plain.Overflow() != 0 || plain.Trim(10) != 0 {
+		t.Fatalf("expected nothing to trim")
+	}
+}
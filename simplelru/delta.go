@@ -0,0 +1,112 @@
+package simplelru
+
+import "time"
+
+// StatsSnapshot is a cache's counters along with the time they were
+// read, so that two snapshots can be compared with Delta.
+type StatsSnapshot struct {
+	Stats
+	// At is when the counters were read.
+	At time.Time
+}
+
+// StatsSnapshot returns the cache's counters as of now.
+func (c *LRU[K, V]) StatsSnapshot() StatsSnapshot {
+	return StatsSnapshot{Stats: c.Stats(), At: time.Now()}
+}
+
+// Delta returns the change in the counters from prev to s, for reporting
+// per-interval counts and rates from lifetime counters.  A counter that
+// went down between the snapshots, because prev was taken from a
+// different cache or before the cache was replaced, is treated as having
+// restarted from zero, so its delta is its value in s.
+func (s StatsSnapshot) Delta(prev StatsSnapshot) StatsDelta {
+	d := StatsDelta{
+		Stats: Stats{
+			Hits:               since(s.Hits, prev.Hits),
+			Misses:             since(s.Misses, prev.Misses),
+			Evictions:          since(s.Evictions, prev.Evictions),
+			Inserts:            since(s.Inserts, prev.Inserts),
+			Rejections:         since(s.Rejections, prev.Rejections),
+			VictimSearches:     since(s.VictimSearches, prev.VictimSearches),
+			Probes:             since(s.Probes, prev.Probes),
+			EmptyProbes:        since(s.EmptyProbes, prev.EmptyProbes),
+			SampledEvictions:   since(s.SampledEvictions, prev.SampledEvictions),
+			SampledLifetime:    since(s.SampledLifetime, prev.SampledLifetime),
+			OneHitWonders:      since(s.OneHitWonders, prev.OneHitWonders),
+			AccuracyLookups:    since(s.AccuracyLookups, prev.AccuracyLookups),
+			ExtraMisses:        since(s.ExtraMisses, prev.ExtraMisses),
+			ExtraHits:          since(s.ExtraHits, prev.ExtraHits),
+			AccuracyEvictions:  since(s.AccuracyEvictions, prev.AccuracyEvictions),
+			PrematureEvictions: since(s.PrematureEvictions, prev.PrematureEvictions),
+		},
+		Elapsed: s.At.Sub(prev.At),
+	}
+	for i, n := range s.EvictionAge.Buckets {
+		d.EvictionAge.Buckets[i] = since(n, prev.EvictionAge.Buckets[i])
+	}
+	for i, n := range s.ValueSize.Buckets {
+		d.ValueSize.Buckets[i] = since(n, prev.ValueSize.Buckets[i])
+	}
+	return d
+}
+
+func since(cur, prev uint64) uint64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
+// StatsDelta is the change in a cache's counters over an interval, as
+// returned by StatsSnapshot.Delta.  Its HitRatio is the hit ratio over
+// the interval.
+type StatsDelta struct {
+	Stats
+	// Elapsed is the length of the interval.
+	Elapsed time.Duration
+}
+
+// HitsPerSecond returns the rate of hits over the interval, or 0 if the
+// interval is empty.
+func (d StatsDelta) HitsPerSecond() float64 {
+	return d.rate(d.Hits)
+}
+
+// MissesPerSecond returns the rate of misses over the interval, or 0 if
+// the interval is empty.
+func (d StatsDelta) MissesPerSecond() float64 {
+	return d.rate(d.Misses)
+}
+
+// LookupsPerSecond returns the rate of lookups, hits and misses both,
+// over the interval, or 0 if the interval is empty.
+func (d StatsDelta) LookupsPerSecond() float64 {
+	return d.rate(d.Hits + d.Misses)
+}
+
+// EvictionsPerSecond returns the rate of evictions over the interval, or
+// 0 if the interval is empty.
+func (d StatsDelta) EvictionsPerSecond() float64 {
+	return d.rate(d.Evictions)
+}
+
+// InsertsPerSecond returns the rate at which new keys were added over the
+// interval, or 0 if the interval is empty.  Compared with
+// EvictionsPerSecond, it shows how fast the cache's contents turn over.
+func (d StatsDelta) InsertsPerSecond() float64 {
+	return d.rate(d.Inserts)
+}
+
+// RejectionsPerSecond returns the rate of rejected additions over the
+// interval, or 0 if the interval is empty.
+func (d StatsDelta) RejectionsPerSecond() float64 {
+	return d.rate(d.Rejections)
+}
+
+func (d StatsDelta) rate(n uint64) float64 {
+	if d.Elapsed <= 0 {
+		return 0
+	}
+	return float64(n) / d.Elapsed.Seconds()
+}
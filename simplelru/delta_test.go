@@ -0,0 +1,71 @@
+package simplelru
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSnapshot_Delta(t *testing.T) {
+	l, err := NewLRU[int, int](2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.Get(1)
+	prev := l.StatsSnapshot()
+
+	l.Get(1)
+	l.Get(1)
+	l.Get(1)
+	l.Get(2)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	cur := l.StatsSnapshot()
+	// pin the interval so the rates are exact
+	cur.At = prev.At.Add(2 * time.Second)
+
+	d := cur.Delta(prev)
+	if d.Hits != 3 || d.Misses != 1 || d.Evictions != 1 {
+		t.Fatalf("bad delta: %+v", d.Stats)
+	}
+	if d.Elapsed != 2*time.Second {
+		t.Fatalf("bad elapsed: %v", d.Elapsed)
+	}
+	if r := d.HitRatio(); r != 0.75 {
+		t.Errorf("expected interval hit ratio of 0.75, got %v", r)
+	}
+	if r := d.HitsPerSecond(); r != 1.5 {
+		t.Errorf("expected 1.5 hits/sec, got %v", r)
+	}
+	if r := d.LookupsPerSecond(); r != 2 {
+		t.Errorf("expected 2 lookups/sec, got %v", r)
+	}
+	if r := d.EvictionsPerSecond(); r != 0.5 {
+		t.Errorf("expected 0.5 evictions/sec, got %v", r)
+	}
+	if r := d.InsertsPerSecond(); r != 1 {
+		t.Errorf("expected 1 insert/sec, got %v", r)
+	}
+	if n := d.EvictionAge.Count(); n != 1 {
+		t.Errorf("expected 1 eviction age in the interval, got %v", n)
+	}
+	if n := d.ValueSize.Count(); n != 0 {
+		t.Errorf("expected no value sizes without WithMaxValueSize, got %v", n)
+	}
+	if r := cur.HitRatio(); math.Abs(r-0.8) > 0.001 {
+		t.Errorf("expected lifetime hit ratio of 0.8, got %v", r)
+	}
+
+	// an empty interval has no rates
+	if r := cur.Delta(cur).HitsPerSecond(); r != 0 {
+		t.Errorf("expected 0 hits/sec over an empty interval, got %v", r)
+	}
+
+	// counters that went backwards restart from zero
+	d = prev.Delta(cur)
+	if d.Hits != prev.Hits || d.Misses != prev.Misses {
+		t.Errorf("expected restarted counters, got %+v", d.Stats)
+	}
+}
@@ -0,0 +1,83 @@
+package simplelru
+
+// EvictionInfo describes an entry leaving the cache, for the callback
+// given WithOnEvictInfo, so that write-back and analytics consumers don't
+// need bookkeeping of their own keyed by the cache's keys.
+type EvictionInfo struct {
+	// Reason is why the entry left.
+	Reason EvictReason
+	// Idle is how long the entry had gone unused, and Age how long it
+	// had been in the cache, in ticks of the cache's logical clock
+	// (which advances once per Add or Get).
+	Idle int64
+	Age  int64
+	// Hits counts the lookups that found the entry.
+	Hits uint64
+}
+
+// WithOnEvictInfo calls onEvict for every entry leaving the cache that
+// the eviction callback given NewLRU would be called for, along with
+// what the cache knows of it, and after that callback.  It costs a map
+// entry per entry in the cache, and a map lookup per hit, to count hits
+// and remember when entries were added.
+func WithOnEvictInfo[K comparable, V any](onEvict func(key K, value V, info EvictionInfo)) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		c.ext.onEvictInfo = onEvict
+		c.ext.meta = make(map[K]entryMeta)
+	}
+}
+
+// entryMeta is what WithOnEvictInfo tracks of each entry.
+type entryMeta struct {
+	// added is the tick at which the entry was added.
+	added int64
+	hits  uint64
+}
+
+// recordAdded notes that key was added at tick now.
+func (x *extension[K, V]) recordAdded(key K, now int64) {
+	if x.meta != nil {
+		x.meta[key] = entryMeta{added: now}
+	}
+}
+
+// recordHit counts a hit on key.
+func (x *extension[K, V]) recordHit(key K) {
+	if x.meta != nil {
+		if m, ok := x.meta[key]; ok {
+			m.hits++
+			x.meta[key] = m
+		}
+	}
+}
+
+// forgetMeta forgets key, which has left the cache.
+func (x *extension[K, V]) forgetMeta(key K) {
+	if len(x.meta) > 0 {
+		delete(x.meta, key)
+	}
+}
+
+// resetMeta forgets every entry.
+func (x *extension[K, V]) resetMeta() {
+	if x.meta != nil {
+		clear(x.meta)
+	}
+}
+
+// notifyEvictInfo calls the callback given WithOnEvictInfo for ent,
+// leaving for reason at tick now.  It must be called before ent's
+// metadata is forgotten.
+func (c *LRU[K, V]) notifyEvictInfo(ent entry[K, V], reason EvictReason) {
+	m := c.ext.meta[ent.key]
+	info := EvictionInfo{
+		Reason: reason,
+		Idle:   c.counter - ent.lastUsed,
+		Age:    c.counter - m.added,
+		Hits:   m.hits,
+	}
+	if c.ext.recover {
+		defer c.ext.recovered("onEvictInfo", ent.key)
+	}
+	c.ext.onEvictInfo(ent.key, ent.value, info)
+}
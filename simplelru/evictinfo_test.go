@@ -0,0 +1,45 @@
+package simplelru
+
+import "testing"
+
+func TestLRU_OnEvictInfo(t *testing.T) {
+	infos := map[int]EvictionInfo{}
+	onEvictInfo := func(key int, value int, info EvictionInfo) {
+		infos[key] = info
+	}
+	l, err := NewLRU[int, int](4, nil, WithOnEvictInfo[int, int](onEvictInfo))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+	l.Get(0)
+	l.Get(0)
+	l.Get(1)
+
+	l.Remove(0)
+	if info := infos[0]; info.Reason != EvictRemove || info.Hits != 2 || info.Age != 7 || info.Idle != 2 {
+		t.Fatalf("bad info for 0: %+v", info)
+	}
+	l.Resize(1)
+	if info := infos[2]; info.Reason != EvictResize || info.Hits != 0 || info.Idle != info.Age {
+		t.Fatalf("bad info for 2: %+v", info)
+	}
+	l.Purge()
+	if info := infos[1]; info.Reason != EvictPurge || info.Hits != 1 {
+		t.Fatalf("bad info for 1: %+v", info)
+	}
+	if len(infos) != 4 || len(l.ext.meta) != 0 {
+		t.Fatalf("expected every entry to be reported and forgotten: %v", infos)
+	}
+
+	// a re-added key starts afresh
+	l.Resize(1)
+	l.Add(5, 5)
+	l.Get(5)
+	l.Add(6, 6)
+	if info := infos[5]; info.Reason != EvictCapacity || info.Hits != 1 {
+		t.Fatalf("bad info for 5: %+v", info)
+	}
+}
@@ -0,0 +1,138 @@
+package simplelru
+
+import (
+	"container/list"
+	"errors"
+)
+
+// ExactLRU is a non-thread safe fixed size cache with strict LRU
+// eviction, using a doubly-linked list to order entries.  It costs more
+// memory and bookkeeping per entry than LRU, but is useful as a baseline
+// to measure LRU's approximation against, or where exact recency matters.
+type ExactLRU[K comparable, V any] struct {
+	size      int
+	evictList *list.List
+	items     map[K]*list.Element
+	onEvict   EvictCallback[K, V]
+}
+
+type exactEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// NewExactLRU constructs an ExactLRU of the given size.
+func NewExactLRU[K comparable, V any](size int, onEvict EvictCallback[K, V]) (*ExactLRU[K, V], error) {
+	if size <= 0 {
+		return nil, errors.New("must provide a positive size")
+	}
+	c := &ExactLRU[K, V]{
+		size:      size,
+		evictList: list.New(),
+		items:     make(map[K]*list.Element, size),
+		onEvict:   onEvict,
+	}
+	return c, nil
+}
+
+// Purge is used to completely clear the cache.
+func (c *ExactLRU[K, V]) Purge() {
+	for k, e := range c.items {
+		if c.onEvict != nil {
+			c.onEvict(k, e.Value.(*exactEntry[K, V]).value)
+		}
+	}
+	c.items = make(map[K]*list.Element, c.size)
+	c.evictList.Init()
+}
+
+// Add adds a value to the cache.  Returns true if an eviction occurred.
+func (c *ExactLRU[K, V]) Add(key K, value V) (evicted bool) {
+	if e, ok := c.items[key]; ok {
+		c.evictList.MoveToFront(e)
+		e.Value.(*exactEntry[K, V]).value = value
+		return false
+	}
+
+	e := c.evictList.PushFront(&exactEntry[K, V]{key, value})
+	c.items[key] = e
+
+	evicted = c.evictList.Len() > c.size
+	if evicted {
+		c.RemoveOldest()
+	}
+	return evicted
+}
+
+// Get looks up a key's value from the cache.
+func (c *ExactLRU[K, V]) Get(key K) (value V, ok bool) {
+	if e, ok := c.items[key]; ok {
+		c.evictList.MoveToFront(e)
+		return e.Value.(*exactEntry[K, V]).value, true
+	}
+	return value, false
+}
+
+// Contains checks if a key is in the cache, without updating the
+// recent-ness or deleting it for being stale.
+func (c *ExactLRU[K, V]) Contains(key K) (ok bool) {
+	_, ok = c.items[key]
+	return ok
+}
+
+// Peek returns the key value (or undefined if not found) without updating
+// the "recently used"-ness of the key.
+func (c *ExactLRU[K, V]) Peek(key K) (value V, ok bool) {
+	if e, ok := c.items[key]; ok {
+		return e.Value.(*exactEntry[K, V]).value, true
+	}
+	return value, false
+}
+
+// Remove removes the provided key from the cache, returning if the
+// key was contained.
+func (c *ExactLRU[K, V]) Remove(key K) (present bool) {
+	if e, ok := c.items[key]; ok {
+		c.removeElement(e)
+		return true
+	}
+	return false
+}
+
+// RemoveOldest removes the least recently used item from the cache.
+func (c *ExactLRU[K, V]) RemoveOldest() (key K, value V, ok bool) {
+	e := c.evictList.Back()
+	if e == nil {
+		return key, value, false
+	}
+	c.removeElement(e)
+	ent := e.Value.(*exactEntry[K, V])
+	return ent.key, ent.value, true
+}
+
+// Len returns the number of items in the cache.
+func (c *ExactLRU[K, V]) Len() int {
+	return c.evictList.Len()
+}
+
+// Resize changes the cache size.
+func (c *ExactLRU[K, V]) Resize(size int) (evicted int) {
+	diff := c.Len() - size
+	if diff < 0 {
+		diff = 0
+	}
+	for i := 0; i < diff; i++ {
+		c.RemoveOldest()
+	}
+	c.size = size
+	return diff
+}
+
+func (c *ExactLRU[K, V]) removeElement(e *list.Element) {
+	c.evictList.Remove(e)
+	ent := e.Value.(*exactEntry[K, V])
+	delete(c.items, ent.key)
+	if c.onEvict != nil {
+		c.onEvict(ent.key, ent.value)
+	}
+}
@@ -0,0 +1,59 @@
+package simplelru
+
+import (
+	"testing"
+)
+
+var _ LRUCache[int, int] = (*ExactLRU[int, int])(nil)
+
+func TestExactLRU(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k, v int) {
+		if k != v {
+			t.Fatalf("Evict values not equal (%v!=%v)", k, v)
+		}
+		evictCounter++
+	}
+	l, err := NewExactLRU[int, int](128, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 256; i++ {
+		l.Add(i, i)
+	}
+	if l.Len() != 128 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if evictCounter != 128 {
+		t.Fatalf("bad evict count: %v", evictCounter)
+	}
+
+	for i := 0; i < 128; i++ {
+		if _, ok := l.Get(i); ok {
+			t.Fatalf("%d should be evicted", i)
+		}
+	}
+	for i := 128; i < 256; i++ {
+		if _, ok := l.Get(i); !ok {
+			t.Fatalf("%d should not be evicted", i)
+		}
+	}
+
+	l.Get(128)
+	if k, _, ok := l.RemoveOldest(); !ok || k != 129 {
+		t.Fatalf("expected 129 to be oldest, got %v", k)
+	}
+
+	if evicted := l.Resize(64); evicted != 63 {
+		t.Fatalf("expected 63 evictions, got %d", evicted)
+	}
+	if !l.Contains(128) || l.Contains(130) {
+		t.Fatalf("resize should have evicted the oldest entries")
+	}
+
+	l.Purge()
+	if l.Len() != 0 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+}
@@ -0,0 +1,158 @@
+package simplelru
+
+import (
+	"container/heap"
+
+	"golang.org/x/exp/slices"
+)
+
+// OldestN returns (up to) the n least recently used entries, least
+// recently used first.  It visits every entry but only sorts the n it
+// keeps, so it is much cheaper than sorting a Snapshot when n is small.
+func (c *LRU[K, V]) OldestN(n int) []Entry[K, V] {
+	return c.extremes(n, func(a, b int64) bool { return a < b })
+}
+
+// NewestN returns (up to) the n most recently used entries, most recently
+// used first.  See OldestN.
+func (c *LRU[K, V]) NewestN(n int) []Entry[K, V] {
+	return c.extremes(n, func(a, b int64) bool { return a > b })
+}
+
+// extremes returns the n entries whose LastUsed sorts first by before, in
+// that order.
+func (c *LRU[K, V]) extremes(n int, before func(a, b int64) bool) []Entry[K, V] {
+	if n <= 0 {
+		return nil
+	}
+	h := &entryHeap[K, V]{before: before}
+	for i := range c.data {
+		ent := &c.data[i]
+		if ent.lastUsed == 0 || c.invalidated(i) {
+			continue
+		}
+		e := Entry[K, V]{ent.key, ent.value, ent.lastUsed}
+		if len(h.entries) < n {
+			heap.Push(h, e)
+		} else if before(e.LastUsed, h.entries[0].LastUsed) {
+			h.entries[0] = e
+			heap.Fix(h, 0)
+		}
+	}
+	slices.SortFunc(h.entries, func(a, b Entry[K, V]) bool {
+		return before(a.LastUsed, b.LastUsed)
+	})
+	return h.entries
+}
+
+// entryHeap keeps the entry that sorts last by before at its root, so
+// that it can be replaced by one that sorts earlier.
+type entryHeap[K comparable, V any] struct {
+	entries []Entry[K, V]
+	before  func(a, b int64) bool
+}
+
+func (h *entryHeap[K, V]) Len() int { return len(h.entries) }
+
+func (h *entryHeap[K, V]) Less(i, j int) bool {
+	return h.before(h.entries[j].LastUsed, h.entries[i].LastUsed)
+}
+
+func (h *entryHeap[K, V]) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+}
+
+func (h *entryHeap[K, V]) Push(x any) {
+	h.entries = append(h.entries, x.(Entry[K, V]))
+}
+
+func (h *entryHeap[K, V]) Pop() any {
+	e := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	return e
+}
+
+// EvictionOrder returns an iterator over the cache's entries in the order
+// an exact LRU would evict them, least recently used first.  The cache
+// evicts the oldest of a random sample rather than the oldest overall, so
+// this is the order it approximates: entries near the front are the
+// likeliest to go next.  The entries are copied when EvictionOrder is
+// called, so the cache can change while they are iterated, and are
+// sorted lazily, so stopping after a few costs little more than the copy.
+// The iterator has the shape of iter.Seq, for range-over-func.
+func (c *LRU[K, V]) EvictionOrder() func(yield func(Entry[K, V]) bool) {
+	// a heap on which before sorts newer first keeps the oldest at its root
+	h := &entryHeap[K, V]{before: func(a, b int64) bool { return a > b }}
+	for i := range c.data {
+		ent := &c.data[i]
+		if ent.lastUsed == 0 || c.invalidated(i) {
+			continue
+		}
+		h.entries = append(h.entries, Entry[K, V]{ent.key, ent.value, ent.lastUsed})
+	}
+	heap.Init(h)
+	return func(yield func(Entry[K, V]) bool) {
+		// iterate a copy of the heap, so the iterator can be reused
+		h := &entryHeap[K, V]{entries: slices.Clone(h.entries), before: h.before}
+		for h.Len() > 0 {
+			if !yield(heap.Pop(h).(Entry[K, V])) {
+				return
+			}
+		}
+	}
+}
+
+// EvictionCandidate is an entry that eviction would choose, as returned
+// by EvictionCandidates.
+type EvictionCandidate[K comparable, V any] struct {
+	Entry[K, V]
+	// Rank is the number of entries in the cache used less recently than
+	// this one: 0 for the least recently used entry, which an exact LRU
+	// would evict.
+	Rank int
+}
+
+// EvictionCandidates runs the victim sampling n times, as n evictions
+// from the cache as it stands would, and returns the entries chosen,
+// without evicting them, for checking how close WithProbes gets to exact
+// LRU on real data.  Each sample is independent, so an entry may be
+// chosen more than once, and samples that choose a free slot, which an
+// eviction would fill instead, are left out.  It costs a sort of the
+// cache's recency, so is meant for debugging rather than serving.  Its
+// samples aren't counted in Stats.
+func (c *LRU[K, V]) EvictionCandidates(n int) []EvictionCandidate[K, V] {
+	stats := c.ext.stats
+	defer func() {
+		c.ext.stats.VictimSearches = stats.VictimSearches
+		c.ext.stats.Probes = stats.Probes
+		c.ext.stats.EmptyProbes = stats.EmptyProbes
+	}()
+	var candidates []EvictionCandidate[K, V]
+	for j := 0; j < n; j++ {
+		off := c.findVictim()
+		if off < 0 {
+			return nil
+		}
+		ent := &c.data[off]
+		if ent.lastUsed == 0 || c.invalidated(off) {
+			continue
+		}
+		candidates = append(candidates, EvictionCandidate[K, V]{
+			Entry: Entry[K, V]{ent.key, ent.value, ent.lastUsed},
+		})
+	}
+	if len(candidates) == 0 {
+		return candidates
+	}
+	var ages []int64
+	for i := range c.data {
+		if c.data[i].lastUsed != 0 && !c.invalidated(i) {
+			ages = append(ages, c.data[i].lastUsed)
+		}
+	}
+	slices.Sort(ages)
+	for i := range candidates {
+		candidates[i].Rank, _ = slices.BinarySearch(ages, candidates[i].LastUsed)
+	}
+	return candidates
+}
@@ -0,0 +1,135 @@
+package simplelru
+
+import "testing"
+
+func TestOldestNewestN(t *testing.T) {
+	l, err := NewLRU[int, int](128, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if l.OldestN(4) != nil {
+		t.Fatalf("expected no entries")
+	}
+	for i := 0; i < 256; i++ {
+		l.Add(i, i)
+	}
+	// touch a few old entries so they become the newest
+	for i := 200; i < 203; i++ {
+		l.Get(i)
+	}
+
+	newest := l.NewestN(5)
+	want := []int{202, 201, 200, 255, 254}
+	if len(newest) != len(want) {
+		t.Fatalf("bad newest: %v", newest)
+	}
+	for i, e := range newest {
+		if e.Key != want[i] {
+			t.Fatalf("bad newest: %v", newest)
+		}
+	}
+
+	all := l.Entries()
+	oldest := l.OldestN(10)
+	if len(oldest) != 10 {
+		t.Fatalf("bad oldest: %v", oldest)
+	}
+	for i, e := range oldest {
+		if e != all[i] {
+			t.Fatalf("%d: expected %v, got %v", i, all[i], e)
+		}
+	}
+	if n := len(l.OldestN(1000)); n != l.Len() {
+		t.Fatalf("expected every entry, got %d", n)
+	}
+
+	l.InvalidateAll()
+	if l.NewestN(5) != nil {
+		t.Fatalf("expected invalidated entries to be skipped")
+	}
+}
+
+func TestEvictionOrder(t *testing.T) {
+	l, err := NewLRU[int, int](128, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 256; i++ {
+		l.Add(i, i)
+	}
+	l.Get(l.OldestN(1)[0].Key)
+
+	all := l.Entries()
+	order := l.EvictionOrder()
+	// the iterator reflects the cache as of the call
+	l.Add(1000, 1000)
+
+	var got []Entry[int, int]
+	order(func(e Entry[int, int]) bool {
+		got = append(got, e)
+		return true
+	})
+	if len(got) != len(all) {
+		t.Fatalf("expected %d entries, got %d", len(all), len(got))
+	}
+	for i, e := range got {
+		if e != all[i] {
+			t.Fatalf("%d: expected %v, got %v", i, all[i], e)
+		}
+	}
+
+	n := 0
+	order(func(e Entry[int, int]) bool {
+		if e != all[n] {
+			t.Fatalf("%d: expected %v on reuse, got %v", n, all[n], e)
+		}
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Fatalf("expected iteration to stop after 3 entries, got %d", n)
+	}
+}
+
+func TestEvictionCandidates(t *testing.T) {
+	l, err := NewLRU[int, int](128, nil, WithProbes[int, int](128))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if c := l.EvictionCandidates(4); c != nil {
+		t.Fatalf("expected no candidates from an empty cache, got %v", c)
+	}
+	for i := 0; i < 128; i++ {
+		l.Add(i, i)
+	}
+
+	// sampling every slot finds the least recently used entry
+	candidates := l.EvictionCandidates(4)
+	if len(candidates) != 4 {
+		t.Fatalf("expected 4 candidates, got %v", candidates)
+	}
+	for _, c := range candidates {
+		if c.Key != 0 || c.Rank != 0 {
+			t.Fatalf("expected the oldest entry, got %+v", c)
+		}
+	}
+	if l.Len() != 128 || !l.Contains(0) {
+		t.Fatalf("expected nothing to be evicted")
+	}
+
+	l2, err := NewLRU[int, int](128, nil, WithProbes[int, int](4))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 128; i++ {
+		l2.Add(i, i)
+	}
+	for _, c := range l2.EvictionCandidates(64) {
+		if c.Rank != c.Key {
+			t.Fatalf("expected rank to match insertion order, got %+v", c)
+		}
+		if c.Rank > 128-4 {
+			t.Fatalf("a candidate can't be newer than all but 3 others: %+v", c)
+		}
+	}
+}
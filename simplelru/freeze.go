@@ -0,0 +1,22 @@
+package simplelru
+
+// Freeze puts the LRU in a read-only mode, for taking a consistent
+// snapshot, draining traffic from it, or examining a misbehaving instance
+// as it was: until Thaw is called, Add, WarmUp, Remove, RemoveFunc,
+// RemoveOldest, Purge, InvalidateAll, Resize and Trim do nothing, and
+// Get and GetRef don't update entries' recency.  Lookups are still
+// counted in Stats.  Release still drops everything, so that a frozen
+// cache can be discarded.
+func (c *LRU[K, V]) Freeze() {
+	c.ext.frozen = true
+}
+
+// Thaw undoes Freeze.
+func (c *LRU[K, V]) Thaw() {
+	c.ext.frozen = false
+}
+
+// Frozen reports whether the LRU is frozen.
+func (c *LRU[K, V]) Frozen() bool {
+	return c.ext.frozen
+}
@@ -0,0 +1,56 @@
+package simplelru
+
+import "testing"
+
+func TestLRU_Freeze(t *testing.T) {
+	evicted := 0
+	l, err := NewLRU[int, int](4, func(int, int) { evicted++ })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+	l.Freeze()
+	if !l.Frozen() {
+		t.Fatalf("expected the LRU to be frozen")
+	}
+	before := l.Entries()
+
+	if l.Add(4, 4) || l.Contains(4) {
+		t.Fatalf("expected Add to do nothing")
+	}
+	if l.Remove(0) || !l.Contains(0) {
+		t.Fatalf("expected Remove to do nothing")
+	}
+	if n := l.RemoveFunc(func(int, int) bool { return true }); n != 0 {
+		t.Fatalf("expected RemoveFunc to do nothing, removed %d", n)
+	}
+	if _, _, ok := l.RemoveOldest(); ok {
+		t.Fatalf("expected RemoveOldest to do nothing")
+	}
+	l.Purge()
+	l.InvalidateAll()
+	l.Resize(1)
+	l.WarmUp([]Entry[int, int]{{Key: 5, Value: 5}})
+	if v, ok := l.Get(0); !ok || v != 0 {
+		t.Fatalf("expected Get to find 0")
+	}
+	after := l.Entries()
+	if len(after) != len(before) || l.Cap() != 4 || evicted != 0 {
+		t.Fatalf("expected the LRU to be unchanged: %v, %v", before, after)
+	}
+	for i := range before {
+		if before[i] != after[i] {
+			t.Fatalf("expected recency to be unchanged: %v, %v", before, after)
+		}
+	}
+	if s := l.Stats(); s.Hits != 1 {
+		t.Fatalf("expected lookups to be counted: %+v", s)
+	}
+
+	l.Thaw()
+	if l.Frozen() || !l.Add(4, 4) || !l.Contains(4) {
+		t.Fatalf("expected Add to work after Thaw")
+	}
+}
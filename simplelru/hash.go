@@ -0,0 +1,76 @@
+package simplelru
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// HashKey returns a 64-bit hash of key that is stable across processes,
+// unlike hash/maphash, so that it can be recorded in traces and compared
+// offline.  Strings, booleans and numeric types are hashed directly; other
+// key types are hashed via their %#v formatting, which is much slower.
+func HashKey[K comparable](key K) uint64 {
+	switch k := any(key).(type) {
+	case string:
+		return hashString(k)
+	case bool:
+		if k {
+			return hashUint64(1)
+		}
+		return hashUint64(0)
+	case int:
+		return hashUint64(uint64(k))
+	case int8:
+		return hashUint64(uint64(k))
+	case int16:
+		return hashUint64(uint64(k))
+	case int32:
+		return hashUint64(uint64(k))
+	case int64:
+		return hashUint64(uint64(k))
+	case uint:
+		return hashUint64(uint64(k))
+	case uint8:
+		return hashUint64(uint64(k))
+	case uint16:
+		return hashUint64(uint64(k))
+	case uint32:
+		return hashUint64(uint64(k))
+	case uint64:
+		return hashUint64(k)
+	case uintptr:
+		return hashUint64(uint64(k))
+	case float32:
+		return hashUint64(math.Float64bits(float64(k)))
+	case float64:
+		return hashUint64(math.Float64bits(k))
+	default:
+		return hashString(fmt.Sprintf("%#v", key))
+	}
+}
+
+// hashString is 64-bit FNV-1a.
+func hashString(s string) uint64 {
+	h := uint64(fnvOffset64)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime64
+	}
+	return h
+}
+
+// hashUint64 is 64-bit FNV-1a over the little-endian bytes of n.
+func hashUint64(n uint64) uint64 {
+	h := uint64(fnvOffset64)
+	for i := 0; i < 8; i++ {
+		h ^= n & 0xff
+		h *= fnvPrime64
+		n >>= 8
+	}
+	return h
+}
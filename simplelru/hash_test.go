@@ -0,0 +1,29 @@
+package simplelru
+
+import (
+	"hash/fnv"
+	"testing"
+)
+
+func TestHashKey(t *testing.T) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("hello"))
+	if HashKey("hello") != h.Sum64() {
+		t.Errorf("string hash should be FNV-1a")
+	}
+
+	if HashKey(1) == HashKey(2) {
+		t.Errorf("expected distinct hashes")
+	}
+	if HashKey(int64(42)) != HashKey(uint64(42)) {
+		t.Errorf("expected integer hashes to agree regardless of type")
+	}
+
+	type point struct{ x, y int }
+	if HashKey(point{1, 2}) != HashKey(point{1, 2}) {
+		t.Errorf("expected struct hashes to be deterministic")
+	}
+	if HashKey(point{1, 2}) == HashKey(point{2, 1}) {
+		t.Errorf("expected distinct struct hashes")
+	}
+}
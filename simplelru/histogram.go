@@ -0,0 +1,76 @@
+package simplelru
+
+import (
+	"math"
+	"math/bits"
+)
+
+// HistogramBuckets is the number of buckets in a Histogram.
+const HistogramBuckets = 65
+
+// Histogram is a coarse histogram of non-negative values with
+// power-of-two bucket boundaries: bucket 0 counts zeros, and bucket i
+// counts values in [2^(i-1), 2^i).  Recording is a couple of instructions,
+// which makes it cheap enough to leave on by default.
+type Histogram struct {
+	Buckets [HistogramBuckets]uint64
+}
+
+// Record adds a single value to the histogram.
+func (h *Histogram) Record(v uint64) {
+	h.Buckets[HistogramBucket(v)]++
+}
+
+// HistogramBucket returns the index of the bucket v is counted in.
+func HistogramBucket(v uint64) int {
+	return bits.Len64(v)
+}
+
+// Merge adds the counts in other to h.
+func (h *Histogram) Merge(other *Histogram) {
+	for i, n := range other.Buckets {
+		h.Buckets[i] += n
+	}
+}
+
+// Count returns the number of recorded values.
+func (h *Histogram) Count() uint64 {
+	var n uint64
+	for _, b := range h.Buckets {
+		n += b
+	}
+	return n
+}
+
+// Quantile returns an upper bound on the q-th quantile (0 <= q <= 1) of
+// the recorded values: the upper boundary of the bucket containing it.
+// It returns 0 for an empty histogram.
+func (h *Histogram) Quantile(q float64) uint64 {
+	total := h.Count()
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(total)))
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for i, b := range h.Buckets {
+		seen += b
+		if seen >= rank {
+			return bucketUpperBound(i)
+		}
+	}
+	return math.MaxUint64
+}
+
+// bucketUpperBound returns the largest value that falls into bucket i.
+func bucketUpperBound(i int) uint64 {
+	if i == 0 {
+		return 0
+	}
+	if i >= 64 {
+		return math.MaxUint64
+	}
+	return 1<<uint(i) - 1
+}
@@ -0,0 +1,38 @@
+package simplelru
+
+import (
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	var h Histogram
+	if h.Quantile(0.5) != 0 {
+		t.Errorf("expected 0 for an empty histogram")
+	}
+
+	h.Record(0)
+	for i := 0; i < 98; i++ {
+		h.Record(5)
+	}
+	h.Record(1000)
+
+	if h.Count() != 100 {
+		t.Errorf("bad count: %d", h.Count())
+	}
+	if q := h.Quantile(0); q != 0 {
+		t.Errorf("bad min: %d", q)
+	}
+	if q := h.Quantile(0.5); q != 7 {
+		t.Errorf("bad median: %d", q)
+	}
+	if q := h.Quantile(1); q != 1023 {
+		t.Errorf("bad max: %d", q)
+	}
+
+	var merged Histogram
+	merged.Merge(&h)
+	merged.Merge(&h)
+	if merged.Count() != 200 || merged.Quantile(0.5) != 7 {
+		t.Errorf("bad merge: %v", merged.Buckets)
+	}
+}
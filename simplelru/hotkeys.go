@@ -0,0 +1,168 @@
+package simplelru
+
+import (
+	"container/heap"
+
+	"golang.org/x/exp/slices"
+)
+
+// HotKey is a frequently accessed key, as estimated by the sketch enabled
+// with WithHotKeys.  Count may overestimate the true number of accesses by
+// at most Error.
+type HotKey[K comparable] struct {
+	Key   K
+	Count uint64
+	Error uint64
+}
+
+// hotKeys is a space-saving heavy-hitter sketch: it tracks a fixed number
+// of counters, and when an untracked key shows up it replaces the key with
+// the smallest count, inheriting that count as its error bound.  The
+// counters are kept in a min-heap so that replacement is O(log n).
+type hotKeys[K comparable] struct {
+	counters []HotKey[K]
+	index    map[K]int
+	capacity int
+}
+
+func newHotKeys[K comparable](capacity int) *hotKeys[K] {
+	return &hotKeys[K]{
+		counters: make([]HotKey[K], 0, capacity),
+		index:    make(map[K]int, capacity),
+		capacity: capacity,
+	}
+}
+
+func (h *hotKeys[K]) record(key K) {
+	if i, ok := h.index[key]; ok {
+		h.counters[i].Count++
+		heap.Fix(h, i)
+		return
+	}
+	if len(h.counters) < h.capacity {
+		heap.Push(h, HotKey[K]{Key: key, Count: 1})
+		return
+	}
+	least := h.counters[0]
+	delete(h.index, least.Key)
+	h.counters[0] = HotKey[K]{Key: key, Count: least.Count + 1, Error: least.Count}
+	h.index[key] = 0
+	heap.Fix(h, 0)
+}
+
+// decay halves every counter and its error bound, dropping those that
+// reach zero, so that keys that were hot long ago give way to keys that
+// are hot now.  Halving keeps the counters in heap order.
+func (h *hotKeys[K]) decay() {
+	for i := range h.counters {
+		h.counters[i].Count /= 2
+		h.counters[i].Error /= 2
+	}
+	for len(h.counters) > 0 && h.counters[0].Count == 0 {
+		heap.Pop(h)
+	}
+}
+
+// top returns up to k of the tracked keys, most frequent first.
+func (h *hotKeys[K]) top(k int) []HotKey[K] {
+	if k <= 0 {
+		return nil
+	}
+	result := make([]HotKey[K], len(h.counters))
+	copy(result, h.counters)
+	sortHotKeys(result)
+	if k < len(result) {
+		result = result[:k]
+	}
+	return result
+}
+
+func (h *hotKeys[K]) Len() int           { return len(h.counters) }
+func (h *hotKeys[K]) Less(i, j int) bool { return h.counters[i].Count < h.counters[j].Count }
+
+func (h *hotKeys[K]) Swap(i, j int) {
+	h.counters[i], h.counters[j] = h.counters[j], h.counters[i]
+	h.index[h.counters[i].Key] = i
+	h.index[h.counters[j].Key] = j
+}
+
+func (h *hotKeys[K]) Push(x any) {
+	hk := x.(HotKey[K])
+	h.index[hk.Key] = len(h.counters)
+	h.counters = append(h.counters, hk)
+}
+
+func (h *hotKeys[K]) Pop() any {
+	n := len(h.counters) - 1
+	hk := h.counters[n]
+	h.counters = h.counters[:n]
+	delete(h.index, hk.Key)
+	return hk
+}
+
+// sortHotKeys sorts keys in descending order of Count.
+func sortHotKeys[K comparable](keys []HotKey[K]) {
+	slices.SortFunc(keys, func(a, b HotKey[K]) bool {
+		return a.Count > b.Count
+	})
+}
+
+// MergeHotKeys combines the HotKeys results of several disjoint caches
+// (such as the shards of a sharded cache), returning the k most frequent.
+func MergeHotKeys[K comparable](k int, lists ...[]HotKey[K]) []HotKey[K] {
+	if k <= 0 {
+		return nil
+	}
+	var merged []HotKey[K]
+	for _, list := range lists {
+		merged = append(merged, list...)
+	}
+	sortHotKeys(merged)
+	if k < len(merged) {
+		merged = merged[:k]
+	}
+	return merged
+}
+
+// HotKeys returns up to k of the most frequently looked-up keys, most
+// frequent first.  It returns nil unless the LRU was created WithHotKeys.
+func (c *LRU[K, V]) HotKeys(k int) []HotKey[K] {
+	if c.ext.hot == nil {
+		return nil
+	}
+	return c.ext.hot.top(k)
+}
+
+// WithHotKeysDecay halves the counts tracked WithHotKeys after every
+// every lookups, so that they measure recent rather than lifetime
+// popularity: without it, a key that was extremely hot long ago outranks
+// one that is moderately hot now indefinitely, for example after traffic
+// shifts.  A key's weight halves every every lookups, so counts reflect
+// roughly the last 2*every lookups.
+func WithHotKeysDecay[K comparable, V any](every int) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		c.ext.hotDecay = every
+	}
+}
+
+// DecayHotKeys halves the counts tracked WithHotKeys now, for callers
+// that would rather decay them on a timer than WithHotKeysDecay.
+func (c *LRU[K, V]) DecayHotKeys() {
+	if c.ext.hot != nil {
+		c.ext.hot.decay()
+		c.ext.hotSince = 0
+	}
+}
+
+// recordHot counts a lookup of key WithHotKeys, decaying the counts if
+// it's time.
+func (x *extension[K, V]) recordHot(key K) {
+	x.hot.record(key)
+	if x.hotDecay > 0 {
+		x.hotSince++
+		if x.hotSince >= x.hotDecay {
+			x.hot.decay()
+			x.hotSince = 0
+		}
+	}
+}
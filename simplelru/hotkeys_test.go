@@ -0,0 +1,109 @@
+package simplelru
+
+import (
+	"testing"
+)
+
+func TestLRU_HotKeys(t *testing.T) {
+	l, err := NewLRU[int, int](128, nil, WithHotKeys[int, int](32))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// keys 0-3 are hot, and 100+ are each looked up once
+	for i := 0; i < 1000; i++ {
+		l.Get(i % 4)
+		l.Get(100 + i)
+	}
+	l.Get(0)
+
+	hot := l.HotKeys(4)
+	if len(hot) != 4 {
+		t.Fatalf("expected 4 hot keys, got %d", len(hot))
+	}
+	if hot[0].Key != 0 {
+		t.Errorf("expected 0 to be the hottest key, got %v", hot[0].Key)
+	}
+	for _, hk := range hot {
+		if hk.Key >= 4 {
+			t.Errorf("unexpected hot key %v", hk.Key)
+		}
+		if hk.Count < 250 || hk.Count-hk.Error > 251 {
+			t.Errorf("bad count for %v: %+v", hk.Key, hk)
+		}
+	}
+	if hot := l.HotKeys(0); hot != nil {
+		t.Errorf("expected no hot keys for k=0, got %+v", hot)
+	}
+	if hot := l.HotKeys(-1); hot != nil {
+		t.Errorf("expected no hot keys for k=-1, got %+v", hot)
+	}
+}
+
+func TestLRU_HotKeysDecay(t *testing.T) {
+	l, err := NewLRU[int, int](128, nil, WithHotKeys[int, int](8), WithHotKeysDecay[int, int](100))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// key 1 was very hot, and key 2 is moderately hot now
+	for i := 0; i < 1000; i++ {
+		l.Get(1)
+	}
+	for i := 0; i < 1000; i++ {
+		if i%4 == 0 {
+			l.Get(2)
+		} else {
+			l.Get(100 + i)
+		}
+	}
+	if hot := l.HotKeys(1); len(hot) != 1 || hot[0].Key != 2 {
+		t.Fatalf("expected 2 to be the hottest key, got %v", hot)
+	}
+
+	// DecayHotKeys decays on demand
+	l, err = NewLRU[int, int](128, nil, WithHotKeys[int, int](8))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		l.Get(1)
+	}
+	for i := 0; i < 500; i++ {
+		l.Get(2)
+	}
+	l.DecayHotKeys()
+	hot := l.HotKeys(2)
+	if len(hot) != 2 || hot[0].Count != 500 || hot[1].Count != 250 {
+		t.Fatalf("expected halved counts, got %v", hot)
+	}
+	for i := 0; i < 10; i++ {
+		l.DecayHotKeys()
+	}
+	if hot := l.HotKeys(2); len(hot) != 0 {
+		t.Fatalf("expected counts to decay away, got %v", hot)
+	}
+}
+
+func TestLRU_HotKeysDisabled(t *testing.T) {
+	l, err := NewLRU[int, int](128, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Get(1)
+	if hot := l.HotKeys(10); hot != nil {
+		t.Errorf("expected nil hot keys, got %v", hot)
+	}
+}
+
+func TestMergeHotKeys(t *testing.T) {
+	a := []HotKey[string]{{Key: "a", Count: 10}, {Key: "b", Count: 3}}
+	b := []HotKey[string]{{Key: "c", Count: 7}}
+	merged := MergeHotKeys(2, a, b)
+	if len(merged) != 2 || merged[0].Key != "a" || merged[1].Key != "c" {
+		t.Errorf("bad merge: %+v", merged)
+	}
+	if merged := MergeHotKeys(-1, a, b); merged != nil {
+		t.Errorf("expected no keys for k=-1, got %+v", merged)
+	}
+}
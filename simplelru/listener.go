@@ -0,0 +1,105 @@
+package simplelru
+
+import (
+	"log/slog"
+)
+
+// Listener is notified of cache activity, so that observability or
+// business logic can subscribe to cache behavior without the cache
+// depending on any particular metrics system.  Methods are called
+// synchronously while the cache is being modified, so they must be fast
+// and must not call back into the cache.
+type Listener[K comparable, V any] interface {
+	// OnHit is called when Get finds key.
+	OnHit(key K, value V)
+	// OnMiss is called when Get doesn't find key.
+	OnMiss(key K)
+	// OnAdd is called when a value is added or updated.
+	OnAdd(key K, value V)
+	// OnEvict is called when an entry is evicted to make room for
+	// another, but not when it is explicitly removed or purged.
+	OnEvict(key K, value V)
+}
+
+// NopListener implements Listener by ignoring every notification.  Embed
+// it in a struct to implement only the methods you care about.
+type NopListener[K comparable, V any] struct{}
+
+// OnHit implements Listener.
+func (NopListener[K, V]) OnHit(key K, value V) {}
+
+// OnMiss implements Listener.
+func (NopListener[K, V]) OnMiss(key K) {}
+
+// OnAdd implements Listener.
+func (NopListener[K, V]) OnAdd(key K, value V) {}
+
+// OnEvict implements Listener.
+func (NopListener[K, V]) OnEvict(key K, value V) {}
+
+func (x *extension[K, V]) notifyHit(key K, value V) {
+	for _, l := range x.listen {
+		x.onHit(l, key, value)
+	}
+}
+
+func (x *extension[K, V]) notifyMiss(key K) {
+	for _, l := range x.listen {
+		x.onMiss(l, key)
+	}
+}
+
+func (x *extension[K, V]) notifyAdd(key K, value V) {
+	for _, l := range x.listen {
+		x.onAdd(l, key, value)
+	}
+}
+
+func (x *extension[K, V]) notifyEvict(key K, value V) {
+	for _, l := range x.listen {
+		x.onEvict(l, key, value)
+	}
+}
+
+// onHit, onMiss, onAdd and onEvict call a single listener, recovering
+// from its panics if the LRU was created WithRecover.
+func (x *extension[K, V]) onHit(l Listener[K, V], key K, value V) {
+	if x.recover {
+		defer x.recovered("OnHit", key)
+	}
+	l.OnHit(key, value)
+}
+
+func (x *extension[K, V]) onMiss(l Listener[K, V], key K) {
+	if x.recover {
+		defer x.recovered("OnMiss", key)
+	}
+	l.OnMiss(key)
+}
+
+func (x *extension[K, V]) onAdd(l Listener[K, V], key K, value V) {
+	if x.recover {
+		defer x.recovered("OnAdd", key)
+	}
+	l.OnAdd(key, value)
+}
+
+func (x *extension[K, V]) onEvict(l Listener[K, V], key K, value V) {
+	if x.recover {
+		defer x.recovered("OnEvict", key)
+	}
+	l.OnEvict(key, value)
+}
+
+// recovered is deferred around a callback to recover from its panic and
+// log it, to the LRU's logger if it has one and the default logger
+// otherwise.
+func (x *extension[K, V]) recovered(callback string, key K) {
+	if r := recover(); r != nil {
+		logger := x.logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+		logger.Error("lru: callback panicked", "callback", callback, "key", key, "panic", r)
+	}
+}
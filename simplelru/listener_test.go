@@ -0,0 +1,113 @@
+package simplelru
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type countingListener struct {
+	NopListener[int, int]
+	hits, misses, adds, evicts int
+}
+
+func (l *countingListener) OnHit(key, value int) {
+	l.hits++
+}
+
+func (l *countingListener) OnMiss(key int) {
+	l.misses++
+}
+
+func (l *countingListener) OnAdd(key, value int) {
+	l.adds++
+}
+
+func (l *countingListener) OnEvict(key, value int) {
+	l.evicts++
+}
+
+func TestLRU_Listener(t *testing.T) {
+	a, b := &countingListener{}, &countingListener{}
+	l, err := NewLRU[int, int](2, nil, WithListener[int, int](a), WithListener[int, int](b))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(2, 3)
+	l.Get(1)
+	l.Get(4)
+	l.Add(3, 3)
+	l.Remove(3)
+	l.Purge()
+
+	for _, cl := range []*countingListener{a, b} {
+		if cl.hits != 1 || cl.misses != 1 || cl.adds != 4 || cl.evicts != 1 {
+			t.Errorf("bad counts: %+v", *cl)
+		}
+	}
+}
+
+type orderListener struct {
+	NopListener[int, int]
+	events []string
+}
+
+func (l *orderListener) OnAdd(key, value int) {
+	l.events = append(l.events, "add")
+}
+
+func (l *orderListener) OnEvict(key, value int) {
+	l.events = append(l.events, "evict")
+}
+
+func TestLRU_ListenerOrder(t *testing.T) {
+	ol := &orderListener{}
+	l, err := NewLRU[int, int](1, nil, WithListener[int, int](ol))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+
+	// the eviction Add causes is reported before the addition
+	if got := strings.Join(ol.events, ","); got != "add,evict,add" {
+		t.Fatalf("bad order: %s", got)
+	}
+}
+
+type panickyListener struct {
+	NopListener[int, int]
+}
+
+func (panickyListener) OnAdd(key, value int) {
+	panic("boom")
+}
+
+func TestLRU_WithRecover(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	l, err := NewLRU[int, int](1, func(k, v int) { panic("bang") },
+		WithListener[int, int](panickyListener{}), WithRecover[int, int](), WithLogger[int, int](logger))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+	if v, ok := l.Get(2); !ok || v != 2 || l.Contains(1) {
+		t.Fatalf("expected adds to complete despite panics")
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	out := buf.String()
+	if n := strings.Count(out, "callback=OnAdd"); n != 2 {
+		t.Errorf("expected 2 OnAdd panics to be logged, got %d:\n%s", n, out)
+	}
+	if n := strings.Count(out, "callback=onEvict"); n != 1 {
+		t.Errorf("expected 1 onEvict panic to be logged, got %d:\n%s", n, out)
+	}
+}
@@ -1,30 +1,59 @@
 package simplelru
 
 import (
-	crand "crypto/rand"
-	"encoding/binary"
 	"errors"
+	"fmt"
+	"log/slog"
 	"math/rand"
+	"time"
 
 	"golang.org/x/exp/slices"
 )
 
-func newRand() *rand.Rand {
-	seedBytes := make([]byte, 8)
-	if _, err := crand.Read(seedBytes); err != nil {
-		panic(err)
-	}
-	seed := binary.LittleEndian.Uint64(seedBytes)
-
-	return rand.New(rand.NewSource(int64(seed)))
-}
-
 // EvictCallback is used to get a callback when a cache entry is evicted
 type EvictCallback[K comparable, V any] func(key K, value V)
 
+// EvictReason describes why an entry was evicted.
+type EvictReason uint8
+
+const (
+	// EvictCapacity means the entry was evicted to make room for a new
+	// one.
+	EvictCapacity EvictReason = iota + 1
+	// EvictResize means the entry was evicted because the cache was
+	// resized smaller.
+	EvictResize
+	// EvictRemove means the entry was removed by Remove or RemoveFunc.
+	// It's only given to the callback given WithOnEvictInfo.
+	EvictRemove
+	// EvictPurge means the entry was removed by Purge.  It's only given
+	// to the callback given WithOnEvictInfo.
+	EvictPurge
+	// EvictQuota means the entry was evicted to keep its class within
+	// the quota given WithQuotas.
+	EvictQuota
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictResize:
+		return "resize"
+	case EvictRemove:
+		return "remove"
+	case EvictPurge:
+		return "purge"
+	case EvictQuota:
+		return "quota"
+	default:
+		return "unknown"
+	}
+}
+
 // TODO: move this to a file that is built only on 64-bit architectures and
 // calculate the right size for 32-byte architectures
-const LRUStructSize = 104
+const LRUStructSize = 72
 
 // LRU implements a non-thread safe fixed size LRU cache
 type LRU[K comparable, V any] struct {
@@ -32,12 +61,92 @@ type LRU[K comparable, V any] struct {
 	data    []entry[K, V]
 	counter int64
 	size    int64
-	rng     rand.Rand
+	rng     *rand.Rand
 	onEvict EvictCallback[K, V]
+	ext     *extension[K, V]
+}
+
+// extension holds bookkeeping that isn't needed to find or evict an
+// entry.  It lives behind a pointer so that LRU stays small enough to
+// pack into cache-line sized shards.
+type extension[K comparable, V any] struct {
+	stats    Stats
+	window   hitWindow
+	classify func(key K) string
+	classes  map[string]*ClassStats
+	hot      *hotKeys[K]
+	// hotDecay is given WithHotKeysDecay, and hotSince counts the
+	// lookups since hot was last decayed.
+	hotDecay int
+	hotSince int
+	trace    *tracer
+	mrc      *MissRatioCurve
+	probes   int
+	logger   *slog.Logger
+	listen   []Listener[K, V]
+	sampler  *evictionSampler[K]
+	// entries last used before floor were invalidated by InvalidateAll,
+	// and stale of them haven't been overwritten yet.
+	floor int64
+	stale int
+	// pins counts how many times each pinned key has been pinned, and
+	// pinsUntil holds when the pin given PinFor lapses, in Unix
+	// nanoseconds, for the keys that have one.
+	pins      map[K]int
+	pinsUntil map[K]int64
+	// recover is set by WithRecover.
+	recover bool
+	// headroom is given WithDeferredEviction.
+	headroom int64
+	// churn is set WithChurnTracking.
+	churn *churnTracker[K]
+	// admission is set by WithAdmissionLimiter, and maxValue and
+	// valueSize by WithMaxValueSize.
+	admission *AdmissionLimiter
+	maxValue  int
+	valueSize func(value V) int
+	// onAdmit is given WithOnAdmit.
+	onAdmit func(key K, value V, wouldEvict Entry[K, V]) bool
+	// onEvictInfo is given WithOnEvictInfo, and meta is what it's told
+	// of each entry.
+	onEvictInfo func(key K, value V, info EvictionInfo)
+	meta        map[K]entryMeta
+	// frozen is set by Freeze.
+	frozen bool
+	// displaced, if set, receives the entry evicted by the Add in
+	// progress, for AddReturningEvicted.
+	displaced *entry[K, V]
+	// accuracy is set WithAccuracyTracking.
+	accuracy *accuracyTracker[K]
+	// shadows are given WithShadow, by name.
+	shadows map[string]*Shadow
+	// costOf is given WithCost, costs is the cost it measured for each
+	// entry not invalidated, and cost is their total.
+	costOf func(key K, value V) int64
+	costs  map[K]int64
+	cost   int64
+	// costVictims is set WithCostAwareEviction.
+	costVictims bool
+	// quotas are given WithQuotas, and usage is what each class with a
+	// quota holds.
+	quotas map[string]Quota
+	usage  map[string]*quotaUsage
+	// clock maps ticks of the logical clock to wall-clock time.
+	clock wallClock
+	// reads is set WithSharedReads.
+	reads *readBuffer
 }
 
 const randomProbes = 8
 
+// Entry is a key-value pair along with when it was last used, in ticks of
+// the cache's logical clock.
+type Entry[K comparable, V any] struct {
+	Key      K
+	Value    V
+	LastUsed int64
+}
+
 // entry is used to hold a value in the evictList
 type entry[K comparable, V any] struct {
 	lastUsed int64
@@ -46,7 +155,7 @@ type entry[K comparable, V any] struct {
 }
 
 // NewLRU constructs an LRU of the given size
-func NewLRU[K comparable, V any](size int, onEvict EvictCallback[K, V]) (*LRU[K, V], error) {
+func NewLRU[K comparable, V any](size int, onEvict EvictCallback[K, V], opts ...Option[K, V]) (*LRU[K, V], error) {
 	if size <= 0 {
 		return nil, errors.New("must provide a positive size")
 	}
@@ -55,8 +164,13 @@ func NewLRU[K comparable, V any](size int, onEvict EvictCallback[K, V]) (*LRU[K,
 		items:   make(map[K]int, size),
 		counter: 1,
 		size:    int64(size),
-		rng:     *newRand(),
+		rng:     newRand(),
 		onEvict: onEvict,
+		ext:     &extension[K, V]{probes: randomProbes},
+	}
+	c.ext.clock.init(time.Now)
+	for _, opt := range opts {
+		opt(c)
 	}
 	return c, nil
 }
@@ -67,45 +181,241 @@ func (c *LRU[K, V]) getCounter() int64 {
 	if c.counter < 0 {
 		panic("counter overflow; won't happen in practice :rip:")
 	}
+	c.ext.clock.tick(n)
 	return n
 }
 
 // Purge is used to completely clear the cache.
 func (c *LRU[K, V]) Purge() {
+	if c.ext.frozen {
+		return
+	}
 	for k, i := range c.items {
+		if c.invalidated(i) {
+			continue
+		}
 		if c.onEvict != nil {
-			c.onEvict(k, c.data[i].value)
+			c.callOnEvict(k, c.data[i].value)
+		}
+		if c.ext.onEvictInfo != nil {
+			c.notifyEvictInfo(c.data[i], EvictPurge)
 		}
 	}
 	c.data = c.data[0:0]
 	c.items = make(map[K]int)
+	c.ext.stale = 0
+	c.ext.pins = nil
+	c.ext.pinsUntil = nil
+	c.ext.costs = nil
+	c.ext.resetChurn()
+	c.ext.resetMeta()
+	c.ext.resetAccuracy()
+	c.ext.resetQuotas()
+	c.ext.cost = 0
+}
+
+// Release drops every entry without calling the eviction callback, and
+// releases the memory held for them rather than keeping it for reuse as
+// Purge does.  It is for caches that are being discarded.
+func (c *LRU[K, V]) Release() {
+	c.data = nil
+	c.items = make(map[K]int)
+	c.ext.stale = 0
+	c.ext.pins = nil
+	c.ext.pinsUntil = nil
+	c.ext.costs = nil
+	c.ext.resetChurn()
+	c.ext.resetMeta()
+	c.ext.resetAccuracy()
+	c.ext.resetQuotas()
+	c.ext.cost = 0
+}
+
+// InvalidateAll makes every entry in the cache absent, in constant time:
+// rather than removing entries, it marks everything used so far as
+// invalid, and invalid entries are dropped as their slots are reused.
+// Unlike Purge, the eviction callback isn't called for them, and until
+// they are dropped they still hold on to their keys and values.
+func (c *LRU[K, V]) InvalidateAll() {
+	if c.ext.frozen {
+		return
+	}
+	c.ext.floor = c.counter
+	c.ext.stale = len(c.items)
+	c.ext.pins = nil
+	c.ext.pinsUntil = nil
+	c.ext.costs = nil
+	c.ext.resetChurn()
+	c.ext.resetMeta()
+	c.ext.resetAccuracy()
+	c.ext.resetQuotas()
+	c.ext.cost = 0
+}
+
+// invalidated reports whether the entry in slot i was invalidated by
+// InvalidateAll.
+func (c *LRU[K, V]) invalidated(i int) bool {
+	lastUsed := c.data[i].lastUsed
+	return lastUsed != 0 && lastUsed < c.ext.floor
+}
+
+// Pin protects key from eviction until it is unpinned as many times as it
+// was pinned, returning false if key isn't in the cache.  Pinned entries
+// can still be removed, which unpins them, and Resize ignores pins.  If
+// every entry the cache could evict is pinned, Add evicts a pinned entry
+// anyway rather than exceed the cache's size.
+func (c *LRU[K, V]) Pin(key K) bool {
+	if !c.Contains(key) {
+		return false
+	}
+	if c.ext.pins == nil {
+		c.ext.pins = make(map[K]int)
+	}
+	c.ext.pins[key]++
+	return true
+}
+
+// PinFor pins key as Pin does, but only for d, so that a pin the code
+// that took it forgets to undo doesn't protect key forever.  Pinning a key
+// for longer extends its pin, and pinning it for less leaves it as it is;
+// either way the key holds a single timed pin, which Unpin counts as a
+// call to Pin, and so can end early.  A lapsed pin is only noticed when
+// the entry is considered for eviction.  It returns false if key isn't
+// in the cache.
+func (c *LRU[K, V]) PinFor(key K, d time.Duration) bool {
+	if !c.Contains(key) {
+		return false
+	}
+	until := c.ext.clock.now().Add(d).UnixNano()
+	if old, ok := c.ext.pinsUntil[key]; ok {
+		c.ext.pinsUntil[key] = max(old, until)
+		return true
+	}
+	c.Pin(key)
+	if c.ext.pinsUntil == nil {
+		c.ext.pinsUntil = make(map[K]int64)
+	}
+	c.ext.pinsUntil[key] = until
+	return true
+}
+
+// Unpin undoes one call to Pin for key.
+func (c *LRU[K, V]) Unpin(key K) {
+	if n := c.ext.pins[key]; n > 1 {
+		c.ext.pins[key] = n - 1
+	} else {
+		delete(c.ext.pins, key)
+		delete(c.ext.pinsUntil, key)
+	}
+}
+
+// pinned reports whether slot i holds a pinned entry, unpinning it first
+// if its pin given PinFor has lapsed.
+func (c *LRU[K, V]) pinned(i int) bool {
+	ent := &c.data[i]
+	if ent.lastUsed == 0 || c.ext.pins[ent.key] == 0 {
+		return false
+	}
+	if until, ok := c.ext.pinsUntil[ent.key]; ok && c.ext.clock.now().UnixNano() >= until {
+		delete(c.ext.pinsUntil, ent.key)
+		c.Unpin(ent.key)
+		return c.ext.pins[ent.key] > 0
+	}
+	return true
+}
+
+// dropInvalidated empties slot i, which holds an invalidated entry.
+func (c *LRU[K, V]) dropInvalidated(i int) {
+	delete(c.items, c.data[i].key)
+	c.data[i] = entry[K, V]{}
+	c.ext.stale--
 }
 
 //go:noinline
 func (c *LRU[K, V]) shuffle() {
 	c.rng.Shuffle(len(c.data), func(i, j int) {
-		c.items[c.data[i].key] = j
-		c.items[c.data[j].key] = i
-
 		c.data[i], c.data[j] = c.data[j], c.data[i]
+
+		// slots emptied by Remove hold the zero key, which mustn't be
+		// indexed
+		if c.data[i].lastUsed != 0 {
+			c.items[c.data[i].key] = i
+		}
+		if c.data[j].lastUsed != 0 {
+			c.items[c.data[j].key] = j
+		}
 	})
 }
 
 // Add adds a value to the cache.  Returns true if an eviction occurred.
+// A new key that the limiter given WithAdmissionLimiter or the function
+// given WithOnAdmit rejects isn't added, and nor is a value larger than
+// WithMaxValueSize allows, which removes the key's old value instead.  A
+// new key whose class is at its quota given WithQuotas evicts from its
+// class rather than the cache as a whole.
 func (c *LRU[K, V]) Add(key K, value V) (evicted bool) {
+	if c.ext.frozen {
+		return false
+	}
+	if c.ext.valueSize != nil {
+		size := c.ext.valueSize(value)
+		c.ext.stats.ValueSize.Record(uint64(max(size, 0)))
+		if size > c.ext.maxValue {
+			c.ext.stats.Rejections++
+			c.Remove(key)
+			return false
+		}
+	}
 	now := c.getCounter()
+	c.ext.shadowAdd(key)
 	// Check for existing item
 	if i, ok := c.items[key]; ok {
+		wasInvalidated := c.invalidated(i)
 		entry := &c.data[i]
+		if wasInvalidated {
+			c.ext.stale--
+			c.ext.recordInsert(key, now)
+		} else {
+			c.ext.subCost(key)
+		}
+		c.ext.addCost(key, value)
 		entry.lastUsed = now
 		entry.value = value
+		c.ext.recordTrace(TraceAdd, key, !wasInvalidated)
+		c.ext.compareAdd(key)
+		c.ext.notifyAdd(key, value)
+		return false
+	}
+	if c.ext.admission != nil && int64(len(c.data)) >= c.size && !c.ext.admission.admit() {
+		c.ext.stats.Rejections++
 		return false
 	}
+	victim := -1
+	if c.ext.quotas != nil {
+		var ok bool
+		if victim, ok = c.quotaVictim(key, value); !ok {
+			return false
+		}
+	}
+	overQuota := victim >= 0
+	if c.ext.onAdmit != nil {
+		var ok bool
+		if victim, ok = c.admit(key, value, victim); !ok {
+			return false
+		}
+	}
+	c.ext.recordTrace(TraceAdd, key, false)
+	c.ext.recordInsert(key, now)
 
 	// Add new item
 	ent := entry[K, V]{now, key, value}
 
-	if int64(len(c.data)) < c.size {
+	if overQuota {
+		evicted = true
+		i := c.evictForQuota(key, value, victim)
+		c.data[i] = ent
+		c.items[key] = i
+	} else if int64(len(c.data)) < c.slots() {
 		i := len(c.data)
 		c.data = append(c.data, ent)
 		c.items[key] = i
@@ -117,57 +427,406 @@ func (c *LRU[K, V]) Add(key K, value V) (evicted bool) {
 		}
 	} else {
 		evicted = true
-		i := c.removeOldest()
+		if victim < 0 {
+			victim = c.findVictim()
+		}
+		i := c.removeVictim(victim)
 		c.data[i] = ent
 		c.items[key] = i
 	}
+	c.ext.addCost(key, value)
+	c.ext.compareAdd(key)
+	// notify after any eviction, so listeners see the cache's changes in
+	// the order they happened
+	c.ext.notifyAdd(key, value)
 
 	return
 }
 
+// AddReturningEvicted is Add, but returns the entry that adding key
+// evicted, if any, so that the caller can deal with it there and then,
+// such as by reusing its buffers, rather than in the eviction callback.
+// The callback is still called for it.  WithDeferredEviction, Add evicts
+// nothing until the cache overflows its headroom, and entries Trim evicts
+// aren't returned.
+func (c *LRU[K, V]) AddReturningEvicted(key K, value V) (evictedKey K, evictedValue V, evicted bool) {
+	var victim entry[K, V]
+	c.ext.displaced = &victim
+	c.Add(key, value)
+	c.ext.displaced = nil
+	if victim.lastUsed == 0 {
+		return evictedKey, evictedValue, false
+	}
+	return victim.key, victim.value, true
+}
+
 // Get looks up a key's value from the cache.
 func (c *LRU[K, V]) Get(key K) (value V, ok bool) {
-	if i, ok := c.items[key]; ok {
+	if i, ok := c.items[key]; ok && !c.invalidated(i) {
 		entry := &c.data[i]
-		entry.lastUsed = c.getCounter()
+		if !c.ext.frozen {
+			entry.lastUsed = c.getCounter()
+		}
+		c.ext.recordLookup(key, true)
+		c.ext.notifyHit(key, entry.value)
 		return entry.value, true
 	}
+	c.ext.recordLookup(key, false)
+	c.ext.notifyMiss(key)
 	return
 }
 
 // Contains checks if a key is in the cache, without updating the recent-ness
 // or deleting it for being stale.
 func (c *LRU[K, V]) Contains(key K) (ok bool) {
-	_, ok = c.items[key]
-	return ok
+	i, ok := c.items[key]
+	return ok && !c.invalidated(i)
 }
 
 // Peek returns the key value (or undefined if not found) without updating
 // the "recently used"-ness of the key.
 func (c *LRU[K, V]) Peek(key K) (value V, ok bool) {
-	if i, ok := c.items[key]; ok {
+	if i, ok := c.items[key]; ok && !c.invalidated(i) {
 		return c.data[i].value, true
 	}
 	return value, false
 }
 
+// GetRef is Get, but returns a pointer to the cached value instead of a
+// copy of it, for values large enough that copying them on every hit is
+// expensive.  The pointer aliases the cache's storage: writes through it
+// change the cached value, and it is only valid until the cache is next
+// modified by anything other than a lookup, since adding, removing,
+// resizing or compacting may move or overwrite the value.
+func (c *LRU[K, V]) GetRef(key K) (value *V, ok bool) {
+	if i, ok := c.items[key]; ok && !c.invalidated(i) {
+		entry := &c.data[i]
+		if !c.ext.frozen {
+			entry.lastUsed = c.getCounter()
+		}
+		c.ext.recordLookup(key, true)
+		if len(c.ext.listen) > 0 {
+			// don't copy the value unless there's someone to pass it to
+			c.ext.notifyHit(key, entry.value)
+		}
+		return &entry.value, true
+	}
+	c.ext.recordLookup(key, false)
+	c.ext.notifyMiss(key)
+	return nil, false
+}
+
+// PeekRef is Peek, but returns a pointer to the cached value, with the
+// same aliasing rules as GetRef.
+func (c *LRU[K, V]) PeekRef(key K) (value *V, ok bool) {
+	if i, ok := c.items[key]; ok && !c.invalidated(i) {
+		return &c.data[i].value, true
+	}
+	return nil, false
+}
+
 // Remove removes the provided key from the cache, returning if the
 // key was contained.
 func (c *LRU[K, V]) Remove(key K) (present bool) {
+	if c.ext.frozen {
+		return false
+	}
+	if c.ext.mrc != nil {
+		c.ext.mrc.recordRemove(HashKey(key))
+	}
+	c.ext.shadowRemove(key)
+	c.ext.compareRemove(key)
 	if i, ok := c.items[key]; ok {
-		c.removeElement(i, c.data[i])
+		if c.invalidated(i) {
+			c.dropInvalidated(i)
+			c.ext.recordTrace(TraceRemove, key, false)
+			return false
+		}
+		c.ext.recordTrace(TraceRemove, key, true)
+		c.removeElement(i, c.data[i], EvictRemove)
 		return true
 	}
+	c.ext.recordTrace(TraceRemove, key, false)
 	return false
 }
 
+// RemoveFunc removes every entry for which fn returns true, as Remove
+// would, and returns how many it removed.  It visits every entry, and fn
+// must not modify the cache.
+func (c *LRU[K, V]) RemoveFunc(fn func(key K, value V) bool) (removed int) {
+	if c.ext.frozen {
+		return 0
+	}
+	for i := range c.data {
+		ent := c.data[i]
+		if ent.lastUsed == 0 || c.invalidated(i) || !fn(ent.key, ent.value) {
+			continue
+		}
+		if c.ext.mrc != nil {
+			c.ext.mrc.recordRemove(HashKey(ent.key))
+		}
+		c.ext.shadowRemove(ent.key)
+		c.ext.compareRemove(ent.key)
+		c.ext.recordTrace(TraceRemove, ent.key, true)
+		c.removeElement(i, ent, EvictRemove)
+		removed++
+	}
+	return removed
+}
+
+// RemoveOldest evicts an old entry from the cache, chosen the same way
+// Add chooses an entry to evict, so it isn't necessarily the least
+// recently used one.  It is for callers that bound the cache by something
+// other than its number of entries, like total bytes.
+func (c *LRU[K, V]) RemoveOldest() (key K, value V, ok bool) {
+	if c.ext.frozen {
+		return
+	}
+	for c.Len() > 0 {
+		off := c.findVictim()
+		if c.data[off].lastUsed == 0 {
+			// the sample found an empty slot, so fall back to a scan
+			off = c.scanVictim()
+		}
+		if c.invalidated(off) {
+			c.dropInvalidated(off)
+			continue
+		}
+		ent := c.data[off]
+		c.evictElement(off, ent, EvictCapacity)
+		return ent.key, ent.value, true
+	}
+	return key, value, false
+}
+
 // Len returns the number of items in the cache.
 func (c *LRU[K, V]) Len() int {
-	return len(c.items)
+	return len(c.items) - c.ext.stale
+}
+
+// Entries returns a copy of the cache's entries in recency order, least
+// recently used first, without updating their recent-ness.
+func (c *LRU[K, V]) Entries() []Entry[K, V] {
+	return c.Snapshot().Entries()
+}
+
+// Snapshot is a copy of an LRU's entries at a point in time.
+type Snapshot[K comparable, V any] struct {
+	data  []entry[K, V]
+	floor int64
 }
 
-// Resize changes the cache size.
+// Snapshot copies the cache's entries without processing them, so that
+// callers holding a lock around the cache can release it quickly and
+// sort or serialize the entries afterwards.
+func (c *LRU[K, V]) Snapshot() Snapshot[K, V] {
+	return Snapshot[K, V]{slices.Clone(c.data), c.ext.floor}
+}
+
+// Entries returns the snapshot's entries in recency order, least recently
+// used first.
+func (s Snapshot[K, V]) Entries() []Entry[K, V] {
+	entries := make([]Entry[K, V], 0, len(s.data))
+	for i := range s.data {
+		entry := &s.data[i]
+		if entry.lastUsed == 0 || entry.lastUsed < s.floor {
+			continue
+		}
+		entries = append(entries, Entry[K, V]{entry.key, entry.value, entry.lastUsed})
+	}
+	slices.SortFunc(entries, func(a, b Entry[K, V]) bool {
+		return a.LastUsed < b.LastUsed
+	})
+	return entries
+}
+
+// WarmUp bulk-loads entries, ordered least recently used first, for
+// preloading a cache at startup.  Recency is assigned by position in
+// entries; their LastUsed fields are ignored.  If there are more entries
+// than fit, the least recently used are skipped.  Unlike Add, WarmUp
+// doesn't count, trace or notify anyone about what it inserts, and an
+// entry it displaces is dropped without calling the eviction callback.
+func (c *LRU[K, V]) WarmUp(entries []Entry[K, V]) {
+	if c.ext.frozen {
+		return
+	}
+	if skip := len(entries) - int(c.size); skip > 0 {
+		entries = entries[skip:]
+	}
+	if len(c.items) == 0 && len(entries) > 0 {
+		c.items = make(map[K]int, len(entries))
+	}
+	// the fill shuffle is deferred until the cache is full and we need
+	// to probe for a victim, or failing that until the end.
+	shuffled := int64(len(c.data)) == c.size
+	for _, e := range entries {
+		ent := entry[K, V]{c.getCounter(), e.Key, e.Value}
+		c.ext.compareAdd(e.Key)
+		if i, ok := c.items[e.Key]; ok {
+			if c.invalidated(i) {
+				c.ext.stale--
+				c.ext.recordInsert(e.Key, ent.lastUsed)
+			} else {
+				c.ext.subCost(e.Key)
+			}
+			c.ext.addCost(e.Key, e.Value)
+			c.data[i] = ent
+			continue
+		}
+		c.ext.addCost(e.Key, e.Value)
+		c.ext.recordInsert(e.Key, ent.lastUsed)
+		if int64(len(c.data)) < c.size {
+			c.items[e.Key] = len(c.data)
+			c.data = append(c.data, ent)
+			continue
+		}
+		if !shuffled {
+			c.shuffle()
+			shuffled = true
+		}
+		i := c.findVictim()
+		if c.invalidated(i) {
+			c.dropInvalidated(i)
+		} else if old := c.data[i]; old.lastUsed != 0 {
+			delete(c.items, old.key)
+			c.ext.subCost(old.key)
+			c.ext.forgetChurn(old.key)
+			c.ext.forgetMeta(old.key)
+		}
+		c.data[i] = ent
+		c.items[e.Key] = i
+	}
+	if !shuffled && int64(len(c.data)) == c.size {
+		c.shuffle()
+	}
+}
+
+// Cap returns the maximum number of items the cache can hold.
+func (c *LRU[K, V]) Cap() int {
+	return int(c.size)
+}
+
+// Range calls fn for each entry in the cache, in no particular order,
+// until fn returns false.  It doesn't update the recent-ness of entries,
+// and fn must not modify the cache.
+func (c *LRU[K, V]) Range(fn func(key K, value V) bool) {
+	for i := range c.data {
+		entry := &c.data[i]
+		if entry.lastUsed == 0 || c.invalidated(i) {
+			continue
+		}
+		if !fn(entry.key, entry.value) {
+			return
+		}
+	}
+}
+
+// AppendChunk appends up to n of the cache's entries to dst, taken in
+// storage order from slot from, and returns dst and the slot to continue
+// from, or -1 once every slot has been visited.  It's for walking a large
+// cache in pieces, releasing any lock around it between them.  Entries
+// stay in their slots as the cache is added to and removed from, so a
+// walk that begins at slot 0 sees every entry that is in the cache
+// throughout it exactly once, unless they're moved in the meantime, as
+// they are when the cache first fills up and by Resize and Compact, in
+// which case it may miss some and see others twice.
+func (c *LRU[K, V]) AppendChunk(dst []Entry[K, V], from, n int) ([]Entry[K, V], int) {
+	i := max(from, 0)
+	for ; i < len(c.data) && n > 0; i++ {
+		entry := &c.data[i]
+		if entry.lastUsed == 0 || c.invalidated(i) {
+			continue
+		}
+		dst = append(dst, Entry[K, V]{entry.key, entry.value, entry.lastUsed})
+		n--
+	}
+	if i >= len(c.data) {
+		return dst, -1
+	}
+	return dst, i
+}
+
+// Validate checks the LRU's internal invariants: that the items index and
+// the data slice agree on where every entry is, that no two keys share a
+// slot, that Len matches the number of occupied slots, and that only
+// cached keys are pinned.  It is for tests, fuzzing and canary checks
+// that a sequence of operations left the LRU uncorrupted, and walks every
+// entry.
+func (c *LRU[K, V]) Validate() error {
+	if int64(len(c.data)) > c.slots() {
+		return fmt.Errorf("%d slots exceeds size %d", len(c.data), c.slots())
+	}
+	for key, i := range c.items {
+		if i < 0 || i >= len(c.data) {
+			return fmt.Errorf("key %v indexes slot %d of %d", key, i, len(c.data))
+		}
+		if ent := &c.data[i]; ent.lastUsed == 0 {
+			return fmt.Errorf("key %v indexes empty slot %d", key, i)
+		} else if ent.key != key {
+			return fmt.Errorf("key %v indexes slot %d holding %v", key, i, ent.key)
+		}
+	}
+	occupied, invalidated := 0, 0
+	for i := range c.data {
+		ent := &c.data[i]
+		if ent.lastUsed == 0 {
+			continue
+		}
+		occupied++
+		if c.invalidated(i) {
+			invalidated++
+		}
+		if ent.lastUsed < 0 || ent.lastUsed >= c.counter {
+			return fmt.Errorf("slot %d last used at %d, outside of [1, %d)", i, ent.lastUsed, c.counter)
+		}
+		if j, ok := c.items[ent.key]; !ok {
+			return fmt.Errorf("slot %d holds unindexed key %v", i, ent.key)
+		} else if j != i {
+			return fmt.Errorf("slot %d holds key %v, also in slot %d", i, ent.key, j)
+		}
+	}
+	if occupied != len(c.items) {
+		return fmt.Errorf("%d occupied slots, but %d indexed", occupied, len(c.items))
+	}
+	if invalidated != c.ext.stale {
+		return fmt.Errorf("%d invalidated slots, but %d counted", invalidated, c.ext.stale)
+	}
+	for key, n := range c.ext.pins {
+		if _, ok := c.items[key]; !ok {
+			return fmt.Errorf("uncached key %v is pinned", key)
+		} else if n <= 0 {
+			return fmt.Errorf("key %v pinned %d times", key, n)
+		}
+	}
+	for key := range c.ext.pinsUntil {
+		if c.ext.pins[key] <= 0 {
+			return fmt.Errorf("key %v has a timed pin, but isn't pinned", key)
+		}
+	}
+	if err := c.validateCost(); err != nil {
+		return err
+	}
+	return c.validateQuotas()
+}
+
+// SetOnEvict replaces the eviction callback given NewLRU, or removes it
+// if onEvict is nil.  Entries already evicted aren't passed to it.
+func (c *LRU[K, V]) SetOnEvict(onEvict EvictCallback[K, V]) {
+	c.onEvict = onEvict
+}
+
+// Resize changes the cache size.  Downsizing reallocates the LRU's
+// storage at the new size, so that the memory it frees can be returned to
+// the OS.
 func (c *LRU[K, V]) Resize(size int) (evicted int) {
+	if c.ext.frozen {
+		return 0
+	}
+	for i := range c.data {
+		if c.invalidated(i) {
+			c.dropInvalidated(i)
+		}
+	}
 	diff := c.Len() - size
 	if diff < 0 {
 		diff = 0
@@ -182,17 +841,23 @@ func (c *LRU[K, V]) Resize(size int) (evicted int) {
 		}
 		c.items[entry.key] = i
 	}
+	// drop any slots emptied by Remove, which sorted to the end
+	c.data = c.data[:len(c.items)]
 	oldSize := len(c.data)
 	for i := 0; i < diff; i++ {
 		j := oldSize - 1 - i
 		entry := c.data[j]
 		if entry.lastUsed > 0 {
-			c.removeElement(j, entry)
+			c.evictElement(j, entry, EvictResize)
 		}
 	}
 	c.size = int64(size)
+	c.ext.resizeAccuracy(size)
 	if size < oldSize {
 		c.data = c.data[:size]
+	}
+	if size < cap(c.data) {
+		c.shrink()
 	} else {
 		oldData := c.data
 		c.data = make([]entry[K, V], oldSize, size)
@@ -205,51 +870,187 @@ func (c *LRU[K, V]) Resize(size int) (evicted int) {
 	return diff
 }
 
+// shrink reallocates the backing array and the items map at the LRU's
+// size, rather than reslicing them, so that the memory a downsized LRU no
+// longer needs can be returned to the OS; a map never shrinks, and a
+// slice keeps its whole backing array alive.  Every slot in data must
+// hold a live entry.
+func (c *LRU[K, V]) shrink() {
+	oldData := c.data
+	c.data = make([]entry[K, V], len(oldData), c.size)
+	copy(c.data, oldData)
+	c.items = make(map[K]int, c.size)
+	for i := range c.data {
+		c.items[c.data[i].key] = i
+	}
+}
+
 // removeOldest removes the oldest item from the cache.
 func (c *LRU[K, V]) removeOldest() (off int) {
-	size := c.Len()
+	return c.removeVictim(c.findVictim())
+}
+
+// removeVictim empties slot off, found by findVictim, and returns it.
+func (c *LRU[K, V]) removeVictim(off int) int {
+	if off < 0 {
+		return off
+	}
+	// we could have found an empty slot
+	if c.invalidated(off) {
+		c.dropInvalidated(off)
+	} else if oldest := c.data[off]; oldest.lastUsed != 0 {
+		c.evictElement(off, oldest, EvictCapacity)
+	}
+	return off
+}
+
+// findVictim returns the offset of the oldest of a random sample of
+// slots, which may be empty, or -1 if no slot has ever been used.
+func (c *LRU[K, V]) findVictim() (off int) {
+	// sample the whole array: once it has filled up, removals leave
+	// holes, and the entries after them need to be candidates too
+	size := len(c.data)
 	if size <= 0 {
 		return -1
 	}
+	// every eviction goes on to update c.ext's counters, so reading the
+	// probe count from it doesn't touch a cache line that eviction
+	// wouldn't anyway; BenchmarkLRU_Rand and BenchmarkLRU_Big show no
+	// difference from a constant.
+	probes := c.ext.probes
 	base := c.rng.Intn(size)
+	if c.ext.costVictims && c.ext.costOf != nil {
+		return c.findCostVictim(base, probes, size, false)
+	}
 	oldestOff := base
-	oldest := c.data[base]
+	oldest := c.data[base].lastUsed
 	// if our offset does NOT result in us wrapping off the end of the array
 	// (which is unlikely! should be predicted well), don't require `% size`
 	// as that is expensive.  duplicate the whole loop to put the conditional
 	// outside the loop rather than in it.
-	if base+randomProbes-1 < size {
-		for j := 1; j < randomProbes; j++ {
+	if base+probes-1 < size {
+		for j := 1; j < probes; j++ {
 			off := base + j
-			candidate := &c.data[off]
-			if candidate.lastUsed < oldest.lastUsed {
+			if lastUsed := c.data[off].lastUsed; lastUsed < oldest {
 				oldestOff = off
-				oldest = *candidate
+				oldest = lastUsed
 			}
 		}
 	} else {
-		for j := 1; j < randomProbes; j++ {
+		for j := 1; j < probes; j++ {
 			off := (base + j) % size
-			candidate := &c.data[off]
-			if candidate.lastUsed < oldest.lastUsed {
+			if lastUsed := c.data[off].lastUsed; lastUsed < oldest {
 				oldestOff = off
-				oldest = *candidate
+				oldest = lastUsed
 			}
 		}
 	}
-
-	// we could have found an empty slot
-	if oldest.lastUsed != 0 {
-		c.removeElement(oldestOff, oldest)
+	c.ext.stats.VictimSearches++
+	c.ext.stats.Probes += uint64(probes)
+	if oldest == 0 || oldest < c.ext.floor {
+		// only a sample holding an empty slot can have sampled any
+		c.ext.stats.EmptyProbes += c.countEmpty(base, probes, size)
+	}
+	if len(c.ext.pins) > 0 && c.pinned(oldestOff) {
+		return c.findUnpinnedVictim(base, probes, size)
 	}
 	return oldestOff
 }
 
+// scanVictim returns the offset of the oldest entry in the cache, or
+// WithCostAwareEviction, of an entry chosen from all of them by weight.
+// The cache must not be empty.
+func (c *LRU[K, V]) scanVictim() (off int) {
+	if c.ext.costVictims && c.ext.costOf != nil {
+		// if every entry is pinned, this can find an empty slot
+		if off = c.findCostVictim(0, len(c.data), len(c.data), true); c.data[off].lastUsed != 0 {
+			return off
+		}
+	}
+	off = -1
+	for i := range c.data {
+		if lastUsed := c.data[i].lastUsed; lastUsed != 0 && (off < 0 || lastUsed < c.data[off].lastUsed) {
+			off = i
+		}
+	}
+	return off
+}
+
+// countEmpty returns the number of empty or invalidated slots among the
+// probes slots from base.
+func (c *LRU[K, V]) countEmpty(base, probes, size int) (n uint64) {
+	for j := 0; j < probes; j++ {
+		i := (base + j) % size
+		if c.data[i].lastUsed == 0 || c.invalidated(i) {
+			n++
+		}
+	}
+	return n
+}
+
+// findUnpinnedVictim is findVictim for when the oldest slot sampled holds
+// a pinned entry: it picks the oldest unpinned slot sampled, or failing
+// that the oldest unpinned slot in the cache, or failing that base.
+func (c *LRU[K, V]) findUnpinnedVictim(base, probes, size int) (off int) {
+	off = -1
+	for j := 0; j < probes; j++ {
+		i := (base + j) % size
+		if !c.pinned(i) && (off < 0 || c.data[i].lastUsed < c.data[off].lastUsed) {
+			off = i
+		}
+	}
+	if off >= 0 {
+		return off
+	}
+	c.ext.stats.Probes += uint64(len(c.data))
+	for i := range c.data {
+		if !c.pinned(i) && (off < 0 || c.data[i].lastUsed < c.data[off].lastUsed) {
+			off = i
+		}
+	}
+	if off >= 0 {
+		return off
+	}
+	return base
+}
+
+// evictElement removes an entry to make room, as opposed to an explicit
+// Remove or Purge.
+func (c *LRU[K, V]) evictElement(i int, ent entry[K, V], reason EvictReason) {
+	if c.ext.displaced != nil {
+		*c.ext.displaced = ent
+	}
+	c.ext.recordEviction(ent.key, c.counter-ent.lastUsed, reason)
+	c.ext.recordLifetime(ent.key, c.counter)
+	c.ext.compareEviction(ent.key, reason)
+	c.ext.notifyEvict(ent.key, ent.value)
+	c.removeElement(i, ent, reason)
+}
+
 // removeElement is used to remove a given list element from the cache
-func (c *LRU[K, V]) removeElement(i int, ent entry[K, V]) {
+func (c *LRU[K, V]) removeElement(i int, ent entry[K, V], reason EvictReason) {
 	c.data[i] = entry[K, V]{}
 	delete(c.items, ent.key)
+	c.ext.subCost(ent.key)
+	c.ext.forgetChurn(ent.key)
+	if len(c.ext.pins) > 0 {
+		delete(c.ext.pins, ent.key)
+		delete(c.ext.pinsUntil, ent.key)
+	}
 	if c.onEvict != nil {
-		c.onEvict(ent.key, ent.value)
+		c.callOnEvict(ent.key, ent.value)
+	}
+	if c.ext.onEvictInfo != nil {
+		c.notifyEvictInfo(ent, reason)
+		c.ext.forgetMeta(ent.key)
+	}
+}
+
+// callOnEvict calls the eviction callback, recovering from its panics if
+// the LRU was created WithRecover.
+func (c *LRU[K, V]) callOnEvict(key K, value V) {
+	if c.ext.recover {
+		defer c.ext.recovered("onEvict", key)
 	}
+	c.onEvict(key, value)
 }
@@ -6,6 +6,8 @@ import (
 	"unsafe"
 )
 
+var _ LRUCache[int, int] = (*LRU[int, int])(nil)
+
 func hackSleep() {
 	// on macOS, UnixNanos() has a max resolution of microseconds.  Sleep
 	// just a smidge here to ensure we evict the right item below.  In production
@@ -225,3 +227,541 @@ func TestLRU_Resize(t *testing.T) {
 		t.Errorf("Cache should have contained 2 elements")
 	}
 }
+
+func TestLRU_ResizeReleasesMemory(t *testing.T) {
+	l, err := NewLRU[int, int](1024, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 1024; i++ {
+		l.Add(i, i)
+	}
+	l.Remove(1023)
+	if evicted := l.Resize(16); evicted != 1023-16 {
+		t.Fatalf("bad evictions: %v", evicted)
+	}
+	if cap(l.data) != 16 {
+		t.Fatalf("expected the backing array to be reallocated, got cap %d", cap(l.data))
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 1024 - 17; i < 1023; i++ {
+		if !l.Contains(i) {
+			t.Fatalf("expected %d to survive", i)
+		}
+	}
+	l.Add(2000, 2000)
+	if l.Len() != 16 || !l.Contains(2000) {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+}
+
+func TestLRU_Entries(t *testing.T) {
+	l, err := NewLRU[int, int](4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	l.Get(1)
+	l.Remove(2)
+
+	entries := l.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("bad len: %v", len(entries))
+	}
+	if entries[0].Key != 3 || entries[1].Key != 1 {
+		t.Errorf("bad order: %v", entries)
+	}
+}
+
+func TestLRU_WarmUp(t *testing.T) {
+	evicted := 0
+	l, err := NewLRU[int, int](128, func(int, int) { evicted++ })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	entries := make([]Entry[int, int], 256)
+	for i := range entries {
+		entries[i] = Entry[int, int]{Key: i, Value: i}
+	}
+	l.WarmUp(entries)
+
+	if l.Len() != 128 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if evicted != 0 {
+		t.Fatalf("expected no eviction callbacks, got %v", evicted)
+	}
+	if l.Contains(127) || !l.Contains(128) || !l.Contains(255) {
+		t.Fatalf("expected only the most recent entries to be kept")
+	}
+	if s := l.Stats(); s.Hits+s.Misses+s.Evictions != 0 {
+		t.Fatalf("expected warm up not to be counted: %+v", s)
+	}
+
+	got := l.Entries()
+	for i, e := range got {
+		if e.Key != 128+i {
+			t.Fatalf("bad recency order at %d: %v", i, e.Key)
+		}
+	}
+
+	// warming a full cache displaces the oldest entries
+	l.WarmUp([]Entry[int, int]{{Key: 1000, Value: 1000}})
+	if l.Len() != 128 || !l.Contains(1000) {
+		t.Fatalf("expected 1000 to be added to a full cache")
+	}
+	if evicted != 0 {
+		t.Fatalf("expected no eviction callbacks, got %v", evicted)
+	}
+}
+
+func TestLRU_RemoveOldest(t *testing.T) {
+	l, err := NewLRU[int, int](128, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, _, ok := l.RemoveOldest(); ok {
+		t.Fatalf("expected nothing to remove")
+	}
+	for i := 0; i < 128; i++ {
+		l.Add(i, i)
+	}
+	for i := 0; i < 100; i++ {
+		l.Remove(i)
+	}
+	for i := 0; i < 28; i++ {
+		k, v, ok := l.RemoveOldest()
+		if !ok || k != v || k < 100 {
+			t.Fatalf("bad removal: %v, %v, %v", k, v, ok)
+		}
+	}
+	if l.Len() != 0 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+}
+
+func TestLRU_WithSeed(t *testing.T) {
+	run := func() []Entry[int, int] {
+		l, err := NewLRU[int, int](128, nil, WithSeed[int, int](42))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		for i := 0; i < 1024; i++ {
+			l.Add(i%300, i)
+			l.Get(i % 17)
+		}
+		return l.Entries()
+	}
+	a, b := run(), run()
+	if len(a) != len(b) {
+		t.Fatalf("expected the same entries, got %d and %d", len(a), len(b))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("expected the same entries, got %v and %v at %d", a[i], b[i], i)
+		}
+	}
+}
+
+func TestLRU_Validate(t *testing.T) {
+	l, err := NewLRU[int, int](8, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// removing before the cache first fills leaves empty slots holding
+	// the zero key when it's shuffled
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+	l.Remove(0)
+	l.Remove(2)
+	for i := 4; i < 10; i++ {
+		l.Add(i, i)
+		if err := l.Validate(); err != nil {
+			t.Fatalf("after adding %d: %v", i, err)
+		}
+	}
+	l.Remove(5)
+	if n, evicted := l.Len(), l.Resize(4); evicted != n-4 {
+		t.Fatalf("expected %d evictions, got %d", n-4, evicted)
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("after resize: %v", err)
+	}
+
+	entries := l.Entries()
+	l.Pin(entries[0].Key)
+	l.ext.pins[-1] = 1
+	if err := l.Validate(); err == nil {
+		t.Fatalf("expected a pinned uncached key to fail validation")
+	}
+	delete(l.ext.pins, -1)
+
+	l.items[entries[0].Key] = l.items[entries[1].Key]
+	if err := l.Validate(); err == nil {
+		t.Fatalf("expected a corrupt index to fail validation")
+	}
+}
+
+func TestLRU_Ref(t *testing.T) {
+	l, err := NewLRU[int, int](8, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	v, ok := l.GetRef(1)
+	if !ok || *v != 1 {
+		t.Fatalf("expected a reference to 1")
+	}
+	*v = 2
+	if p, ok := l.PeekRef(1); !ok || p != v {
+		t.Fatalf("expected the same reference")
+	}
+	if v, _ := l.Peek(1); v != 2 {
+		t.Fatalf("expected writes through the reference to be cached, got %d", v)
+	}
+	if _, ok := l.GetRef(2); ok {
+		t.Fatalf("expected a miss")
+	}
+	if _, ok := l.PeekRef(2); ok {
+		t.Fatalf("expected a miss")
+	}
+	if stats := l.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Fatalf("expected a hit and a miss, got %+v", stats)
+	}
+}
+
+func FuzzLRU_Validate(f *testing.F) {
+	f.Add([]byte{0, 1, 0, 2, 3, 1, 4, 1, 5, 0, 0, 3, 6, 0, 7, 0})
+	f.Add([]byte("pin, resize, invalidate and compact some keys"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		l, err := NewLRU[byte, int](8, nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		for i := 0; i+1 < len(data); i += 2 {
+			key := data[i+1] % 16
+			switch data[i] % 9 {
+			case 0, 1:
+				l.Add(key, i)
+			case 2:
+				l.Get(key)
+			case 3:
+				l.Remove(key)
+			case 4:
+				l.Pin(key)
+			case 5:
+				l.Unpin(key)
+			case 6:
+				l.Resize(int(key%8) + 1)
+			case 7:
+				l.InvalidateAll()
+			case 8:
+				l.Compact()
+			}
+			if err := l.Validate(); err != nil {
+				t.Fatalf("after op %d: %v", i/2, err)
+			}
+		}
+	})
+}
+
+func TestLRU_InvalidateAll(t *testing.T) {
+	evicted := 0
+	l, err := NewLRU[int, int](128, func(k, v int) { evicted++ })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 128; i++ {
+		l.Add(i, i)
+	}
+	l.InvalidateAll()
+	if l.Len() != 0 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if _, ok := l.Get(1); ok || l.Contains(2) {
+		t.Fatalf("expected invalidated entries to be absent")
+	}
+	if _, ok := l.Peek(3); ok || l.Remove(4) {
+		t.Fatalf("expected invalidated entries to be absent")
+	}
+	if len(l.Entries()) != 0 {
+		t.Fatalf("expected no entries")
+	}
+
+	// re-adding a key revives it, and new entries displace invalidated
+	// ones without calling back about them
+	l.Add(5, 50)
+	l.Add(1000, 1000)
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if l.Len() != 2 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if v, ok := l.Get(5); !ok || v != 50 {
+		t.Fatalf("bad value: %v, %v", v, ok)
+	}
+	if evicted != 0 {
+		t.Fatalf("expected no eviction callbacks, got %d", evicted)
+	}
+	l.Purge()
+	if evicted != 2 {
+		t.Fatalf("expected 2 eviction callbacks, got %d", evicted)
+	}
+
+	for i := 0; i < 128; i++ {
+		l.Add(i, i)
+	}
+	l.InvalidateAll()
+	l.Add(1, 1)
+	if l.Resize(64) != 0 || l.Len() != 1 {
+		t.Fatalf("expected invalidated entries to be dropped by Resize")
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestLRU_RemoveFunc(t *testing.T) {
+	evicted := 0
+	l, err := NewLRU[int, int](128, func(k, v int) { evicted++ })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		l.Add(i, i*10)
+	}
+	n := l.RemoveFunc(func(k, v int) bool {
+		return k%2 == 0 && v >= 500
+	})
+	if n != 25 || evicted != 25 || l.Len() != 75 {
+		t.Fatalf("bad removals %d, evictions %d or len %d", n, evicted, l.Len())
+	}
+	if l.Contains(50) || !l.Contains(51) || !l.Contains(48) {
+		t.Fatalf("removed the wrong keys")
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+// Test that adding works once removals have left a full cache with holes
+func TestLRU_AddAfterRemove(t *testing.T) {
+	l, err := NewLRU[int, int](16, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 16; i++ {
+		l.Add(i, i)
+	}
+	for i := 0; i < 16; i++ {
+		l.Remove(i)
+	}
+	for i := 16; i < 64; i++ {
+		l.Add(i, i)
+		if !l.Contains(i) {
+			t.Fatalf("%d should have been added", i)
+		}
+		if err := l.Validate(); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if l.Len() != 16 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+}
+
+func TestLRU_Pin(t *testing.T) {
+	l, err := NewLRU[int, int](8, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if l.Pin(0) {
+		t.Fatalf("expected pinning a missing key to fail")
+	}
+	for i := 0; i < 8; i++ {
+		l.Add(i, i)
+	}
+	// the oldest entries would be the first to go
+	if !l.Pin(0) || !l.Pin(1) || !l.Pin(1) {
+		t.Fatalf("expected pinning to succeed")
+	}
+	for i := 8; i < 100; i++ {
+		l.Add(i, i)
+	}
+	if !l.Contains(0) || !l.Contains(1) {
+		t.Fatalf("expected pinned entries to survive")
+	}
+
+	l.Unpin(0)
+	l.Unpin(1)
+	for i := 100; i < 200; i++ {
+		l.Add(i, i)
+	}
+	if l.Contains(0) || !l.Contains(1) {
+		t.Fatalf("expected only the entry that was still pinned to survive")
+	}
+
+	// a cache full of pinned entries still evicts to stay within its size
+	for _, e := range l.Entries() {
+		l.Pin(e.Key)
+	}
+	l.Add(1000, 1000)
+	if l.Len() != 8 || !l.Contains(1000) {
+		t.Fatalf("bad len %d", l.Len())
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestLRU_SetOnEvict(t *testing.T) {
+	l, err := NewLRU[int, int](2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+
+	var evicted []int
+	l.SetOnEvict(func(key int, value int) {
+		evicted = append(evicted, key)
+	})
+	l.Resize(1)
+	if len(evicted) != 1 {
+		t.Fatalf("expected one eviction to be reported, got %v", evicted)
+	}
+	l.SetOnEvict(nil)
+	l.Add(4, 4)
+	if len(evicted) != 1 {
+		t.Fatalf("expected no evictions after removing the callback, got %v", evicted)
+	}
+}
+
+func TestLRU_AppendChunk(t *testing.T) {
+	l, err := NewLRU[int, int](100, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		l.Add(i, i)
+	}
+	for i := 0; i < 100; i += 3 {
+		l.Remove(i)
+	}
+
+	seen := map[int]int{}
+	var chunk []Entry[int, int]
+	chunks := 0
+	for from := 0; from >= 0; chunks++ {
+		chunk, from = l.AppendChunk(chunk[:0], from, 8)
+		if len(chunk) > 8 {
+			t.Fatalf("expected at most 8 entries, got %d", len(chunk))
+		}
+		for _, e := range chunk {
+			seen[e.Key]++
+			if e.Value != e.Key || e.LastUsed == 0 {
+				t.Fatalf("bad entry %+v", e)
+			}
+		}
+		// entries removed between chunks are skipped, and the rest
+		// stay put
+		if chunks == 0 {
+			l.Remove(1)
+			l.Remove(2)
+		}
+	}
+	if chunks < l.Len()/8 {
+		t.Fatalf("expected the walk to take several chunks, took %d", chunks)
+	}
+	for i := 0; i < 100; i++ {
+		if n := seen[i]; n > 1 || n == 0 && l.Contains(i) {
+			t.Fatalf("key %d seen %d times", i, n)
+		}
+	}
+}
+
+func TestLRU_AddReturningEvicted(t *testing.T) {
+	var callbacks int
+	l, err := NewLRU[int, string](1, func(int, string) { callbacks++ })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, _, evicted := l.AddReturningEvicted(1, "one"); evicted {
+		t.Fatalf("expected nothing to be evicted")
+	}
+	if _, _, evicted := l.AddReturningEvicted(1, "uno"); evicted {
+		t.Fatalf("expected an update to evict nothing")
+	}
+	k, v, evicted := l.AddReturningEvicted(2, "two")
+	if !evicted || k != 1 || v != "uno" {
+		t.Fatalf("expected 1 to be evicted, got %v %q %v", k, v, evicted)
+	}
+	if callbacks != 1 {
+		t.Fatalf("expected the eviction callback to be called too")
+	}
+
+	// an empty slot left by Remove isn't an eviction
+	l.Remove(2)
+	if _, _, evicted := l.AddReturningEvicted(3, "three"); evicted {
+		t.Fatalf("expected filling a hole to evict nothing")
+	}
+}
+
+func TestLRU_PinFor(t *testing.T) {
+	l, err := NewLRU[int, int](8, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	now := time.Unix(1000, 0)
+	l.ext.clock.now = func() time.Time { return now }
+	if l.PinFor(0, time.Minute) {
+		t.Fatalf("expected pinning a missing key to fail")
+	}
+	for i := 0; i < 8; i++ {
+		l.Add(i, i)
+	}
+	// a shorter pin doesn't cut a longer one short
+	if !l.PinFor(0, time.Minute) || !l.PinFor(0, time.Second) || !l.PinFor(1, time.Second) {
+		t.Fatalf("expected pinning to succeed")
+	}
+	if n := l.ext.pins[0]; n != 1 {
+		t.Fatalf("expected a single pin, got %d", n)
+	}
+	for i := 8; i < 100; i++ {
+		l.Add(i, i)
+	}
+	if !l.Contains(0) || !l.Contains(1) {
+		t.Fatalf("expected pinned entries to survive")
+	}
+
+	// once 1's pin lapses, it's evicted like any other entry
+	now = now.Add(2 * time.Second)
+	for i := 100; i < 200; i++ {
+		l.Add(i, i)
+	}
+	if !l.Contains(0) || l.Contains(1) {
+		t.Fatalf("expected only the entry still pinned to survive")
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Unpin ends a timed pin early
+	l.Unpin(0)
+	for i := 200; i < 300; i++ {
+		l.Add(i, i)
+	}
+	if l.Contains(0) || len(l.ext.pinsUntil) != 0 {
+		t.Fatalf("expected Unpin to end the timed pin")
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package simplelru
+
+import (
+	"errors"
+	"sync"
+
+	"golang.org/x/exp/slices"
+)
+
+// MissRatioCurve estimates the hit ratio a cache would achieve at
+// several sizes other than its actual size, using the SHARDS technique:
+// a small, hash-sampled subset of keys is fed through keys-only exact
+// LRUs scaled down by the sampling rate.  This answers "would doubling
+// capacity actually help?" without paying for the memory first.
+//
+// A MissRatioCurve is safe for concurrent use, so a single instance can be
+// shared by every shard of a sharded cache.
+type MissRatioCurve struct {
+	mu          sync.Mutex
+	sampleEvery uint64
+	sizes       []int
+	ghosts      []*ExactLRU[uint64, struct{}]
+	hits        []uint64
+	lookups     uint64
+}
+
+// NewMissRatioCurve returns an estimator simulating each of the given
+// cache sizes.  Roughly one in sampleEvery keys is sampled; larger values
+// use less memory and CPU at the cost of accuracy, and values from 100 to
+// 1000 are typical for large caches.
+func NewMissRatioCurve(sizes []int, sampleEvery int) (*MissRatioCurve, error) {
+	if len(sizes) == 0 {
+		return nil, errors.New("must provide at least one size")
+	}
+	if sampleEvery < 1 {
+		sampleEvery = 1
+	}
+	sizes = append([]int(nil), sizes...)
+	slices.Sort(sizes)
+	m := &MissRatioCurve{
+		sampleEvery: uint64(sampleEvery),
+		sizes:       sizes,
+		ghosts:      make([]*ExactLRU[uint64, struct{}], len(sizes)),
+		hits:        make([]uint64, len(sizes)),
+	}
+	for i, size := range sizes {
+		if size <= 0 {
+			return nil, errors.New("must provide positive sizes")
+		}
+		scaled := (size + sampleEvery - 1) / sampleEvery
+		ghost, err := NewExactLRU[uint64, struct{}](scaled, nil)
+		if err != nil {
+			return nil, err
+		}
+		m.ghosts[i] = ghost
+	}
+	return m, nil
+}
+
+func (m *MissRatioCurve) sampled(hash uint64) bool {
+	return hash%m.sampleEvery == 0
+}
+
+func (m *MissRatioCurve) recordLookup(hash uint64) {
+	if !m.sampled(hash) {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lookups++
+	for i, ghost := range m.ghosts {
+		if _, ok := ghost.Get(hash); ok {
+			m.hits[i]++
+		} else {
+			ghost.Add(hash, struct{}{})
+		}
+	}
+}
+
+func (m *MissRatioCurve) recordRemove(hash uint64) {
+	if !m.sampled(hash) {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ghost := range m.ghosts {
+		ghost.Remove(hash)
+	}
+}
+
+// EstimateHitRatioAt returns the estimated hit ratio of a cache with the
+// given size, interpolating linearly between the simulated sizes.  Sizes
+// beyond the largest simulated size are estimated as the largest, since
+// the curve can't be extrapolated.  It returns 0 before any sampled
+// lookups.
+func (m *MissRatioCurve) EstimateHitRatioAt(size int) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.lookups == 0 || size <= 0 {
+		return 0
+	}
+	prevSize, prevRatio := 0, 0.0
+	for i, s := range m.sizes {
+		r := float64(m.hits[i]) / float64(m.lookups)
+		if size <= s {
+			return prevRatio + (r-prevRatio)*float64(size-prevSize)/float64(s-prevSize)
+		}
+		prevSize, prevRatio = s, r
+	}
+	return prevRatio
+}
+
+// EstimateHitRatioAt returns the hit ratio the LRU would be expected to
+// achieve if it had the given size.  It returns 0 unless the LRU was
+// created WithMissRatioCurve.
+func (c *LRU[K, V]) EstimateHitRatioAt(size int) float64 {
+	if c.ext.mrc == nil {
+		return 0
+	}
+	return c.ext.mrc.EstimateHitRatioAt(size)
+}
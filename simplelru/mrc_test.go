@@ -0,0 +1,68 @@
+package simplelru
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMissRatioCurve(t *testing.T) {
+	m, err := NewMissRatioCurve([]int{2000, 500}, 1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l, err := NewLRU[int, int](1000, nil, WithMissRatioCurve[int, int](m))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if r := l.EstimateHitRatioAt(2000); r != 0 {
+		t.Fatalf("expected no estimate before lookups, got %v", r)
+	}
+
+	// loop over 1000 keys, which only fits in the larger simulated cache
+	for i := 0; i < 10000; i++ {
+		if _, ok := l.Get(i % 1000); !ok {
+			l.Add(i%1000, i)
+		}
+	}
+
+	cases := map[int]float64{
+		250:  0,
+		500:  0,
+		1250: 0.45,
+		2000: 0.9,
+		4000: 0.9,
+	}
+	for size, expected := range cases {
+		if r := l.EstimateHitRatioAt(size); math.Abs(r-expected) > 0.001 {
+			t.Errorf("EstimateHitRatioAt(%d) = %v, expected %v", size, r, expected)
+		}
+	}
+}
+
+func TestMissRatioCurveSampled(t *testing.T) {
+	m, err := NewMissRatioCurve([]int{500, 2000}, 8)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 100000; i++ {
+		m.recordLookup(HashKey(i % 1000))
+	}
+
+	if r := m.EstimateHitRatioAt(2000); math.Abs(r-0.99) > 0.01 {
+		t.Errorf("expected a hit ratio near 0.99 at 2000, got %v", r)
+	}
+	if r := m.EstimateHitRatioAt(500); r > 0.2 {
+		t.Errorf("expected a low hit ratio at 500, got %v", r)
+	}
+}
+
+func TestNewMissRatioCurveErrors(t *testing.T) {
+	if _, err := NewMissRatioCurve(nil, 1); err == nil {
+		t.Errorf("expected an error with no sizes")
+	}
+	if _, err := NewMissRatioCurve([]int{0}, 1); err == nil {
+		t.Errorf("expected an error with a zero size")
+	}
+}
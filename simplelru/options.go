@@ -0,0 +1,122 @@
+package simplelru
+
+import (
+	"log/slog"
+	"math/rand"
+)
+
+// Option configures optional behavior of an LRU at construction time.
+type Option[K comparable, V any] func(c *LRU[K, V])
+
+// WithClassifier tracks hits, misses and evictions separately for each
+// class of keys, as named by classify.  This is useful when a single cache
+// holds several kinds of objects and you need to know which is being
+// starved.  classify is called on every lookup and eviction, so it should
+// be cheap.
+func WithClassifier[K comparable, V any](classify func(key K) string) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		c.ext.classify = classify
+		c.ext.classes = make(map[string]*ClassStats)
+	}
+}
+
+// WithHotKeys tracks (approximately) the capacity most frequently
+// looked-up keys, which can then be retrieved with HotKeys.  Larger
+// capacities give more accurate counts at the cost of memory and a
+// slightly more expensive Get.
+func WithHotKeys[K comparable, V any](capacity int) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		if capacity > 0 {
+			c.ext.hot = newHotKeys[K](capacity)
+		}
+	}
+}
+
+// WithTrace records Get, Add and Remove operations to w.  Operations are
+// sampled by key: roughly one in sampleEvery keys is traced, and every
+// operation on a traced key is recorded.  Pass 1 to trace everything.
+func WithTrace[K comparable, V any](w *TraceWriter, sampleEvery int) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		if sampleEvery < 1 {
+			sampleEvery = 1
+		}
+		c.ext.trace = &tracer{w: w, sampleEvery: uint64(sampleEvery)}
+	}
+}
+
+// WithProbes sets the number of entries sampled when choosing an eviction
+// victim (8 by default).  More probes approximate a true LRU more closely,
+// at the cost of slower evictions.
+func WithProbes[K comparable, V any](probes int) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		if probes > 0 {
+			c.ext.probes = probes
+		}
+	}
+}
+
+// WithMissRatioCurve feeds lookups and removals to m, so that
+// EstimateHitRatioAt can report how the LRU would perform at other sizes.
+func WithMissRatioCurve[K comparable, V any](m *MissRatioCurve) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		c.ext.mrc = m
+	}
+}
+
+// WithLogger logs a sample of evictions to logger at debug level, with
+// the evicted key and its age, so that the kinds of entries being evicted
+// show up in standard logs.
+func WithLogger[K comparable, V any](logger *slog.Logger) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		c.ext.logger = logger
+	}
+}
+
+// WithListener registers l to be notified of hits, misses, additions,
+// evictions and expirations.  It can be given more than once to register
+// several listeners.
+func WithListener[K comparable, V any](l Listener[K, V]) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		c.ext.listen = append(c.ext.listen, l)
+	}
+}
+
+// WithEvictionSampler calls fn for one in every evictions, with the
+// evicted key, how long it had gone unused (in ticks of the LRU's logical
+// clock) and why it was evicted.  This shows what kinds of entries are
+// being evicted without the overhead of handling every eviction.  fn is
+// called while the LRU is being modified, and must not call back into it.
+func WithEvictionSampler[K comparable, V any](every int, fn func(key K, age int64, reason EvictReason)) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		if every < 1 {
+			every = 1
+		}
+		c.ext.sampler = &evictionSampler[K]{every: uint64(every), fn: fn}
+	}
+}
+
+// WithRandSource draws the random numbers used to choose eviction victims
+// from src, instead of a source seeded from crypto/rand, so that an LRU's
+// evictions can be reproduced exactly.  src must not be shared with
+// anything that uses it concurrently.
+func WithRandSource[K comparable, V any](src rand.Source) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		c.rng = rand.New(src)
+	}
+}
+
+// WithSeed is WithRandSource with a math/rand source seeded with seed.
+func WithSeed[K comparable, V any](seed int64) Option[K, V] {
+	return WithRandSource[K, V](rand.NewSource(seed))
+}
+
+// WithRecover recovers from panics in the eviction callback and
+// listeners, logging them at error level to the logger given WithLogger,
+// or slog's default logger, instead of letting them unwind through
+// whichever call happened to trigger the callback and leave the LRU
+// half-modified.
+func WithRecover[K comparable, V any]() Option[K, V] {
+	return func(c *LRU[K, V]) {
+		c.ext.recover = true
+	}
+}
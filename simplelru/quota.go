@@ -0,0 +1,203 @@
+package simplelru
+
+import "fmt"
+
+// Quota bounds how much of a cache one class of keys may hold.
+type Quota struct {
+	// Entries is the most entries the class may hold, or 0 for no limit.
+	Entries int
+	// Cost is the most the class's entries may cost in total, as
+	// measured by the function given WithCost, or 0 for no limit.
+	Cost int64
+}
+
+// WithQuotas bounds each class of keys named in quotas, as named by the
+// classifier given WithClassifier, so that one noisy class, such as one
+// tenant's keys in a shared cache, can't take over the cache however
+// recently its keys were used.  Adding a new key to a class at its quota
+// evicts the oldest of a sample of the class's own entries, with the
+// reason EvictQuota, rather than an entry from the cache as a whole, and
+// a value that on its own costs more than its class's Cost quota isn't
+// added, and is counted in Stats' Rejections.  Quotas are enforced when
+// keys are added, not when they're updated, and pinned entries are never
+// evicted for a quota.  Finding a class's entries means scanning past
+// those of other classes, so evicting for a quota costs more the smaller
+// the class's share of the cache.  Classes not in quotas are unbounded,
+// and without WithClassifier every class is.
+func WithQuotas[K comparable, V any](quotas map[string]Quota) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		c.ext.quotas = make(map[string]Quota, len(quotas))
+		for class, q := range quotas {
+			c.ext.quotas[class] = q
+		}
+		c.ext.usage = make(map[string]*quotaUsage)
+	}
+}
+
+// quotaUsage is what a class with a quota holds.
+type quotaUsage struct {
+	entries int
+	cost    int64
+}
+
+// exceeds reports whether the class would exceed q after adding an entry
+// costing cost.
+func (u *quotaUsage) exceeds(q Quota, cost int64) bool {
+	return (q.Entries > 0 && u.entries+1 > q.Entries) || (q.Cost > 0 && u.cost+cost > q.Cost)
+}
+
+// quotaFor returns key's class, its quota and its usage, or a nil usage
+// if its class has no quota.
+func (x *extension[K, V]) quotaFor(key K) (class string, q Quota, u *quotaUsage) {
+	if x.quotas == nil || x.classify == nil {
+		return "", q, nil
+	}
+	class = x.classify(key)
+	q, ok := x.quotas[class]
+	if !ok {
+		return "", q, nil
+	}
+	if u = x.usage[class]; u == nil {
+		u = &quotaUsage{}
+		x.usage[class] = u
+	}
+	return class, q, u
+}
+
+// chargeQuota counts key, costing cost, against its class's quota.
+func (x *extension[K, V]) chargeQuota(key K, cost int64) {
+	if _, _, u := x.quotaFor(key); u != nil {
+		u.entries++
+		u.cost += cost
+	}
+}
+
+// creditQuota undoes chargeQuota for key.
+func (x *extension[K, V]) creditQuota(key K, cost int64) {
+	if _, _, u := x.quotaFor(key); u != nil {
+		u.entries--
+		u.cost -= cost
+	}
+}
+
+// resetQuotas forgets every class's usage.
+func (x *extension[K, V]) resetQuotas() {
+	if x.usage != nil {
+		clear(x.usage)
+	}
+}
+
+// quotaCost returns what value would cost key's class, or 0 if the
+// class's quota doesn't bound cost.
+func (x *extension[K, V]) quotaCost(key K, value V, q Quota) int64 {
+	if q.Cost > 0 && x.costOf != nil {
+		return x.costOf(key, value)
+	}
+	return 0
+}
+
+// quotaVictim returns the slot of an entry of key's class to evict to
+// keep the class within its quota once key is added, or -1 if there's
+// room for it, or none of the class's entries can be evicted.  It
+// returns false if value on its own costs more than the quota.
+func (c *LRU[K, V]) quotaVictim(key K, value V) (victim int, ok bool) {
+	class, q, u := c.ext.quotaFor(key)
+	if u == nil {
+		return -1, true
+	}
+	cost := c.ext.quotaCost(key, value, q)
+	if q.Cost > 0 && cost > q.Cost {
+		c.ext.stats.Rejections++
+		return -1, false
+	}
+	if !u.exceeds(q, cost) {
+		return -1, true
+	}
+	return c.findClassVictim(class), true
+}
+
+// evictForQuota evicts victim, found by quotaVictim, and then as many more
+// of the class's entries as it takes for key and value to fit within the
+// class's quota, and returns victim's slot for key.
+func (c *LRU[K, V]) evictForQuota(key K, value V, victim int) int {
+	class, q, u := c.ext.quotaFor(key)
+	cost := c.ext.quotaCost(key, value, q)
+	for off := victim; off >= 0; off = c.findClassVictim(class) {
+		c.ext.class(c.data[off].key).QuotaEvictions++
+		c.evictElement(off, c.data[off], EvictQuota)
+		if !u.exceeds(q, cost) {
+			break
+		}
+	}
+	return victim
+}
+
+// findClassVictim returns the slot of the oldest of the first few
+// unpinned entries of class found scanning from a random slot, or -1 if
+// there are none.
+func (c *LRU[K, V]) findClassVictim(class string) (off int) {
+	size := len(c.data)
+	if size == 0 {
+		return -1
+	}
+	base := c.rng.Intn(size)
+	off = -1
+	found, j := 0, 0
+	for ; j < size && found < c.ext.probes; j++ {
+		i := (base + j) % size
+		ent := &c.data[i]
+		if ent.lastUsed == 0 || c.invalidated(i) || (len(c.ext.pins) > 0 && c.pinned(i)) || c.ext.classify(ent.key) != class {
+			continue
+		}
+		found++
+		if off < 0 || ent.lastUsed < c.data[off].lastUsed {
+			off = i
+		}
+	}
+	c.ext.stats.VictimSearches++
+	c.ext.stats.Probes += uint64(j)
+	return off
+}
+
+// QuotaUsage returns the number of entries each class with a quota given
+// WithQuotas holds, and what they cost, keyed by class name.  It returns
+// nil if the LRU wasn't created WithQuotas.
+func (c *LRU[K, V]) QuotaUsage() map[string]Quota {
+	if c.ext.quotas == nil {
+		return nil
+	}
+	usage := make(map[string]Quota, len(c.ext.usage))
+	for class, u := range c.ext.usage {
+		usage[class] = Quota{Entries: u.entries, Cost: u.cost}
+	}
+	return usage
+}
+
+// validateQuotas checks that each class's usage matches the entries
+// cached.
+func (c *LRU[K, V]) validateQuotas() error {
+	if c.ext.quotas == nil || c.ext.classify == nil {
+		return nil
+	}
+	counted := make(map[string]int)
+	for i := range c.data {
+		if ent := &c.data[i]; ent.lastUsed != 0 && !c.invalidated(i) {
+			class := c.ext.classify(ent.key)
+			if _, ok := c.ext.quotas[class]; ok {
+				counted[class]++
+			}
+		}
+	}
+	for class, u := range c.ext.usage {
+		if u.entries != counted[class] {
+			return fmt.Errorf("class %q holds %d entries, but %d counted", class, counted[class], u.entries)
+		}
+		delete(counted, class)
+	}
+	for class, n := range counted {
+		if n > 0 {
+			return fmt.Errorf("class %q holds %d entries, but none counted", class, n)
+		}
+	}
+	return nil
+}
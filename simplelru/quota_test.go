@@ -0,0 +1,101 @@
+package simplelru
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func tenant(key string) string {
+	class, _, _ := strings.Cut(key, "/")
+	return class
+}
+
+func TestLRU_Quotas(t *testing.T) {
+	l, err := NewLRU[string, int](64, nil, WithClassifier[string, int](tenant), WithQuotas[string, int](map[string]Quota{
+		"noisy": {Entries: 8},
+	}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 16; i++ {
+		l.Add("quiet/"+strconv.Itoa(i), i)
+	}
+	// the noisy tenant's keys are always the most recently used, but
+	// can only displace each other
+	for i := 0; i < 1000; i++ {
+		l.Add("noisy/"+strconv.Itoa(i), i)
+	}
+	if usage := l.QuotaUsage(); usage["noisy"].Entries != 8 {
+		t.Fatalf("expected the noisy tenant to be held to 8 entries, got %+v", usage)
+	}
+	for i := 0; i < 16; i++ {
+		if !l.Contains("quiet/" + strconv.Itoa(i)) {
+			t.Fatalf("expected quiet/%d to be kept", i)
+		}
+	}
+	if n := l.Len(); n != 24 {
+		t.Fatalf("expected 24 entries, got %d", n)
+	}
+	if cs := l.ClassStats()["noisy"]; cs.QuotaEvictions != 992 || cs.Evictions != 992 {
+		t.Fatalf("bad class stats %+v", cs)
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// removals and purges keep the usage in step
+	l.Remove("noisy/999")
+	if usage := l.QuotaUsage(); usage["noisy"].Entries != 7 {
+		t.Fatalf("expected 7 entries after a removal, got %+v", usage)
+	}
+	l.Purge()
+	if usage := l.QuotaUsage(); usage["noisy"].Entries != 0 {
+		t.Fatalf("expected no entries after a purge, got %+v", usage)
+	}
+}
+
+func TestLRU_QuotaCost(t *testing.T) {
+	var reasons []EvictReason
+	l, err := NewLRU[string, int](64, nil,
+		WithClassifier[string, int](tenant),
+		WithCost[string, int](func(key string, value int) int64 { return int64(value) }),
+		WithQuotas[string, int](map[string]Quota{"a": {Cost: 100}}),
+		WithOnEvictInfo[string, int](func(key string, value int, info EvictionInfo) {
+			reasons = append(reasons, info.Reason)
+		}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		l.Add("a/"+strconv.Itoa(i), 10)
+	}
+	if len(reasons) != 0 {
+		t.Fatalf("expected no evictions within the quota, got %v", reasons)
+	}
+	// one entry costing 50 has to displace five
+	if !l.Add("a/big", 50) {
+		t.Fatalf("expected an eviction")
+	}
+	if usage := l.QuotaUsage()["a"]; usage.Cost > 100 || usage.Entries != 6 {
+		t.Fatalf("bad usage %+v", usage)
+	}
+	if len(reasons) != 5 || reasons[0] != EvictQuota {
+		t.Fatalf("bad reasons %v", reasons)
+	}
+	// a value over the quota on its own is rejected
+	if l.Add("a/huge", 101); l.Contains("a/huge") {
+		t.Fatalf("expected a value over the quota to be rejected")
+	}
+	if s := l.Stats(); s.Rejections != 1 {
+		t.Fatalf("expected a rejection, got %+v", s)
+	}
+	// classes without a quota are unbounded
+	l.Add("b/huge", 1000)
+	if !l.Contains("b/huge") {
+		t.Fatalf("expected a class without a quota to be unbounded")
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
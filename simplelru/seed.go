@@ -0,0 +1,45 @@
+package simplelru
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+func newRand() *rand.Rand {
+	seed, ok := cryptoSeed()
+	if !ok {
+		seed = fallbackSeed()
+	}
+	return rand.New(rand.NewSource(int64(seed)))
+}
+
+// fallbackSeeds counts calls to fallbackSeed, so that LRUs created in
+// the same clock tick still get different seeds.
+var fallbackSeeds atomic.Uint64
+
+// fallbackSeed makes a seed from the time, the address of a fresh
+// allocation (which varies with ASLR) and a counter, for when
+// crypto/rand is unavailable or fails.  It's fine for choosing eviction
+// candidates, but isn't unpredictable enough for anything an attacker
+// might want to guess.
+func fallbackSeed() uint64 {
+	h := uint64(time.Now().UnixNano())
+	h = mix64(h ^ uint64(allocAddr()))
+	h = mix64(h ^ fallbackSeeds.Add(1))
+	return h
+}
+
+// allocAddr returns the address of a new allocation.
+func allocAddr() uintptr {
+	return uintptr(unsafe.Pointer(new([16]byte)))
+}
+
+// mix64 is splitmix64's finalizer.
+func mix64(h uint64) uint64 {
+	h += 0x9e3779b97f4a7c15
+	h = (h ^ (h >> 30)) * 0xbf58476d1ce4e5b9
+	h = (h ^ (h >> 27)) * 0x94d049bb133111eb
+	return h ^ (h >> 31)
+}
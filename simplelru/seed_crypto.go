@@ -0,0 +1,18 @@
+//go:build !tinygo
+
+package simplelru
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+)
+
+// cryptoSeed reads a seed from crypto/rand, reporting false if it
+// fails, as it can in sandboxes without a source of randomness.
+func cryptoSeed() (uint64, bool) {
+	var seedBytes [8]byte
+	if _, err := crand.Read(seedBytes[:]); err != nil {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64(seedBytes[:]), true
+}
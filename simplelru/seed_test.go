@@ -0,0 +1,14 @@
+package simplelru
+
+import "testing"
+
+func TestFallbackSeed(t *testing.T) {
+	seen := make(map[uint64]bool)
+	for i := 0; i < 1000; i++ {
+		seed := fallbackSeed()
+		if seen[seed] {
+			t.Fatalf("seed %d repeated after %d calls", seed, i)
+		}
+		seen[seed] = true
+	}
+}
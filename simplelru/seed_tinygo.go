@@ -0,0 +1,9 @@
+//go:build tinygo
+
+package simplelru
+
+// cryptoSeed always falls back under TinyGo, where crypto/rand isn't
+// implemented for every target.
+func cryptoSeed() (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,225 @@
+package simplelru
+
+import (
+	"errors"
+
+	"golang.org/x/exp/slices"
+)
+
+// DefaultWays is the number of entries per set of a SetAssociativeLRU
+// created with ways of zero: eight 64-bit timestamps fill a cache line.
+const DefaultWays = 8
+
+// SetAssociativeLRU is a non-thread safe fixed size cache laid out like a
+// hardware cache: its slots are divided into sets of a fixed number of
+// ways, a key can only be stored in the set its hash picks, and eviction
+// replaces the least recently used entry in that set.  Lookups and victim
+// selection scan a single set, with no index map, random probing or fill
+// shuffle, so they touch a few adjacent cache lines.  The price is
+// conflict misses: keys that hash to a busy set evict each other while
+// other sets have room, which more ways make rarer.  Keys are hashed with
+// HashKey, so key types other than strings, booleans and numbers are slow.
+type SetAssociativeLRU[K comparable, V any] struct {
+	ways    int
+	slots   []entry[K, V]
+	len     int
+	counter int64
+	onEvict EvictCallback[K, V]
+}
+
+var _ LRUCache[int, int] = (*SetAssociativeLRU[int, int])(nil)
+
+// NewSetAssociativeLRU constructs a SetAssociativeLRU holding size
+// entries, rounded up to a whole number of sets of ways entries each, or
+// of DefaultWays if ways is zero.
+func NewSetAssociativeLRU[K comparable, V any](size, ways int, onEvict EvictCallback[K, V]) (*SetAssociativeLRU[K, V], error) {
+	if size <= 0 {
+		return nil, errors.New("must provide a positive size")
+	}
+	if ways < 0 {
+		return nil, errors.New("must provide a non-negative number of ways")
+	}
+	if ways == 0 {
+		ways = DefaultWays
+	}
+	c := &SetAssociativeLRU[K, V]{
+		ways:    ways,
+		counter: 1,
+		onEvict: onEvict,
+	}
+	c.slots = make([]entry[K, V], c.roundUp(size))
+	return c, nil
+}
+
+// roundUp returns size rounded up to a whole number of sets.
+func (c *SetAssociativeLRU[K, V]) roundUp(size int) int {
+	return (size + c.ways - 1) / c.ways * c.ways
+}
+
+// set returns the slots key may be stored in.
+func (c *SetAssociativeLRU[K, V]) set(key K) []entry[K, V] {
+	sets := uint64(len(c.slots) / c.ways)
+	i := int(HashKey(key)%sets) * c.ways
+	return c.slots[i : i+c.ways : i+c.ways]
+}
+
+// find returns key's slot in set, or -1.
+func find[K comparable, V any](set []entry[K, V], key K) int {
+	for i := range set {
+		if set[i].lastUsed != 0 && set[i].key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Purge is used to completely clear the cache.
+func (c *SetAssociativeLRU[K, V]) Purge() {
+	for i := range c.slots {
+		if ent := c.slots[i]; ent.lastUsed != 0 && c.onEvict != nil {
+			c.onEvict(ent.key, ent.value)
+		}
+	}
+	clear(c.slots)
+	c.len = 0
+}
+
+// Add adds a value to the cache.  Returns true if an eviction occurred.
+func (c *SetAssociativeLRU[K, V]) Add(key K, value V) (evicted bool) {
+	set := c.set(key)
+	victim := 0
+	for i := range set {
+		if set[i].lastUsed == 0 {
+			// empty slots sort first, so this is the victim if none
+			// holds key
+			victim = i
+			continue
+		}
+		if set[i].key == key {
+			set[i].lastUsed = c.tick()
+			set[i].value = value
+			return false
+		}
+		if set[victim].lastUsed != 0 && set[i].lastUsed < set[victim].lastUsed {
+			victim = i
+		}
+	}
+	old := set[victim]
+	set[victim] = entry[K, V]{c.tick(), key, value}
+	if old.lastUsed == 0 {
+		c.len++
+		return false
+	}
+	if c.onEvict != nil {
+		c.onEvict(old.key, old.value)
+	}
+	return true
+}
+
+func (c *SetAssociativeLRU[K, V]) tick() int64 {
+	n := c.counter
+	c.counter++
+	return n
+}
+
+// Get looks up a key's value from the cache.
+func (c *SetAssociativeLRU[K, V]) Get(key K) (value V, ok bool) {
+	set := c.set(key)
+	if i := find(set, key); i >= 0 {
+		set[i].lastUsed = c.tick()
+		return set[i].value, true
+	}
+	return value, false
+}
+
+// Contains checks if a key is in the cache, without updating the
+// recent-ness.
+func (c *SetAssociativeLRU[K, V]) Contains(key K) (ok bool) {
+	return find(c.set(key), key) >= 0
+}
+
+// Peek returns the key value (or undefined if not found) without updating
+// the "recently used"-ness of the key.
+func (c *SetAssociativeLRU[K, V]) Peek(key K) (value V, ok bool) {
+	set := c.set(key)
+	if i := find(set, key); i >= 0 {
+		return set[i].value, true
+	}
+	return value, false
+}
+
+// Remove removes the provided key from the cache, returning if the
+// key was contained.
+func (c *SetAssociativeLRU[K, V]) Remove(key K) (present bool) {
+	set := c.set(key)
+	i := find(set, key)
+	if i < 0 {
+		return false
+	}
+	c.removeElement(&set[i])
+	return true
+}
+
+// RemoveOldest removes the least recently used item from the whole
+// cache.  Unlike eviction, it scans every set.
+func (c *SetAssociativeLRU[K, V]) RemoveOldest() (key K, value V, ok bool) {
+	oldest := -1
+	for i := range c.slots {
+		if lastUsed := c.slots[i].lastUsed; lastUsed != 0 && (oldest < 0 || lastUsed < c.slots[oldest].lastUsed) {
+			oldest = i
+		}
+	}
+	if oldest < 0 {
+		return key, value, false
+	}
+	ent := c.slots[oldest]
+	c.removeElement(&c.slots[oldest])
+	return ent.key, ent.value, true
+}
+
+func (c *SetAssociativeLRU[K, V]) removeElement(ent *entry[K, V]) {
+	old := *ent
+	*ent = entry[K, V]{}
+	c.len--
+	if c.onEvict != nil {
+		c.onEvict(old.key, old.value)
+	}
+}
+
+// Len returns the number of items in the cache.
+func (c *SetAssociativeLRU[K, V]) Len() int {
+	return c.len
+}
+
+// Cap returns the number of entries the cache can hold: its size rounded
+// up to a whole number of sets.
+func (c *SetAssociativeLRU[K, V]) Cap() int {
+	return len(c.slots)
+}
+
+// Resize changes the cache size, rounded up to a whole number of sets.
+// Keys move between sets, so entries are re-added most recently used
+// last, and those that no longer fit in their new set are evicted; that
+// can be more than the difference in size.
+func (c *SetAssociativeLRU[K, V]) Resize(size int) (evicted int) {
+	if size <= 0 {
+		size = 1
+	}
+	var entries []entry[K, V]
+	for _, ent := range c.slots {
+		if ent.lastUsed != 0 {
+			entries = append(entries, ent)
+		}
+	}
+	slices.SortFunc(entries, func(a, b entry[K, V]) bool {
+		return a.lastUsed < b.lastUsed
+	})
+	c.slots = make([]entry[K, V], c.roundUp(size))
+	c.len = 0
+	for _, ent := range entries {
+		if c.Add(ent.key, ent.value) {
+			evicted++
+		}
+	}
+	return evicted
+}
@@ -0,0 +1,167 @@
+package simplelru
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSetAssociativeLRU(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k, v int) {
+		if k != v {
+			t.Fatalf("Evict values not equal (%v!=%v)", k, v)
+		}
+		evictCounter++
+	}
+	l, err := NewSetAssociativeLRU[int, int](100, 4, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if l.Cap() != 100 {
+		t.Fatalf("bad cap: %v", l.Cap())
+	}
+
+	for i := 0; i < 1000; i++ {
+		l.Add(i, i)
+	}
+	if l.Len() != 100 {
+		t.Fatalf("expected a full cache, got %v", l.Len())
+	}
+	if evictCounter != 900 {
+		t.Fatalf("bad evict count: %v", evictCounter)
+	}
+	sameSet := func(a, b int) bool {
+		return &l.set(a)[0] == &l.set(b)[0]
+	}
+	// each set holds its four most recently added keys
+	for i := 0; i < 1000; i++ {
+		newer := 0
+		for j := i + 1; j < 1000; j++ {
+			if sameSet(i, j) {
+				newer++
+			}
+		}
+		if l.Contains(i) != (newer < 4) {
+			t.Fatalf("%d: contains %v with %d newer keys in its set", i, l.Contains(i), newer)
+		}
+	}
+
+	// a key that's used stays, and the set's least recently used goes
+	key, last := 996, 0
+	l.Get(key)
+	for i, added := 1000, 0; added < 3; i++ {
+		if sameSet(i, key) {
+			l.Add(i, i)
+			last = i
+			added++
+		}
+	}
+	if !l.Contains(key) {
+		t.Fatalf("expected %d to survive", key)
+	}
+	for i := last + 1; i < last+1000; i++ {
+		if sameSet(i, key) {
+			l.Add(i, i)
+			break
+		}
+	}
+	if l.Contains(key) {
+		t.Fatalf("expected %d to be evicted", key)
+	}
+
+	if !l.Remove(last) || l.Contains(last) {
+		t.Fatalf("bad remove")
+	}
+	n := l.Len()
+	if _, _, ok := l.RemoveOldest(); !ok || l.Len() != n-1 {
+		t.Fatalf("bad remove oldest")
+	}
+	l.Purge()
+	if l.Len() != 0 || l.Contains(998) {
+		t.Fatalf("expected an empty cache")
+	}
+}
+
+func TestSetAssociativeLRU_Resize(t *testing.T) {
+	l, err := NewSetAssociativeLRU[int, int](64, 0, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 64; i++ {
+		l.Add(i, i)
+	}
+	n := l.Len()
+	evicted := l.Resize(20)
+	if l.Cap() != 24 {
+		t.Fatalf("expected the size to round up to 3 sets, got %v", l.Cap())
+	}
+	if l.Len() != n-evicted || l.Len() > 24 {
+		t.Fatalf("bad len %v after evicting %v of %v", l.Len(), evicted, n)
+	}
+	// the most recently added key always survives
+	if !l.Contains(63) {
+		t.Fatalf("expected the newest key to survive")
+	}
+}
+
+// Test that the hit ratio of a set-associative cache on a skewed workload
+// is close to an exact LRU's
+func TestSetAssociativeLRU_HitRatio(t *testing.T) {
+	exact, err := NewExactLRU[int, int](1024, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	set, err := NewSetAssociativeLRU[int, int](1024, 16, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(rng, 1.1, 1, 1<<16)
+	hits := map[string]int{}
+	for i := 0; i < 200000; i++ {
+		key := int(zipf.Uint64())
+		for name, c := range map[string]LRUCache[int, int]{"exact": exact, "set": set} {
+			if _, ok := c.Get(key); ok {
+				hits[name]++
+			} else {
+				c.Add(key, key)
+			}
+		}
+	}
+	if ratio := float64(hits["set"]) / float64(hits["exact"]); ratio < 0.95 {
+		t.Fatalf("set-associative hits %d are too far below exact's %d", hits["set"], hits["exact"])
+	}
+}
+
+func BenchmarkSetAssociativeLRU(b *testing.B) {
+	approx, err := NewLRU[int64, int64](8192, nil)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	set, err := NewSetAssociativeLRU[int64, int64](8192, 0, nil)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	trace := make([]int64, 1<<16)
+	for i := range trace {
+		trace[i] = rand.Int63() % 32768
+	}
+	for _, bc := range []struct {
+		name  string
+		cache LRUCache[int64, int64]
+	}{{"approximate", approx}, {"set", set}} {
+		b.Run(bc.name, func(b *testing.B) {
+			var hit, miss int
+			for i := 0; i < b.N; i++ {
+				key := trace[i%len(trace)]
+				if _, ok := bc.cache.Get(key); ok {
+					hit++
+				} else {
+					miss++
+					bc.cache.Add(key, key)
+				}
+			}
+			b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(hit+miss))
+		})
+	}
+}
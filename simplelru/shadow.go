@@ -0,0 +1,190 @@
+package simplelru
+
+import (
+	"errors"
+	"sync"
+)
+
+// ShadowConfig describes a candidate cache configuration for a Shadow to
+// simulate.
+type ShadowConfig struct {
+	// Size is the number of entries the candidate holds.
+	Size int
+	// Probes is the number of entries the candidate samples when
+	// choosing an eviction victim, as WithProbes sets, or 0 for the
+	// default.  It is ignored if Exact is set.
+	Probes int
+	// Exact makes the candidate a true LRU rather than an approximate
+	// one.
+	Exact bool
+}
+
+// ShadowStats is a point-in-time snapshot of a Shadow's counters.
+type ShadowStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// HitRatio returns the fraction of lookups that would have been hits, or
+// 0 if there have been no lookups.
+func (s ShadowStats) HitRatio() float64 {
+	return ratio(s.Hits, s.Misses)
+}
+
+// Shadow is a keys-only cache with a candidate configuration, fed the
+// same lookups, additions and removals as a real cache, to measure the
+// hit ratio the candidate would achieve in production without serving
+// from it.  Unlike a MissRatioCurve, which samples keys, a Shadow sees
+// every operation, so it costs a lock, a hash and an entry per key, but
+// can simulate any configuration.  Keys a lookup misses are added, as if
+// the caller filled the cache on every miss.
+//
+// A Shadow is safe for concurrent use, so a single instance can be shared
+// by every shard of a sharded cache.
+type Shadow struct {
+	mu     sync.Mutex
+	approx *LRU[uint64, struct{}]
+	exact  *ExactLRU[uint64, struct{}]
+	stats  ShadowStats
+}
+
+// NewShadow returns a Shadow simulating config.
+func NewShadow(config ShadowConfig) (*Shadow, error) {
+	if config.Size <= 0 {
+		return nil, errors.New("must provide a positive size")
+	}
+	s := &Shadow{}
+	var err error
+	if config.Exact {
+		s.exact, err = NewExactLRU[uint64, struct{}](config.Size, s.evicted)
+	} else {
+		s.approx, err = NewLRU[uint64, struct{}](config.Size, s.evicted, WithProbes[uint64, struct{}](config.Probes))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Shadow) evicted(uint64, struct{}) {
+	s.stats.Evictions++
+}
+
+func (s *Shadow) recordLookup(hash uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var hit bool
+	if s.exact != nil {
+		_, hit = s.exact.Get(hash)
+	} else {
+		_, hit = s.approx.Get(hash)
+	}
+	if hit {
+		s.stats.Hits++
+		return
+	}
+	s.stats.Misses++
+	s.add(hash)
+}
+
+func (s *Shadow) recordAdd(hash uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add(hash)
+}
+
+func (s *Shadow) add(hash uint64) {
+	if s.exact != nil {
+		s.exact.Add(hash, struct{}{})
+	} else {
+		s.approx.Add(hash, struct{}{})
+	}
+}
+
+func (s *Shadow) recordRemove(hash uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.exact != nil {
+		s.exact.Remove(hash)
+	} else {
+		s.approx.Remove(hash)
+	}
+}
+
+// Stats returns a snapshot of the shadow's counters.
+func (s *Shadow) Stats() ShadowStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// Len returns the number of keys in the shadow.
+func (s *Shadow) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.exact != nil {
+		return s.exact.Len()
+	}
+	return s.approx.Len()
+}
+
+// WithShadow feeds the LRU's lookups, additions and removals to s, under
+// name, so that ShadowStats can report how a candidate configuration would
+// perform.  It can be given more than once, with different names.
+func WithShadow[K comparable, V any](name string, s *Shadow) Option[K, V] {
+	return func(c *LRU[K, V]) {
+		if c.ext.shadows == nil {
+			c.ext.shadows = make(map[string]*Shadow)
+		}
+		c.ext.shadows[name] = s
+	}
+}
+
+// ShadowStats returns a snapshot of the counters of each shadow given
+// WithShadow, by name, or nil if there are none.
+func (c *LRU[K, V]) ShadowStats() map[string]ShadowStats {
+	return shadowStats(c.ext.shadows)
+}
+
+// shadowStats returns a snapshot of the counters of each of shadows.
+func shadowStats(shadows map[string]*Shadow) map[string]ShadowStats {
+	if len(shadows) == 0 {
+		return nil
+	}
+	stats := make(map[string]ShadowStats, len(shadows))
+	for name, s := range shadows {
+		stats[name] = s.Stats()
+	}
+	return stats
+}
+
+func (x *extension[K, V]) shadowLookup(key K) {
+	if len(x.shadows) == 0 {
+		return
+	}
+	hash := HashKey(key)
+	for _, s := range x.shadows {
+		s.recordLookup(hash)
+	}
+}
+
+func (x *extension[K, V]) shadowAdd(key K) {
+	if len(x.shadows) == 0 {
+		return
+	}
+	hash := HashKey(key)
+	for _, s := range x.shadows {
+		s.recordAdd(hash)
+	}
+}
+
+func (x *extension[K, V]) shadowRemove(key K) {
+	if len(x.shadows) == 0 {
+		return
+	}
+	hash := HashKey(key)
+	for _, s := range x.shadows {
+		s.recordRemove(hash)
+	}
+}
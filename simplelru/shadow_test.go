@@ -0,0 +1,49 @@
+package simplelru
+
+import "testing"
+
+func TestShadow(t *testing.T) {
+	bigger, err := NewShadow(ShadowConfig{Size: 256})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	exact, err := NewShadow(ShadowConfig{Size: 64, Exact: true})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l, err := NewLRU[int, int](64, nil, WithShadow[int, int]("bigger", bigger), WithShadow[int, int]("exact", exact))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// a working set of 128 keys fits the bigger shadow, but not the cache
+	for round := 0; round < 10; round++ {
+		for i := 0; i < 128; i++ {
+			if _, ok := l.Get(i); !ok {
+				l.Add(i, i)
+			}
+		}
+	}
+	stats := l.ShadowStats()
+	if s := stats["bigger"]; s.Hits != 9*128 || s.Misses != 128 || s.Evictions != 0 {
+		t.Fatalf("bad bigger stats: %+v", s)
+	}
+	// a true LRU of the same size thrashes on a loop larger than itself
+	if s := stats["exact"]; s.Hits != 0 || s.Misses != 10*128 {
+		t.Fatalf("bad exact stats: %+v", s)
+	}
+	if l.Stats().HitRatio() >= stats["bigger"].HitRatio() {
+		t.Fatalf("expected the bigger shadow to do better: %v", l.Stats().HitRatio())
+	}
+
+	l.Remove(0)
+	if bigger.Len() != 127 {
+		t.Fatalf("expected removals to reach the shadow, got %d keys", bigger.Len())
+	}
+	if _, err := NewShadow(ShadowConfig{}); err == nil {
+		t.Fatalf("expected a zero size to fail")
+	}
+	if plain, _ := NewLRU[int, int](8, nil); plain.ShadowStats() != nil {
+		t.Fatalf("expected no shadow stats")
+	}
+}
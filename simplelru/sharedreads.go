@@ -0,0 +1,87 @@
+package simplelru
+
+import "sync/atomic"
+
+// readBufferSize is the number of hits GetShared buffers before its
+// callers have to take the exclusive lock, which applies them.
+const readBufferSize = 64
+
+// WithSharedReads enables GetShared, for callers that guard the LRU with
+// a sync.RWMutex and want lookups to share the read lock.
+func WithSharedReads[K comparable, V any]() Option[K, V] {
+	return func(c *LRU[K, V]) {
+		c.ext.reads = &readBuffer{}
+	}
+}
+
+// readBuffer holds the hits GetShared made since ApplyReads last ran.
+// Each hit claims a slot with n, so concurrent callers never write the
+// same one, and ApplyReads, which runs under the exclusive lock, sees
+// every slot written under the shared lock before it.
+type readBuffer struct {
+	n      atomic.Int32
+	slots  [readBufferSize]int
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// sharedLookups reports whether a lookup only needs counting, so that
+// GetShared can do it; anything else recorded on lookups, like
+// WithClassifier's counters, needs the exclusive lock.
+func (x *extension[K, V]) sharedLookups() bool {
+	return x.reads != nil && x.classify == nil && x.hot == nil && x.trace == nil &&
+		x.mrc == nil && len(x.shadows) == 0 && x.accuracy == nil && x.churn == nil &&
+		x.onEvictInfo == nil && len(x.listen) == 0
+}
+
+// GetShared is Get for callers holding a lock shared with other readers,
+// who must call ApplyReads whenever they take the exclusive lock, before
+// doing anything else with the LRU.  Rather than update key's recency,
+// which other readers may be looking at, it buffers the hit for
+// ApplyReads.  It returns false for shared if it can't, because the
+// buffer is full, or the LRU wasn't created WithSharedReads, or records
+// more than hits and misses on lookups; the caller must then call Get
+// under the exclusive lock instead.
+func (c *LRU[K, V]) GetShared(key K) (value V, ok, shared bool) {
+	r := c.ext.reads
+	if !c.ext.sharedLookups() {
+		return value, false, false
+	}
+	i, ok := c.items[key]
+	if !ok || c.invalidated(i) {
+		r.misses.Add(1)
+		return value, false, true
+	}
+	if !c.ext.frozen {
+		n := r.n.Add(1) - 1
+		if n >= readBufferSize {
+			return value, false, false
+		}
+		r.slots[n] = i
+	}
+	r.hits.Add(1)
+	return c.data[i].value, true, true
+}
+
+// ApplyReads updates the recency of the entries GetShared hit since it
+// last ran, and counts GetShared's hits and misses.  It must be called
+// under the exclusive lock.
+func (c *LRU[K, V]) ApplyReads() {
+	r := c.ext.reads
+	if r == nil {
+		return
+	}
+	n := min(int(r.n.Load()), readBufferSize)
+	// nothing can have moved or replaced the entries since they were
+	// hit, as that would have needed the exclusive lock
+	for _, i := range r.slots[:n] {
+		c.data[i].lastUsed = c.getCounter()
+	}
+	r.n.Store(0)
+	hits, misses := r.hits.Swap(0), r.misses.Swap(0)
+	if hits+misses > 0 {
+		c.ext.stats.Hits += hits
+		c.ext.stats.Misses += misses
+		c.ext.window.recordMany(hits, misses)
+	}
+}
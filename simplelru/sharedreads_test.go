@@ -0,0 +1,74 @@
+package simplelru
+
+import "testing"
+
+func TestLRU_GetShared(t *testing.T) {
+	l, err := NewLRU[int, int](4, nil, WithSharedReads[int, int]())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+	before := l.data[l.items[0]].lastUsed
+	if v, ok, shared := l.GetShared(0); !shared || !ok || v != 0 {
+		t.Fatalf("expected a shared hit, got %v %v %v", v, ok, shared)
+	}
+	if _, ok, shared := l.GetShared(10); !shared || ok {
+		t.Fatalf("expected a shared miss, got %v %v", ok, shared)
+	}
+	// the hit is buffered, not applied
+	if l.data[l.items[0]].lastUsed != before {
+		t.Fatalf("expected GetShared to leave recency alone")
+	}
+	if s := l.Stats(); s.Hits != 1 || s.Misses != 1 {
+		t.Fatalf("expected pending lookups counted, got %+v", s)
+	}
+
+	l.ApplyReads()
+	if l.data[l.items[0]].lastUsed <= before {
+		t.Fatalf("expected ApplyReads to update recency")
+	}
+	if s := l.Stats(); s.Hits != 1 || s.Misses != 1 {
+		t.Fatalf("expected lookups counted once, got %+v", s)
+	}
+	// 0 is now the most recently used, so 1 is evicted in its place
+	l.Add(4, 4)
+	if !l.Contains(0) || l.Contains(1) {
+		t.Fatalf("expected the applied hit to protect 0")
+	}
+
+	// once the buffer is full, callers have to fall back to Get
+	for i := 0; i < readBufferSize; i++ {
+		if _, _, shared := l.GetShared(2); !shared {
+			t.Fatalf("expected hit %d to be buffered", i)
+		}
+	}
+	if _, _, shared := l.GetShared(2); shared {
+		t.Fatalf("expected a full buffer to refuse the hit")
+	}
+	l.ApplyReads()
+	if _, _, shared := l.GetShared(2); !shared {
+		t.Fatalf("expected ApplyReads to empty the buffer")
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// lookups recording more than hits and misses aren't shared
+	l, err = NewLRU[int, int](4, nil, WithSharedReads[int, int](), WithClassifier[int, int](func(int) string { return "" }))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(0, 0)
+	if _, _, shared := l.GetShared(0); shared {
+		t.Fatalf("expected a classified lookup not to be shared")
+	}
+	l, err = NewLRU[int, int](4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, _, shared := l.GetShared(0); shared {
+		t.Fatalf("expected GetShared to need WithSharedReads")
+	}
+}
@@ -0,0 +1,307 @@
+package simplelru
+
+import (
+	"context"
+	"log/slog"
+)
+
+const (
+	// hitWindowBuckets is the number of buckets in the sliding hit-ratio
+	// window, and hitWindowBucketSize is the number of lookups each bucket
+	// covers.  Together they bound how far back HitRatio can look.
+	hitWindowBuckets    = 64
+	hitWindowBucketSize = 256
+
+	// evictionLogSampleEvery is the number of evictions per eviction
+	// logged WithLogger.
+	evictionLogSampleEvery = 1024
+)
+
+// Stats is a point-in-time snapshot of a cache's counters.
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// Inserts counts the keys added that weren't already cached.
+	Inserts uint64
+	// Rejections counts additions that were rejected, by the limiter
+	// given WithAdmissionLimiter, by the function given WithOnAdmit, or
+	// for being larger than WithMaxValueSize allows.
+	Rejections uint64
+	// EvictionAge records how long evicted entries had gone unused, in
+	// ticks of the cache's logical clock (which advances once per Add or
+	// Get).  If entries are routinely evicted shortly after their last
+	// use, the cache is undersized.
+	EvictionAge Histogram
+	// ValueSize records the sizes of the values added, including those
+	// rejected, as measured by the function given WithMaxValueSize, or
+	// nothing if none was.  Its Delta between snapshots shows the sizes
+	// being cached lately, so that values growing, and using more memory
+	// for the same number of entries, stand out.
+	ValueSize Histogram
+	// VictimSearches counts the samplings of the cache for a slot to
+	// evict, Probes the slots they looked at, and EmptyProbes those of
+	// them that were empty: never filled, emptied by Remove, or
+	// invalidated.  Together they show how well the approximation is
+	// working; see ProbesPerEviction and EmptyProbeRate.
+	VictimSearches uint64
+	Probes         uint64
+	EmptyProbes    uint64
+	// SampledEvictions counts the evictions of entries sampled
+	// WithChurnTracking, SampledLifetime totals how long they lasted, in
+	// ticks of the cache's logical clock, and OneHitWonders counts those
+	// that were never read.  See AvgLifetime and OneHitWonderRatio.
+	SampledEvictions uint64
+	SampledLifetime  uint64
+	OneHitWonders    uint64
+	// AccuracyLookups counts the lookups of keys sampled
+	// WithAccuracyTracking, ExtraMisses those that missed but would have
+	// hit in an exact LRU, and ExtraHits those that hit but would have
+	// missed.  AccuracyEvictions counts the sampled keys evicted for
+	// capacity, and PrematureEvictions those an exact LRU would still
+	// have held.  See ExtraMissRate and PrematureEvictionRate.
+	AccuracyLookups    uint64
+	ExtraMisses        uint64
+	ExtraHits          uint64
+	AccuracyEvictions  uint64
+	PrematureEvictions uint64
+}
+
+// Merge adds the counters in other to s, for combining the stats of
+// several caches or shards.
+func (s *Stats) Merge(other *Stats) {
+	s.Hits += other.Hits
+	s.Misses += other.Misses
+	s.Evictions += other.Evictions
+	s.Inserts += other.Inserts
+	s.Rejections += other.Rejections
+	s.EvictionAge.Merge(&other.EvictionAge)
+	s.ValueSize.Merge(&other.ValueSize)
+	s.VictimSearches += other.VictimSearches
+	s.Probes += other.Probes
+	s.EmptyProbes += other.EmptyProbes
+	s.SampledEvictions += other.SampledEvictions
+	s.SampledLifetime += other.SampledLifetime
+	s.OneHitWonders += other.OneHitWonders
+	s.AccuracyLookups += other.AccuracyLookups
+	s.ExtraMisses += other.ExtraMisses
+	s.ExtraHits += other.ExtraHits
+	s.AccuracyEvictions += other.AccuracyEvictions
+	s.PrematureEvictions += other.PrematureEvictions
+}
+
+// ClassStats holds the counters for a single class of keys, as
+// determined by the classifier passed to WithClassifier.
+type ClassStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// QuotaEvictions counts the evictions that kept the class within
+	// its quota given WithQuotas, which are also counted in Evictions.
+	QuotaEvictions uint64
+}
+
+// HitRatio returns the fraction of lookups that were hits over the
+// lifetime of the cache, or 0 if there have been no lookups.
+func (s Stats) HitRatio() float64 {
+	return ratio(s.Hits, s.Misses)
+}
+
+// ProbesPerEviction returns the average number of slots sampled per
+// search for a slot to evict, or 0 if there have been none.  It exceeds
+// WithProbes only when pinned entries force wider searches.
+func (s Stats) ProbesPerEviction() float64 {
+	if s.VictimSearches == 0 {
+		return 0
+	}
+	return float64(s.Probes) / float64(s.VictimSearches)
+}
+
+// EmptyProbeRate returns the fraction of sampled slots that were empty,
+// or 0 if none have been sampled.  Empty slots are filled rather than
+// evicted, so a high rate means many additions are evicting nothing,
+// and the samples are comparing fewer live entries than WithProbes.
+func (s Stats) EmptyProbeRate() float64 {
+	if s.Probes == 0 {
+		return 0
+	}
+	return float64(s.EmptyProbes) / float64(s.Probes)
+}
+
+func ratio(hits, misses uint64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+// hitWindow is a ring buffer of lookup outcomes, bucketed by lookup count
+// rather than wall-clock time so that recording a lookup is just a couple
+// of increments.
+type hitWindow struct {
+	buckets [hitWindowBuckets]struct {
+		hits, misses uint32
+	}
+	cur int
+}
+
+func (w *hitWindow) record(hit bool) {
+	b := &w.buckets[w.cur]
+	if hit {
+		b.hits++
+	} else {
+		b.misses++
+	}
+	if b.hits+b.misses >= hitWindowBucketSize {
+		w.cur = (w.cur + 1) % hitWindowBuckets
+		w.buckets[w.cur].hits, w.buckets[w.cur].misses = 0, 0
+	}
+}
+
+// recordMany records hits and misses at once, as GetShared's are,
+// spreading them across buckets in proportion.
+func (w *hitWindow) recordMany(hits, misses uint64) {
+	for hits+misses > 0 {
+		b := &w.buckets[w.cur]
+		room := uint64(hitWindowBucketSize - b.hits - b.misses)
+		h := min(hits, hits*room/(hits+misses))
+		m := min(misses, room-h)
+		if h+m < room {
+			h = min(hits, room-m)
+		}
+		b.hits += uint32(h)
+		b.misses += uint32(m)
+		hits -= h
+		misses -= m
+		if b.hits+b.misses >= hitWindowBucketSize {
+			w.cur = (w.cur + 1) % hitWindowBuckets
+			w.buckets[w.cur].hits, w.buckets[w.cur].misses = 0, 0
+		}
+	}
+}
+
+// counts returns the number of hits and misses among (approximately) the
+// most recent window lookups.  The result is rounded up to whole buckets,
+// and is capped at the size of the ring.
+func (w *hitWindow) counts(window int) (hits, misses uint64) {
+	for i := 0; i < hitWindowBuckets; i++ {
+		if int(hits+misses) >= window {
+			break
+		}
+		b := &w.buckets[(w.cur-i+hitWindowBuckets)%hitWindowBuckets]
+		hits += uint64(b.hits)
+		misses += uint64(b.misses)
+	}
+	return hits, misses
+}
+
+func (x *extension[K, V]) recordLookup(key K, hit bool) {
+	if hit {
+		x.stats.Hits++
+	} else {
+		x.stats.Misses++
+	}
+	x.window.record(hit)
+	if hit {
+		x.recordRead(key)
+		x.recordHit(key)
+	}
+	x.recordTrace(TraceGet, key, hit)
+	if x.mrc != nil {
+		x.mrc.recordLookup(HashKey(key))
+	}
+	x.shadowLookup(key)
+	x.compareLookup(key, hit)
+	if x.hot != nil {
+		x.recordHot(key)
+	}
+	if x.classify != nil {
+		cs := x.class(key)
+		if hit {
+			cs.Hits++
+		} else {
+			cs.Misses++
+		}
+	}
+}
+
+func (x *extension[K, V]) recordTrace(op TraceOp, key K, hit bool) {
+	if x.trace != nil {
+		x.trace.record(op, HashKey(key), hit)
+	}
+}
+
+func (x *extension[K, V]) recordEviction(key K, age int64, reason EvictReason) {
+	x.stats.Evictions++
+	x.stats.EvictionAge.Record(uint64(age))
+	if x.sampler != nil && x.stats.Evictions%x.sampler.every == 0 {
+		x.sampler.fn(key, age, reason)
+	}
+	if x.logger != nil && x.stats.Evictions%evictionLogSampleEvery == 1 {
+		x.logger.LogAttrs(context.Background(), slog.LevelDebug, "lru: evicted entry",
+			slog.Any("key", key),
+			slog.Int64("age", age),
+			slog.String("reason", reason.String()),
+			slog.Uint64("evictions", x.stats.Evictions))
+	}
+	if x.classify != nil {
+		x.class(key).Evictions++
+	}
+}
+
+func (x *extension[K, V]) class(key K) *ClassStats {
+	name := x.classify(key)
+	cs, ok := x.classes[name]
+	if !ok {
+		cs = &ClassStats{}
+		x.classes[name] = cs
+	}
+	return cs
+}
+
+// Stats returns a snapshot of the cache's counters, including the hits
+// and misses of GetShared that ApplyReads hasn't counted yet.
+func (c *LRU[K, V]) Stats() Stats {
+	s := c.ext.stats
+	if r := c.ext.reads; r != nil {
+		s.Hits += r.hits.Load()
+		s.Misses += r.misses.Load()
+	}
+	return s
+}
+
+// ClassStats returns a snapshot of the per-class counters, keyed by class
+// name.  It returns nil if no classifier was configured.
+func (c *LRU[K, V]) ClassStats() map[string]ClassStats {
+	if c.ext.classify == nil {
+		return nil
+	}
+	classes := make(map[string]ClassStats, len(c.ext.classes))
+	for name, cs := range c.ext.classes {
+		classes[name] = *cs
+	}
+	return classes
+}
+
+// WindowCounts returns the number of hits and misses among roughly the
+// last window lookups.
+func (c *LRU[K, V]) WindowCounts(window int) (hits, misses uint64) {
+	if r := c.ext.reads; r != nil {
+		// GetShared's lookups not yet applied are the most recent
+		hits, misses = r.hits.Load(), r.misses.Load()
+	}
+	h, m := c.ext.window.counts(window - int(hits+misses))
+	return hits + h, misses + m
+}
+
+// HitRatio returns the hit ratio over roughly the last window lookups,
+// so that callers can react to recent behavior rather than lifetime
+// counters.  It returns 0 if there have been no lookups.
+func (c *LRU[K, V]) HitRatio(window int) float64 {
+	return ratio(c.ext.window.counts(window))
+}
+
+type evictionSampler[K comparable] struct {
+	every uint64
+	fn    func(key K, age int64, reason EvictReason)
+}
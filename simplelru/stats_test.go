@@ -0,0 +1,176 @@
+package simplelru
+
+import (
+	"math"
+	"testing"
+)
+
+// Test that HitRatio reflects recent lookups rather than the lifetime total
+func TestLRU_HitRatio(t *testing.T) {
+	l, err := NewLRU[int, int](128, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if r := l.HitRatio(1024); r != 0 {
+		t.Fatalf("expected 0 hit ratio with no lookups, got %v", r)
+	}
+
+	l.Add(1, 1)
+	for i := 0; i < 8*hitWindowBucketSize; i++ {
+		l.Get(1)
+	}
+	for i := 0; i < 2*hitWindowBucketSize; i++ {
+		l.Get(2)
+	}
+
+	if r := l.HitRatio(2 * hitWindowBucketSize); r != 0 {
+		t.Errorf("expected recent window to be all misses, got %v", r)
+	}
+	if r := l.HitRatio(10 * hitWindowBucketSize); math.Abs(r-0.8) > 0.01 {
+		t.Errorf("expected hit ratio of 0.8, got %v", r)
+	}
+
+	stats := l.Stats()
+	if stats.Hits != 8*hitWindowBucketSize || stats.Misses != 2*hitWindowBucketSize {
+		t.Errorf("bad stats: %+v", stats)
+	}
+	if r := stats.HitRatio(); math.Abs(r-0.8) > 0.01 {
+		t.Errorf("expected lifetime hit ratio of 0.8, got %v", r)
+	}
+}
+
+// Test that the window only remembers a bounded number of lookups
+func TestLRU_HitRatioWindowBounded(t *testing.T) {
+	l, err := NewLRU[int, int](128, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 2*hitWindowBuckets*hitWindowBucketSize; i++ {
+		l.Get(1)
+	}
+	l.Add(1, 1)
+	for i := 0; i < hitWindowBuckets*hitWindowBucketSize; i++ {
+		l.Get(1)
+	}
+
+	if r := l.HitRatio(math.MaxInt); r != 1 {
+		t.Errorf("old misses should have aged out of the window, got %v", r)
+	}
+}
+
+// Test that hits, misses and evictions are attributed to key classes
+func TestLRU_ClassStats(t *testing.T) {
+	classify := func(k int) string {
+		if k%2 == 0 {
+			return "even"
+		}
+		return "odd"
+	}
+	l, err := NewLRU[int, int](2, nil, WithClassifier[int, int](classify))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Get(1)
+	l.Get(2)
+	l.Get(4)
+	l.Add(3, 3)
+
+	classes := l.ClassStats()
+	if odd := classes["odd"]; odd.Hits != 1 || odd.Misses != 0 {
+		t.Errorf("bad odd stats: %+v", odd)
+	}
+	if even := classes["even"]; even.Hits != 1 || even.Misses != 1 {
+		t.Errorf("bad even stats: %+v", even)
+	}
+	if classes["odd"].Evictions+classes["even"].Evictions != 1 {
+		t.Errorf("expected a single eviction: %+v", classes)
+	}
+	if l.Stats().Evictions != 1 {
+		t.Errorf("expected a single eviction: %+v", l.Stats())
+	}
+}
+
+func TestLRU_EvictionAge(t *testing.T) {
+	l, err := NewLRU[int, int](16, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		l.Add(i, i)
+	}
+
+	stats := l.Stats()
+	if stats.EvictionAge.Count() != stats.Evictions || stats.Evictions != 1000-16 {
+		t.Fatalf("expected an age for each of %d evictions, got %d", stats.Evictions, stats.EvictionAge.Count())
+	}
+	// every entry is evicted after roughly 16 newer adds
+	if q := stats.EvictionAge.Quantile(0.5); q < 15 || q > 63 {
+		t.Errorf("unexpected median eviction age %d", q)
+	}
+}
+
+func TestLRU_EvictionSampler(t *testing.T) {
+	reasons := map[EvictReason]int{}
+	sampler := func(key int, age int64, reason EvictReason) {
+		if age <= 0 {
+			t.Errorf("expected a positive age, got %d", age)
+		}
+		reasons[reason]++
+	}
+	l, err := NewLRU[int, int](10, nil, WithEvictionSampler[int, int](10, sampler))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 110; i++ {
+		l.Add(i, i)
+	}
+	if reasons[EvictCapacity] != 10 {
+		t.Errorf("expected 10 sampled capacity evictions, got %v", reasons)
+	}
+
+	l.Resize(0)
+	if reasons[EvictResize] != 1 {
+		t.Errorf("expected 1 sampled resize eviction, got %v", reasons)
+	}
+}
+
+func TestLRU_ProbeStats(t *testing.T) {
+	l, err := NewLRU[int, int](128, nil, WithProbes[int, int](4))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		l.Add(i, i)
+	}
+	stats := l.Stats()
+	if stats.VictimSearches != 1000-128 || stats.ProbesPerEviction() != 4 {
+		t.Fatalf("expected %d searches of 4 probes, got %d of %v", 1000-128, stats.VictimSearches, stats.ProbesPerEviction())
+	}
+	if stats.EmptyProbes != 0 {
+		t.Fatalf("expected no empty probes of a full cache, got %d", stats.EmptyProbes)
+	}
+
+	// with half the slots empty, some probes find one
+	for i := 0; i < 1000; i += 2 {
+		l.Remove(i)
+	}
+	for i := 0; i < 32; i++ {
+		l.Add(1000+i, i)
+	}
+	stats = l.Stats()
+	if rate := stats.EmptyProbeRate(); rate <= 0 || rate >= 1 {
+		t.Fatalf("unexpected empty probe rate %v", rate)
+	}
+
+	l.EvictionCandidates(10)
+	if after := l.Stats(); after.Probes != stats.Probes {
+		t.Fatalf("expected EvictionCandidates not to count probes")
+	}
+}
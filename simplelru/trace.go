@@ -0,0 +1,167 @@
+package simplelru
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// TraceOp identifies the kind of operation a TraceRecord describes.
+type TraceOp uint8
+
+const (
+	TraceGet TraceOp = iota + 1
+	TraceAdd
+	TraceRemove
+)
+
+// traceRecordSize is the encoded size of a TraceRecord: the op, the hit
+// flag, the key hash and the timestamp.
+const traceRecordSize = 1 + 1 + 8 + 8
+
+// TraceRecord is a compact record of a single cache operation.
+type TraceRecord struct {
+	Op TraceOp
+	// Hit is whether the key was present: for TraceGet this is a cache
+	// hit, for TraceAdd it means an existing entry was updated, and for
+	// TraceRemove it means something was removed.
+	Hit     bool
+	KeyHash uint64
+	// Time is when the operation happened, in nanoseconds since the Unix
+	// epoch.
+	Time int64
+}
+
+// TraceWriter encodes TraceRecords to an underlying io.Writer.  It is
+// safe for concurrent use, so a single TraceWriter can be shared between
+// several caches or the shards of a sharded cache.  Records are buffered;
+// call Flush before closing the underlying writer.
+type TraceWriter struct {
+	mu  sync.Mutex
+	w   *bufio.Writer
+	err error
+}
+
+// NewTraceWriter returns a TraceWriter that writes to w.
+func NewTraceWriter(w io.Writer) *TraceWriter {
+	return &TraceWriter{w: bufio.NewWriter(w)}
+}
+
+// Write encodes a single record.  Once a write to the underlying writer
+// fails, all subsequent writes return the same error.
+func (t *TraceWriter) Write(r TraceRecord) error {
+	var buf [traceRecordSize]byte
+	buf[0] = byte(r.Op)
+	if r.Hit {
+		buf[1] = 1
+	}
+	binary.LittleEndian.PutUint64(buf[2:10], r.KeyHash)
+	binary.LittleEndian.PutUint64(buf[10:18], uint64(r.Time))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return t.err
+	}
+	_, t.err = t.w.Write(buf[:])
+	return t.err
+}
+
+// Flush writes any buffered records to the underlying writer.
+func (t *TraceWriter) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return t.err
+	}
+	t.err = t.w.Flush()
+	return t.err
+}
+
+// TraceReader decodes records written by a TraceWriter.
+type TraceReader struct {
+	r *bufio.Reader
+}
+
+// NewTraceReader returns a TraceReader that reads from r.
+func NewTraceReader(r io.Reader) *TraceReader {
+	return &TraceReader{r: bufio.NewReader(r)}
+}
+
+// Read returns the next record, or io.EOF when there are no more.
+func (t *TraceReader) Read() (TraceRecord, error) {
+	var buf [traceRecordSize]byte
+	if _, err := io.ReadFull(t.r, buf[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return TraceRecord{}, errors.New("truncated trace record")
+		}
+		return TraceRecord{}, err
+	}
+	return TraceRecord{
+		Op:      TraceOp(buf[0]),
+		Hit:     buf[1] != 0,
+		KeyHash: binary.LittleEndian.Uint64(buf[2:10]),
+		Time:    int64(binary.LittleEndian.Uint64(buf[10:18])),
+	}, nil
+}
+
+type tracer struct {
+	w           *TraceWriter
+	sampleEvery uint64
+}
+
+// record writes op to the trace if key is sampled.  Sampling is by key
+// hash rather than by operation, so that every operation on a sampled key
+// is recorded; that keeps the trace useful for simulating cache sizes.
+func (t *tracer) record(op TraceOp, hash uint64, hit bool) {
+	if hash%t.sampleEvery != 0 {
+		return
+	}
+	// errors are sticky in the TraceWriter, and are reported by Flush
+	_ = t.w.Write(TraceRecord{
+		Op:      op,
+		Hit:     hit,
+		KeyHash: hash,
+		Time:    time.Now().UnixNano(),
+	})
+}
+
+// TraceRecency replays the trace read from r and returns the hashes of
+// the keys it leaves cached, least recently used first, for warming up a
+// new cache in the order the traced one had.  A key is cached once it's
+// added, or hit by a lookup (if it was added before the trace started),
+// and isn't once it's removed or missed.  Evictions aren't traced, so the
+// result includes keys the traced cache had evicted; keep the most recent
+// of them that fit.
+func TraceRecency(r *TraceReader) ([]uint64, error) {
+	lastUsed := make(map[uint64]int64)
+	for seq := int64(1); ; seq++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch {
+		case rec.Op == TraceAdd, rec.Op == TraceGet && rec.Hit:
+			// sequence numbers order records exactly, even those
+			// with the same timestamp
+			lastUsed[rec.KeyHash] = seq
+		case rec.Op == TraceGet, rec.Op == TraceRemove:
+			delete(lastUsed, rec.KeyHash)
+		}
+	}
+	hashes := make([]uint64, 0, len(lastUsed))
+	for hash := range lastUsed {
+		hashes = append(hashes, hash)
+	}
+	slices.SortFunc(hashes, func(a, b uint64) bool {
+		return lastUsed[a] < lastUsed[b]
+	})
+	return hashes, nil
+}
@@ -0,0 +1,106 @@
+package simplelru
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestTraceRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewTraceWriter(&buf)
+	l, err := NewLRU[int, int](2, nil, WithTrace[int, int](w, 1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Get(1)
+	l.Add(1, 1)
+	l.Add(1, 2)
+	l.Get(1)
+	l.Remove(1)
+	l.Remove(1)
+	if err := w.Flush(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expected := []TraceRecord{
+		{Op: TraceGet, Hit: false},
+		{Op: TraceAdd, Hit: false},
+		{Op: TraceAdd, Hit: true},
+		{Op: TraceGet, Hit: true},
+		{Op: TraceRemove, Hit: true},
+		{Op: TraceRemove, Hit: false},
+	}
+	r := NewTraceReader(&buf)
+	for i, e := range expected {
+		rec, err := r.Read()
+		if err != nil {
+			t.Fatalf("record %d: err: %v", i, err)
+		}
+		if rec.Op != e.Op || rec.Hit != e.Hit || rec.KeyHash != HashKey(1) || rec.Time == 0 {
+			t.Errorf("record %d: got %+v, expected %+v", i, rec, e)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestTraceSampling(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewTraceWriter(&buf)
+	l, err := NewLRU[int, int](1024, nil, WithTrace[int, int](w, 16))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 4096; i++ {
+		l.Get(i)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	n := buf.Len() / traceRecordSize
+	if n < 128 || n > 512 {
+		t.Errorf("expected roughly 256 sampled records, got %d", n)
+	}
+}
+
+func TestTraceRecency(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewTraceWriter(&buf)
+	l, err := NewLRU[int, int](8, nil, WithTrace[int, int](w, 1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	l.Get(1)
+	l.Remove(2)
+	l.Add(4, 4)
+	l.Get(5)
+	if err := w.Flush(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	hashes, err := TraceRecency(NewTraceReader(&buf))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expected := []uint64{HashKey(3), HashKey(1), HashKey(4)}
+	if len(hashes) != len(expected) {
+		t.Fatalf("expected %d keys, got %d", len(expected), len(hashes))
+	}
+	for i := range expected {
+		if hashes[i] != expected[i] {
+			t.Fatalf("key %d: expected hash %x, got %x", i, expected[i], hashes[i])
+		}
+	}
+
+	if _, err := TraceRecency(NewTraceReader(bytes.NewReader([]byte{1, 2, 3}))); err == nil {
+		t.Fatalf("expected an error for a truncated trace")
+	}
+}
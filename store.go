@@ -0,0 +1,130 @@
+package lru
+
+import (
+	"context"
+	"errors"
+)
+
+// Store is a backing store, like a database or file store, that a cache
+// created WithStore writes through to.
+type Store[K comparable, V any] interface {
+	// Put stores value under key.
+	Put(ctx context.Context, key K, value V) error
+	// Delete removes key.  Deleting a key that isn't stored isn't an
+	// error.
+	Delete(ctx context.Context, key K) error
+}
+
+// ErrNoStore is returned by Set and Delete on a cache created without
+// WithStore.
+var ErrNoStore = errors.New("lru: no store configured")
+
+// WithStore makes the cache write-through: Set and Delete write to store
+// before updating the cache, and return its errors, unless the cache is
+// also created WithWriteBehind.  Add and Remove only update the cache, for
+// populating it from the store, as a loader does.
+func WithStore[K comparable, V any](store Store[K, V]) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.store = store
+	}
+}
+
+// storeWriter writes through to a Store.  Writes of the same key are
+// serialized, so that the cache ends up holding the value that was
+// stored last.
+type storeWriter[K comparable, V any] struct {
+	store Store[K, V]
+	locks keyLocks[K]
+}
+
+func newStoreWriter[K comparable, V any](store Store[K, V]) *storeWriter[K, V] {
+	if store == nil {
+		return nil
+	}
+	return &storeWriter[K, V]{store: store}
+}
+
+// set stores value, then adds it to the cache with add.  If the store
+// fails, key is removed from the cache with remove, since we can't know
+// whether the store still holds what the cache does.
+func (w *storeWriter[K, V]) set(ctx context.Context, key K, value V, add func(K, V) bool, remove func(K) bool) error {
+	mu := w.locks.lock(key)
+	defer mu.Unlock()
+	if err := w.store.Put(ctx, key, value); err != nil {
+		remove(key)
+		return err
+	}
+	add(key, value)
+	return nil
+}
+
+// delete deletes key from the store, and then from the cache with
+// remove, whether or not the store succeeded.
+func (w *storeWriter[K, V]) delete(ctx context.Context, key K, remove func(K) bool) error {
+	mu := w.locks.lock(key)
+	defer mu.Unlock()
+	err := w.store.Delete(ctx, key)
+	remove(key)
+	return err
+}
+
+// Set writes value to the store given WithStore, and if that succeeds,
+// adds it to the cache.  If the store fails, Set returns its error and
+// removes key from the cache.
+func (c *Cache[K, V]) Set(ctx context.Context, key K, value V) error {
+	if c.behind != nil {
+		return c.setBehind(ctx, key, pendingWrite[V]{value: value})
+	}
+	if c.writes == nil {
+		return ErrNoStore
+	}
+	if c.isClosed() {
+		return ErrClosed
+	}
+	return c.writes.set(ctx, key, value, c.Add, c.Remove)
+}
+
+// Delete deletes key from the store given WithStore and from the cache,
+// returning the store's error.
+func (c *Cache[K, V]) Delete(ctx context.Context, key K) error {
+	if c.behind != nil {
+		return c.setBehind(ctx, key, pendingWrite[V]{deleted: true})
+	}
+	if c.writes == nil {
+		return ErrNoStore
+	}
+	if c.isClosed() {
+		return ErrClosed
+	}
+	return c.writes.delete(ctx, key, c.Remove)
+}
+
+// Set writes value to the store given WithStore, and if that succeeds,
+// adds it to the cache.  See Cache.Set.
+func (c *ShardedCache[V]) Set(ctx context.Context, key string, value V) error {
+	if c.behind != nil {
+		return c.setBehind(ctx, key, pendingWrite[V]{value: value})
+	}
+	if c.writes == nil {
+		return ErrNoStore
+	}
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	return c.writes.set(ctx, key, value, c.Add, c.Remove)
+}
+
+// Delete deletes key from the store given WithStore and from the cache,
+// returning the store's error.
+func (c *ShardedCache[V]) Delete(ctx context.Context, key string) error {
+	if c.behind != nil {
+		return c.setBehind(ctx, key, pendingWrite[V]{deleted: true})
+	}
+	if c.writes == nil {
+		return ErrNoStore
+	}
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	return c.writes.delete(ctx, key, c.Remove)
+}
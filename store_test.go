@@ -0,0 +1,147 @@
+package lru
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// mapStore is a Store backed by a map, which fails while fail is set.
+type mapStore[K comparable, V any] struct {
+	mu   sync.Mutex
+	m    map[K]V
+	fail bool
+}
+
+func newMapStore[K comparable, V any]() *mapStore[K, V] {
+	return &mapStore[K, V]{m: make(map[K]V)}
+}
+
+func (s *mapStore[K, V]) Put(ctx context.Context, key K, value V) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errors.New("store unavailable")
+	}
+	s.m[key] = value
+	return nil
+}
+
+func (s *mapStore[K, V]) Delete(ctx context.Context, key K) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errors.New("store unavailable")
+	}
+	delete(s.m, key)
+	return nil
+}
+
+func (s *mapStore[K, V]) get(key K) (V, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.m[key]
+	return v, ok
+}
+
+func TestStore(t *testing.T) {
+	store := newMapStore[int, int]()
+	l, err := New[int, int](128, WithStore[int, int](store))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := l.Set(ctx, 1, 10); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if v, ok := store.get(1); !ok || v != 10 {
+		t.Fatalf("expected the value to be stored")
+	}
+	if v, ok := l.Peek(1); !ok || v != 10 {
+		t.Fatalf("expected the value to be cached")
+	}
+
+	// Add only updates the cache
+	l.Add(2, 20)
+	if _, ok := store.get(2); ok {
+		t.Fatalf("Add shouldn't write through")
+	}
+
+	store.fail = true
+	if err := l.Set(ctx, 1, 11); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if l.Contains(1) {
+		t.Fatalf("a failed write should remove the cached value")
+	}
+	l.Add(1, 10)
+	if err := l.Delete(ctx, 1); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if l.Contains(1) {
+		t.Fatalf("a failed delete should still remove the cached value")
+	}
+	store.fail = false
+
+	if err := l.Delete(ctx, 1); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := store.get(1); ok {
+		t.Fatalf("expected the value to be deleted")
+	}
+
+	plain, err := New[int, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if plain.Set(ctx, 1, 1) != ErrNoStore || plain.Delete(ctx, 1) != ErrNoStore {
+		t.Fatalf("expected ErrNoStore")
+	}
+}
+
+func TestShardedStore(t *testing.T) {
+	store := newMapStore[string, int]()
+	l, err := NewSharded[int](1024, 16, WithStore[string, int](store))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ctx := context.Background()
+
+	// concurrent writes of the same keys leave the cache agreeing with
+	// the store
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if err := l.Set(ctx, strconv.Itoa(i%16), g*1000+i); err != nil {
+					t.Errorf("err: %v", err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	for i := 0; i < 16; i++ {
+		k := strconv.Itoa(i)
+		stored, _ := store.get(k)
+		if cached, ok := l.Peek(k); !ok || cached != stored {
+			t.Fatalf("%s: cached %d, stored %d", k, cached, stored)
+		}
+	}
+
+	if err := l.Delete(ctx, "1"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := store.get("1"); ok || l.Contains("1") {
+		t.Fatalf("expected 1 to be deleted")
+	}
+	l.Close()
+	if l.Set(ctx, "1", 1) != ErrClosed {
+		t.Fatalf("expected ErrClosed")
+	}
+}
@@ -0,0 +1,206 @@
+package lru
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+// TieredCache is a thread-safe cache with two tiers: a small front tier
+// that is a strict LRU, for the hottest entries, in front of a large
+// ShardedCache.  New entries go into the back tier, and are promoted to
+// the front when they're hit there; the front tier's least recently used
+// entry is demoted to the back to make room.  An entry is in at most one
+// tier.  This bounds the approximation's error for the entries that are
+// used most, at the cost of a lock around the front tier that every hit
+// there, every promotion and every modification takes.
+type TieredCache[V any] struct {
+	mu    sync.Mutex
+	front *simplelru.ExactLRU[string, V]
+	back  *ShardedCache[V]
+	size  int
+
+	onEvict func(key string, value V)
+	// evicted holds entries evicted while mu is held, to be passed to
+	// onEvict once it's released.  promoting is the key being moved to
+	// the front tier, whose removal from the back isn't an eviction.
+	evicted   []evictedEntry[string, V]
+	promoting string
+}
+
+// NewTiered creates a TieredCache with a front tier of frontSize entries
+// and a back tier of backSize entries spread across shardCount shards.
+// opts configure the back tier.
+func NewTiered[V any](frontSize, backSize, shardCount int, opts ...Option[string, V]) (*TieredCache[V], error) {
+	return NewTieredWithEvict[V](frontSize, backSize, shardCount, nil, opts...)
+}
+
+// NewTieredWithEvict constructs a TieredCache with the given eviction
+// callback, which is called for entries that leave the cache entirely,
+// not for moves between tiers.  As with NewWithEvict, it is called after
+// the cache's locks are released, so it may call back into the cache.
+func NewTieredWithEvict[V any](frontSize, backSize, shardCount int, onEvicted func(key string, value V), opts ...Option[string, V]) (*TieredCache[V], error) {
+	if frontSize <= 0 {
+		return nil, errors.New("must provide a positive front size")
+	}
+	front, err := simplelru.NewExactLRU[string, V](frontSize, nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &TieredCache[V]{
+		front:   front,
+		size:    frontSize,
+		onEvict: onEvicted,
+	}
+	var deferEvict func(key string, value V)
+	if onEvicted != nil {
+		deferEvict = c.deferEvict
+	}
+	c.back, err = NewShardedWithEvict[V](backSize, shardCount, deferEvict, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// deferEvict is the back tier's eviction callback.  Every change to the
+// back tier that could evict is made with mu held, so it is too.
+func (c *TieredCache[V]) deferEvict(key string, value V) {
+	if key == c.promoting {
+		return
+	}
+	c.evicted = append(c.evicted, evictedEntry[string, V]{key: key, value: value})
+}
+
+// unlock releases mu, then calls the eviction callback for the entries
+// evicted while it was held.
+func (c *TieredCache[V]) unlock() {
+	evicted := c.evicted
+	c.evicted = nil
+	c.mu.Unlock()
+	for _, e := range evicted {
+		c.onEvict(e.key, e.value)
+	}
+}
+
+// Add adds a value to the cache, updating it in place if it's in the
+// front tier and adding it to the back tier otherwise.  Returns true if
+// an eviction occurred.
+func (c *TieredCache[V]) Add(key string, value V) (evicted bool) {
+	c.mu.Lock()
+	defer c.unlock()
+	if c.front.Contains(key) {
+		c.front.Add(key, value)
+		return false
+	}
+	return c.back.Add(key, value)
+}
+
+// Get looks up a key's value from the cache, promoting it to the front
+// tier if it was found in the back.
+func (c *TieredCache[V]) Get(key string) (value V, ok bool) {
+	c.mu.Lock()
+	value, ok = c.front.Get(key)
+	c.mu.Unlock()
+	if ok {
+		return value, true
+	}
+	if _, ok := c.back.Get(key); !ok {
+		return value, false
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	// the entry may have been changed, removed or promoted by someone
+	// else since we looked
+	if value, ok = c.front.Get(key); ok {
+		return value, true
+	}
+	if value, ok = c.back.Peek(key); !ok {
+		return value, false
+	}
+	c.promote(key, value)
+	return value, true
+}
+
+// promote moves an entry from the back tier to the front, demoting the
+// front tier's least recently used entry if it's full.
+func (c *TieredCache[V]) promote(key string, value V) {
+	c.promoting = key
+	c.back.Remove(key)
+	c.promoting = ""
+	if c.front.Len() >= c.size {
+		if oldKey, oldValue, ok := c.front.RemoveOldest(); ok {
+			c.back.Add(oldKey, oldValue)
+		}
+	}
+	c.front.Add(key, value)
+}
+
+// Contains checks if a key is in the cache, without updating the
+// recent-ness or deleting it for being stale.
+func (c *TieredCache[V]) Contains(key string) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+// Peek returns the key value (or undefined if not found) without updating
+// the "recently used"-ness of the key or moving it between tiers.
+func (c *TieredCache[V]) Peek(key string) (value V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if value, ok = c.front.Peek(key); ok {
+		return value, true
+	}
+	return c.back.Peek(key)
+}
+
+// Remove removes the provided key from the cache.
+func (c *TieredCache[V]) Remove(key string) (present bool) {
+	c.mu.Lock()
+	defer c.unlock()
+	if value, ok := c.front.Peek(key); ok {
+		c.front.Remove(key)
+		if c.onEvict != nil {
+			c.evicted = append(c.evicted, evictedEntry[string, V]{key: key, value: value})
+		}
+		return true
+	}
+	return c.back.Remove(key)
+}
+
+// Purge is used to completely clear the cache.
+func (c *TieredCache[V]) Purge() {
+	c.mu.Lock()
+	defer c.unlock()
+	for {
+		key, value, ok := c.front.RemoveOldest()
+		if !ok {
+			break
+		}
+		if c.onEvict != nil {
+			c.evicted = append(c.evicted, evictedEntry[string, V]{key: key, value: value})
+		}
+	}
+	c.back.Purge()
+}
+
+// Len returns the number of items in the cache.
+func (c *TieredCache[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.front.Len() + c.back.Len()
+}
+
+// FrontLen returns the number of items in the front tier.
+func (c *TieredCache[V]) FrontLen() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.front.Len()
+}
+
+// Cap returns the maximum number of items the cache can hold.
+func (c *TieredCache[V]) Cap() int {
+	return c.size + c.back.Cap()
+}
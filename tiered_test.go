@@ -0,0 +1,120 @@
+package lru
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestTiered(t *testing.T) {
+	var evicted []string
+	c, err := NewTieredWithEvict[int](2, 64, 4, func(k string, v int) {
+		evicted = append(evicted, k)
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		c.Add(strconv.Itoa(i), i)
+	}
+	if c.FrontLen() != 0 {
+		t.Fatalf("new entries should go to the back tier: %d", c.FrontLen())
+	}
+
+	// hits promote, and the front tier's oldest is demoted to the back
+	for _, k := range []string{"0", "1", "2"} {
+		if v, ok := c.Get(k); !ok || strconv.Itoa(v) != k {
+			t.Fatalf("bad: %v %v", k, v)
+		}
+	}
+	if c.FrontLen() != 2 {
+		t.Fatalf("bad front len: %d", c.FrontLen())
+	}
+	if c.Len() != 4 {
+		t.Fatalf("bad len: %d", c.Len())
+	}
+	if _, ok := c.front.Peek("0"); ok {
+		t.Fatalf("0 should have been demoted")
+	}
+	if v, ok := c.back.Peek("0"); !ok || v != 0 {
+		t.Fatalf("0 should be in the back tier")
+	}
+	if len(evicted) != 0 {
+		t.Fatalf("moves between tiers shouldn't be evictions: %v", evicted)
+	}
+
+	// updates to the front tier are visible
+	c.Add("2", 20)
+	if v, ok := c.Peek("2"); !ok || v != 20 {
+		t.Fatalf("bad: %v", v)
+	}
+	if c.back.Contains("2") {
+		t.Fatalf("2 should only be in the front tier")
+	}
+
+	if !c.Remove("2") || c.Contains("2") {
+		t.Fatalf("2 should have been removed")
+	}
+	if !c.Remove("3") || c.Contains("3") {
+		t.Fatalf("3 should have been removed")
+	}
+	if len(evicted) != 2 {
+		t.Fatalf("bad evictions: %v", evicted)
+	}
+
+	c.Purge()
+	if c.Len() != 0 {
+		t.Fatalf("bad len: %d", c.Len())
+	}
+	if len(evicted) != 4 {
+		t.Fatalf("bad evictions: %v", evicted)
+	}
+}
+
+func TestTieredReentrantEvict(t *testing.T) {
+	var c *TieredCache[int]
+	c, err := NewTieredWithEvict[int](1, 1, 1, func(k string, v int) {
+		c.Contains(k)
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c.Add("a", 1)
+	c.Get("a")
+	c.Add("b", 2)
+	c.Add("c", 3)
+	c.Remove("a")
+}
+
+func TestTieredConcurrent(t *testing.T) {
+	c, err := NewTiered[int](8, 256, 4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				k := strconv.Itoa((i * (g + 1)) % 64)
+				c.Add(k, len(k))
+				if v, ok := c.Get(k); ok && v != len(k) {
+					t.Errorf("bad value for %s: %d", k, v)
+					return
+				}
+				if i%7 == 0 {
+					c.Remove(k)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	for i := 0; i < 64; i++ {
+		k := strconv.Itoa(i)
+		if c.front.Contains(k) && c.back.Contains(k) {
+			t.Fatalf("%s is in both tiers", k)
+		}
+	}
+}
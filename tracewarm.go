@@ -0,0 +1,71 @@
+package lru
+
+import (
+	"context"
+	"io"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+// WarmUpFromTrace warms the cache up from a trace recorded WithTrace by
+// a previous process, a lighter-weight alternative to Save and Load for
+// values that are cheap to fetch again: it replays the trace read from r
+// to find the keys the traced cache held, in the order it last used
+// them, and loads values for as many of the most recent as fit, adding
+// them least recently used first.  Traces record only hashes of keys, so
+// keys lists the candidates to match them against, such as every key in
+// the backing store; and they record only the keys sampled, so use a
+// sampleEvery of 1 to warm the whole cache.  Keys whose values fail to
+// load are skipped, and the first error is returned along with the
+// number of entries added.
+func (c *Cache[K, V]) WarmUpFromTrace(ctx context.Context, r io.Reader, keys []K, load Loader[K, V]) (n int, err error) {
+	entries, err := traceEntries(ctx, r, keys, load, c.Cap())
+	c.WarmUp(entries)
+	return len(entries), err
+}
+
+// WarmUpFromTrace warms the cache up from a trace, as
+// Cache.WarmUpFromTrace does.
+func (c *ShardedCache[V]) WarmUpFromTrace(ctx context.Context, r io.Reader, keys []string, load Loader[string, V]) (n int, err error) {
+	entries, err := traceEntries(ctx, r, keys, load, c.Cap())
+	c.WarmUp(entries)
+	return len(entries), err
+}
+
+// traceEntries loads the values for the (up to) size keys most recently
+// used in the trace read from r, and returns them least recently used
+// first.
+func traceEntries[K comparable, V any](ctx context.Context, r io.Reader, keys []K, load Loader[K, V], size int) ([]simplelru.Entry[K, V], error) {
+	hashes, err := simplelru.TraceRecency(simplelru.NewTraceReader(r))
+	if err != nil {
+		return nil, err
+	}
+	byHash := make(map[uint64]K, len(keys))
+	for _, key := range keys {
+		byHash[simplelru.HashKey(key)] = key
+	}
+	// walk from the most recently used, so that the loads are spent on
+	// the keys that make the cut
+	var entries []simplelru.Entry[K, V]
+	var loadErr error
+	for i := len(hashes) - 1; i >= 0 && len(entries) < size; i-- {
+		key, ok := byHash[hashes[i]]
+		if !ok {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			loadErr = err
+			break
+		}
+		value, err := load(ctx, key)
+		if err != nil {
+			if loadErr == nil {
+				loadErr = err
+			}
+			continue
+		}
+		entries = append(entries, simplelru.Entry[K, V]{Key: key, Value: value})
+	}
+	reverse(entries)
+	return entries, loadErr
+}
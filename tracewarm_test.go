@@ -0,0 +1,93 @@
+package lru
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+func TestWarmUpFromTrace(t *testing.T) {
+	var buf bytes.Buffer
+	w := simplelru.NewTraceWriter(&buf)
+	old, err := New[int, int](64, WithTrace[int, int](w, 1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 32; i++ {
+		old.Add(i, i)
+	}
+	// make the first keys the most recently used
+	for i := 0; i < 8; i++ {
+		old.Get(i)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	keys := make([]int, 100)
+	for i := range keys {
+		keys[i] = i
+	}
+	errOdd := errors.New("odd")
+	load := func(ctx context.Context, key int) (int, error) {
+		if key == 31 {
+			return 0, errOdd
+		}
+		return key * 10, nil
+	}
+	l, err := New[int, int](16)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	n, err := l.WarmUpFromTrace(context.Background(), bytes.NewReader(buf.Bytes()), keys, load)
+	if err != errOdd {
+		t.Fatalf("expected the load error, got %v", err)
+	}
+	if n != 16 || l.Len() != 16 {
+		t.Fatalf("expected 16 entries, got %d", l.Len())
+	}
+	// the 8 keys read last, then the most recently added of the rest,
+	// skipping the one that failed to load
+	for _, key := range []int{0, 7, 23, 30} {
+		if v, ok := l.Peek(key); !ok || v != key*10 {
+			t.Fatalf("expected %d to be warmed, got %v %v", key, v, ok)
+		}
+	}
+	if l.Contains(31) || l.Contains(22) {
+		t.Fatalf("expected only the most recent keys to be warmed")
+	}
+	if oldest := l.OldestN(1); len(oldest) != 1 || oldest[0].Key != 23 {
+		t.Fatalf("expected 23 to be the least recently used, got %v", oldest)
+	}
+
+	sharded, err := NewSharded[int](64, 4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	strKeys := make([]string, 32)
+	for i := range strKeys {
+		strKeys[i] = strconv.Itoa(i)
+	}
+	buf.Reset()
+	w = simplelru.NewTraceWriter(&buf)
+	traced, err := NewSharded[int](64, 4, WithTrace[string, int](w, 1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, key := range strKeys {
+		traced.Add(key, 1)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	n, err = sharded.WarmUpFromTrace(context.Background(), &buf, strKeys, func(ctx context.Context, key string) (int, error) {
+		return strconv.Atoi(key)
+	})
+	if err != nil || n != 32 || sharded.Len() != 32 {
+		t.Fatalf("expected 32 entries, got %d: %v", n, err)
+	}
+}
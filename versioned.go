@@ -0,0 +1,110 @@
+package lru
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Versioned is a value along with the version a VersionedCache assigned
+// it when it was added.
+type Versioned[V any] struct {
+	Value   V
+	Version uint64
+}
+
+// VersionedCache is a thread-safe cache that gives every value added a
+// version, so that read-modify-write updates can check, with
+// ReplaceIfVersion, that the entry they read hasn't changed since.
+// Versions increase across the whole cache, not just per key, so a key
+// removed and added again never reuses a version.  They start from the
+// time the cache was created, so versions from a cache that is saved and
+// loaded into a new process, or persisted alongside the value, keep
+// increasing too.
+type VersionedCache[K comparable, V any] struct {
+	cache *Cache[K, Versioned[V]]
+	// locks serializes writes by key, so that ReplaceIfVersion's check
+	// and replacement happen together.
+	locks   keyLocks[K]
+	version atomic.Uint64
+}
+
+// NewVersioned creates a VersionedCache of the given size.  opts
+// configure the underlying Cache, which holds Versioned values.
+func NewVersioned[K comparable, V any](size int, opts ...Option[K, Versioned[V]]) (*VersionedCache[K, V], error) {
+	cache, err := New[K, Versioned[V]](size, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c := &VersionedCache[K, V]{cache: cache}
+	c.version.Store(uint64(time.Now().UnixNano()))
+	return c, nil
+}
+
+// Cache returns the underlying cache, for its stats, persistence and so
+// on.  Values added to it directly should keep versions increasing.
+func (c *VersionedCache[K, V]) Cache() *Cache[K, Versioned[V]] {
+	return c.cache
+}
+
+// Add adds a value to the cache, returning its version and whether an
+// eviction occurred.
+func (c *VersionedCache[K, V]) Add(key K, value V) (version uint64, evicted bool) {
+	mu := c.locks.lock(key)
+	defer mu.Unlock()
+	return c.add(key, value)
+}
+
+func (c *VersionedCache[K, V]) add(key K, value V) (version uint64, evicted bool) {
+	version = c.version.Add(1)
+	return version, c.cache.Add(key, Versioned[V]{value, version})
+}
+
+// Get looks up a key's value and version from the cache.
+func (c *VersionedCache[K, V]) Get(key K) (value V, version uint64, ok bool) {
+	v, ok := c.cache.Get(key)
+	return v.Value, v.Version, ok
+}
+
+// Peek returns a key's value and version without updating its "recently
+// used"-ness.
+func (c *VersionedCache[K, V]) Peek(key K) (value V, version uint64, ok bool) {
+	v, ok := c.cache.Peek(key)
+	return v.Value, v.Version, ok
+}
+
+// ReplaceIfVersion replaces key's value, if key is in the cache and its
+// version is still version, returning the new version.  It returns false
+// without changing the cache if the entry was replaced, removed or
+// evicted since version was read.
+func (c *VersionedCache[K, V]) ReplaceIfVersion(key K, version uint64, value V) (newVersion uint64, ok bool) {
+	mu := c.locks.lock(key)
+	defer mu.Unlock()
+	if cur, ok := c.cache.Peek(key); !ok || cur.Version != version {
+		return 0, false
+	}
+	newVersion, _ = c.add(key, value)
+	return newVersion, true
+}
+
+// Contains reports whether key is in the cache, without updating its
+// "recently used"-ness.
+func (c *VersionedCache[K, V]) Contains(key K) bool {
+	return c.cache.Contains(key)
+}
+
+// Remove removes key from the cache, returning whether it was present.
+func (c *VersionedCache[K, V]) Remove(key K) (present bool) {
+	mu := c.locks.lock(key)
+	defer mu.Unlock()
+	return c.cache.Remove(key)
+}
+
+// Purge is used to completely clear the cache.
+func (c *VersionedCache[K, V]) Purge() {
+	c.cache.Purge()
+}
+
+// Len returns the number of items in the cache.
+func (c *VersionedCache[K, V]) Len() int {
+	return c.cache.Len()
+}
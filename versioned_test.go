@@ -0,0 +1,70 @@
+package lru
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestVersionedCache(t *testing.T) {
+	l, err := NewVersioned[string, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	v1, _ := l.Add("a", 1)
+	value, version, ok := l.Get("a")
+	if !ok || value != 1 || version != v1 {
+		t.Fatalf("bad entry: %v, %v, %v", value, version, ok)
+	}
+
+	v2, ok := l.ReplaceIfVersion("a", v1, 2)
+	if !ok || v2 <= v1 {
+		t.Fatalf("expected the replacement to succeed with a newer version, got %v, %v", v2, ok)
+	}
+	// the old version is stale
+	if _, ok := l.ReplaceIfVersion("a", v1, 3); ok {
+		t.Fatalf("expected a stale version to fail")
+	}
+	if value, version, _ := l.Peek("a"); value != 2 || version != v2 {
+		t.Fatalf("bad entry: %v, %v", value, version)
+	}
+
+	// re-adding a removed key doesn't reuse its version
+	l.Remove("a")
+	if _, ok := l.ReplaceIfVersion("a", v2, 3); ok {
+		t.Fatalf("expected replacing a removed key to fail")
+	}
+	if v3, _ := l.Add("a", 3); v3 <= v2 {
+		t.Fatalf("expected a newer version, got %v", v3)
+	}
+	if _, ok := l.ReplaceIfVersion("a", v2, 4); ok {
+		t.Fatalf("expected the removed entry's version to fail")
+	}
+}
+
+func TestVersionedCacheConcurrentIncrement(t *testing.T) {
+	l, err := NewVersioned[string, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add("n", 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; {
+				value, version, _ := l.Get("n")
+				if _, ok := l.ReplaceIfVersion("n", version, value+1); ok {
+					j++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if value, _, _ := l.Get("n"); value != 800 {
+		t.Fatalf("expected 800 increments, got %d", value)
+	}
+}
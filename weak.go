@@ -0,0 +1,163 @@
+//go:build go1.24
+
+package lru
+
+import (
+	"runtime"
+	"sync"
+	"weak"
+)
+
+// WeakCache is a thread-safe cache of pointers that holds on to its
+// entries weakly once they're evicted.  An evicted entry can still be
+// found, and is re-admitted by Get, for as long as something else keeps
+// its value alive; once nothing does the garbage collector reclaims it
+// and the entry is gone.  This suits large values, like parsed documents,
+// that are shared across requests: an approximate eviction doesn't throw
+// away a value that is still in use only to load it again.
+//
+// WeakCache needs Go 1.24 or later, for the weak package.
+type WeakCache[K comparable, V any] struct {
+	strong *Cache[K, *V]
+
+	mu   sync.Mutex
+	weak map[K]weak.Pointer[V]
+}
+
+// weakCleanup identifies a weak entry to remove once its value has been
+// reclaimed.
+type weakCleanup[K comparable, V any] struct {
+	key K
+	ptr weak.Pointer[V]
+}
+
+// NewWeak creates a WeakCache that holds on to up to size entries
+// strongly.  opts configure the underlying Cache; an eviction callback
+// given to it would be called when entries become weak, not when they are
+// reclaimed, so WeakCache doesn't take one.
+func NewWeak[K comparable, V any](size int, opts ...Option[K, *V]) (*WeakCache[K, V], error) {
+	c := &WeakCache[K, V]{
+		weak: make(map[K]weak.Pointer[V]),
+	}
+	var err error
+	c.strong, err = NewWithEvict[K, *V](size, c.demote, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// demote is the strong cache's eviction callback, which it calls without
+// holding its lock.
+func (c *WeakCache[K, V]) demote(key K, value *V) {
+	if value == nil {
+		return
+	}
+	ptr := weak.Make(value)
+	c.mu.Lock()
+	c.weak[key] = ptr
+	c.mu.Unlock()
+	runtime.AddCleanup(value, c.reclaimed, weakCleanup[K, V]{key, ptr})
+}
+
+// reclaimed removes a weak entry once the garbage collector has reclaimed
+// its value, unless the key has been given another value since.
+func (c *WeakCache[K, V]) reclaimed(cleanup weakCleanup[K, V]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ptr, ok := c.weak[cleanup.key]; ok && ptr == cleanup.ptr {
+		delete(c.weak, cleanup.key)
+	}
+}
+
+// Add adds a value to the cache.  Returns true if an eviction occurred.
+func (c *WeakCache[K, V]) Add(key K, value *V) (evicted bool) {
+	c.mu.Lock()
+	delete(c.weak, key)
+	c.mu.Unlock()
+	return c.strong.Add(key, value)
+}
+
+// Get looks up a key's value from the cache.  A value that was evicted
+// but is still alive is found, and added back to the cache.
+func (c *WeakCache[K, V]) Get(key K) (value *V, ok bool) {
+	if value, ok = c.strong.Get(key); ok {
+		return value, true
+	}
+	if value = c.take(key); value == nil {
+		return nil, false
+	}
+	// someone may have added a newer value since we looked
+	if previous, ok, _ := c.strong.PeekOrAdd(key, value); ok {
+		return previous, true
+	}
+	return value, true
+}
+
+// take removes key's weak entry, returning its value if it's still alive.
+func (c *WeakCache[K, V]) take(key K) *V {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ptr, ok := c.weak[key]
+	if !ok {
+		return nil
+	}
+	delete(c.weak, key)
+	return ptr.Value()
+}
+
+// Peek returns the key value (or nil if not found) without updating the
+// "recently used"-ness of the key or re-admitting an evicted one.
+func (c *WeakCache[K, V]) Peek(key K) (value *V, ok bool) {
+	if value, ok = c.strong.Peek(key); ok {
+		return value, true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ptr, ok := c.weak[key]; ok {
+		if value = ptr.Value(); value != nil {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// Contains checks if a key is in the cache, strongly or weakly, without
+// updating its recent-ness.
+func (c *WeakCache[K, V]) Contains(key K) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+// Remove removes the provided key from the cache, returning if the key
+// was contained.
+func (c *WeakCache[K, V]) Remove(key K) (present bool) {
+	// removing it from the strong cache makes it weak, so drop it from
+	// the weak entries afterwards
+	present = c.strong.Remove(key)
+	if c.take(key) != nil {
+		present = true
+	}
+	return present
+}
+
+// Purge is used to completely clear the cache.
+func (c *WeakCache[K, V]) Purge() {
+	c.strong.Purge()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.weak)
+}
+
+// Len returns the number of entries held strongly.
+func (c *WeakCache[K, V]) Len() int {
+	return c.strong.Len()
+}
+
+// WeakLen returns the number of evicted entries being held weakly, some of
+// whose values may already have been reclaimed.
+func (c *WeakCache[K, V]) WeakLen() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.weak)
+}
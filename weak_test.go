@@ -0,0 +1,92 @@
+//go:build go1.24
+
+package lru
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+type weakValue struct {
+	n   int
+	buf [1024]byte
+}
+
+func TestWeakCache(t *testing.T) {
+	c, err := NewWeak[int, weakValue](4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	kept := &weakValue{n: 0}
+	c.Add(0, kept)
+	for i := 1; i < 64; i++ {
+		c.Add(i, &weakValue{n: i})
+	}
+	if c.Len() > 4 {
+		t.Fatalf("bad len: %d", c.Len())
+	}
+
+	// 0 was evicted, but is still referenced, so it survives
+	if v, ok := c.Get(0); !ok || v != kept {
+		t.Fatalf("expected the evicted value to be found")
+	}
+	if v, ok := c.strong.Peek(0); !ok || v != kept {
+		t.Fatalf("expected the value to be re-admitted")
+	}
+
+	// nothing else refers to the other evicted values, so they are
+	// eventually reclaimed
+	deadline := time.Now().Add(5 * time.Second)
+	for c.WeakLen() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("weak entries weren't reclaimed: %d", c.WeakLen())
+		}
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	missing := 0
+	for i := 1; i < 64; i++ {
+		if !c.Contains(i) {
+			missing++
+		}
+	}
+	if missing < 59 {
+		t.Fatalf("expected reclaimed values to be gone: %d missing", missing)
+	}
+	runtime.KeepAlive(kept)
+}
+
+func TestWeakCacheRemove(t *testing.T) {
+	c, err := NewWeak[int, weakValue](1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	a, b := &weakValue{n: 1}, &weakValue{n: 2}
+	c.Add(1, a)
+	c.Add(2, b)
+	if c.WeakLen() != 1 || !c.Contains(1) {
+		t.Fatalf("expected 1 to be held weakly")
+	}
+	if !c.Remove(1) || c.Contains(1) {
+		t.Fatalf("expected 1 to be removed")
+	}
+	if !c.Remove(2) || c.Contains(2) || c.WeakLen() != 0 {
+		t.Fatalf("expected 2 to be removed")
+	}
+
+	// a newer value replaces a weak one
+	c.Add(1, a)
+	c.Add(2, b)
+	c.Add(1, b)
+	if v, _ := c.Get(1); v != b {
+		t.Fatalf("bad value")
+	}
+	c.Purge()
+	if c.Len() != 0 || c.WeakLen() != 0 {
+		t.Fatalf("expected purge to clear both")
+	}
+	runtime.KeepAlive(a)
+	runtime.KeepAlive(b)
+}
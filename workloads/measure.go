@@ -0,0 +1,56 @@
+package workloads
+
+// Cache is the subset of a cache's methods Measure needs, which
+// simplelru.LRU and lru.Cache have.  Caches with other key types, like
+// lru.ShardedCache, can be measured with MeasureFunc.
+type Cache[K comparable, V any] interface {
+	Get(key K) (value V, ok bool)
+	Add(key K, value V) (evicted bool)
+}
+
+// Result counts the hits and misses of a measured workload.
+type Result struct {
+	Hits   int
+	Misses int
+}
+
+// HitRatio returns the fraction of lookups that hit, or 0 if there were
+// none.
+func (r Result) HitRatio() float64 {
+	if total := r.Hits + r.Misses; total > 0 {
+		return float64(r.Hits) / float64(total)
+	}
+	return 0
+}
+
+// Measure looks up the first warmup+n keys from g in c, adding the zero
+// value on a miss, as a read-through cache would, and counts the hits
+// and misses of the last n.
+func Measure[V any](c Cache[uint64, V], g Generator, warmup, n int) Result {
+	var zero V
+	return MeasureFunc(g, warmup, n, func(key uint64) bool {
+		if _, ok := c.Get(key); ok {
+			return true
+		}
+		c.Add(key, zero)
+		return false
+	})
+}
+
+// MeasureFunc is like Measure, for caches that don't fit the Cache
+// interface: lookup looks key up, filling the cache on a miss, and
+// reports whether it hit.
+func MeasureFunc(g Generator, warmup, n int, lookup func(key uint64) bool) Result {
+	for i := 0; i < warmup; i++ {
+		lookup(g.Next())
+	}
+	var r Result
+	for i := 0; i < n; i++ {
+		if lookup(g.Next()) {
+			r.Hits++
+		} else {
+			r.Misses++
+		}
+	}
+	return r
+}
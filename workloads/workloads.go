@@ -0,0 +1,103 @@
+// Package workloads generates synthetic key streams, such as skewed Zipf
+// lookups and scans, and measures the hit ratio a cache achieves on
+// them.  It's used by this module's benchmarks and simulations, and is
+// meant for checking a cache's size and configuration against the shape
+// of a workload before deploying it.
+package workloads
+
+import "math/rand"
+
+// Generator produces an endless stream of keys.
+type Generator interface {
+	Next() uint64
+}
+
+// GeneratorFunc adapts a function to a Generator.
+type GeneratorFunc func() uint64
+
+// Next implements Generator.
+func (f GeneratorFunc) Next() uint64 {
+	return f()
+}
+
+// ZipfGenerator generates keys following a Zipf distribution, the
+// classic model of skewed, "popular items" workloads.
+type ZipfGenerator struct {
+	zipf *rand.Zipf
+}
+
+// Zipf returns a Generator of keys in [0, keys), where the probability of
+// key k is proportional to (v + k) ** -s.  s must be greater than 1 and
+// v at least 1; larger s means more skew.
+func Zipf(seed int64, s, v float64, keys uint64) *ZipfGenerator {
+	rng := rand.New(rand.NewSource(seed))
+	return &ZipfGenerator{zipf: rand.NewZipf(rng, s, v, keys-1)}
+}
+
+// Next implements Generator.
+func (z *ZipfGenerator) Next() uint64 {
+	return z.zipf.Uint64()
+}
+
+// UniformGenerator generates keys with equal probability, which no
+// eviction policy can do better than random on.
+type UniformGenerator struct {
+	rng  *rand.Rand
+	keys uint64
+}
+
+// Uniform returns a Generator of keys picked uniformly from [0, keys).
+func Uniform(seed int64, keys uint64) *UniformGenerator {
+	return &UniformGenerator{rng: rand.New(rand.NewSource(seed)), keys: keys}
+}
+
+// Next implements Generator.
+func (u *UniformGenerator) Next() uint64 {
+	return uint64(u.rng.Int63n(int64(u.keys)))
+}
+
+// ScanGenerator generates sequential, never-repeated keys, like a batch
+// job or crawler touching every item once.
+type ScanGenerator struct {
+	next uint64
+}
+
+// Scan returns a Generator of the keys start, start+1, ...
+func Scan(start uint64) *ScanGenerator {
+	return &ScanGenerator{next: start}
+}
+
+// Next implements Generator.
+func (s *ScanGenerator) Next() uint64 {
+	key := s.next
+	s.next++
+	return key
+}
+
+// LoopGenerator generates keys cycling repeatedly through a fixed set,
+// which is the worst case for LRU when the loop is larger than the
+// cache.
+type LoopGenerator struct {
+	keys, next uint64
+}
+
+// Loop returns a Generator cycling through the keys [0, keys).
+func Loop(keys uint64) *LoopGenerator {
+	return &LoopGenerator{keys: keys}
+}
+
+// Next implements Generator.
+func (l *LoopGenerator) Next() uint64 {
+	key := l.next
+	l.next = (l.next + 1) % l.keys
+	return key
+}
+
+// Take returns the next n keys from g.
+func Take(g Generator, n int) []uint64 {
+	keys := make([]uint64, n)
+	for i := range keys {
+		keys[i] = g.Next()
+	}
+	return keys
+}
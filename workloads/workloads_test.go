@@ -0,0 +1,60 @@
+package workloads
+
+import (
+	"testing"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+func TestGenerators(t *testing.T) {
+	if keys := Take(Scan(5), 3); keys[0] != 5 || keys[1] != 6 || keys[2] != 7 {
+		t.Fatalf("unexpected scan %v", keys)
+	}
+	if keys := Take(Loop(2), 3); keys[0] != 0 || keys[1] != 1 || keys[2] != 0 {
+		t.Fatalf("unexpected loop %v", keys)
+	}
+	for _, key := range Take(Uniform(1, 10), 1000) {
+		if key >= 10 {
+			t.Fatalf("key %d out of range", key)
+		}
+	}
+	counts := make(map[uint64]int)
+	for _, key := range Take(Zipf(1, 1.1, 1, 1000), 10000) {
+		if key >= 1000 {
+			t.Fatalf("key %d out of range", key)
+		}
+		counts[key]++
+	}
+	if counts[0] <= counts[500] {
+		t.Fatalf("expected key 0 to be more popular than key 500")
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	c, err := simplelru.NewLRU[uint64, struct{}](10, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// a loop that fits in the cache always hits once it's warm
+	if r := Measure[struct{}](c, Loop(10), 10, 100); r.Hits != 100 || r.HitRatio() != 1 {
+		t.Fatalf("expected only hits, got %+v", r)
+	}
+	// and a scan never does
+	if r := Measure[struct{}](c, Scan(100), 10, 100); r.Misses != 100 || r.HitRatio() != 0 {
+		t.Fatalf("expected only misses, got %+v", r)
+	}
+
+	big, err := simplelru.NewLRU[uint64, struct{}](1000, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	small, err := simplelru.NewLRU[uint64, struct{}](100, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	bigRatio := Measure[struct{}](big, Zipf(1, 1.1, 1, 10000), 10000, 10000).HitRatio()
+	smallRatio := Measure[struct{}](small, Zipf(1, 1.1, 1, 10000), 10000, 10000).HitRatio()
+	if bigRatio <= smallRatio {
+		t.Fatalf("expected a bigger cache to hit more: %v vs %v", bigRatio, smallRatio)
+	}
+}
@@ -0,0 +1,311 @@
+package lru
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// evictFlushTimeout bounds how long releasing an evicted entry waits for
+// its queued write, so that a store that hangs can't hang every
+// operation that evicts.
+const evictFlushTimeout = 10 * time.Second
+
+// WithWriteBehind makes Set and Delete on a cache created WithStore
+// write-behind rather than write-through: they update the cache and queue
+// the write, returning without waiting for the store.  Queued writes of
+// the same key are coalesced, so only the latest is stored, and are
+// written to the store in the background by the given number of worker
+// goroutines.  At most queueSize keys can have writes queued; beyond
+// that, Set and Delete wait for room, or for their context to be done.
+//
+// An entry that is evicted with a write still queued has it written
+// before the eviction callback is called, so the callback can rely on
+// the store being up to date, unless the store takes longer than 10
+// seconds, in which case the write is abandoned and logged.  Errors from background writes are logged
+// to the logger given WithLogger, or slog's default logger; Flush writes
+// everything queued and returns them.
+func WithWriteBehind[K comparable, V any](queueSize, workers int) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.behindQueue = queueSize
+		o.behindWorkers = workers
+	}
+}
+
+// pendingWrite is a write queued for a key: either a value to put, or a
+// deletion.
+type pendingWrite[V any] struct {
+	value   V
+	deleted bool
+}
+
+// writeBehind queues writes to a Store, and writes them in the
+// background.
+type writeBehind[K comparable, V any] struct {
+	store  Store[K, V]
+	logger *slog.Logger
+
+	// slots holds a token for every key with a pending write, bounding
+	// the queue.  ready tells workers which keys to write; it may name
+	// keys whose writes were already done by flush, which they skip.
+	slots chan struct{}
+	ready chan K
+	done  chan struct{}
+	wg    sync.WaitGroup
+
+	mu      sync.Mutex
+	pending map[K]pendingWrite[V]
+	// inflight holds a channel for each key being written, closed once
+	// it has been, so that writes of the same key aren't reordered.
+	inflight map[K]chan struct{}
+}
+
+func newWriteBehind[K comparable, V any](o *options[K, V]) (*writeBehind[K, V], error) {
+	if o.behindQueue <= 0 {
+		return nil, nil
+	}
+	if o.store == nil {
+		return nil, errors.New("write-behind needs a store")
+	}
+	workers := o.behindWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	w := &writeBehind[K, V]{
+		store:    o.store,
+		logger:   o.logger,
+		slots:    make(chan struct{}, o.behindQueue),
+		ready:    make(chan K, o.behindQueue),
+		done:     make(chan struct{}),
+		pending:  make(map[K]pendingWrite[V]),
+		inflight: make(map[K]chan struct{}),
+	}
+	if w.logger == nil {
+		w.logger = slog.Default()
+	}
+	w.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go w.work()
+	}
+	return w, nil
+}
+
+func (w *writeBehind[K, V]) work() {
+	defer w.wg.Done()
+	for {
+		select {
+		case key := <-w.ready:
+			w.flush(context.Background(), key)
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// reserve waits for room in the queue for a write.
+func (w *writeBehind[K, V]) reserve(ctx context.Context) error {
+	select {
+	case w.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release gives back room reserved for a write.
+func (w *writeBehind[K, V]) release() {
+	<-w.slots
+}
+
+// queue records a write of key, for which room must have been reserved,
+// replacing any write already queued for it.  It returns whether the
+// key is newly queued, in which case the caller must pass it to notify
+// once it has released the cache's lock.
+func (w *writeBehind[K, V]) queue(key K, write pendingWrite[V]) bool {
+	w.mu.Lock()
+	_, coalesced := w.pending[key]
+	w.pending[key] = write
+	w.mu.Unlock()
+	if coalesced {
+		w.release()
+	}
+	return !coalesced
+}
+
+// notify tells the workers that key has a write queued.
+func (w *writeBehind[K, V]) notify(key K) {
+	select {
+	case w.ready <- key:
+	case <-w.done:
+	}
+}
+
+// flush writes key's queued write, if it has one, waiting for any write
+// of it already in flight first.  Errors are logged as well as returned,
+// since workers have no one to return them to.
+func (w *writeBehind[K, V]) flush(ctx context.Context, key K) error {
+	w.mu.Lock()
+	for {
+		ch, ok := w.inflight[key]
+		if !ok {
+			break
+		}
+		w.mu.Unlock()
+		<-ch
+		w.mu.Lock()
+	}
+	write, ok := w.pending[key]
+	if !ok {
+		w.mu.Unlock()
+		return nil
+	}
+	delete(w.pending, key)
+	ch := make(chan struct{})
+	w.inflight[key] = ch
+	w.mu.Unlock()
+	w.release()
+
+	var err error
+	if write.deleted {
+		err = w.store.Delete(ctx, key)
+	} else {
+		err = w.store.Put(ctx, key, write.value)
+	}
+
+	w.mu.Lock()
+	delete(w.inflight, key)
+	w.mu.Unlock()
+	close(ch)
+	if err != nil {
+		w.logger.Error("lru: write-behind failed", "key", key, "deleted", write.deleted, "err", err)
+	}
+	return err
+}
+
+// flushEvicted writes the write queued for key, which was just evicted,
+// if it has one, giving up after evictFlushTimeout.
+func (w *writeBehind[K, V]) flushEvicted(key K) {
+	ctx, cancel := context.WithTimeout(context.Background(), evictFlushTimeout)
+	defer cancel()
+	w.flush(ctx, key)
+}
+
+// flushAll writes every queued write, and waits for those in flight,
+// returning the first error.
+func (w *writeBehind[K, V]) flushAll(ctx context.Context) error {
+	w.mu.Lock()
+	keys := make([]K, 0, len(w.pending)+len(w.inflight))
+	for key := range w.pending {
+		keys = append(keys, key)
+	}
+	for key := range w.inflight {
+		if _, ok := w.pending[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	w.mu.Unlock()
+	var firstErr error
+	for _, key := range keys {
+		if err := w.flush(ctx, key); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// close writes everything queued and stops the workers.
+func (w *writeBehind[K, V]) close() {
+	w.flushAll(context.Background())
+	close(w.done)
+	w.wg.Wait()
+}
+
+// setBehind updates the cache and queues write.
+func (c *Cache[K, V]) setBehind(ctx context.Context, key K, write pendingWrite[V]) error {
+	// once closed, the workers are gone and room may never be made
+	if c.isClosed() {
+		return ErrClosed
+	}
+	if err := c.behind.reserve(ctx); err != nil {
+		return err
+	}
+	c.lock.Lock()
+	if c.closed {
+		c.unlock()
+		c.behind.release()
+		return ErrClosed
+	}
+	if write.deleted {
+		c.lru.Remove(key)
+		markQueued(c.evicted, key)
+	} else {
+		c.lru.Add(key, write.value)
+	}
+	queued := c.behind.queue(key, write)
+	c.unlock()
+	if queued {
+		c.behind.notify(key)
+	}
+	return nil
+}
+
+// markQueued marks key's entry among evicted, removed by a Delete whose
+// write is being queued, so that releasing it doesn't wait for the
+// store.
+func markQueued[K comparable, V any](evicted []evictedEntry[K, V], key K) {
+	for i := range evicted {
+		if evicted[i].key == key {
+			evicted[i].queued = true
+		}
+	}
+}
+
+// Flush writes every write queued by a cache created WithWriteBehind to
+// the store, and waits for those already being written, returning the
+// first error of those it wrote.  It returns nil for other caches.
+func (c *Cache[K, V]) Flush(ctx context.Context) error {
+	if c.behind == nil {
+		return nil
+	}
+	return c.behind.flushAll(ctx)
+}
+
+// setBehind updates the cache and queues write.
+func (c *ShardedCache[V]) setBehind(ctx context.Context, key string, write pendingWrite[V]) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	if err := c.behind.reserve(ctx); err != nil {
+		return err
+	}
+	shard := c.lockShardFor(key)
+	if c.closed.Load() {
+		c.unlock(shard)
+		c.behind.release()
+		return ErrClosed
+	}
+	if write.deleted {
+		shard.lru.Remove(key)
+		markQueued(shard.evicted, key)
+	} else {
+		shard.lru.Add(key, write.value)
+	}
+	queued := c.behind.queue(key, write)
+	c.unlock(shard)
+	if queued {
+		c.behind.notify(key)
+	}
+	return nil
+}
+
+// Flush writes every write queued by a cache created WithWriteBehind to
+// the store, and waits for those already being written, returning the
+// first error of those it wrote.  It returns nil for other caches.
+func (c *ShardedCache[V]) Flush(ctx context.Context) error {
+	if c.behind == nil {
+		return nil
+	}
+	return c.behind.flushAll(ctx)
+}
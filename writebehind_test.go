@@ -0,0 +1,206 @@
+package lru
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gatedStore is a mapStore whose writes of blocked wait for gate to be
+// closed, and which counts its writes.
+type gatedStore struct {
+	*mapStore[int, int]
+	blocked int
+	gate    chan struct{}
+	puts    atomic.Int32
+}
+
+func newGatedStore(blocked int) *gatedStore {
+	return &gatedStore{
+		mapStore: newMapStore[int, int](),
+		blocked:  blocked,
+		gate:     make(chan struct{}),
+	}
+}
+
+func (s *gatedStore) Put(ctx context.Context, key, value int) error {
+	if key == s.blocked {
+		<-s.gate
+	}
+	s.puts.Add(1)
+	return s.mapStore.Put(ctx, key, value)
+}
+
+func (s *gatedStore) Delete(ctx context.Context, key int) error {
+	if key == s.blocked {
+		select {
+		case <-s.gate:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return s.mapStore.Delete(ctx, key)
+}
+
+func queuedWrites[K comparable, V any](w *writeBehind[K, V]) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.pending)
+}
+
+func TestWriteBehind(t *testing.T) {
+	store := newGatedStore(-1)
+	l, err := New[int, int](128, WithStore[int, int](store), WithWriteBehind[int, int](4, 1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ctx := context.Background()
+
+	// occupy the only worker
+	if err := l.Set(ctx, -1, 0); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for queuedWrites(l.behind) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	for i := 0; i < 100; i++ {
+		if err := l.Set(ctx, 1, i); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if v, ok := l.Peek(1); !ok || v != 99 {
+		t.Fatalf("Set should update the cache immediately: %v", v)
+	}
+	if _, ok := store.get(1); ok {
+		t.Fatalf("Set shouldn't wait for the store")
+	}
+	if err := l.Delete(ctx, 2); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// the queue has room for 4 keys
+	for i := 3; i < 5; i++ {
+		if err := l.Set(ctx, i, i); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := l.Set(timeout, 5, 5); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected Set to wait for room, got %v", err)
+	}
+
+	close(store.gate)
+	if err := l.Flush(ctx); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if v, ok := store.get(1); !ok || v != 99 {
+		t.Fatalf("expected the latest value to be stored: %v", v)
+	}
+	// writes of 1 were coalesced
+	if n := store.puts.Load(); n != 4 {
+		t.Fatalf("expected 4 puts, got %d", n)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if l.Set(ctx, 1, 1) != ErrClosed {
+		t.Fatalf("expected ErrClosed")
+	}
+
+	if _, err := New[int, int](128, WithWriteBehind[int, int](4, 1)); err == nil {
+		t.Fatalf("expected write-behind without a store to fail")
+	}
+}
+
+func TestWriteBehindFlushesEvicted(t *testing.T) {
+	store := newGatedStore(-1)
+	var l *Cache[int, int]
+	var evicted atomic.Int32
+	l, err := NewWithEvict[int, int](2, func(k, v int) {
+		evicted.Add(1)
+		if stored, ok := store.get(k); !ok || stored != v {
+			t.Errorf("%d was evicted before being stored", k)
+		}
+	}, WithStore[int, int](store), WithWriteBehind[int, int](16, 1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ctx := context.Background()
+
+	// occupy the only worker with a write that is never evicted
+	l.Set(ctx, -1, 0)
+	l.Pin(-1)
+	for i := 0; i < 10; i++ {
+		if err := l.Set(ctx, i, i); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if evicted.Load() != 9 {
+		t.Fatalf("bad evictions: %d", evicted.Load())
+	}
+	close(store.gate)
+	l.Close()
+}
+
+func TestShardedWriteBehind(t *testing.T) {
+	store := newMapStore[string, int]()
+	l, err := NewSharded[int](1024, 16, WithStore[string, int](store), WithWriteBehind[string, int](64, 4))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 500; i++ {
+		if err := l.Set(ctx, strconv.Itoa(i%100), i); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if err := l.Delete(ctx, "0"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 1; i < 100; i++ {
+		if v, ok := store.get(strconv.Itoa(i)); !ok || v != 400+i {
+			t.Fatalf("%d: expected %d to be stored, got %d", i, 400+i, v)
+		}
+	}
+	if _, ok := store.get("0"); ok {
+		t.Fatalf("expected 0 to be deleted")
+	}
+}
+
+func TestWriteBehindDeleteDoesntWait(t *testing.T) {
+	store := newGatedStore(1)
+	var released atomic.Int32
+	l, err := NewWithEvict[int, int](128, func(key, value int) {
+		released.Add(1)
+	}, WithStore[int, int](store), WithWriteBehind[int, int](4, 1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ctx := context.Background()
+	l.Add(1, 1)
+	// the store's deletion of 1 blocks, but Delete only queues it
+	finishes(t, func() {
+		if err := l.Delete(ctx, 1); err != nil {
+			t.Errorf("err: %v", err)
+		}
+	})
+	if l.Contains(1) || released.Load() != 1 {
+		t.Fatalf("expected 1 to be removed and released")
+	}
+	close(store.gate)
+	if err := l.Flush(ctx); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := store.get(1); ok {
+		t.Fatalf("expected 1 to be deleted from the store")
+	}
+	l.Close()
+}
@@ -0,0 +1,139 @@
+package lru
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithWriteBuffer coalesces bursts of Adds to a ShardedCache: rather than
+// taking its shard's lock, Add stores the value in a per-shard buffer of
+// up to size keys, where a later Add of the same key replaces it, so a
+// key updated thousands of times a second costs one write to the cache
+// rather than thousands.  A shard's buffered writes are applied when its
+// buffer fills, every window, by a goroutine the cache starts and Close
+// stops, and before any other operation takes the shard's lock, so that
+// lookups see them.  Until then, Len and the cache's statistics don't
+// count them, and Add can't tell whether it will evict, so it returns
+// false.  It can't be combined with WithTwoChoices, and has no effect on
+// a Cache.
+func WithWriteBuffer[K comparable, V any](size int, window time.Duration) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.bufferSize = size
+		o.bufferWindow = window
+	}
+}
+
+var errBufferTwoChoices = errors.New("WithWriteBuffer can't be used WithTwoChoices")
+
+// writeBuffer holds a shard's buffered writes.
+type writeBuffer[V any] struct {
+	mu      sync.Mutex
+	pending map[string]V
+	// n is len(pending), for checking whether there's anything to apply
+	// without taking mu.
+	n    atomic.Int32
+	size int
+	// spare is the map pending last swapped out, which is only touched
+	// while the shard's lock is held.
+	spare map[string]V
+	// closed is the cache's, so that writes buffered after Close are
+	// dropped, and unlock is the cache's unlock of the shard, for flush.
+	closed *atomic.Bool
+	unlock func()
+}
+
+// buffer stores value under key in the shard's buffer, returning false
+// if the buffer is full and it should be applied first.
+func (s *shard[V]) buffer(key string, value V) bool {
+	b := s.buf
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.pending[key]; !ok {
+		if len(b.pending) >= b.size {
+			return false
+		}
+		b.n.Add(1)
+	}
+	b.pending[key] = value
+	return true
+}
+
+// buffered reports whether the shard has buffered writes to apply.
+func (s *shard[V]) buffered() bool {
+	return s.buf != nil && s.buf.n.Load() > 0
+}
+
+// applyWrites adds the shard's buffered writes to it.  The shard's write
+// lock must be held.
+func (s *shard[V]) applyWrites() {
+	b := s.buf
+	b.mu.Lock()
+	pending := b.pending
+	if b.spare == nil {
+		b.spare = make(map[string]V, b.size)
+	}
+	b.pending, b.spare = b.spare, nil
+	b.n.Store(0)
+	b.mu.Unlock()
+	if !b.closed.Load() {
+		for key, value := range pending {
+			s.lru.Add(key, value)
+		}
+	}
+	clear(pending)
+	b.spare = pending
+}
+
+// flush applies the shard's buffered writes.
+func (s *shard[V]) flush() {
+	// lock applies them
+	s.lock()
+	s.buf.unlock()
+}
+
+// bufferFlusher applies a cache's buffered writes every window.
+type bufferFlusher struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startBufferFlusher starts applying c's buffered writes, returning nil
+// if it wasn't created WithWriteBuffer.
+func startBufferFlusher[V any](c *ShardedCache[V], window time.Duration) *bufferFlusher {
+	if c.shards[0].buf == nil {
+		return nil
+	}
+	if window <= 0 {
+		window = time.Millisecond
+	}
+	f := &bufferFlusher{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(f.done)
+		ticker := time.NewTicker(window)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				for i := range c.shards {
+					if shard := &c.shards[i]; shard.buffered() {
+						shard.flush()
+					}
+				}
+			case <-f.stop:
+				return
+			}
+		}
+	}()
+	return f
+}
+
+// close stops applying writes, and waits for a flush in progress.
+func (f *bufferFlusher) close() {
+	close(f.stop)
+	<-f.done
+}
@@ -0,0 +1,138 @@
+package lru
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bpowers/approx-lru/simplelru"
+)
+
+// addCounter counts the writes that reach the cache.
+type addCounter struct {
+	simplelru.NopListener[string, int]
+	adds atomic.Int64
+}
+
+func (a *addCounter) OnAdd(key string, value int) {
+	a.adds.Add(1)
+}
+
+func TestWriteBuffer(t *testing.T) {
+	var adds addCounter
+	l, err := NewSharded[int](1024, 4,
+		WithWriteBuffer[string, int](8, time.Hour),
+		WithListener[string, int](&adds))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+
+	// repeated writes of a key coalesce, and a lookup sees the last
+	for i := 0; i < 1000; i++ {
+		l.Add("a", i)
+	}
+	if n := adds.adds.Load(); n != 0 {
+		t.Fatalf("expected writes to be buffered, got %d adds", n)
+	}
+	if v, ok := l.Peek("a"); !ok || v != 999 {
+		t.Fatalf("expected the last write, got %v %v", v, ok)
+	}
+	if n := adds.adds.Load(); n != 1 {
+		t.Fatalf("expected the writes to coalesce into 1 add, got %d", n)
+	}
+
+	// a full buffer is applied by the next write
+	for i := 0; i < 100; i++ {
+		l.Add(strconv.Itoa(i), i)
+	}
+	if n := adds.adds.Load(); n <= 1 {
+		t.Fatalf("expected full buffers to be applied, got %d adds", n)
+	}
+	l.Remove("5")
+	if l.Contains("5") {
+		t.Fatalf("expected Remove to follow the buffered write")
+	}
+	if v, ok := l.Get("6"); !ok || v != 6 {
+		t.Fatalf("expected 6, got %v %v", v, ok)
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Close()
+	l.Add("b", 1)
+	if l.Contains("b") {
+		t.Fatalf("expected writes after Close to be dropped")
+	}
+
+	if _, err := NewSharded[int](64, 4, WithWriteBuffer[string, int](8, time.Hour), WithTwoChoices[string, int]()); err == nil {
+		t.Fatalf("expected an error WithTwoChoices")
+	}
+}
+
+func TestWriteBufferWindow(t *testing.T) {
+	var adds addCounter
+	l, err := NewSharded[int](64, 4,
+		WithWriteBuffer[string, int](8, time.Millisecond),
+		WithListener[string, int](&adds))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+	l.Add("a", 1)
+	deadline := time.Now().Add(5 * time.Second)
+	for adds.adds.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if l.Len() != 1 {
+		t.Fatalf("expected the write to be applied within the window")
+	}
+}
+
+func TestWriteBufferConcurrent(t *testing.T) {
+	l, err := NewSharded[int](256, 4, WithWriteBuffer[string, int](16, time.Millisecond))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := strconv.Itoa(i % 32)
+				l.Add(key, i)
+				l.Get(key)
+			}
+		}(g)
+	}
+	wg.Wait()
+	if err := l.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func BenchmarkShardedLRU_WriteBuffer(b *testing.B) {
+	l, err := NewSharded[int](8192, 16, WithWriteBuffer[string, int](64, time.Millisecond))
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+	keys := make([]string, 16)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			l.Add(keys[i%len(keys)], i)
+			i++
+		}
+	})
+}